@@ -2,14 +2,31 @@ package main
 
 import (
 	"arbitrage-bot/commands"
-	"fmt"
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/rpcpool"
+	"arbitrage-bot/services/tokenregistry"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/urfave/cli"
-	"log"
+	"log/slog"
 	"os"
 )
 
 func main() {
+	if err := config.Init(); err != nil {
+		slog.Error("Error loading the configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	logger.Setup(config.Get().Log)
+
+	if err := tokenregistry.Init(); err != nil {
+		slog.Error("Error loading the token registry", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if err := rpcpool.Init(); err != nil {
+		slog.Error("Error creating the rpc pool", slog.Any("error", err))
+		os.Exit(1)
+	}
 	app := &cli.App{
 		Commands: []cli.Command{
 			{
@@ -17,15 +34,24 @@ func main() {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:  "pool-data-temp",
-						Usage: "pool data temp (containing pool addresses)",
+						Usage: "pool data temp (containing pool addresses), the V3 factory events are scanned if omitted",
+					},
+					&cli.Uint64Flag{
+						Name:  "from-block",
+						Usage: "first block of the factory scan (defaults to the last scanned block)",
+					},
+					&cli.Uint64Flag{
+						Name:  "to-block",
+						Usage: "last block of the factory scan (defaults to the latest block)",
 					},
 				},
 				Action: func(ctx *cli.Context) {
+					var command = commands.NewFetchUniswapPoolDataCommand()
+
 					if poolDataTemp := ctx.String("pool-data-temp"); poolDataTemp != "" {
-						var command = commands.NewFetchUniswapPoolDataCommand()
 						command.Fetch(poolDataTemp)
 					} else {
-						fmt.Println("Please provide both network and pool-data-temp")
+						command.FetchFromFactory(ctx.Uint64("from-block"), ctx.Uint64("to-block"))
 					}
 				},
 			},
@@ -36,10 +62,39 @@ func main() {
 					command.Fetch()
 				},
 			},
+			{
+				Name: "spatial-arbitrage",
+				Action: func(ctx *cli.Context) {
+					var command = commands.NewSpatialArbitrageCommand()
+					command.Run()
+				},
+			},
+			{
+				Name: "cex-dex-arbitrage",
+				Action: func(ctx *cli.Context) {
+					var command = commands.NewCexDexArbitrageCommand()
+					command.Run()
+				},
+			},
+			{
+				Name: "relay-stub",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "address",
+						Value: "127.0.0.1:8091",
+						Usage: "listen address of the local eth_sendBundle relay",
+					},
+				},
+				Action: func(ctx *cli.Context) {
+					var command = commands.NewRelayStubCommand()
+					command.Serve(ctx.String("address"))
+				},
+			},
 		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		slog.Error("Error running command", slog.Any("error", err))
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+	"arbitrage-bot/services/sourceprovider/dex"
+	"arbitrage-bot/services/tokenregistry"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type CexDexArbitrageCommand struct {
+	dexSourceProvider dex.ISourceProvider
+	cexSourceProvider cex.ISourceProvider
+	calculator        *arbitrage.CexDexArbitrageCalculator
+}
+
+// NewCexDexArbitrageCommand ... creates the command comparing the pools of the network (PancakeSwap if the router is
+// set, Uniswap otherwise) with the markets of cexDex.exchange, sized by cexDex.sizes
+func NewCexDexArbitrageCommand() *CexDexArbitrageCommand {
+	var network = config.Get().ActiveNetwork()
+	var dexSourceProvider dex.ISourceProvider
+	var err error
+
+	if network.CexDex.Exchange == "" {
+		helpers.Panic(fmt.Errorf("cexDex.exchange isn't set for %s", config.Get().Network))
+	}
+	if network.Contracts.PancakeswapRouter != "" {
+		dexSourceProvider, err = dex.NewPancakeswapSourceProvider()
+	} else {
+		dexSourceProvider, err = dex.NewUniswapSourceProviderService()
+	}
+	helpers.Panic(err)
+	cexSourceProvider, err := cex.NewSourceProvider(network.CexDex.Exchange)
+	helpers.Panic(err)
+
+	return &CexDexArbitrageCommand{
+		dexSourceProvider: dexSourceProvider,
+		cexSourceProvider: cexSourceProvider,
+		calculator:        arbitrage.NewCexDexArbitrageCalculator(dexSourceProvider, cexSourceProvider, network.CexDex.Sizes),
+	}
+}
+
+// getDexSymbols ... returns the unique pools of the cached triangles (fetched by the <dex>-fetch-pools commands)
+func (c *CexDexArbitrageCommand) getDexSymbols() []*sourceprovider.Symbol {
+	var triangularPairs [][3]*sourceprovider.Symbol
+	helpers.Panic(jsonHelper.ReadJSONFile(c.dexSourceProvider.GetArbitragePairCachePath(), &triangularPairs))
+
+	var chainID = config.Get().ActiveNetwork().ChainID
+	var symbols []*sourceprovider.Symbol
+	var seen = make(map[string]bool)
+
+	for _, triangularPair := range triangularPairs {
+		// caches written before the fee tier was part of the Uniswap names
+		sourceprovider.MigrateSymbolNames(triangularPair[:]...)
+
+		for _, symbol := range triangularPair {
+			if !seen[symbol.Symbol] {
+				seen[symbol.Symbol] = true
+				tokenregistry.Get().AnnotateDEXSymbol(chainID, symbol)
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+
+	return symbols
+}
+
+// matchSymbols ... keeps the pools whose pair is listed on the CEX, returns them with the CEX symbols to subscribe
+func (c *CexDexArbitrageCommand) matchSymbols(
+	dexSymbols []*sourceprovider.Symbol,
+) ([]*sourceprovider.Symbol, []*sourceprovider.Symbol) {
+	cexSymbols, err := c.cexSourceProvider.GetSymbols(false)
+	helpers.Panic(err)
+
+	var cexSymbolsByPair = make(map[string]*sourceprovider.Symbol)
+
+	for _, symbol := range cexSymbols {
+		cexSymbolsByPair[symbol.GetBaseAssetID()+"/"+symbol.GetQuoteAssetID()] = symbol
+	}
+
+	var matchedDexSymbols, matchedCexSymbols []*sourceprovider.Symbol
+
+	for _, symbol := range dexSymbols {
+		for _, pair := range []string{
+			symbol.GetBaseAssetID() + "/" + symbol.GetQuoteAssetID(),
+			symbol.GetQuoteAssetID() + "/" + symbol.GetBaseAssetID(),
+		} {
+			if cexSymbol, ok := cexSymbolsByPair[pair]; ok {
+				matchedDexSymbols = append(matchedDexSymbols, symbol)
+				matchedCexSymbols = append(matchedCexSymbols, cexSymbol)
+				break
+			}
+		}
+	}
+
+	return matchedDexSymbols, matchedCexSymbols
+}
+
+// Run ... subscribes to the pools listed on the CEX & logs the CEX-DEX opportunities on every price update of the
+// pools until the process is interrupted (the opportunities aren't executed)
+func (c *CexDexArbitrageCommand) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var dexSymbols, cexSymbols = c.matchSymbols(c.getDexSymbols())
+
+	if len(dexSymbols) == 0 {
+		slog.Warn("No pool is listed on the exchange", slog.String("exchange", c.cexSourceProvider.GetName()))
+		return
+	}
+	helpers.Panic(c.cexSourceProvider.SubscribeSymbols(ctx, cexSymbols))
+
+	var pingChannel = make(chan bool)
+	go c.dexSourceProvider.SubscribeSymbols(ctx, dexSymbols, pingChannel)
+	slog.Info(
+		"CEX-DEX arbitrage started",
+		slog.String("dex", c.dexSourceProvider.GetName()),
+		slog.String("cex", c.cexSourceProvider.GetName()),
+		slog.Int("pools", len(dexSymbols)),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("CEX-DEX arbitrage stopped")
+			return
+		case <-pingChannel:
+			var opportunities = 0
+
+			for _, symbol := range dexSymbols {
+				results, err := c.calculator.CalcCexDexArb(*symbol)
+
+				if err != nil {
+					continue
+				}
+				for _, result := range results {
+					opportunities++
+					slog.Info("CEX-DEX arbitrage opportunity",
+						slog.String("pool", result.DexSymbol.Symbol),
+						slog.String("market", result.CexSymbol.Symbol),
+						slog.String("direction", result.Direction),
+						slog.Float64("amountIn", result.AmountIn),
+						slog.Float64("dexPrice", result.DexPrice),
+						slog.Float64("cexVwap", result.CexVWAP),
+						slog.Float64("netEdge", result.NetEdge),
+						slog.Float64("netEdgePerc", result.NetEdgePerc),
+					)
+				}
+			}
+			metrics.ObserveEvaluations(c.dexSourceProvider.GetName(), len(dexSymbols))
+			metrics.IncSurfaceOpportunities(c.dexSourceProvider.GetName(), opportunities)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers"
+	fileHelper "arbitrage-bot/helpers/file"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/discovery"
+	"arbitrage-bot/services/sourceprovider"
+	"log/slog"
+)
+
+// filterPools ... runs the liquidity & volume filter (if enabled) & saves its decisions next to the cached pairs
+func filterPools(
+	reader discovery.ReserveReader,
+	symbols []*sourceprovider.Symbol,
+	arbitragePairCachePath string,
+) []*sourceprovider.Symbol {
+	if !config.Get().ActiveNetwork().Discovery.Liquidity.Enabled {
+		return symbols
+	}
+
+	var poolFilter = discovery.NewPoolFilter(reader)
+	var keptSymbols, results = poolFilter.Handle(symbols, nil)
+	helpers.Panic(poolFilter.SaveResults(arbitragePairCachePath, results))
+
+	return keptSymbols
+}
+
+// updateTriangularPairs ... recomputes the triangular pairs of the pools which weren't used for the cached ones, then
+// records the pools in the discovery state (an interrupted run recomputes the same pools)
+func updateTriangularPairs(
+	triangularPairFinder *arbitrage.TriangularPairFinder,
+	state *discovery.State,
+	statePath string,
+	symbols []*sourceprovider.Symbol,
+	arbitragePairCachePath string,
+) {
+	var cachedTriangularPairs [][3]*sourceprovider.Symbol
+
+	if fileHelper.PathExists(arbitragePairCachePath) {
+		helpers.Panic(jsonHelper.ReadJSONFile(arbitragePairCachePath, &cachedTriangularPairs))
+	}
+	// the cached pairs are saved again with the current Uniswap names
+	for _, triangularPair := range cachedTriangularPairs {
+		sourceprovider.MigrateSymbolNames(triangularPair[:]...)
+	}
+
+	var affectedPools = state.AffectedPools(symbols)
+	var triangularPairs = triangularPairFinder.HandleNewPairs(symbols, affectedPools, cachedTriangularPairs)
+	helpers.Panic(jsonHelper.WriteJSONFileAtomic(arbitragePairCachePath, triangularPairs))
+	slog.Info(
+		"Updated triangular pairs",
+		slog.Int("affectedPools", len(affectedPools)),
+		slog.Int("cached", len(cachedTriangularPairs)),
+		slog.Int("total", len(triangularPairs)),
+	)
+
+	state.SetTrianglePools(symbols)
+	helpers.Panic(state.Save(statePath))
+}
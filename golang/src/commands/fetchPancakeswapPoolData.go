@@ -1,13 +1,16 @@
 package commands
 
 import (
+	"arbitrage-bot/config"
 	"arbitrage-bot/helpers"
-	jsonHelper "arbitrage-bot/helpers/json"
 	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/discovery"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/dex"
+	"arbitrage-bot/services/tokensafety"
 	"arbitrage-bot/services/web3"
-	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
 	"sync"
 )
 
@@ -17,53 +20,147 @@ type FetchPancakeswapPoolDataCommand struct {
 
 // NewFetchPancakeswapPoolDataCommand ... creates a new FetchPancakeswapPoolDataCommand
 func NewFetchPancakeswapPoolDataCommand() *FetchPancakeswapPoolDataCommand {
+	web3Service, err := web3.NewPancakeswapWeb3Service()
+	helpers.Panic(err)
+
 	return &FetchPancakeswapPoolDataCommand{
-		web3Service: web3.NewPancakeswapWeb3Service(),
+		web3Service: web3Service,
+	}
+}
+
+// discoveryBatchSize ... pools read by index between two checkpoints
+const discoveryBatchSize uint64 = 100
+
+// fetchSymbolsByIndex ... fetches the pools of the factory indices [from, to)
+func (c *FetchPancakeswapPoolDataCommand) fetchSymbolsByIndex(from uint64, to uint64) []*sourceprovider.Symbol {
+	var addresses, err = c.web3Service.GetPairAddresses(from, to)
+
+	if err != nil {
+		slog.Warn("Error fetching pair addresses", slog.Uint64("from", from), slog.Uint64("to", to), slog.Any("error", err))
+		return nil
+	}
+
+	return c.fetchSymbolsByAddress(addresses)
+}
+
+// fetchSymbolsByAddress ... fetches the pool data of the addresses
+func (c *FetchPancakeswapPoolDataCommand) fetchSymbolsByAddress(addresses []common.Address) []*sourceprovider.Symbol {
+	var symbols, failed, err = c.web3Service.GetPoolsData(addresses)
+
+	if err != nil {
+		slog.Warn("Error fetching pool data", slog.Int("pools", len(addresses)), slog.Any("error", err))
+		return nil
+	}
+	for address, err := range failed {
+		slog.Warn("Error fetching pool data", slog.String("address", address.String()), slog.Any("error", err))
+	}
+
+	return symbols
+}
+
+// discoverPools ... the first run reads the factory by index (up to maxPools), the pools created since the block it
+// started at are picked up from the PairCreated logs, the state is saved after every batch
+func (c *FetchPancakeswapPoolDataCommand) discoverPools(state *discovery.State, statePath string) {
+	var network = config.Get().ActiveNetwork()
+	var scanner = discovery.NewEventScanner(network.Contracts.PancakeswapFactory, discovery.PancakeswapPairCreated)
+	latestBlock, err := scanner.LatestBlock()
+	helpers.Panic(err)
+	pairsLength, err := c.web3Service.GetPairsLength()
+	helpers.Panic(err)
+
+	if state.Block == 0 {
+		state.Block = latestBlock
+	}
+
+	var maxIndex = min(pairsLength, network.Discovery.MaxPools)
+
+	for state.NextIndex < maxIndex {
+		var end = min(state.NextIndex+discoveryBatchSize, maxIndex)
+		var added = state.AddSymbols(c.fetchSymbolsByIndex(state.NextIndex, end))
+		state.NextIndex = end
+		helpers.Panic(state.Save(statePath))
+		slog.Info("Fetched pools by index", slog.Uint64("nextIndex", end), slog.Int("added", len(added)))
 	}
+
+	err = scanner.Scan(state.Block, latestBlock, func(pools []common.Address, lastBlock uint64) error {
+		var added = state.AddSymbols(c.fetchSymbolsByAddress(pools))
+		state.Block = lastBlock
+		slog.Info("Fetched created pools", slog.Uint64("block", lastBlock), slog.Int("added", len(added)))
+
+		return state.Save(statePath)
+	})
+	helpers.Panic(err)
 }
 
-// fetchSymbolsFromNetwork ... fetches symbols from the network
-func (c *FetchPancakeswapPoolDataCommand) fetchSymbols() []*sourceprovider.Symbol {
-	var symbols []*sourceprovider.Symbol
-	var maxPools = 1000
+// analyseTokens ... runs the safety analysis on the unregistered tokens of the pools which weren't analysed yet,
+// then saves the allow/deny list
+func (c *FetchPancakeswapPoolDataCommand) analyseTokens(symbols []*sourceprovider.Symbol) *tokensafety.List {
+	var list, err = tokensafety.LoadList(tokensafety.ListPath())
+	helpers.Panic(err)
+	analyser, err := tokensafety.NewAnalyser()
+	helpers.Panic(err)
 	var concurrency = 5
-	var channel = make(chan int)
+	var channel = make(chan *sourceprovider.Symbol)
+	var analysed sync.Map
 	var wg = sync.WaitGroup{}
 	wg.Add(concurrency)
 
-	// Fetch pool data concurrently (5 coroutines)
 	for range concurrency {
 		go func() {
 			defer wg.Done()
-			for index := range channel {
-				var symbol, err = c.web3Service.GetPoolDataByIndex(index)
-				if err == nil {
-					symbols = append(symbols, &symbol)
-				} else {
-					fmt.Println("Error fetching pool data:", err)
+			for symbol := range channel {
+				for _, address := range analyser.TokensToAnalyse(symbol) {
+					// a token is analysed once, through the first pool it's found in
+					if _, loaded := analysed.LoadOrStore(address, true); loaded || list.IsAnalysed(address) {
+						continue
+					}
+					var report = analyser.AnalyseToken(symbol, address)
+					list.Add(report)
+
+					if len(report.Reasons) > 0 {
+						slog.Info(
+							"Denied token",
+							slog.String("symbol", report.Symbol),
+							slog.String("address", report.Address),
+							slog.Any("reasons", report.Reasons),
+						)
+					}
 				}
 			}
 		}()
 	}
-	for index := range maxPools {
-		channel <- index
+	for _, symbol := range symbols {
+		channel <- symbol
 	}
 	close(channel)
 	wg.Wait()
+	helpers.Panic(list.Save(tokensafety.ListPath()))
+	slog.Info("Analysed tokens", slog.Int("allowed", len(list.Allow)), slog.Int("denied", len(list.Deny)))
 
-	return symbols
+	return list
 }
 
-// Fetch ... fetches Pancake pool data & find triangular pairs
+// Fetch ... discovers the new Pancake pools & updates the affected triangular pairs (safe to interrupt & restart)
 func (c *FetchPancakeswapPoolDataCommand) Fetch() {
-	// Fetch symbols from the network
-	var symbols = c.fetchSymbols()
-	fmt.Println("Fetched", len(symbols), "symbols")
+	sourceProvider, err := dex.NewPancakeswapSourceProvider()
+	helpers.Panic(err)
+	var statePath = discovery.StatePath(sourceProvider.GetName())
+	state, err := discovery.LoadState(statePath)
+	helpers.Panic(err)
+
+	// Fetch the new pools from the network
+	c.discoverPools(state, statePath)
+	slog.Info("Discovered pools", slog.Int("count", len(state.Symbols)))
 
-	// Find triangular pairs & save to cache
-	var sourceProvider = dex.NewPancakeswapSourceProvider()
+	// Drop the pools with little liquidity
+	var cachePath = sourceProvider.GetArbitragePairCachePath()
+	var symbols = filterPools(c.web3Service, state.Symbols, cachePath)
+
+	// Find triangular pairs (skipping unsafe tokens) & save to cache
 	var triangularPairFinder = arbitrage.TriangularPairFinder{}
-	triangularPairs := triangularPairFinder.Handle(symbols)
-	var err = jsonHelper.WriteJSONFile(sourceProvider.GetArbitragePairCachePath(), triangularPairs)
-	helpers.Panic(err)
+
+	if config.Get().ActiveNetwork().Discovery.TokenSafety.Enabled {
+		triangularPairFinder.SafetyList = c.analyseTokens(symbols)
+	}
+	updateTriangularPairs(&triangularPairFinder, state, statePath, symbols, cachePath)
 }
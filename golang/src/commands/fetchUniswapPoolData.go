@@ -1,14 +1,17 @@
 package commands
 
 import (
+	"arbitrage-bot/config"
 	"arbitrage-bot/helpers"
 	jsonHelper "arbitrage-bot/helpers/json"
 	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/discovery"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/dex"
 	"arbitrage-bot/services/web3"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
-	"sync"
+	"log/slog"
 )
 
 type FetchUniswapPoolDataCommand struct {
@@ -17,50 +20,113 @@ type FetchUniswapPoolDataCommand struct {
 
 // NewFetchUniswapPoolDataCommand ... creates a new FetchUniswapPoolDataCommand
 func NewFetchUniswapPoolDataCommand() *FetchUniswapPoolDataCommand {
+	web3Service, err := web3.NewUniswapWeb3Service()
+	helpers.Panic(err)
+
 	return &FetchUniswapPoolDataCommand{
-		web3Service: web3.NewUniswapWeb3Service(),
+		web3Service: web3Service,
 	}
 }
 
-// fetchSymbolsFromNetwork ... fetches symbols from the network
-func (c *FetchUniswapPoolDataCommand) fetchSymbols(poolData []map[string]string) []*sourceprovider.Symbol {
-	var symbols []*sourceprovider.Symbol
-	var concurrency = 5
-	var channel = make(chan common.Address)
-	var wg = sync.WaitGroup{}
-	wg.Add(concurrency)
-
-	// Fetch pool data concurrently (5 coroutines)
-	for range concurrency {
-		go func() {
-			defer wg.Done()
-			for poolAddress := range channel {
-				var symbol = c.web3Service.GetPoolData(poolAddress)
-				symbols = append(symbols, &symbol)
-			}
-		}()
+// fetchSymbols ... fetches the pool data of the addresses from the network
+func (c *FetchUniswapPoolDataCommand) fetchSymbols(poolAddresses []common.Address) []*sourceprovider.Symbol {
+	var symbols, failed, err = c.web3Service.GetPoolsData(poolAddresses)
+
+	if err != nil {
+		slog.Warn("Error fetching pool data", slog.Int("pools", len(poolAddresses)), slog.Any("error", err))
+		return nil
 	}
-	for _, pair := range poolData {
-		var poolAddress = common.HexToAddress(pair["address"])
-		channel <- poolAddress
+	for address, err := range failed {
+		slog.Warn("Error fetching pool data", slog.String("address", address.String()), slog.Any("error", err))
 	}
-	close(channel)
-	wg.Wait()
 
 	return symbols
 }
 
-// Fetch ... fetches Uniswap pool data & find triangular pairs
+// Fetch ... fetches the Uniswap pools of the temp file which aren't known yet & updates the affected triangular pairs
 func (c *FetchUniswapPoolDataCommand) Fetch(poolDataTempFilepath string) {
 	var poolData []map[string]string
 	var err = jsonHelper.ReadJSONFile(poolDataTempFilepath, &poolData)
 	helpers.Panic(err)
-	// Fetch symbols from the network
-	var symbols = c.fetchSymbols(poolData)
+	sourceProvider, err := dex.NewUniswapSourceProviderService()
+	helpers.Panic(err)
+	var statePath = discovery.StatePath(sourceProvider.GetName())
+	state, err := discovery.LoadState(statePath)
+	helpers.Panic(err)
+
+	// Fetch the new pools from the network
+	var knownPools = make(map[common.Address]bool)
+	var newPools []common.Address
+
+	for _, symbol := range state.Symbols {
+		knownPools[common.HexToAddress(symbol.Address)] = true
+	}
+	for _, pair := range poolData {
+		if poolAddress := common.HexToAddress(pair["address"]); !knownPools[poolAddress] {
+			newPools = append(newPools, poolAddress)
+		}
+	}
+	state.AddSymbols(c.fetchSymbols(newPools))
+	helpers.Panic(state.Save(statePath))
+	c.updateTriangularPairs(sourceProvider, state, statePath)
+}
+
+// FetchFromFactory ... scans the PoolCreated events of the V3 factory (every fee tier) over a block range & updates
+// the affected triangular pairs, the range defaults to the last scanned block (or the factory deployment) up to the
+// latest block
+func (c *FetchUniswapPoolDataCommand) FetchFromFactory(fromBlock uint64, toBlock uint64) {
+	var network = config.Get().ActiveNetwork()
+	sourceProvider, err := dex.NewUniswapSourceProviderService()
+	helpers.Panic(err)
+	var statePath = discovery.StatePath(sourceProvider.GetName())
+	state, err := discovery.LoadState(statePath)
+	helpers.Panic(err)
+
+	if network.Contracts.UniswapFactory == "" {
+		helpers.Panic(fmt.Errorf("contracts.uniswapFactory isn't set for %s", config.Get().Network))
+	}
+
+	var scanner = discovery.NewEventScanner(network.Contracts.UniswapFactory, discovery.UniswapPoolCreated)
+
+	// the scanner starts after scanStart, scanning from the checkpoint (or the factory deployment) leaves no gap so
+	// the checkpoint can move
+	var resumeBlock = max(state.Block, max(network.Contracts.UniswapFactoryBlock, 1)-1)
+	var scanStart = resumeBlock
+
+	if fromBlock > 0 {
+		scanStart = fromBlock - 1
+	}
+	var contiguous = scanStart <= resumeBlock
+
+	if toBlock == 0 {
+		toBlock, err = scanner.LatestBlock()
+		helpers.Panic(err)
+	}
+
+	err = scanner.Scan(scanStart, toBlock, func(pools []common.Address, lastBlock uint64) error {
+		var added = state.AddSymbols(c.fetchSymbols(pools))
+
+		if contiguous && lastBlock > state.Block {
+			state.Block = lastBlock
+		}
+		slog.Info("Fetched created pools", slog.Uint64("block", lastBlock), slog.Int("added", len(added)))
+
+		return state.Save(statePath)
+	})
+	helpers.Panic(err)
+	c.updateTriangularPairs(sourceProvider, state, statePath)
+}
+
+// updateTriangularPairs ... filters the discovered pools & updates the cached triangular pairs
+func (c *FetchUniswapPoolDataCommand) updateTriangularPairs(
+	sourceProvider *dex.UniswapSourceProviderService,
+	state *discovery.State,
+	statePath string,
+) {
+	// Drop the pools with little liquidity
+	var cachePath = sourceProvider.GetArbitragePairCachePath()
+	var symbols = filterPools(c.web3Service, state.Symbols, cachePath)
 	// Find triangular pairs & save to cache
-	var sourceProvider = dex.NewUniswapSourceProviderService()
 	var triangularPairFinder = arbitrage.TriangularPairFinder{}
-	triangularPairs := triangularPairFinder.Handle(symbols)
-	err = jsonHelper.WriteJSONFile(sourceProvider.GetArbitragePairCachePath(), triangularPairs)
-	helpers.Panic(err)
+	updateTriangularPairs(&triangularPairFinder, state, statePath, symbols, cachePath)
 }
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"arbitrage-bot/helpers"
+	"arbitrage-bot/services/web3"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type RelayStubCommand struct {
+	relay *web3.RelayStub
+}
+
+// NewRelayStubCommand ... creates a local relay recording the bundles of the bundle submitter
+func NewRelayStubCommand() *RelayStubCommand {
+	return &RelayStubCommand{relay: web3.NewRelayStub()}
+}
+
+// Serve ... serves the relay stub until the process is interrupted
+func (c *RelayStubCommand) Serve(address string) {
+	helpers.Panic(c.relay.Start(address))
+	slog.Info("Point relay.url at the relay stub to submit the bundles to it", slog.String("url", c.relay.URL()))
+
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	slog.Info("Relay stub stopped", slog.Int("bundles", len(c.relay.Bundles())))
+	helpers.Panic(c.relay.Close())
+}
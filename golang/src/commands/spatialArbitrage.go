@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers"
+	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// spatialMetricsProvider ... provider label of the spatial evaluations
+const spatialMetricsProvider = "spatial"
+
+type SpatialArbitrageCommand struct {
+	sourceProviders []cex.ISourceProvider
+	calculator      *arbitrage.SpatialArbitrageCalculator
+	interval        time.Duration
+}
+
+// NewSpatialArbitrageCommand ... creates the command comparing the pairs listed on the exchanges of spatial.exchanges
+func NewSpatialArbitrageCommand() *SpatialArbitrageCommand {
+	var settings = config.Get().Spatial
+	var sourceProviders []cex.ISourceProvider
+
+	for _, exchange := range settings.Exchanges {
+		sourceProvider, err := cex.NewSourceProvider(exchange)
+		helpers.Panic(err)
+		sourceProviders = append(sourceProviders, sourceProvider)
+	}
+	if len(sourceProviders) < 2 {
+		helpers.Panic(fmt.Errorf("spatial.exchanges needs 2 exchanges at least"))
+	}
+
+	return &SpatialArbitrageCommand{
+		sourceProviders: sourceProviders,
+		calculator:      arbitrage.NewSpatialArbitrageCalculator(sourceProviders...),
+		interval:        settings.Interval,
+	}
+}
+
+// Run ... subscribes to the pairs listed on several exchanges & logs the spatial opportunities every interval until
+// the process is interrupted (the opportunities aren't executed)
+func (c *SpatialArbitrageCommand) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var symbolsByProvider = make(map[string][]*sourceprovider.Symbol)
+
+	for _, sourceProvider := range c.sourceProviders {
+		symbols, err := sourceProvider.GetSymbols(false)
+		helpers.Panic(err)
+		symbolsByProvider[sourceProvider.GetName()] = symbols
+	}
+
+	var matches = c.calculator.MatchSymbols(symbolsByProvider)
+	var subscribed = make(map[string][]*sourceprovider.Symbol)
+
+	for _, match := range matches {
+		for provider, symbol := range match {
+			subscribed[provider] = append(subscribed[provider], symbol)
+		}
+	}
+	for _, sourceProvider := range c.sourceProviders {
+		helpers.Panic(sourceProvider.SubscribeSymbols(ctx, subscribed[sourceProvider.GetName()]))
+	}
+	slog.Info("Spatial arbitrage started", slog.Int("pairs", len(matches)), slog.Duration("interval", c.interval))
+
+	var ticker = time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Spatial arbitrage stopped")
+			return
+		case <-ticker.C:
+			var opportunities = 0
+
+			for _, match := range matches {
+				result, err := c.calculator.CalcSpatialArb(match)
+
+				if err != nil {
+					continue
+				}
+				opportunities++
+				slog.Info("Spatial arbitrage opportunity",
+					slog.String("pair", result.BaseAsset+"/"+result.QuoteAsset),
+					slog.String("buy", result.BuyExchange),
+					slog.String("sell", result.SellExchange),
+					slog.Float64("quantity", result.Quantity),
+					slog.Float64("buyVwap", result.BuyVWAP),
+					slog.Float64("sellVwap", result.SellVWAP),
+					slog.Float64("netProfit", result.NetProfit),
+					slog.Float64("netProfitPerc", result.NetProfitPerc),
+				)
+			}
+			metrics.ObserveEvaluations(spatialMetricsProvider, len(matches))
+			metrics.IncSurfaceOpportunities(spatialMetricsProvider, opportunities)
+		}
+	}
+}
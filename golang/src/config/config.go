@@ -0,0 +1,558 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath ... default location of the configuration file (overridden by CONFIG_PATH)
+const DefaultConfigPath string = "config.yaml"
+
+// EnvPrefix ... prefix of the environment variables overriding configuration keys
+const EnvPrefix string = "ARB"
+
+// Config ... Represents the configuration file
+type Config struct {
+	Network       string                     `yaml:"network"`
+	TokenRegistry string                     `yaml:"tokenRegistry"` // path of the token registry file
+	Log           LogConfig                  `yaml:"log"`
+	Metrics       MetricsConfig              `yaml:"metrics"`
+	Networks      map[string]*NetworkProfile `yaml:"networks"`
+	Exchanges     map[string]*ExchangeConfig `yaml:"exchanges"`
+	Spatial       SpatialConfig              `yaml:"spatial"`
+}
+
+// LogConfig ... Represents the logging settings
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// MetricsConfig ... Represents the Prometheus endpoint settings
+type MetricsConfig struct {
+	Address string `yaml:"address"` // leave empty to disable the endpoint
+}
+
+// NetworkProfile ... Represents the settings of a network
+type NetworkProfile struct {
+	ChainID    int64              `yaml:"chainId"`
+	RPCURLs    []string           `yaml:"rpcUrls"`
+	RPC        RPCConfig          `yaml:"rpc"`
+	Contracts  ContractsConfig    `yaml:"contracts"`
+	ABIs       ABIsConfig         `yaml:"abis"`
+	Thresholds ThresholdsConfig   `yaml:"thresholds"`
+	Polling    PollingConfig      `yaml:"polling"`
+	Subgraph   SubgraphConfig     `yaml:"subgraph"`
+	Gas        GasConfig          `yaml:"gas"`
+	Discovery  DiscoveryConfig    `yaml:"discovery"`
+	Portfolio  PortfolioConfig    `yaml:"portfolio"`
+	Risk       RiskConfig         `yaml:"risk"`
+	Paper      PaperTradingConfig `yaml:"paper"`
+	Wallet     WalletConfig       `yaml:"wallet"`
+	Relay      RelayConfig        `yaml:"relay"`
+	Mempool    MempoolConfig      `yaml:"mempool"`
+	Nonces     NonceConfig        `yaml:"nonces"`
+	CexDex     CexDexConfig       `yaml:"cexDex"`
+}
+
+// RPCConfig ... Represents the limits of the RPC endpoint pool (applied to every endpoint)
+type RPCConfig struct {
+	RateLimit    float64       `yaml:"rateLimit"`    // requests per second
+	Burst        int           `yaml:"burst"`        // requests sent at once before the rate limit applies
+	MaxRetries   int           `yaml:"maxRetries"`   // retries of a failed request on another endpoint
+	RetryBackoff time.Duration `yaml:"retryBackoff"` // wait before the first retry, doubled on every retry
+	Timeout      time.Duration `yaml:"timeout"`      // wait for the response headers of a request
+	Cooldown     time.Duration `yaml:"cooldown"`     // an endpoint failing repeatedly is skipped for this long
+}
+
+// ContractsConfig ... Represents the contract addresses of a network
+type ContractsConfig struct {
+	PancakeswapFactory   string `yaml:"pancakeswapFactory"`
+	PancakeswapRouter    string `yaml:"pancakeswapRouter"`
+	UniswapFactory       string `yaml:"uniswapFactory"`      // V3 factory (pool discovery)
+	UniswapFactoryBlock  uint64 `yaml:"uniswapFactoryBlock"` // deployment block of the V3 factory
+	UniswapQuoter        string `yaml:"uniswapQuoter"`
+	UniswapQuoterVersion string `yaml:"uniswapQuoterVersion"` // v1 or v2
+	ArbitrageExecutor    string `yaml:"arbitrageExecutor"`
+	Multicall3           string `yaml:"multicall3"`
+}
+
+// ABIsConfig ... Represents the ABI file paths
+type ABIsConfig struct {
+	ArbitrageExecutor  string `yaml:"arbitrageExecutor"`
+	ERC20              string `yaml:"erc20"`
+	Multicall3         string `yaml:"multicall3"`
+	PancakeswapFactory string `yaml:"pancakeswapFactory"`
+	PancakeswapPool    string `yaml:"pancakeswapPool"`
+	PancakeswapRouter  string `yaml:"pancakeswapRouter"`
+	UniswapPool        string `yaml:"uniswapPool"`
+	UniswapQuoter      string `yaml:"uniswapQuoter"`
+	UniswapQuoterV2    string `yaml:"uniswapQuoterV2"`
+}
+
+// ThresholdsConfig ... Represents the trading thresholds (percentages are in the same unit as ProfitLossPerc)
+type ThresholdsConfig struct {
+	StartingAmount float64 `yaml:"startingAmount"`
+	MinProfitPerc  float64 `yaml:"minProfitPerc"`
+	MaxProfitPerc  float64 `yaml:"maxProfitPerc"`
+}
+
+// PollingConfig ... Represents the polling intervals
+type PollingConfig struct {
+	PriceInterval time.Duration `yaml:"priceInterval"`
+	CycleInterval time.Duration `yaml:"cycleInterval"`
+}
+
+// GasConfig ... Represents the gas assumptions used to price on-chain swaps
+type GasConfig struct {
+	SwapGasUnits uint64 `yaml:"swapGasUnits"`
+	NativeTicker string `yaml:"nativeTicker"` // CEX ticker of the native token (f.e. BNB)
+}
+
+// DiscoveryConfig ... Represents the settings of the pool discovery commands
+type DiscoveryConfig struct {
+	MaxPools     uint64            `yaml:"maxPools"`     // pools read by index on the first run (PancakeSwap)
+	LogChunkSize uint64            `yaml:"logChunkSize"` // blocks per eth_getLogs request
+	TokenSafety  TokenSafetyConfig `yaml:"tokenSafety"`
+	Liquidity    LiquidityConfig   `yaml:"liquidity"`
+}
+
+// TokenSafetyConfig ... Represents the limits of the token safety analysis (honeypots, fee-on-transfer tokens)
+type TokenSafetyConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	ProbeShare       float64 `yaml:"probeShare"`       // share of the pool reserves used by the simulated trades
+	MaxTransferTax   float64 `yaml:"maxTransferTax"`   // f.e. 0.001 = 0.1%
+	MaxRoundTripLoss float64 `yaml:"maxRoundTripLoss"` // loss of a buy & sell round trip, pool fees included
+	DenyPausable     bool    `yaml:"denyPausable"`
+	DenyBlacklist    bool    `yaml:"denyBlacklist"`
+}
+
+// LiquidityConfig ... Represents the liquidity & volume filter of the discovered pools
+type LiquidityConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	QuoteAssets      []string `yaml:"quoteAssets"`      // canonical ids valued at 1 (f.e. USDT), the common quote currency
+	MinLiquidity     float64  `yaml:"minLiquidity"`     // in the quote currency
+	MinVolume        float64  `yaml:"minVolume"`        // in the quote currency, only applied when the source has volumes
+	TopPoolsPerToken int      `yaml:"topPoolsPerToken"` // 0 keeps every pool
+}
+
+// PortfolioConfig ... Represents the funds tracked by the portfolio service (the ERC20 balances of the wallet & the
+// executor contract, the balances of the CEX accounts with an API key)
+type PortfolioConfig struct {
+	Enabled         bool          `yaml:"enabled"`         // cap the starting amounts by the available inventory
+	Wallet          string        `yaml:"wallet"`          // address of the executor wallet
+	RefreshInterval time.Duration `yaml:"refreshInterval"` // age of the balances before they're read again
+	MaxShare        float64       `yaml:"maxShare"`        // share of the available inventory a trade may use
+	RebalanceShare  float64       `yaml:"rebalanceShare"`  // flag a venue holding less than this share of an asset
+}
+
+// RiskConfig ... Represents the limits checked before executing an opportunity, the amounts are limited per canonical
+// asset id in units of the asset (a starting token without both limits isn't traded, the gas counts against the loss
+// of the native token which needs a daily loss limit too)
+type RiskConfig struct {
+	Enabled            bool               `yaml:"enabled"`            // apply the limits (the kill switch always applies)
+	MaxNotional        map[string]float64 `yaml:"maxNotional"`        // asset id -> largest amount traded at once
+	MaxTradesPerMinute int                `yaml:"maxTradesPerMinute"` // 0 for no limit
+	MaxDailyLoss       map[string]float64 `yaml:"maxDailyLoss"`       // asset id -> realized loss over the UTC day
+	Blacklist          []string           `yaml:"blacklist"`          // canonical asset ids or token addresses never traded
+	FailureCooldown    time.Duration      `yaml:"failureCooldown"`    // a triangle isn't executed again for this long after a failure
+	KillSwitchFile     string             `yaml:"killSwitchFile"`     // execution halts while this file exists
+}
+
+// PaperTradingConfig ... Represents the simulated execution of the paper-trading mode (the -paper flag of the bot)
+type PaperTradingConfig struct {
+	Latency         time.Duration      `yaml:"latency"`         // delay between the decision & the simulated fill
+	SummaryInterval time.Duration      `yaml:"summaryInterval"` // interval of the PnL summaries, 0 to disable them
+	Balances        map[string]float64 `yaml:"balances"`        // canonical asset id -> virtual starting balance
+}
+
+// WalletConfig ... Represents the executor wallet signing the swapIn transactions, set the key with
+// ARB_NETWORKS_<NAME>_WALLET_PRIVATEKEY
+type WalletConfig struct {
+	PrivateKey string `yaml:"privateKey"` // hex key of the executor wallet
+	Public     bool   `yaml:"public"`     // without a relay, send swapIn to the public mempool instead of dry-running it
+}
+
+// RelayConfig ... Represents the private relay receiving the swapIn transactions as bundles (Flashbots-style
+// eth_sendBundle) instead of the public mempool, set the key with ARB_NETWORKS_<NAME>_RELAY_AUTHKEY
+type RelayConfig struct {
+	URL      string  `yaml:"url"`      // eth_sendBundle endpoint, leave empty to disable the bundles
+	AuthKey  string  `yaml:"authKey"`  // hex key signing the X-Flashbots-Signature header (holds no funds)
+	Blocks   int     `yaml:"blocks"`   // a bundle targets each of the next N blocks
+	TipShare float64 `yaml:"tipShare"` // share of the expected profit transferred to block.coinbase
+}
+
+// MempoolConfig ... Represents the pending transaction watcher looking for swaps to back-run
+type MempoolConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	WsURL          string        `yaml:"wsUrl"`          // WebSocket RPC endpoint streaming the full pending transactions
+	ReservesMaxAge time.Duration `yaml:"reservesMaxAge"` // age of the pool reserves before they're read again
+	Candidates     int           `yaml:"candidates"`     // candidates queued for the main loop, the newest are dropped once full
+	GasLimit       uint64        `yaml:"gasLimit"`       // of a back-run swapIn, not estimated before the pending swap
+}
+
+// NonceConfig ... Represents the nonce manager of the executor wallet, a transaction pending for StuckBlocks blocks
+// is replaced with bumped fees & cancelled (0 value transfer to itself) after MaxReplacements replacements
+type NonceConfig struct {
+	StuckBlocks     uint64        `yaml:"stuckBlocks"`     // blocks a transaction stays pending before it's replaced
+	FeeBump         float64       `yaml:"feeBump"`         // fee increase of a replacement, the nodes require 10% at least
+	MaxReplacements int           `yaml:"maxReplacements"` // replacements of a transaction before it's cancelled
+	CheckInterval   time.Duration `yaml:"checkInterval"`   // interval of the resync & of the stuck transaction check
+}
+
+// CexDexConfig ... Represents the CEX-DEX arbitrage command (cex-dex-arbitrage), the DEX pools of the network are
+// compared to the markets of one exchange
+type CexDexConfig struct {
+	Exchange string    `yaml:"exchange"` // binance or mexc
+	Sizes    []float64 `yaml:"sizes"`    // trade sizes evaluated, in units of the CEX quote asset
+}
+
+// SpatialConfig ... Represents the spatial arbitrage command (spatial-arbitrage), the pairs listed on several
+// exchanges are compared
+type SpatialConfig struct {
+	Exchanges []string      `yaml:"exchanges"` // binance, mexc
+	Interval  time.Duration `yaml:"interval"`  // interval of the evaluations
+}
+
+// SubgraphConfig ... Represents The Graph settings
+type SubgraphConfig struct {
+	APIKey    string `yaml:"apiKey"`
+	UniswapID string `yaml:"uniswapId"`
+}
+
+// ExchangeConfig ... Represents the trading costs & the trading API settings of a CEX
+type ExchangeConfig struct {
+	TakerFee       float64            `yaml:"takerFee"`       // f.e. 0.001 = 0.1%
+	WithdrawalFees map[string]float64 `yaml:"withdrawalFees"` // asset -> fee in units of the asset
+	TransferTime   time.Duration      `yaml:"transferTime"`   // expected time for a withdrawal to arrive
+	APIKey         string             `yaml:"apiKey"`
+	APISecret      string             `yaml:"apiSecret"`
+	APIURL         string             `yaml:"apiUrl"`      // REST API, defaults to the exchange (f.e. a mock exchange)
+	WsAPIURL       string             `yaml:"wsApiUrl"`    // WebSocket API, defaults to the exchange
+	UseWsAPI       bool               `yaml:"useWsApi"`    // place the orders through the WebSocket API
+	OrderType      string             `yaml:"orderType"`   // market or ioc (limit orders at the price + slippage)
+	MaxSlippage    float64            `yaml:"maxSlippage"` // limit price offset of ioc orders, f.e. 0.002 = 0.2%
+	RecvWindow     time.Duration      `yaml:"recvWindow"`  // validity of a signed request
+}
+
+// Exchange ... returns the settings of a CEX (zero costs if it's not configured)
+func (c *Config) Exchange(name string) ExchangeConfig {
+	if exchange, ok := c.Exchanges[name]; ok && exchange != nil {
+		return *exchange
+	}
+
+	return ExchangeConfig{}
+}
+
+// legacyEnvKeys ... environment variables used before the configuration file, mapped to their keys
+var legacyEnvKeys = map[string]func(c *Config, value string){
+	"NETWORK_RPC_URL": func(c *Config, value string) {
+		if profile, ok := c.Networks[c.Network]; ok {
+			profile.RPCURLs = []string{value}
+		}
+	},
+	"UNISWAP_QUOTER_ADDRESS": func(c *Config, value string) {
+		if profile, ok := c.Networks[c.Network]; ok {
+			profile.Contracts.UniswapQuoter = value
+		}
+	},
+	"SUBGRAPH_API_KEY": func(c *Config, value string) {
+		if profile, ok := c.Networks[c.Network]; ok {
+			profile.Subgraph.APIKey = value
+		}
+	},
+	"SUBGRAPH_UNISWAP_ID": func(c *Config, value string) {
+		if profile, ok := c.Networks[c.Network]; ok {
+			profile.Subgraph.UniswapID = value
+		}
+	},
+}
+
+var (
+	instance *Config
+	initErr  error
+	once     sync.Once
+)
+
+// Init ... loads the configuration from CONFIG_PATH once, the entry points call it before any service to report an
+// invalid configuration
+func Init() error {
+	once.Do(func() {
+		var path = os.Getenv("CONFIG_PATH")
+
+		if path == "" {
+			path = DefaultConfigPath
+		}
+		instance, initErr = Load(path)
+	})
+
+	return initErr
+}
+
+// Get ... returns the configuration loaded by Init (loaded on first use, panics if it's invalid as the entry points
+// already reported it)
+func Get() *Config {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+
+	return instance
+}
+
+// Load ... reads the configuration file, applies the environment overrides and validates the result
+func Load(path string) (*Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	// the network has to be known before the legacy keys are applied on its profile
+	if network := os.Getenv("NETWORK_NAME"); network != "" {
+		config.Network = network
+	}
+	for key, apply := range legacyEnvKeys {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			apply(&config, value)
+		}
+	}
+	if err = applyEnvOverrides(&config, EnvPrefix, os.Environ()); err != nil {
+		return nil, err
+	}
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// ActiveNetwork ... returns the profile of the selected network
+func (c *Config) ActiveNetwork() *NetworkProfile {
+	return c.Networks[c.Network]
+}
+
+// Validate ... checks the selected network profile is usable
+func (c *Config) Validate() error {
+	var errs []string
+	var profile, ok = c.Networks[c.Network]
+
+	if c.Network == "" {
+		return fmt.Errorf("invalid config: network is not set")
+	} else if !ok || profile == nil {
+		return fmt.Errorf("invalid config: network %s has no profile", c.Network)
+	}
+
+	if c.TokenRegistry == "" {
+		errs = append(errs, "tokenRegistry is required")
+	}
+	if profile.ChainID <= 0 {
+		errs = append(errs, "chainId must be positive")
+	}
+	if len(profile.RPCURLs) == 0 {
+		errs = append(errs, "at least one rpcUrl is required")
+	}
+	if profile.RPC.RateLimit <= 0 || profile.RPC.Burst <= 0 {
+		errs = append(errs, "rpc.rateLimit & rpc.burst must be positive")
+	}
+	if profile.RPC.MaxRetries < 0 {
+		errs = append(errs, "rpc.maxRetries can't be negative")
+	}
+	if profile.RPC.Timeout <= 0 {
+		errs = append(errs, "rpc.timeout must be positive")
+	}
+	if profile.Contracts.Multicall3 == "" {
+		errs = append(errs, "contracts.multicall3 is required")
+	}
+	// every network trades on PancakeSwap (factory & router) or Uniswap (quoter), the executor contract is required
+	// by the on-chain executors (checked when they're created, the paper trading doesn't need it)
+	var contracts = profile.Contracts
+	var pancakeswap = contracts.PancakeswapFactory != "" || contracts.PancakeswapRouter != ""
+
+	if !pancakeswap && contracts.UniswapQuoter == "" {
+		errs = append(
+			errs, "contracts.pancakeswapFactory & contracts.pancakeswapRouter or contracts.uniswapQuoter are required",
+		)
+	} else if pancakeswap && (contracts.PancakeswapFactory == "" || contracts.PancakeswapRouter == "") {
+		errs = append(errs, "contracts.pancakeswapFactory & contracts.pancakeswapRouter are both required")
+	}
+	if contracts.UniswapFactory != "" && contracts.UniswapQuoter == "" {
+		errs = append(errs, "contracts.uniswapQuoter is required with contracts.uniswapFactory")
+	}
+	for name, address := range map[string]string{
+		"pancakeswapFactory": profile.Contracts.PancakeswapFactory,
+		"pancakeswapRouter":  profile.Contracts.PancakeswapRouter,
+		"uniswapFactory":     profile.Contracts.UniswapFactory,
+		"uniswapQuoter":      profile.Contracts.UniswapQuoter,
+		"arbitrageExecutor":  profile.Contracts.ArbitrageExecutor,
+		"multicall3":         profile.Contracts.Multicall3,
+	} {
+		if address != "" && !common.IsHexAddress(address) {
+			errs = append(errs, fmt.Sprintf("contracts.%s is not a valid address", name))
+		}
+	}
+	if version := profile.Contracts.UniswapQuoterVersion; version != "" && version != "v1" && version != "v2" {
+		errs = append(errs, "contracts.uniswapQuoterVersion must be v1 or v2")
+	}
+	for name, path := range map[string]string{
+		"arbitrageExecutor":  profile.ABIs.ArbitrageExecutor,
+		"erc20":              profile.ABIs.ERC20,
+		"multicall3":         profile.ABIs.Multicall3,
+		"pancakeswapFactory": profile.ABIs.PancakeswapFactory,
+		"pancakeswapPool":    profile.ABIs.PancakeswapPool,
+		"pancakeswapRouter":  profile.ABIs.PancakeswapRouter,
+		"uniswapPool":        profile.ABIs.UniswapPool,
+		"uniswapQuoter":      profile.ABIs.UniswapQuoter,
+		"uniswapQuoterV2":    profile.ABIs.UniswapQuoterV2,
+	} {
+		if path == "" {
+			errs = append(errs, fmt.Sprintf("abis.%s is required", name))
+		} else if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Sprintf("abis.%s: %v", name, err))
+		}
+	}
+	if profile.Thresholds.StartingAmount <= 0 {
+		errs = append(errs, "thresholds.startingAmount must be positive")
+	}
+	if profile.Thresholds.MinProfitPerc >= profile.Thresholds.MaxProfitPerc {
+		errs = append(errs, "thresholds.minProfitPerc must be lower than thresholds.maxProfitPerc")
+	}
+	if profile.Polling.PriceInterval <= 0 || profile.Polling.CycleInterval <= 0 {
+		errs = append(errs, "polling intervals must be positive")
+	}
+	if profile.Discovery.LogChunkSize == 0 {
+		errs = append(errs, "discovery.logChunkSize must be positive")
+	}
+	if tokenSafety := profile.Discovery.TokenSafety; tokenSafety.Enabled &&
+		(tokenSafety.ProbeShare <= 0 || tokenSafety.ProbeShare >= 1) {
+		errs = append(errs, "discovery.tokenSafety.probeShare must be between 0 and 1")
+	}
+	if liquidity := profile.Discovery.Liquidity; liquidity.Enabled && len(liquidity.QuoteAssets) == 0 {
+		errs = append(errs, "discovery.liquidity.quoteAssets is required")
+	}
+	if portfolio := profile.Portfolio; portfolio.Enabled {
+		if portfolio.Wallet != "" && !common.IsHexAddress(portfolio.Wallet) {
+			errs = append(errs, "portfolio.wallet is not a valid address")
+		}
+		if portfolio.RefreshInterval <= 0 {
+			errs = append(errs, "portfolio.refreshInterval must be positive")
+		}
+		if portfolio.MaxShare <= 0 || portfolio.MaxShare > 1 {
+			errs = append(errs, "portfolio.maxShare must be between 0 and 1")
+		}
+		if portfolio.RebalanceShare < 0 || portfolio.RebalanceShare >= 1 {
+			errs = append(errs, "portfolio.rebalanceShare must be between 0 and 1")
+		}
+	}
+
+	if risk := profile.Risk; risk.Enabled {
+		if len(risk.MaxNotional) == 0 || len(risk.MaxDailyLoss) == 0 {
+			errs = append(errs, "risk.maxNotional & risk.maxDailyLoss must limit the traded tokens")
+		}
+		for assetID, limit := range risk.MaxNotional {
+			if limit <= 0 {
+				errs = append(errs, fmt.Sprintf("risk.maxNotional of %s must be positive", assetID))
+			}
+		}
+		for assetID, limit := range risk.MaxDailyLoss {
+			if limit <= 0 {
+				errs = append(errs, fmt.Sprintf("risk.maxDailyLoss of %s must be positive", assetID))
+			}
+		}
+		if risk.MaxTradesPerMinute < 0 {
+			errs = append(errs, "risk.maxTradesPerMinute can't be negative")
+		}
+		if risk.FailureCooldown < 0 {
+			errs = append(errs, "risk.failureCooldown can't be negative")
+		}
+	}
+
+	if profile.Paper.Latency < 0 || profile.Paper.SummaryInterval < 0 {
+		errs = append(errs, "paper.latency & paper.summaryInterval can't be negative")
+	}
+
+	if wallet := profile.Wallet; wallet.Public && profile.Relay.URL == "" {
+		if wallet.PrivateKey == "" {
+			errs = append(errs, "wallet.public requires wallet.privateKey")
+		}
+		if profile.Contracts.ArbitrageExecutor == "" {
+			errs = append(errs, "wallet.public requires contracts.arbitrageExecutor")
+		}
+	}
+
+	if relay := profile.Relay; relay.URL != "" {
+		if profile.Wallet.PrivateKey == "" || relay.AuthKey == "" {
+			errs = append(errs, "wallet.privateKey & relay.authKey are required")
+		}
+		if profile.Contracts.ArbitrageExecutor == "" {
+			errs = append(errs, "relay requires contracts.arbitrageExecutor")
+		}
+		if relay.Blocks <= 0 {
+			errs = append(errs, "relay.blocks must be positive")
+		}
+		if relay.TipShare < 0 || relay.TipShare >= 1 {
+			errs = append(errs, "relay.tipShare must be between 0 and 1")
+		}
+		if uint64(relay.Blocks) >= profile.Nonces.StuckBlocks {
+			errs = append(errs, "nonces.stuckBlocks must exceed relay.blocks (a bundle is released once its targets are mined)")
+		}
+	}
+
+	if mempool := profile.Mempool; mempool.Enabled {
+		if mempool.WsURL == "" {
+			errs = append(errs, "mempool.wsUrl is required")
+		}
+		if profile.Contracts.PancakeswapRouter == "" {
+			errs = append(errs, "mempool requires contracts.pancakeswapRouter")
+		}
+		if mempool.ReservesMaxAge <= 0 || mempool.Candidates <= 0 || mempool.GasLimit == 0 {
+			errs = append(errs, "mempool.reservesMaxAge, mempool.candidates & mempool.gasLimit must be positive")
+		}
+	}
+
+	if nonces := profile.Nonces; nonces.StuckBlocks == 0 || nonces.CheckInterval <= 0 {
+		errs = append(errs, "nonces.stuckBlocks & nonces.checkInterval must be positive")
+	} else if nonces.FeeBump < 0.1 || nonces.MaxReplacements < 0 {
+		errs = append(errs, "nonces.feeBump must be 0.1 at least & nonces.maxReplacements can't be negative")
+	}
+
+	if cexDex := profile.CexDex; cexDex.Exchange != "" {
+		if _, ok := c.Exchanges[cexDex.Exchange]; !ok {
+			errs = append(errs, fmt.Sprintf("cexDex.exchange %s isn't configured in exchanges", cexDex.Exchange))
+		}
+		if len(cexDex.Sizes) == 0 || slices.ContainsFunc(cexDex.Sizes, func(size float64) bool { return size <= 0 }) {
+			errs = append(errs, "cexDex.sizes must be positive")
+		}
+	}
+
+	if spatial := c.Spatial; len(spatial.Exchanges) > 0 {
+		if len(spatial.Exchanges) < 2 {
+			errs = append(errs, "spatial.exchanges needs 2 exchanges at least")
+		}
+		for _, exchange := range spatial.Exchanges {
+			if _, ok := c.Exchanges[exchange]; !ok {
+				errs = append(errs, fmt.Sprintf("spatial.exchanges: %s isn't configured in exchanges", exchange))
+			}
+		}
+		if spatial.Interval <= 0 {
+			errs = append(errs, "spatial.interval must be positive")
+		}
+	}
+
+	if len(errs) > 0 {
+		slices.Sort(errs)
+		return fmt.Errorf("invalid config for network %s: %s", c.Network, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
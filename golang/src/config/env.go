@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnvOverrides ... overrides any key of the config with PREFIX_<PATH> environment variables,
+// f.e. ARB_NETWORKS_BSC_RPCURLS=https://a,https://b or ARB_LOG_LEVEL=debug
+func applyEnvOverrides(config *Config, prefix string, environ []string) error {
+	var fields = make(map[string]reflect.Value)
+	collectFields(reflect.ValueOf(config).Elem(), prefix, fields)
+
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		field, ok := fields[key]
+
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// collectFields ... maps the environment variable name of every leaf key to its field
+func collectFields(value reflect.Value, name string, fields map[string]reflect.Value) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := range value.NumField() {
+			var tag = strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+
+			if tag == "" || tag == "-" {
+				continue
+			}
+			collectFields(value.Field(i), name+"_"+envName(tag), fields)
+		}
+	case reflect.Map:
+		// only the entries defined in the file can be overridden
+		for _, key := range value.MapKeys() {
+			collectFields(value.MapIndex(key), name+"_"+envName(key.String()), fields)
+		}
+	case reflect.Pointer:
+		if !value.IsNil() {
+			collectFields(value.Elem(), name, fields)
+		}
+	default:
+		// map values of non-pointer types can't be set in place
+		if value.CanSet() {
+			fields[name] = value
+		}
+	}
+}
+
+// envName ... converts a key to its environment variable form (bsc-testnet -> BSC_TESTNET)
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// setField ... parses the value according to the field type
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err == nil {
+			field.SetInt(int64(duration))
+		}
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(number)
+	case reflect.Uint64:
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(number)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(number)
+	case reflect.Bool:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolean)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		var items = strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
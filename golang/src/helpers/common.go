@@ -1,7 +1,6 @@
 package helpers
 
 import (
-	"fmt"
 	"os"
 )
 
@@ -19,12 +18,6 @@ func PanicBatch(errs ...error) {
 	}
 }
 
-func VerboseLog(verbose bool, message string) {
-	if verbose {
-		fmt.Println(message)
-	}
-}
-
 func Batch[T any](arr []T, batchSize int) [][]T {
 	var batches [][]T
 
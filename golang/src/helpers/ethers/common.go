@@ -5,21 +5,9 @@ import (
 	sp "arbitrage-bot/services/sourceprovider"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"math"
-	"math/big"
 	"sync"
 )
 
-func WeiToEther(wei *big.Int, decimals int) float64 {
-	f := new(big.Float).SetInt(wei)
-	ethValue, _ := f.Quo(f, big.NewFloat(math.Pow10(decimals))).Float64()
-	return ethValue
-}
-
-func EtherToWei(eth float64, decimals int) *big.Int {
-	return big.NewInt(int64(eth * math.Pow(10, float64(decimals))))
-}
-
 // CallContractMethod ... call contract method asynchronously
 func CallContractMethod(
 	wg *sync.WaitGroup,
@@ -77,27 +65,3 @@ func GetTradePathsFromSurfaceResult(surfaceResult models.TriangularArbSurfaceRes
 
 	return GetTradePaths(symbols, tradeDirections)
 }
-
-func GetPancakeSwapAddresses(networkName string) map[string]string {
-	if networkName == "bsc" {
-		return map[string]string{
-			"factory": "0xcA143Ce32Fe78f1f7019d7d551a6402fC5350c73",
-			"router":  "0x10ED43C718714eb63d5aA57B78B54704E256024E",
-		}
-	} else if networkName == "bsc-testnet" {
-		return map[string]string{
-			"factory": "0xB7926C0430Afb07AA7DEfDE6DA862aE0Bde767bc",
-			"router":  "0x9Ac64Cc6e4415144C455BD8E4837Fea55603e5c3",
-		}
-	}
-	return map[string]string{}
-}
-
-func GetArbitrageExecutorAddresses(networkName string) map[string]common.Address {
-	if networkName == "bsc-testnet" {
-		return map[string]common.Address{
-			"v1": common.HexToAddress("0x1959b2a1776dee3daef75ae6b545f9c8d6b0df6b"),
-		}
-	}
-	return map[string]common.Address{}
-}
@@ -1,63 +1,100 @@
 package io
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
-
-	"arbitrage-bot/helpers"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// closeTimeout ... wait for the close frame of the server before the connection is dropped
+const closeTimeout = 3 * time.Second
+
+// WebSocket errors, the underlying error is wrapped
+var (
+	ErrWebSocketUnavailable = errors.New("websocket unavailable") // the connection couldn't be opened
+	ErrWebSocketClosed      = errors.New("websocket closed")      // the connection was lost or stopped
+)
+
 type WebSocketClient struct {
 	Endpoint string
 	Conn     *websocket.Conn
 	Done     chan struct{}
 	StopOnce sync.Once
+	stopped  chan struct{} // closed when the read loop returns
 }
 
-func NewWebSocketClient(endpoint string) *WebSocketClient {
+// NewWebSocketClient ... opens a connection to the endpoint
+func NewWebSocketClient(endpoint string) (*WebSocketClient, error) {
 	conn, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
-	helpers.Panic(err)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrWebSocketUnavailable, endpoint, err)
+	}
 
 	return &WebSocketClient{
 		Endpoint: endpoint,
 		Conn:     conn,
 		Done:     make(chan struct{}),
-	}
+		stopped:  make(chan struct{}),
+	}, nil
 }
 
-func (wsc *WebSocketClient) WriteJSON(data interface{}) {
-	err := wsc.Conn.WriteJSON(data)
-	helpers.Panic(err)
+// WriteJSON ... sends a JSON message
+func (wsc *WebSocketClient) WriteJSON(data interface{}) error {
+	if err := wsc.Conn.WriteJSON(data); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrWebSocketClosed, wsc.Endpoint, err)
+	}
+
+	return nil
 }
 
-func (wsc *WebSocketClient) Start(streamHandler func(data *[]byte)) {
+// Start ... reads the messages in a goroutine & calls the streamHandler with every one of them, the errorHandler is
+// called once if the connection is lost (not after Stop)
+func (wsc *WebSocketClient) Start(streamHandler func(data *[]byte), errorHandler func(err error)) {
 	// Start a goroutine to read messages from the WebSocket, and call the streamHandler function
 	go func() {
+		defer close(wsc.stopped)
 		defer wsc.Conn.Close()
 		for {
-			select {
-			case <-wsc.Done:
-				return
-			default:
-				_, dataByte, err := wsc.Conn.ReadMessage()
-				helpers.Panic(err)
-				streamHandler(&dataByte)
+			_, dataByte, err := wsc.Conn.ReadMessage()
+
+			if err != nil {
+				select {
+				case <-wsc.Done:
+					// the read fails once the server answers the close frame of Stop
+					return
+				default:
+					errorHandler(fmt.Errorf("%w: %s: %w", ErrWebSocketClosed, wsc.Endpoint, err))
+					return
+				}
 			}
+			streamHandler(&dataByte)
 		}
 	}()
 }
 
+// Stop ... sends a close frame & waits for the read loop to return, the connection is dropped if the server doesn't
+// answer in time
 func (wsc *WebSocketClient) Stop() {
 	wsc.StopOnce.Do(func() {
 		close(wsc.Done)
-		err := wsc.Conn.WriteMessage(
+		err := wsc.Conn.WriteControl(
 			websocket.CloseMessage,
 			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(closeTimeout),
 		)
 		if err != nil {
-			log.Println("Error during closing websocket:", err)
+			slog.Warn("Error during closing websocket", slog.String("endpoint", wsc.Endpoint), slog.Any("error", err))
+		}
+
+		select {
+		case <-wsc.stopped:
+		case <-time.After(closeTimeout):
+			wsc.Conn.Close()
 		}
 	})
 }
@@ -30,6 +30,23 @@ func WriteJSONFile(filePath string, v any) error {
 	return err
 }
 
+// WriteJSONFileAtomic ... writes to a temporary file & renames it, so an interrupted write never leaves a partial file
+func WriteJSONFileAtomic(filePath string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "\t")
+
+	if err != nil {
+		return err
+	}
+
+	var tempPath = filePath + ".tmp"
+
+	if err = os.WriteFile(tempPath, jsonData, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, filePath)
+}
+
 func ReadJSONABIFile(filePath string) (abi.ABI, error) {
 	jsonData, err := os.ReadFile(filePath)
 
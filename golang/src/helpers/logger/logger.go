@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/models"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup ... configures the default logger from the log config (level: debug, info, warn, error; format: text, json)
+func Setup(logConfig config.LogConfig) {
+	var options = &slog.HandlerOptions{Level: parseLevel(logConfig.Level)}
+	var handler slog.Handler
+
+	if strings.ToLower(logConfig.Format) == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// parseLevel ... converts a level name to slog.Level, defaults to info
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithProvider ... returns a logger carrying the network and provider fields
+func WithProvider(provider string) *slog.Logger {
+	return slog.Default().With(
+		slog.String("network", config.Get().Network),
+		slog.String("provider", provider),
+	)
+}
+
+// WithOpportunity ... returns a logger carrying the contextual fields of a triangular opportunity
+func WithOpportunity(provider string, surfaceResult models.TriangularArbSurfaceResult) *slog.Logger {
+	return WithProvider(provider).With(
+		slog.String("symbol", surfaceResult.Swap1),
+		slog.String("triangleId", surfaceResult.TriangleID()),
+		slog.Uint64("blockNumber", surfaceResult.BlockNumber),
+	)
+}
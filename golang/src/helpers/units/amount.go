@@ -0,0 +1,260 @@
+package units
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrDecimalsMismatch ... the amounts have different decimals, a sign they're amounts of different tokens
+var ErrDecimalsMismatch = errors.New("amounts with different decimals")
+
+// Amount ... exact token amount: an integer number of base units (wei) & the decimals of the token, floats are only
+// derived from it for display & ranking
+type Amount struct {
+	wei      *big.Int
+	decimals int
+}
+
+// NewAmount ... creates an amount from base units (copied)
+func NewAmount(wei *big.Int, decimals int) Amount {
+	if wei == nil {
+		return Amount{wei: new(big.Int), decimals: decimals}
+	}
+
+	return Amount{wei: new(big.Int).Set(wei), decimals: decimals}
+}
+
+// Zero ... returns a zero amount of a token
+func Zero(decimals int) Amount {
+	return Amount{wei: new(big.Int), decimals: decimals}
+}
+
+// ParseAmount ... parses a decimal string (f.e. "1.5") exactly, digits beyond the token decimals are truncated
+func ParseAmount(value string, decimals int) (Amount, error) {
+	var text = strings.TrimSpace(value)
+	var negative = strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	var integer, fraction, _ = strings.Cut(text, ".")
+
+	if integer == "" && fraction == "" {
+		return Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(fraction) > decimals {
+		fraction = fraction[:decimals]
+	}
+
+	var digits = integer + fraction + strings.Repeat("0", decimals-len(fraction))
+	var wei, ok = new(big.Int).SetString(digits, 10)
+
+	if !ok || strings.ContainsAny(digits, "+-") {
+		return Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		wei.Neg(wei)
+	}
+
+	return Amount{wei: wei, decimals: decimals}, nil
+}
+
+// FromFloat ... converts a float (f.e. a configured amount) using its shortest decimal representation, so 0.1 gives
+// exactly 10^(decimals-1) base units & large amounts don't overflow
+func FromFloat(value float64, decimals int) Amount {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Zero(decimals)
+	}
+
+	var amount, err = ParseAmount(strconv.FormatFloat(value, 'f', -1, 64), decimals)
+
+	if err != nil {
+		return Zero(decimals)
+	}
+
+	return amount
+}
+
+// Wei ... returns the base units (a copy, safe to modify)
+func (a Amount) Wei() *big.Int {
+	if a.wei == nil {
+		return new(big.Int)
+	}
+
+	return new(big.Int).Set(a.wei)
+}
+
+// Decimals ... returns the decimals of the token
+func (a Amount) Decimals() int {
+	return a.decimals
+}
+
+// IsZero ... whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a.wei == nil || a.wei.Sign() == 0
+}
+
+// Sign ... returns -1, 0 or 1
+func (a Amount) Sign() int {
+	if a.wei == nil {
+		return 0
+	}
+
+	return a.wei.Sign()
+}
+
+// Cmp ... compares two amounts of the same token, panics on different decimals (see CmpE for external amounts)
+func (a Amount) Cmp(b Amount) int {
+	a.mustMatch(b)
+
+	return a.Wei().Cmp(b.Wei())
+}
+
+// CmpE ... compares two amounts, ErrDecimalsMismatch if they have different decimals
+func (a Amount) CmpE(b Amount) (int, error) {
+	if err := a.match(b); err != nil {
+		return 0, err
+	}
+
+	return a.Wei().Cmp(b.Wei()), nil
+}
+
+// Add ... returns a + b (amounts of the same token), panics on different decimals (see AddE for external amounts)
+func (a Amount) Add(b Amount) Amount {
+	a.mustMatch(b)
+
+	return Amount{wei: new(big.Int).Add(a.Wei(), b.Wei()), decimals: a.decimals}
+}
+
+// AddE ... returns a + b, ErrDecimalsMismatch if they have different decimals
+func (a Amount) AddE(b Amount) (Amount, error) {
+	if err := a.match(b); err != nil {
+		return Amount{}, err
+	}
+
+	return Amount{wei: new(big.Int).Add(a.Wei(), b.Wei()), decimals: a.decimals}, nil
+}
+
+// Sub ... returns a - b (amounts of the same token), panics on different decimals (see SubE for external amounts)
+func (a Amount) Sub(b Amount) Amount {
+	a.mustMatch(b)
+
+	return Amount{wei: new(big.Int).Sub(a.Wei(), b.Wei()), decimals: a.decimals}
+}
+
+// SubE ... returns a - b, ErrDecimalsMismatch if they have different decimals
+func (a Amount) SubE(b Amount) (Amount, error) {
+	if err := a.match(b); err != nil {
+		return Amount{}, err
+	}
+
+	return Amount{wei: new(big.Int).Sub(a.Wei(), b.Wei()), decimals: a.decimals}, nil
+}
+
+// MulRate ... returns the amount multiplied by a rate (f.e. 1 - fee), rounded down to a base unit. The rate is taken
+// at its shortest decimal representation so 0.9975 applies exactly
+func (a Amount) MulRate(rate float64) Amount {
+	var exact, ok = new(big.Rat).SetString(strconv.FormatFloat(rate, 'g', -1, 64))
+
+	if !ok {
+		return Zero(a.decimals)
+	}
+
+	var product = new(big.Int).Mul(a.Wei(), exact.Num())
+
+	return Amount{wei: product.Div(product, exact.Denom()), decimals: a.decimals}
+}
+
+// Ratio ... returns a / b as a float (f.e. a profit percentage), 0 if b is zero
+func (a Amount) Ratio(b Amount) float64 {
+	if b.IsZero() {
+		return 0
+	}
+
+	// both sides in the same scale so tokens with different decimals compare by value
+	var numerator = new(big.Int).Mul(a.Wei(), pow10(b.decimals))
+	var denominator = new(big.Int).Mul(b.Wei(), pow10(a.decimals))
+	var ratio, _ = new(big.Rat).SetFrac(numerator, denominator).Float64()
+
+	return ratio
+}
+
+// Float64 ... returns the amount in token units (display & ranking only)
+func (a Amount) Float64() float64 {
+	var value, _ = new(big.Rat).SetFrac(a.Wei(), pow10(a.decimals)).Float64()
+
+	return value
+}
+
+// String ... returns the exact amount in token units (f.e. "1.5")
+func (a Amount) String() string {
+	var wei = a.Wei()
+	var negative = wei.Sign() < 0
+	var digits = wei.Abs(wei).String()
+
+	if a.decimals > 0 {
+		if len(digits) <= a.decimals {
+			digits = strings.Repeat("0", a.decimals-len(digits)+1) + digits
+		}
+		var point = len(digits) - a.decimals
+		digits = strings.TrimRight(digits[:point]+"."+digits[point:], "0")
+		digits = strings.TrimSuffix(digits, ".")
+	}
+	if negative {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// amountJSON ... JSON representation of an amount (the base units as a string keep their precision)
+type amountJSON struct {
+	Wei      string `json:"wei"`
+	Decimals int    `json:"decimals"`
+}
+
+// MarshalJSON ... encodes the base units & decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(amountJSON{Wei: a.Wei().String(), Decimals: a.decimals})
+}
+
+// UnmarshalJSON ... decodes the base units & decimals
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var value amountJSON
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	var wei, ok = new(big.Int).SetString(value.Wei, 10)
+
+	if !ok {
+		return fmt.Errorf("invalid amount %q", value.Wei)
+	}
+	a.wei, a.decimals = wei, value.Decimals
+
+	return nil
+}
+
+// match ... returns ErrDecimalsMismatch if the amounts have different decimals
+func (a Amount) match(b Amount) error {
+	if a.decimals != b.decimals {
+		return fmt.Errorf("%w: %d & %d", ErrDecimalsMismatch, a.decimals, b.decimals)
+	}
+
+	return nil
+}
+
+// mustMatch ... panics if the amounts have different decimals, only for amounts derived from each other
+func (a Amount) mustMatch(b Amount) {
+	if err := a.match(b); err != nil {
+		panic(err)
+	}
+}
+
+// pow10 ... returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,157 @@
+package units
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		decimals int
+		wei      string
+		invalid  bool
+	}{
+		{value: "1.5", decimals: 18, wei: "1500000000000000000"},
+		{value: "100", decimals: 6, wei: "100000000"},
+		{value: ".25", decimals: 2, wei: "25"},
+		{value: "7.", decimals: 2, wei: "700"},
+		{value: "-0.001", decimals: 3, wei: "-1"},
+		{value: "+2", decimals: 0, wei: "2"},
+		{value: "0.123456789", decimals: 4, wei: "1234"}, // truncated
+		{value: " 12345678901234567890.1 ", decimals: 18, wei: "12345678901234567890100000000000000000"},
+		{value: "", decimals: 18, invalid: true},
+		{value: ".", decimals: 18, invalid: true},
+		{value: "1.2.3", decimals: 18, invalid: true},
+		{value: "1e18", decimals: 18, invalid: true},
+		{value: "--1", decimals: 18, invalid: true},
+		{value: "1.-5", decimals: 18, invalid: true},
+	} {
+		amount, err := ParseAmount(test.value, test.decimals)
+
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %s, want an error", test.value, amount.Wei())
+			}
+			continue
+		}
+		if err != nil || amount.Wei().String() != test.wei || amount.Decimals() != test.decimals {
+			t.Errorf("ParseAmount(%q, %d) = %s (%v), want %s", test.value, test.decimals, amount.Wei(), err, test.wei)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	for _, test := range []struct {
+		wei      int64
+		decimals int
+		want     string
+	}{
+		{wei: 1500000000000000000, decimals: 18, want: "1.5"},
+		{wei: 1, decimals: 18, want: "0.000000000000000001"},
+		{wei: 0, decimals: 18, want: "0"},
+		{wei: 100, decimals: 2, want: "1"},
+		{wei: -25, decimals: 2, want: "-0.25"},
+		{wei: 42, decimals: 0, want: "42"},
+	} {
+		if got := NewAmount(big.NewInt(test.wei), test.decimals).String(); got != test.want {
+			t.Errorf("String(%d, %d) = %s, want %s", test.wei, test.decimals, got, test.want)
+		}
+	}
+
+	// exact beyond the float64 precision
+	if amount, _ := ParseAmount("123456789.123456789123456789", 18); amount.String() != "123456789.123456789123456789" {
+		t.Errorf("String = %s, want the parsed value", amount)
+	}
+}
+
+func TestAmountRatio(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		aDec int
+		bDec int
+		want float64
+	}{
+		{a: "1.5", b: "1", aDec: 18, bDec: 18, want: 1.5},
+		{a: "1", b: "4", aDec: 6, bDec: 18, want: 0.25}, // compared by value
+		{a: "-0.1", b: "1", aDec: 18, bDec: 18, want: -0.1},
+		{a: "1", b: "0", aDec: 18, bDec: 18, want: 0},
+	} {
+		var a, _ = ParseAmount(test.a, test.aDec)
+		var b, _ = ParseAmount(test.b, test.bDec)
+
+		if got := a.Ratio(b); got != test.want {
+			t.Errorf("%s.Ratio(%s) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestAmountMulRate(t *testing.T) {
+	for _, test := range []struct {
+		wei  string
+		rate float64
+		want string
+	}{
+		{wei: "1000000000000000000", rate: 0.9975, want: "997500000000000000"},
+		{wei: "1000", rate: 0.9975, want: "997"}, // rounded down
+		{wei: "100000000000000000000000000", rate: 0.5, want: "50000000000000000000000000"},
+		{wei: "1000", rate: 0, want: "0"},
+		{wei: "1000", rate: 1e-3, want: "1"},
+		{wei: "-1000", rate: 0.9975, want: "-998"}, // rounded down
+	} {
+		var wei, _ = new(big.Int).SetString(test.wei, 10)
+
+		if got := NewAmount(wei, 18).MulRate(test.rate); got.Wei().String() != test.want || got.Decimals() != 18 {
+			t.Errorf("MulRate(%s, %v) = %s, want %s", test.wei, test.rate, got.Wei(), test.want)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var amount, _ = ParseAmount("98765432109876543210.000000000000000001", 18)
+	data, err := json.Marshal(amount)
+
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != `{"wei":"98765432109876543210000000000000000001","decimals":18}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	var decoded Amount
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Cmp(amount) != 0 || decoded.Decimals() != 18 {
+		t.Errorf("Unmarshal = %s, want %s", decoded, amount)
+	}
+	if err := json.Unmarshal([]byte(`{"wei":"1.5","decimals":18}`), &decoded); err == nil {
+		t.Error("Unmarshal of a fractional wei succeeded, want an error")
+	}
+}
+
+func TestAmountDecimalsMismatch(t *testing.T) {
+	var a, b = FromFloat(1, 18), FromFloat(1, 6)
+
+	if _, err := a.CmpE(b); !errors.Is(err, ErrDecimalsMismatch) {
+		t.Errorf("CmpE = %v, want ErrDecimalsMismatch", err)
+	}
+	if _, err := a.AddE(b); !errors.Is(err, ErrDecimalsMismatch) {
+		t.Errorf("AddE = %v, want ErrDecimalsMismatch", err)
+	}
+	if _, err := a.SubE(b); !errors.Is(err, ErrDecimalsMismatch) {
+		t.Errorf("SubE = %v, want ErrDecimalsMismatch", err)
+	}
+	if sum, err := a.AddE(FromFloat(0.5, 18)); err != nil || sum.String() != "1.5" {
+		t.Errorf("AddE = %s (%v), want 1.5", sum, err)
+	}
+	if difference, err := a.SubE(FromFloat(0.5, 18)); err != nil || difference.String() != "0.5" {
+		t.Errorf("SubE = %s (%v), want 0.5", difference, err)
+	}
+	if cmp, err := a.CmpE(FromFloat(2, 18)); err != nil || cmp != -1 {
+		t.Errorf("CmpE = %d (%v), want -1", cmp, err)
+	}
+}
@@ -1,26 +1,59 @@
 package main
 
 import (
-	"arbitrage-bot/helpers"
+	"arbitrage-bot/config"
+	ethersHelper "arbitrage-bot/helpers/ethers"
 	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/logger"
 	"arbitrage-bot/models"
 	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/execution"
+	"arbitrage-bot/services/mempool"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/papertrading"
+	"arbitrage-bot/services/portfolio"
+	"arbitrage-bot/services/risk"
+	"arbitrage-bot/services/rpcpool"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/dex"
+	"arbitrage-bot/services/tokenregistry"
 	"arbitrage-bot/services/web3"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/ethereum/go-ethereum/core/types"
 	_ "github.com/joho/godotenv/autoload"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
-func step1(sourceProvider sourceprovider.ISourceProvider) [][3]*sourceprovider.Symbol {
+func step1(sourceProvider sourceprovider.ISourceProvider) ([][3]*sourceprovider.Symbol, error) {
 	// get cached arbitrage pairs (need to run command to fetch if not exists)
 	arbitragePairPath := sourceProvider.GetArbitragePairCachePath()
 	var symbols [][3]*sourceprovider.Symbol
-	err := jsonHelper.ReadJSONFile(arbitragePairPath, &symbols)
-	helpers.Panic(err)
 
-	return symbols
+	if err := jsonHelper.ReadJSONFile(arbitragePairPath, &symbols); err != nil {
+		return nil, fmt.Errorf("error reading the arbitrage pairs %s: %w", arbitragePairPath, err)
+	}
+
+	// caches written before the token registry don't carry the canonical asset ids & the Uniswap names written before
+	// the fee tier was part of them are migrated
+	var chainID = config.Get().ActiveNetwork().ChainID
+
+	for _, triangularPair := range symbols {
+		sourceprovider.MigrateSymbolNames(triangularPair[:]...)
+
+		for _, symbol := range triangularPair {
+			tokenregistry.Get().AnnotateDEXSymbol(chainID, symbol)
+		}
+	}
+
+	return symbols, nil
 
 	//if !force && fileHelper.PathExists(arbitragePairPath) {
 	//
@@ -39,15 +72,158 @@ func step1(sourceProvider sourceprovider.ISourceProvider) [][3]*sourceprovider.S
 	//return triangularPairs
 }
 
+// newExecutor ... returns the paper-trading executor (the fills are quoted again after a latency & kept in a virtual
+// ledger, nothing is sent on-chain), the bundle executor if a private relay is configured or the flash swap executor
+// (sending to the public mempool with wallet.public, dry-running otherwise). Without an executor contract to dry-run
+// the executions are paper traded
+func newExecutor(
+	ctx context.Context, paperTrading bool, sourceProvider dex.ISourceProvider, symbols []*sourceprovider.Symbol,
+) (execution.Executor, error) {
+	var network = config.Get().ActiveNetwork()
+
+	// the relay & the public mempool require the contract (validated with the configuration)
+	if !paperTrading && network.Contracts.ArbitrageExecutor == "" {
+		slog.Warn("No arbitrage executor contract configured (contracts.arbitrageExecutor), paper trading the executions")
+		paperTrading = true
+	}
+
+	if !paperTrading {
+		if relay := network.Relay; relay.URL != "" {
+			slog.Info("Submitting the executions as bundles", slog.String("relay", relay.URL))
+			return execution.NewBundleExecutor(symbols, sourceProvider.Web3Service())
+		}
+
+		if network.Wallet.Public {
+			slog.Warn("No private relay configured, sending the executions to the public mempool")
+		} else {
+			slog.Warn("No private relay configured, the executions are only dry-run")
+		}
+
+		return execution.NewFlashSwapExecutor(symbols)
+	}
+
+	var paperTradingExecutor = papertrading.NewPaperTradingExecutor(sourceProvider.Web3Service())
+	go paperTradingExecutor.ReportSummaries(ctx)
+	slog.Info("Paper trading enabled", slog.String("ledger", papertrading.LedgerPath()))
+
+	return execution.NewPaperExecutor(paperTradingExecutor), nil
+}
+
+// logOpportunityError ... logs the error of an opportunity, a failing quote is expected (f.e. the pools moved since
+// the surface rate) while the other errors (f.e. an unavailable RPC or an unknown symbol) need attention
+func logOpportunityError(log *slog.Logger, message string, err error) {
+	if errors.Is(err, web3.ErrQuoteFailed) {
+		log.Debug(message, slog.Any("error", err))
+	} else {
+		log.Warn(message, slog.Any("error", err))
+	}
+}
+
+// waitFor ... waits for the services to return, gives up once the context is done
+func waitFor(ctx context.Context, services *sync.WaitGroup) error {
+	var done = make(chan struct{})
+
+	go func() {
+		services.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// prepareAndExecute ... prepares a plan & executes it with the executor
+func prepareAndExecute(executor execution.Executor, plan *execution.Plan) (*execution.Status, error) {
+	if err := executor.Prepare(plan); err != nil {
+		return nil, err
+	}
+
+	return executor.Execute(plan)
+}
+
+// newOutcome ... returns the realized outcome of an execution for the risk manager, only a succeeded status traded
+// (a failed, simulated or missing one didn't)
+func newOutcome(status *execution.Status, err error) *risk.Outcome {
+	var outcome = &risk.Outcome{Err: err}
+
+	if status == nil {
+		return outcome
+	}
+	if status.State == execution.StateFailed && outcome.Err == nil {
+		outcome.Err = errors.New(status.Error)
+	}
+	if status.State == execution.StateSucceeded {
+		outcome.ProfitLoss = status.ProfitLoss
+	}
+	outcome.GasCost = status.GasCost
+
+	return outcome
+}
+
+// shutdownTimeout ... wait for the executions in flight & the services on shutdown
+const shutdownTimeout = 30 * time.Second
+
 // CEX/DEX arbitrage opportunities
 func main() {
+	var paperTrading = flag.Bool("paper", false, "simulate the executions with a virtual ledger instead of sending them")
+	flag.Parse()
+
+	// load & validate the configuration before starting any service
+	if err := config.Init(); err != nil {
+		slog.Error("Error loading the configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	var cfg = config.Get()
+	var network = cfg.ActiveNetwork()
+	logger.Setup(cfg.Log)
+
+	if err := tokenregistry.Init(); err != nil {
+		slog.Error("Error loading the token registry", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if err := rpcpool.Init(); err != nil {
+		slog.Error("Error creating the rpc pool", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// SIGINT & SIGTERM stop the services, the evaluation & the execution in flight finish first
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var services sync.WaitGroup
+
 	//sourceProvider := dex.NewUniswapSourceProviderService()
-	sourceProvider := dex.NewPancakeswapSourceProvider()
+	sourceProvider, err := dex.NewPancakeswapSourceProvider()
+
+	if err != nil {
+		slog.Error("Error creating the source provider", slog.Any("error", err))
+		os.Exit(1)
+	}
 	arbitrageCalculator := arbitrage.NewAmmArbitrageCalculator(sourceProvider)
-	arbitrageExecutor := web3.NewArbitrageExecutorWeb3Service()
+
+	// expose the Prometheus metrics endpoint (optional)
+	if cfg.Metrics.Address != "" {
+		services.Add(1)
+		go func() {
+			defer services.Done()
+
+			if err := metrics.Serve(ctx, cfg.Metrics.Address); err != nil {
+				slog.Error("Error serving the metrics", slog.String("address", cfg.Metrics.Address),
+					slog.Any("error", err))
+			}
+		}()
+	}
 
 	// for networks like base, celo, we'll run a command to obtain the triangular pairs, then get cache from step1
-	var triangularPairBatches = step1(sourceProvider)
+	triangularPairBatches, err := step1(sourceProvider)
+
+	if err != nil {
+		slog.Error("Error loading the triangular pairs, run the pool fetch & the discovery first", slog.Any("error", err))
+		os.Exit(1)
+	}
 	var symbols []*sourceprovider.Symbol
 
 	for _, pair := range triangularPairBatches {
@@ -55,46 +231,261 @@ func main() {
 			symbols = append(symbols, symbol)
 		}
 	}
+	// the main loop only depends on the Executor interface
+	executor, err := newExecutor(ctx, *paperTrading, sourceProvider, symbols)
+
+	if err != nil {
+		slog.Error("Error creating the executor", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// balances of the wallet, the executor contract & the CEX accounts, capping the starting amounts if enabled
+	var portfolioService = portfolio.NewPortfolioService()
+	portfolioService.TrackSymbols(symbols)
+
+	if err := portfolioService.Refresh(); err != nil {
+		slog.Warn("Error reading the balances", slog.Any("error", err))
+	}
+
+	// limits checked before every execution, the kill switch halts the execution while the evaluation keeps running
+	var riskManager = risk.NewRiskManager()
+
+	// pending swaps of the router re-evaluating the triangles of the pools they trade (optional), the candidates go
+	// through the same evaluation as the opportunities of a cycle
+	var candidates <-chan mempool.Candidate
+
+	// a back-run must land right after its pending swap, only a bundle can order them
+	if network.Mempool.Enabled && executor.GetName() != execution.BundleExecutorName {
+		slog.Warn("The back-runs are only sent as bundles, the mempool isn't watched",
+			slog.String("executor", executor.GetName()))
+	} else if network.Mempool.Enabled {
+		watcher, err := mempool.NewMempoolWatcher(arbitrageCalculator, sourceProvider.Web3Service(), triangularPairBatches)
+
+		if err != nil {
+			slog.Warn("Error creating the mempool watcher, the mempool isn't watched", slog.Any("error", err))
+		} else {
+			services.Add(1)
+			go func() {
+				defer services.Done()
+				watcher.Watch(ctx)
+			}()
+			candidates = watcher.Candidates()
+		}
+	}
+
 	var pingChannel = make(chan bool)
-	var startingAmount float64 = 5
-	var verbose = false
-	go sourceProvider.SubscribeSymbols(symbols, pingChannel, verbose)
+	var startingAmount = network.Thresholds.StartingAmount
+	var log = logger.WithProvider(sourceProvider.GetName())
+	services.Add(1)
+	go func() {
+		defer services.Done()
+		sourceProvider.SubscribeSymbols(ctx, symbols, pingChannel)
+	}()
+
+	// plan id -> opportunity of the submitted plans, their outcome is recorded once settled
+	var submitted = make(map[string]risk.Opportunity)
 
-	fmt.Println("Subscribed to symbols, waiting for data...")
-	time.Sleep(3 * time.Second)
-	fmt.Println("Starting the arbitrage calculation...")
+	// recordSettlements ... records the outcome of the submitted plans settled since the last cycle
+	var recordSettlements = func() {
+		for planID, opportunity := range submitted {
+			status, ok := executor.Status(planID)
+
+			if ok && status.State == execution.StateSubmitted {
+				continue
+			}
+			delete(submitted, planID)
+
+			if ok {
+				riskManager.RecordSettlement(opportunity, *newOutcome(status, nil))
+			}
+		}
+	}
+
+	// evaluateOpportunity ... caps the starting amount by the inventory, confirms the surface result with the depth
+	// & executes it if the profit is within the configured band & the risk limits allow it
+	var evaluateOpportunity = func(
+		surfaceRate models.TriangularArbSurfaceResult,
+		calcDepth func(models.TriangularArbSurfaceResult) (models.TriangularArbDepthResult, error),
+		backRun *types.Transaction,
+	) {
+		var opportunityLog = logger.WithOpportunity(sourceProvider.GetName(), surfaceRate)
+
+		// the starting token is the input of the first swap, only the available inventory is traded
+		var startingToken = ethersHelper.GetTradePathsFromSurfaceResult(surfaceRate)[0].BaseAssetAddress
+		var startingAssetID = tokenregistry.Get().IDForAddress(network.ChainID, startingToken.Hex())
+		surfaceRate.StartingAmount = portfolioService.CapStartingAmount(
+			startingAssetID, surfaceRate.StartingAmount, portfolio.OnChainVenues...,
+		)
+
+		if surfaceRate.StartingAmount <= 0 {
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeSkipped)
+			opportunityLog.Debug("No inventory of the starting token", slog.String("asset", startingAssetID))
+			return
+		}
+		// a failing quote only drops the opportunity, the next ones are still evaluated
+		depthResult, err := calcDepth(surfaceRate)
+
+		if err != nil {
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeSkipped)
+			logOpportunityError(opportunityLog, "Error calculating depth", err)
+			return
+		}
+		opportunityLog.Debug(
+			"Calculated depth",
+			slog.Float64("surfaceProfitLossPerc", surfaceRate.ProfitLossPerc),
+			slog.Float64("profitLossPerc", depthResult.ProfitLossPerc),
+		)
+
+		// execute the arbitrage if the profit is within the configured band
+		if depthResult.ProfitLossPerc <= network.Thresholds.MinProfitPerc ||
+			depthResult.ProfitLossPerc >= network.Thresholds.MaxProfitPerc {
+			return
+		}
+		metrics.IncDepthConfirmations(sourceProvider.GetName())
+		var plan = execution.NewPlan(sourceProvider.GetName(), surfaceRate, depthResult)
+		plan.BackRun = backRun
+		var opportunity = risk.Opportunity{
+			TriangleID:      plan.TriangleID,
+			StartingAssetID: startingAssetID,
+			AssetIDs:        plan.AssetIDs(),
+			Notional:        plan.AmountIn,
+		}
+
+		if decision := riskManager.Check(opportunity); !decision.Allowed {
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeSkipped)
+			return
+		}
+
+		status, err := prepareAndExecute(executor, plan)
+		var attrs = []any{slog.String("executor", executor.GetName()), slog.String("planId", plan.ID)}
+
+		// the realized PnL net of gas counts against the daily loss, a submitted plan is recorded once settled & a
+		// dry-run traded nothing (it doesn't count against the trade rate either)
+		switch {
+		case err != nil:
+			riskManager.RecordExecution(opportunity, newOutcome(status, err))
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeFailure)
+			opportunityLog.Error("Error executing arbitrage", append(attrs, slog.Any("error", err))...)
+		case status.State == execution.StateSimulated:
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeSimulated)
+			opportunityLog.Info(
+				"Simulated arbitrage",
+				append(attrs,
+					slog.Float64("profitLoss", status.ProfitLoss),
+					slog.Float64("profitLossPerc", depthResult.ProfitLossPerc),
+				)...,
+			)
+		default:
+			if status.State == execution.StateSubmitted {
+				riskManager.RecordExecution(opportunity, nil)
+				submitted[plan.ID] = opportunity
+			} else {
+				riskManager.RecordExecution(opportunity, newOutcome(status, nil))
+			}
+			metrics.IncExecutions(sourceProvider.GetName(), metrics.OutcomeSuccess)
+			opportunityLog.Info(
+				"Executed arbitrage",
+				append(attrs,
+					slog.String("state", status.State),
+					slog.Float64("profitLoss", status.ProfitLoss),
+					slog.Float64("profitLossPerc", depthResult.ProfitLossPerc),
+				)...,
+			)
+		}
+	}
+
+	// evaluateCandidate ... evaluates a back-run candidate on the reserves after its pending swap
+	var evaluateCandidate = func(candidate mempool.Candidate) {
+		log.Debug("Evaluating back-run candidate", slog.String("txHash", candidate.Swap.Tx.Hash().Hex()))
+		evaluateOpportunity(candidate.SurfaceResult, candidate.CalcDepth, candidate.Swap.Tx)
+	}
+
+	log.Info("Subscribed to symbols, waiting for data...", slog.Int("symbols", len(symbols)))
+	select {
+	case <-time.After(3 * time.Second):
+	case <-ctx.Done():
+	}
+	log.Info("Starting the arbitrage calculation...")
+
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			continue
+		case candidate := <-candidates:
+			evaluateCandidate(candidate)
+			continue
+		case _, ok := <-pingChannel:
+			// the provider closes the channel once the context is done
+			if !ok {
+				continue
+			}
+		}
 
-	for range pingChannel {
 		var surfaceResults []models.TriangularArbSurfaceResult
 
 		for _, triangularPairs := range triangularPairBatches {
-			surfaceResult, _ := arbitrageCalculator.CalcTriangularArbSurfaceRate(triangularPairs, startingAmount)
+			surfaceResult, err := arbitrageCalculator.CalcTriangularArbSurfaceRate(triangularPairs, startingAmount)
 
+			// the other errors only mean no profitable direction was found
+			if errors.Is(err, sourceprovider.ErrSymbolNotFound) {
+				log.Warn("Error calculating surface rate", slog.Any("error", err))
+				continue
+			}
 			if surfaceResult.ProfitLoss > 0 {
 				surfaceResults = append(surfaceResults, surfaceResult)
 			}
 		}
+		metrics.ObserveEvaluations(sourceProvider.GetName(), len(triangularPairBatches))
+		recordSettlements()
+		metrics.IncSurfaceOpportunities(sourceProvider.GetName(), len(surfaceResults))
+
+		if err := portfolioService.RefreshIfStale(); err != nil {
+			log.Warn("Error reading the balances", slog.Any("error", err))
+		}
 
 		if len(surfaceResults) > 0 {
-			fmt.Println("Fetching depth for the surface results...")
+			log.Debug("Fetching depth for the surface results...", slog.Int("count", len(surfaceResults)))
 
 			for _, surfaceRate := range surfaceResults {
-				var depthResult = arbitrageCalculator.CalcDepthOpportunityForward(surfaceRate, verbose)
+				// no new execution once the shutdown started
+				if ctx.Err() != nil {
+					break
+				}
+				evaluateOpportunity(surfaceRate, arbitrageCalculator.CalcDepthOpportunityForward, nil)
+			}
+		}
 
-				// execute the arbitrage if the profit is between 1% and 10%
-				if depthResult.ProfitLossPerc > 0.01 && depthResult.ProfitLossPerc < 0.1 {
-					var loanAddress = arbitrageExecutor.GetLoanAddress(symbols, depthResult.TradePaths)
-					err := arbitrageExecutor.ExecuteArbitrage(depthResult.TradePaths, startingAmount, loanAddress)
+		log.Debug("Finished the arbitrage calculation cycle")
 
-					if err != nil {
-						fmt.Println(err)
-					}
-					fmt.Println("HAHAHAHA", depthResult)
-				}
+		// the candidates found while waiting for the next cycle are evaluated right away
+		var nextCycle = time.After(network.Polling.CycleInterval)
+
+		for waiting := true; waiting; {
+			select {
+			case candidate := <-candidates:
+				evaluateCandidate(candidate)
+			case <-nextCycle:
+				waiting = false
+			case <-ctx.Done():
+				waiting = false
 			}
 		}
+	}
+
+	// a second signal kills the process
+	stop()
+	log.Info("Shutting down, waiting for the executions in flight...")
+	var shutdownCtx, cancel = context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-		fmt.Println("========================")
-		time.Sleep(10 * time.Second)
+	// the submitted executions are tracked until they're mined & the ledgers are saved
+	if err := executor.Close(shutdownCtx); err != nil {
+		log.Warn("Error closing the executor", slog.String("executor", executor.GetName()), slog.Any("error", err))
+	}
+	// the provider, the mempool watcher & the metrics server return with the context
+	if err := waitFor(shutdownCtx, &services); err != nil {
+		log.Warn("Services still running", slog.Any("error", err))
 	}
+	rpcpool.Get().Close()
+	log.Info("Stopped")
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/trading/tradingtest"
+	"flag"
+	_ "github.com/joho/godotenv/autoload"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Mock Binance exchange for trying the CEX execution without touching real funds, kept out of the bot & the CLI
+func main() {
+	var address = flag.String("address", "127.0.0.1:8090", "listen address of the mock Binance REST & WebSocket API")
+	flag.Parse()
+
+	if err := config.Init(); err != nil {
+		slog.Error("Error loading the configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	logger.Setup(config.Get().Log)
+
+	// accepts the configured Binance API key & secret
+	var settings = config.Get().Exchange(sourceprovider.BinanceProviderName)
+	var server = tradingtest.NewBinanceServer(settings.APIKey, settings.APISecret, settings.TakerFee,
+		tradingtest.DefaultBinanceSymbols())
+
+	// starting balances of the account (GET /api/v3/account)
+	for asset, balance := range map[string]float64{"USDT": 10000, "BTC": 0.1, "ETH": 2} {
+		server.SetBalance(asset, balance)
+	}
+
+	helpers.Panic(server.Start(*address))
+	slog.Info("Point exchanges.binance.apiUrl / wsApiUrl at the mock exchange to trade against it",
+		slog.String("apiUrl", server.APIURL()), slog.String("wsApiUrl", server.WsAPIURL()))
+
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	helpers.Panic(server.Close())
+}
@@ -1,6 +1,10 @@
 package models
 
-import sp "arbitrage-bot/services/sourceprovider"
+import (
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"fmt"
+)
 
 type TriangularArbSurfaceResult struct {
 	Swap1             string    `json:"swap1"`
@@ -31,12 +35,38 @@ type TriangularArbSurfaceResult struct {
 	TradeDescription1 string    `json:"tradeDescription1"`
 	TradeDescription2 string    `json:"tradeDescription2"`
 	TradeDescription3 string    `json:"tradeDescription3"`
+	BlockNumber       uint64    `json:"blockNumber"` // Only used in DEX
+}
+
+// TriangleID ... returns the identifier of the triangle (contracts joined by underscores)
+func (t TriangularArbSurfaceResult) TriangleID() string {
+	return fmt.Sprintf("%s_%s_%s", t.Contract1, t.Contract2, t.Contract3)
 }
 
 type TriangularArbDepthResult struct {
 	ProfitLoss     float64
 	ProfitLossPerc float64
 	TradePaths     []sp.TradePath
+	AmountIn       units.Amount // exact amount of the first input token (only used in DEX)
+	AmountOut      units.Amount // exact amount of the last output token (only used in DEX)
+
+	// Only used in CEX
+	StartingAmount    float64                 // amount traded: the surface amount capped by MaxFillableAmount
+	MaxFillableAmount float64                 // largest starting amount the three orderbooks fill completely
+	Legs              []TriangularArbDepthLeg // walk of the orderbook of every trade
+}
+
+// TriangularArbDepthLeg ... Represents the walk of an orderbook by one trade of a triangle, prices are in units of
+// the output asset per unit of the input asset
+type TriangularArbDepthLeg struct {
+	AmountIn    float64 `json:"amountIn"`    // input filled
+	AmountOut   float64 `json:"amountOut"`   // output received
+	Unfilled    float64 `json:"unfilled"`    // input the orderbook couldn't absorb
+	Filled      bool    `json:"filled"`      // whether the whole input was filled
+	VWAP        float64 `json:"vwap"`        // volume weighted average price
+	WorstPrice  float64 `json:"worstPrice"`  // price of the deepest level consumed
+	Levels      int     `json:"levels"`      // levels consumed
+	PriceImpact float64 `json:"priceImpact"` // relative difference between the VWAP & the best price
 }
 
 type TriangularArbFullResult struct {
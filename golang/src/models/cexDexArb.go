@@ -0,0 +1,37 @@
+package models
+
+import sp "arbitrage-bot/services/sourceprovider"
+
+// CEX-DEX arbitrage directions
+const (
+	CexDexBuyDexSellCex = "buyDexSellCex"
+	CexDexBuyCexSellDex = "buyCexSellDex"
+)
+
+// CexDexArbResult ... Represents a CEX-DEX arbitrage opportunity in one direction (amounts in the CEX quote asset)
+type CexDexArbResult struct {
+	Direction    string    `json:"direction"`
+	DexProvider  string    `json:"dexProvider"`
+	CexProvider  string    `json:"cexProvider"`
+	DexSymbol    sp.Symbol `json:"dexSymbol"`
+	CexSymbol    sp.Symbol `json:"cexSymbol"`
+	BaseAsset    string    `json:"baseAsset"`
+	QuoteAsset   string    `json:"quoteAsset"`
+	BlockNumber  uint64    `json:"blockNumber"`
+	AmountIn     float64   `json:"amountIn"`
+	AmountOut    float64   `json:"amountOut"`
+	BaseQuantity float64   `json:"baseQuantity"`
+	// execution prices (quote per base)
+	DexPrice        float64 `json:"dexPrice"`
+	CexVWAP         float64 `json:"cexVwap"`
+	CexFullyFilled  bool    `json:"cexFullyFilled"`
+	CexLevelsUsed   int     `json:"cexLevelsUsed"`
+	DexFee          float64 `json:"dexFee"` // already included in the DEX quote
+	CexTakerFee     float64 `json:"cexTakerFee"`
+	GasCost         float64 `json:"gasCost"`
+	GrossEdge       float64 `json:"grossEdge"` // AmountOut - AmountIn, before gas
+	NetEdge         float64 `json:"netEdge"`   // after gas, DEX fee and CEX taker fee
+	NetEdgePerc     float64 `json:"netEdgePerc"`
+	SurfaceDexPrice float64 `json:"surfaceDexPrice"`
+	SurfaceCexPrice float64 `json:"surfaceCexPrice"`
+}
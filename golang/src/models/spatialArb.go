@@ -0,0 +1,37 @@
+package models
+
+import (
+	sp "arbitrage-bot/services/sourceprovider"
+	"time"
+)
+
+// SpatialArbResult ... Represents a CEX-CEX (spatial) arbitrage opportunity: buy on one venue, sell on another
+type SpatialArbResult struct {
+	BaseAsset    string    `json:"baseAsset"`
+	QuoteAsset   string    `json:"quoteAsset"`
+	BuyExchange  string    `json:"buyExchange"`
+	SellExchange string    `json:"sellExchange"`
+	BuySymbol    sp.Symbol `json:"buySymbol"`
+	SellSymbol   sp.Symbol `json:"sellSymbol"`
+	// best prices (top of the book)
+	BestAsk float64 `json:"bestAsk"`
+	BestBid float64 `json:"bestBid"`
+	// sizing from both order books (quantity in base asset, amounts in quote asset)
+	Quantity       float64 `json:"quantity"`
+	BuyVWAP        float64 `json:"buyVwap"`
+	SellVWAP       float64 `json:"sellVwap"`
+	BuyCost        float64 `json:"buyCost"`
+	SellProceeds   float64 `json:"sellProceeds"`
+	BuyTakerFee    float64 `json:"buyTakerFee"`
+	SellTakerFee   float64 `json:"sellTakerFee"`
+	LevelsConsumed int     `json:"levelsConsumed"`
+	// transfer assumptions: the inventory is pre-positioned on both venues and the acquired base asset is
+	// withdrawn from the buy venue to the sell venue to rebalance
+	TransferAsset      string        `json:"transferAsset"`
+	WithdrawalFee      float64       `json:"withdrawalFee"`      // in base asset
+	WithdrawalFeeQuote float64       `json:"withdrawalFeeQuote"` // valued at the sell VWAP
+	TransferTime       time.Duration `json:"transferTime"`
+	GrossProfit        float64       `json:"grossProfit"`
+	NetProfit          float64       `json:"netProfit"`
+	NetProfitPerc      float64       `json:"netProfitPerc"`
+}
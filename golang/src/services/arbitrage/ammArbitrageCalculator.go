@@ -2,7 +2,9 @@ package arbitrage
 
 import (
 	ethersHelper "arbitrage-bot/helpers/ethers"
+	"arbitrage-bot/helpers/units"
 	"arbitrage-bot/models"
+	"arbitrage-bot/services/metrics"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/dex"
 	"fmt"
@@ -18,11 +20,14 @@ func NewAmmArbitrageCalculator(sourceProvider dex.ISourceProvider) *AmmArbitrage
 	return &AmmArbitrageCalculator{sourceProvider: sourceProvider}
 }
 
-// GetPriceForTriangularPair ... get the price data for the triangular pair
-func (a *AmmArbitrageCalculator) getPriceForTriangularPair(triangularPair [3]*sourceprovider.Symbol) (TriangularDexPrice, error) {
-	symbol1Price := a.sourceProvider.GetSymbolPrice(triangularPair[0].Symbol)
-	symbol2Price := a.sourceProvider.GetSymbolPrice(triangularPair[1].Symbol)
-	symbol3Price := a.sourceProvider.GetSymbolPrice(triangularPair[2].Symbol)
+// GetPriceForTriangularPair ... get the price data for the triangular pair, the overrides (symbol -> price) replace
+// the prices of the source provider
+func (a *AmmArbitrageCalculator) getPriceForTriangularPair(
+	triangularPair [3]*sourceprovider.Symbol, overrides map[string]*dex.SymbolPrice,
+) (TriangularDexPrice, error) {
+	symbol1Price := a.symbolPrice(triangularPair[0].Symbol, overrides)
+	symbol2Price := a.symbolPrice(triangularPair[1].Symbol, overrides)
+	symbol3Price := a.symbolPrice(triangularPair[2].Symbol, overrides)
 
 	if symbol1Price == nil {
 		err := fmt.Errorf("symbol %s not found", triangularPair[0].Symbol)
@@ -35,6 +40,10 @@ func (a *AmmArbitrageCalculator) getPriceForTriangularPair(triangularPair [3]*so
 		return TriangularDexPrice{}, err
 	}
 
+	for _, symbolPrice := range []*dex.SymbolPrice{symbol1Price, symbol2Price, symbol3Price} {
+		metrics.ObservePriceStaleness(a.sourceProvider.GetName(), symbolPrice.EventTime)
+	}
+
 	return TriangularDexPrice{
 		pairAToken0: symbol1Price.Token0Price,
 		pairAToken1: symbol1Price.Token1Price,
@@ -42,12 +51,30 @@ func (a *AmmArbitrageCalculator) getPriceForTriangularPair(triangularPair [3]*so
 		pairBToken1: symbol2Price.Token1Price,
 		pairCToken0: symbol3Price.Token0Price,
 		pairCToken1: symbol3Price.Token1Price,
+		blockNumber: symbol1Price.BlockNumber,
 	}, nil
 }
 
+// symbolPrice ... returns the override of a symbol's price if any, the price of the source provider otherwise
+func (a *AmmArbitrageCalculator) symbolPrice(symbol string, overrides map[string]*dex.SymbolPrice) *dex.SymbolPrice {
+	if price, ok := overrides[symbol]; ok {
+		return price
+	}
+
+	return a.sourceProvider.GetSymbolPrice(symbol)
+}
+
 // CalcTriangularArbSurfaceRate ... calculates the surface rate for the triangular pair.
 func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]*sourceprovider.Symbol, startingAmount float64) (models.TriangularArbSurfaceResult, error) {
-	priceData, err := a.getPriceForTriangularPair(triangularPair)
+	return a.CalcTriangularArbSurfaceRateWithPrices(triangularPair, startingAmount, nil)
+}
+
+// CalcTriangularArbSurfaceRateWithPrices ... calculates the surface rate for the triangular pair with some prices
+// replaced (symbol -> price), f.e. the prices after a pending swap
+func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRateWithPrices(
+	triangularPair [3]*sourceprovider.Symbol, startingAmount float64, overrides map[string]*dex.SymbolPrice,
+) (models.TriangularArbSurfaceResult, error) {
+	priceData, err := a.getPriceForTriangularPair(triangularPair, overrides)
 
 	if err != nil {
 		return models.TriangularArbSurfaceResult{}, err
@@ -70,18 +97,21 @@ func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]
 
 	var aPair = triangularPair[0].Symbol
 	var aPairContractAddress = triangularPair[0].Address
-	var aBase = triangularPair[0].BaseAsset
-	var aQuote = triangularPair[0].QuoteAsset
+	var aBase = triangularPair[0].GetBaseAssetID()
+	var aQuote = triangularPair[0].GetQuoteAssetID()
 
 	var bPair = triangularPair[1].Symbol
 	var bPairContractAddress = triangularPair[1].Address
-	var bBase = triangularPair[1].BaseAsset
-	var bQuote = triangularPair[1].QuoteAsset
+	var bBase = triangularPair[1].GetBaseAssetID()
+	var bQuote = triangularPair[1].GetQuoteAssetID()
 
 	var cPair = triangularPair[2].Symbol
 	var cPairContractAddress = triangularPair[2].Address
-	var cBase = triangularPair[2].BaseAsset
-	var cQuote = triangularPair[2].QuoteAsset
+	var cBase = triangularPair[2].GetBaseAssetID()
+	var cQuote = triangularPair[2].GetQuoteAssetID()
+	// assets are matched by their canonical ids, the names are only used for display
+	var assetNames = getAssetNames(triangularPair)
+
 	// set directions and loop through
 	var directions = [2]string{"forward", "backward"}
 	var tradingResult models.TriangularArbSurfaceResult
@@ -362,9 +392,15 @@ func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]
 		var profitLossPercentage = profitLoss / startingAmount * 100
 
 		// Trade Descriptions
+		swap1, swap2, swap3 = assetNames[swap1], assetNames[swap2], assetNames[swap3]
 		var tradeDescription1 = fmt.Sprintf("Start with %v of %v, swap at %v for %v, acquiring %v", swap1, startingAmount, swap1Rate, swap2, acquiredCoinT1)
 		var tradeDescription2 = fmt.Sprintf("Swap %v of %v at %v for %v, acquiring %v", acquiredCoinT1, swap2, swap2Rate, swap3, acquiredCoinT2)
 		var tradeDescription3 = fmt.Sprintf("Swap %v of %v at %v for %v, acquiring %v", acquiredCoinT2, swap3, swap3Rate, swap1, acquiredCoinT3)
+		symbols, err := a.getSymbols(contract1, contract2, contract3)
+
+		if err != nil {
+			return models.TriangularArbSurfaceResult{}, err
+		}
 		tradingResult = models.TriangularArbSurfaceResult{
 			Swap1:             swap1,
 			Swap2:             swap2,
@@ -372,9 +408,9 @@ func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]
 			Contract1:         contract1,
 			Contract2:         contract2,
 			Contract3:         contract3,
-			Symbol1:           a.sourceProvider.GetSymbol(contract1),
-			Symbol2:           a.sourceProvider.GetSymbol(contract2),
-			Symbol3:           a.sourceProvider.GetSymbol(contract3),
+			Symbol1:           symbols[0],
+			Symbol2:           symbols[1],
+			Symbol3:           symbols[2],
 			Contract1Address:  contract1Address,
 			Contract2Address:  contract2Address,
 			Contract3Address:  contract3Address,
@@ -394,6 +430,7 @@ func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]
 			TradeDescription1: tradeDescription1,
 			TradeDescription2: tradeDescription2,
 			TradeDescription3: tradeDescription3,
+			BlockNumber:       priceData.blockNumber,
 		}
 
 		if profitLoss > MinSurfaceRate {
@@ -404,26 +441,46 @@ func (a *AmmArbitrageCalculator) CalcTriangularArbSurfaceRate(triangularPair [3]
 	return tradingResult, fmt.Errorf("no profitable arbitrage found")
 }
 
-func (a *AmmArbitrageCalculator) CalcDepthOpportunityForward(
-	surfaceResult models.TriangularArbSurfaceResult, verbose bool,
-) models.TriangularArbDepthResult {
-	var contract1 = surfaceResult.Contract1
-	var contract2 = surfaceResult.Contract2
-	var contract3 = surfaceResult.Contract3
-	var allContracts = fmt.Sprintf("%s_%s_%s", contract1, contract2, contract3)
-	_ = allContracts
+// getSymbols ... returns the symbols of the contracts of a surface result
+func (a *AmmArbitrageCalculator) getSymbols(contracts ...string) ([]sourceprovider.Symbol, error) {
+	var symbols = make([]sourceprovider.Symbol, len(contracts))
+
+	for i, contract := range contracts {
+		symbol, err := a.sourceProvider.GetSymbol(contract)
+
+		if err != nil {
+			return nil, err
+		}
+		symbols[i] = symbol
+	}
+
+	return symbols, nil
+}
 
+// CalcDepthOpportunityForward ... quotes the trade paths of the surface result on-chain
+func (a *AmmArbitrageCalculator) CalcDepthOpportunityForward(
+	surfaceResult models.TriangularArbSurfaceResult,
+) (models.TriangularArbDepthResult, error) {
 	var tradePaths = ethersHelper.GetTradePathsFromSurfaceResult(surfaceResult)
-	var acquiredCoinT3 = a.sourceProvider.Web3Service().GetPriceMultiplePaths(
-		tradePaths, surfaceResult.StartingAmount, verbose,
-	)
-	var profitLoss, profitLossPerc = a.calcDepthArb(surfaceResult.StartingAmount, acquiredCoinT3)
+	var amountIn = tradePaths[0].AmountIn(surfaceResult.StartingAmount)
+	acquiredCoinT3, err := a.sourceProvider.Web3Service().GetPriceMultiplePaths(tradePaths, amountIn)
+
+	if err != nil {
+		return models.TriangularArbDepthResult{}, err
+	}
+	profitLoss, profitLossPerc, err := a.calcDepthArb(amountIn, acquiredCoinT3)
+
+	if err != nil {
+		return models.TriangularArbDepthResult{}, err
+	}
 
 	return models.TriangularArbDepthResult{
 		ProfitLoss:     profitLoss,
 		ProfitLossPerc: profitLossPerc,
 		TradePaths:     tradePaths,
-	}
+		AmountIn:       amountIn,
+		AmountOut:      acquiredCoinT3,
+	}, nil
 }
 
 func (a *AmmArbitrageCalculator) revertDirection(direction string) string {
@@ -435,13 +492,18 @@ func (a *AmmArbitrageCalculator) revertDirection(direction string) string {
 	return ""
 }
 
-// calcDepthArb ... calculate the depth arbitrage
+// calcDepthArb ... calculate the depth arbitrage, the profit is computed exactly (the triangle starts & ends with
+// the same token) & only converted to floats for ranking, an output in other decimals than the input is an error
 func (a *AmmArbitrageCalculator) calcDepthArb(
-	amountIn float64,
-	outputOut float64,
-) (float64, float64) {
-	var profitLoss = outputOut - amountIn
-	var profitLossPerc = (profitLoss / amountIn) * 100
+	amountIn units.Amount,
+	outputOut units.Amount,
+) (float64, float64, error) {
+	profitLoss, err := outputOut.SubE(amountIn)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("error calculating depth arbitrage: %w", err)
+	}
+	var profitLossPerc = profitLoss.Ratio(amountIn) * 100
 
-	return profitLoss, profitLossPerc
+	return profitLoss.Float64(), profitLossPerc, nil
 }
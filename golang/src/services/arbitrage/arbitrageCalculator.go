@@ -2,9 +2,11 @@ package arbitrage
 
 import (
 	"arbitrage-bot/models"
+	"arbitrage-bot/services/metrics"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/cex"
 	"fmt"
+	"math"
 )
 
 // ArbitrageCalculator ... the main calculator for the arbitrage
@@ -34,6 +36,10 @@ func (a *ArbitrageCalculator) getPriceForTriangularPair(triangularPair [3]*sourc
 		return TriangularBidAskPrice{}, err
 	}
 
+	for _, symbolPrice := range []*cex.SymbolPrice{symbol1Price, symbol2Price, symbol3Price} {
+		metrics.ObservePriceStaleness(a.sourceProvider.GetName(), symbolPrice.EventTime)
+	}
+
 	return TriangularBidAskPrice{
 		pairAAsk: symbol1Price.BestAsk,
 		pairABid: symbol1Price.BestBid,
@@ -67,14 +73,22 @@ func (a *ArbitrageCalculator) CalcTriangularArbSurfaceRate(
 	var calculated = false
 
 	var aPair = triangularPair[0].Symbol
-	var aBase = triangularPair[0].BaseAsset
-	var aQuote = triangularPair[0].QuoteAsset
+	var aBase = triangularPair[0].GetBaseAssetID()
+	var aQuote = triangularPair[0].GetQuoteAssetID()
 	var bPair = triangularPair[1].Symbol
-	var bBase = triangularPair[1].BaseAsset
-	var bQuote = triangularPair[1].QuoteAsset
+	var bBase = triangularPair[1].GetBaseAssetID()
+	var bQuote = triangularPair[1].GetQuoteAssetID()
 	var cPair = triangularPair[2].Symbol
-	var cBase = triangularPair[2].BaseAsset
-	var cQuote = triangularPair[2].QuoteAsset
+	var cBase = triangularPair[2].GetBaseAssetID()
+	var cQuote = triangularPair[2].GetQuoteAssetID()
+
+	// assets are matched by their canonical ids, the names are only used for display
+	var assetNames = getAssetNames(triangularPair)
+	var symbolsByContract = map[string]*sourceprovider.Symbol{
+		aPair: triangularPair[0],
+		bPair: triangularPair[1],
+		cPair: triangularPair[2],
+	}
 
 	// set directions and loop through
 	var directions = [2]string{"forward", "backward"}
@@ -332,12 +346,25 @@ func (a *ArbitrageCalculator) CalcTriangularArbSurfaceRate(
 			}
 		}
 
+		// round every trade to the trading rules of its symbol, a trade the exchange would reject discards the direction
+		var contractSymbols = [3]*sourceprovider.Symbol{
+			symbolsByContract[contract1], symbolsByContract[contract2], symbolsByContract[contract3],
+		}
+		var acquiredCoins, rulesErr = simulateLegalTrades(startingAmount, contractSymbols,
+			[3]string{directionTrade1, directionTrade2, directionTrade3}, [3]float64{swap1Rate, swap2Rate, swap3Rate})
+
+		if rulesErr != nil {
+			continue
+		}
+		acquiredCoinT1, acquiredCoinT2, acquiredCoinT3 = acquiredCoins[0], acquiredCoins[1], acquiredCoins[2]
+
 		// PROFIT LOSS OUTPUT
 		// Profit and loss calculation
 		var profitLoss = acquiredCoinT3 - startingAmount
 		var profitLossPercentage = profitLoss / startingAmount * 100
 
 		// Trade Descriptions
+		swap1, swap2, swap3 = assetNames[swap1], assetNames[swap2], assetNames[swap3]
 		var tradeDescription1 = fmt.Sprintf("Start with %v of %v, swap at %v for %v, acquiring %v", swap1, startingAmount, swap1Rate, swap2, acquiredCoinT1)
 		var tradeDescription2 = fmt.Sprintf("Swap %v of %v at %v for %v, acquiring %v", acquiredCoinT1, swap2, swap2Rate, swap3, acquiredCoinT2)
 		var tradeDescription3 = fmt.Sprintf("Swap %v of %v at %v for %v, acquiring %v", acquiredCoinT2, swap3, swap3Rate, swap1, acquiredCoinT3)
@@ -350,6 +377,9 @@ func (a *ArbitrageCalculator) CalcTriangularArbSurfaceRate(
 				Contract1:         contract1,
 				Contract2:         contract2,
 				Contract3:         contract3,
+				Symbol1:           *contractSymbols[0],
+				Symbol2:           *contractSymbols[1],
+				Symbol3:           *contractSymbols[2],
 				DirectionTrade1:   directionTrade1,
 				DirectionTrade2:   directionTrade2,
 				DirectionTrade3:   directionTrade3,
@@ -381,14 +411,14 @@ func (a *ArbitrageCalculator) reformatOrderbook(
 
 	if directionTrade == "baseToQuote" {
 		for _, entry := range orderBookPrice.Asks {
-			// TODO: add comment to this
+			// the input is the quote asset: a level gives 1/price base per quote & absorbs price*quantity quote
 			var adjPrice float64 = 0
 
 			if entry.Price != 0 {
 				adjPrice = 1 / entry.Price
 			}
 
-			var adjQuantity float64 = entry.Quantity * adjPrice
+			var adjQuantity float64 = entry.Quantity * entry.Price
 			result = append(result, &sourceprovider.OrderbookEntry{
 				Price:    adjPrice,
 				Quantity: adjQuantity,
@@ -408,49 +438,6 @@ func (a *ArbitrageCalculator) reformatOrderbook(
 	return result
 }
 
-// CalculateAcquiredCoin ... get acquired coin also known as (aka) Depth calculation
-func (a *ArbitrageCalculator) calculateAcquiredCoin(amountIn float64, orderbook []*sourceprovider.OrderbookEntry) float64 {
-	// CHALLENGES:
-	// - Full amount of starting amount in can be eaten on the first level (level 0)
-	// - Some of the amount in can be eaten up by multiple levels
-	// - Some coins may not have enough liquidity
-
-	tradingBalance := amountIn
-	quantityBought := 0.0
-	acquiredCoin := 0.0
-	counts := 0
-
-	for _, level := range orderbook {
-		var amountBought float64 = 0
-
-		// Amount In <= first level's total amount
-		if level.Quantity >= tradingBalance {
-			quantityBought = tradingBalance
-			tradingBalance = 0
-			amountBought = quantityBought * level.Price
-		}
-		// Amount In > a given level's total amount
-		if level.Quantity < tradingBalance {
-			quantityBought = level.Quantity
-			tradingBalance -= level.Quantity
-			amountBought = quantityBought * level.Price
-		}
-
-		acquiredCoin += amountBought
-
-		if tradingBalance == 0 {
-			return acquiredCoin
-		}
-
-		counts++
-
-		if counts == len(orderbook) {
-			return 0
-		}
-	}
-	return 0
-}
-
 func (a *ArbitrageCalculator) GetDepth(
 	surfaceRate models.TriangularArbSurfaceResult,
 ) (models.TriangularArbDepthResult, error) {
@@ -479,26 +466,56 @@ func (a *ArbitrageCalculator) GetDepth(
 		return result, err
 	}
 
-	// get acquired coins
-	orderbook1 := a.reformatOrderbook(directionTrade1, depthContract1)
-	orderbook2 := a.reformatOrderbook(directionTrade2, depthContract2)
-	orderbook3 := a.reformatOrderbook(directionTrade3, depthContract3)
-	acquiredCoinT1 := a.calculateAcquiredCoin(startingAmount, orderbook1)
-	acquiredCoinT2 := a.calculateAcquiredCoin(acquiredCoinT1, orderbook2)
-	acquiredCoinT3 := a.calculateAcquiredCoin(acquiredCoinT2, orderbook3)
+	// size the trade to what the three orderbooks can fill, then walk them
+	var orderbooks = [][]*sourceprovider.OrderbookEntry{
+		a.reformatOrderbook(directionTrade1, depthContract1),
+		a.reformatOrderbook(directionTrade2, depthContract2),
+		a.reformatOrderbook(directionTrade3, depthContract3),
+	}
+	result.MaxFillableAmount = maxFillableAmount(orderbooks)
+	result.StartingAmount = math.Min(startingAmount, result.MaxFillableAmount)
 
-	// calculate profit loss also known as real rate
-	profitLoss := acquiredCoinT3 - startingAmount
-	realRatePercent := 0.0
+	if result.StartingAmount <= 0 {
+		return result, fmt.Errorf("orderbooks of %s, %s & %s have no fillable depth", contract1, contract2, contract3)
+	}
+
+	var amountIn = result.StartingAmount
+	var contracts = [3]string{contract1, contract2, contract3}
+	var directionTrades = [3]string{directionTrade1, directionTrade2, directionTrade3}
 
-	if profitLoss != 0 {
-		realRatePercent = (profitLoss / startingAmount) * 100
+	for i, orderbook := range orderbooks {
+		// only legal quantities are traded, the rounding dust stays unspent
+		var symbol = a.contractSymbol(surfaceRate, contracts[i])
+		var leg = walkOrderbook(roundLegInput(symbol, directionTrades[i], amountIn), orderbook)
+		leg.AmountOut = roundLegOutput(symbol, directionTrades[i], leg.AmountOut)
+
+		if err := checkLegRules(symbol, directionTrades[i], leg.AmountIn, leg.AmountOut); err != nil {
+			return result, err
+		}
+		result.Legs = append(result.Legs, leg)
+		amountIn = leg.AmountOut
 	}
 
-	if realRatePercent > -1 {
-		result.ProfitLoss = profitLoss
-		result.ProfitLossPerc = realRatePercent
+	// calculate profit loss also known as real rate
+	var acquiredCoinT3 = result.Legs[2].AmountOut
+	result.ProfitLoss = acquiredCoinT3 - result.StartingAmount
+	result.ProfitLossPerc = result.ProfitLoss / result.StartingAmount * 100
+
+	if result.ProfitLossPerc > -1 {
 		return result, nil
 	}
 	return result, fmt.Errorf("no profitable arbitrage found")
 }
+
+// contractSymbol ... returns the symbol of a contract of the surface result (nil if it's unknown)
+func (a *ArbitrageCalculator) contractSymbol(
+	surfaceRate models.TriangularArbSurfaceResult, contract string,
+) *sourceprovider.Symbol {
+	for _, symbol := range []sourceprovider.Symbol{surfaceRate.Symbol1, surfaceRate.Symbol2, surfaceRate.Symbol3} {
+		if symbol.Symbol == contract {
+			return &symbol
+		}
+	}
+
+	return nil
+}
@@ -12,3 +12,15 @@ type IArbitrageCalculator interface {
 	) (models.TriangularArbSurfaceResult, error)
 	GetDepth(surfaceRate models.TriangularArbSurfaceResult) models.TriangularArbDepthResult
 }
+
+// getAssetNames ... maps the canonical asset ids of a triangle to their display names
+func getAssetNames(triangularPair [3]*sourceprovider.Symbol) map[string]string {
+	var assetNames = make(map[string]string)
+
+	for _, symbol := range triangularPair {
+		assetNames[symbol.GetBaseAssetID()] = symbol.BaseAsset
+		assetNames[symbol.GetQuoteAssetID()] = symbol.QuoteAsset
+	}
+
+	return assetNames
+}
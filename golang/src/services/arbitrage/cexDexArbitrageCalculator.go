@@ -0,0 +1,385 @@
+package arbitrage
+
+import (
+	"arbitrage-bot/config"
+	ethersHelper "arbitrage-bot/helpers/ethers"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/models"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+	"arbitrage-bot/services/sourceprovider/dex"
+	"arbitrage-bot/services/tokenregistry"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// cexDexMarket ... the CEX market matching a DEX pool
+type cexDexMarket struct {
+	cexSymbol     sourceprovider.Symbol
+	baseIsDexBase bool // whether the CEX base asset is the base (token0) of the DEX pool
+}
+
+// CexDexArbitrageCalculator ... calculator comparing a DEX pool with the orderbook of the same pair on a CEX
+type CexDexArbitrageCalculator struct {
+	dexSourceProvider dex.ISourceProvider
+	cexSourceProvider cex.ISourceProvider
+	sizes             []float64 // candidate trade sizes in the CEX quote asset
+}
+
+// NewCexDexArbitrageCalculator ... creates a new instance of the CexDexArbitrageCalculator
+func NewCexDexArbitrageCalculator(
+	dexSourceProvider dex.ISourceProvider,
+	cexSourceProvider cex.ISourceProvider,
+	sizes []float64,
+) *CexDexArbitrageCalculator {
+	return &CexDexArbitrageCalculator{
+		dexSourceProvider: dexSourceProvider,
+		cexSourceProvider: cexSourceProvider,
+		sizes:             sizes,
+	}
+}
+
+// getMarket ... maps the pool tokens to CEX tickers (token registry) & finds the listed CEX symbol
+func (c *CexDexArbitrageCalculator) getMarket(dexSymbol sourceprovider.Symbol) (cexDexMarket, error) {
+	var chainID = config.Get().ActiveNetwork().ChainID
+	var provider = c.cexSourceProvider.GetName()
+	var ticker0, ok0 = tokenregistry.Get().CEXTicker(provider, chainID, dexSymbol.BaseAssetAddress)
+	var ticker1, ok1 = tokenregistry.Get().CEXTicker(provider, chainID, dexSymbol.QuoteAssetAddress)
+
+	if !ok0 || !ok1 {
+		return cexDexMarket{}, fmt.Errorf("no CEX ticker for the tokens of %s", dexSymbol.Symbol)
+	}
+
+	for _, market := range []cexDexMarket{
+		{cexSymbol: sourceprovider.Symbol{Symbol: ticker0 + ticker1, BaseAsset: ticker0, QuoteAsset: ticker1}, baseIsDexBase: true},
+		{cexSymbol: sourceprovider.Symbol{Symbol: ticker1 + ticker0, BaseAsset: ticker1, QuoteAsset: ticker0}, baseIsDexBase: false},
+	} {
+		if price := c.cexSourceProvider.GetSymbolPrice(market.cexSymbol.Symbol); price != nil {
+			if price.Symbol != nil {
+				market.cexSymbol = *price.Symbol
+			}
+			return market, nil
+		}
+	}
+
+	return cexDexMarket{}, fmt.Errorf("%s/%s isn't listed on %s", ticker0, ticker1, c.cexSourceProvider.GetName())
+}
+
+// CalcCexDexArb ... calculates the net edge of the pool against the CEX in both directions
+func (c *CexDexArbitrageCalculator) CalcCexDexArb(dexSymbol sourceprovider.Symbol) ([]models.CexDexArbResult, error) {
+	market, err := c.getMarket(dexSymbol)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var cexPrice = c.cexSourceProvider.GetSymbolPrice(market.cexSymbol.Symbol)
+	var dexPrice = c.dexSourceProvider.GetSymbolPrice(dexSymbol.Symbol)
+	var depth = c.cexSourceProvider.GetSymbolOrderbookDepth(market.cexSymbol.Symbol)
+
+	if dexPrice == nil {
+		return nil, fmt.Errorf("%w: %s", sourceprovider.ErrSymbolNotFound, dexSymbol.Symbol)
+	} else if depth == nil {
+		return nil, fmt.Errorf("orderbook of %s not found", market.cexSymbol.Symbol)
+	}
+	metrics.ObservePriceStaleness(c.cexSourceProvider.GetName(), cexPrice.EventTime)
+	metrics.ObservePriceStaleness(c.dexSourceProvider.GetName(), dexPrice.EventTime)
+
+	// price of the CEX base asset in the CEX quote asset on the DEX
+	var surfaceDexPrice = dexPrice.Token1Price
+
+	if !market.baseIsDexBase {
+		surfaceDexPrice = dexPrice.Token0Price
+	}
+
+	gasCost, err := c.getGasCost(market.cexSymbol.QuoteAsset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var takerFee = config.Get().Exchange(c.cexSourceProvider.GetName()).TakerFee
+	var results []models.CexDexArbResult
+
+	// buy the base asset on the DEX, sell it on the CEX
+	if surfaceDexPrice < cexPrice.BestBid*(1-takerFee) {
+		result, ok, err := c.calcBestSize(dexSymbol, market, depth, models.CexDexBuyDexSellCex, gasCost)
+
+		if err != nil {
+			return nil, err
+		} else if ok {
+			result.SurfaceDexPrice = surfaceDexPrice
+			result.SurfaceCexPrice = cexPrice.BestBid
+			result.BlockNumber = dexPrice.BlockNumber
+			results = append(results, result)
+		}
+	}
+
+	// buy the base asset on the CEX, sell it on the DEX
+	if surfaceDexPrice > cexPrice.BestAsk*(1+takerFee) {
+		result, ok, err := c.calcBestSize(dexSymbol, market, depth, models.CexDexBuyCexSellDex, gasCost)
+
+		if err != nil {
+			return nil, err
+		} else if ok {
+			result.SurfaceDexPrice = surfaceDexPrice
+			result.SurfaceCexPrice = cexPrice.BestAsk
+			result.BlockNumber = dexPrice.BlockNumber
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no CEX-DEX arbitrage found for %s", dexSymbol.Symbol)
+	}
+
+	return results, nil
+}
+
+// calcBestSize ... evaluates every candidate size & keeps the one with the highest net edge, a failing DEX quote
+// stops the evaluation
+func (c *CexDexArbitrageCalculator) calcBestSize(
+	dexSymbol sourceprovider.Symbol,
+	market cexDexMarket,
+	depth *sourceprovider.SymbolOrderbookDepth,
+	direction string,
+	gasCost float64,
+) (models.CexDexArbResult, bool, error) {
+	var bestResult models.CexDexArbResult
+	var found = false
+
+	for _, size := range c.sizes {
+		var result models.CexDexArbResult
+		var ok bool
+		var err error
+
+		if direction == models.CexDexBuyDexSellCex {
+			result, ok, err = c.calcBuyDexSellCex(dexSymbol, market, depth, size)
+		} else {
+			result, ok, err = c.calcBuyCexSellDex(dexSymbol, market, depth, size)
+		}
+
+		if err != nil {
+			return models.CexDexArbResult{}, false, err
+		} else if !ok {
+			continue
+		}
+		result.GasCost = gasCost
+		result.NetEdge = result.GrossEdge - gasCost
+		result.NetEdgePerc = result.NetEdge / result.AmountIn * 100
+
+		if !found || result.NetEdge > bestResult.NetEdge {
+			bestResult = result
+			found = true
+		}
+	}
+
+	return bestResult, found, nil
+}
+
+// calcBuyDexSellCex ... swaps amountIn of the quote asset for the base asset on the DEX & sells it on the CEX bids
+func (c *CexDexArbitrageCalculator) calcBuyDexSellCex(
+	dexSymbol sourceprovider.Symbol,
+	market cexDexMarket,
+	depth *sourceprovider.SymbolOrderbookDepth,
+	amountIn float64,
+) (models.CexDexArbResult, bool, error) {
+	baseQuantity, err := c.quoteDex(dexSymbol, amountIn, c.dexDirection(market, false))
+
+	if err != nil || baseQuantity == 0 {
+		return models.CexDexArbResult{}, false, err
+	}
+
+	// the CEX only sells whole steps, the dust stays unsold
+	var cexQuantity = roundLegInput(&market.cexSymbol, "quoteToBase", baseQuantity)
+	var fill = sellOnOrderbook(depth.Bids, cexQuantity)
+
+	if checkLegRules(&market.cexSymbol, "quoteToBase", cexQuantity, fill.quoteAmount) != nil {
+		return models.CexDexArbResult{}, false, nil
+	}
+
+	var takerFee = fill.quoteAmount * config.Get().Exchange(c.cexSourceProvider.GetName()).TakerFee
+	var amountOut = fill.quoteAmount - takerFee
+	var result = c.newResult(dexSymbol, market, models.CexDexBuyDexSellCex, fill)
+	result.AmountIn = amountIn
+	result.AmountOut = amountOut
+	result.BaseQuantity = baseQuantity
+	result.DexPrice = amountIn / baseQuantity
+	result.DexFee = amountIn * c.dexFeeRate(dexSymbol)
+	result.CexTakerFee = takerFee
+	result.GrossEdge = amountOut - amountIn
+
+	return result, true, nil
+}
+
+// calcBuyCexSellDex ... buys the base asset with amountIn of the quote asset on the CEX asks & sells it on the DEX
+func (c *CexDexArbitrageCalculator) calcBuyCexSellDex(
+	dexSymbol sourceprovider.Symbol,
+	market cexDexMarket,
+	depth *sourceprovider.SymbolOrderbookDepth,
+	amountIn float64,
+) (models.CexDexArbResult, bool, error) {
+	// the CEX only buys whole steps
+	var fill = roundFillQuantity(&market.cexSymbol,
+		buyOnOrderbook(depth.Asks, roundLegInput(&market.cexSymbol, "baseToQuote", amountIn)))
+
+	if fill.baseAmount == 0 || checkLegRules(&market.cexSymbol, "baseToQuote", fill.quoteAmount, fill.baseAmount) != nil {
+		return models.CexDexArbResult{}, false, nil
+	}
+
+	// the taker fee is charged in the received asset
+	var feeRate = config.Get().Exchange(c.cexSourceProvider.GetName()).TakerFee
+	var baseQuantity = fill.baseAmount * (1 - feeRate)
+	amountOut, err := c.quoteDex(dexSymbol, baseQuantity, c.dexDirection(market, true))
+
+	if err != nil || amountOut == 0 {
+		return models.CexDexArbResult{}, false, err
+	}
+
+	var dexFeeRate = c.dexFeeRate(dexSymbol)
+	var result = c.newResult(dexSymbol, market, models.CexDexBuyCexSellDex, fill)
+	result.AmountIn = fill.quoteAmount
+	result.AmountOut = amountOut
+	result.BaseQuantity = baseQuantity
+	result.DexPrice = amountOut / baseQuantity
+	result.DexFee = amountOut / (1 - dexFeeRate) * dexFeeRate
+	result.CexTakerFee = fill.quoteAmount * feeRate
+	result.GrossEdge = amountOut - fill.quoteAmount
+
+	return result, true, nil
+}
+
+// newResult ... fills the common fields of a result
+func (c *CexDexArbitrageCalculator) newResult(
+	dexSymbol sourceprovider.Symbol,
+	market cexDexMarket,
+	direction string,
+	fill orderbookFill,
+) models.CexDexArbResult {
+	return models.CexDexArbResult{
+		Direction:      direction,
+		DexProvider:    c.dexSourceProvider.GetName(),
+		CexProvider:    c.cexSourceProvider.GetName(),
+		DexSymbol:      dexSymbol,
+		CexSymbol:      market.cexSymbol,
+		BaseAsset:      market.cexSymbol.BaseAsset,
+		QuoteAsset:     market.cexSymbol.QuoteAsset,
+		CexVWAP:        fill.vwap(),
+		CexFullyFilled: fill.filled,
+		CexLevelsUsed:  fill.levels,
+	}
+}
+
+// quoteDex ... returns the output of a DEX swap of amountIn (in token units), the quote is exact but the orderbook
+// side of the calculation works with floats
+func (c *CexDexArbitrageCalculator) quoteDex(
+	dexSymbol sourceprovider.Symbol, amountIn float64, direction string,
+) (float64, error) {
+	var tradePath = ethersHelper.GetTradePaths([]sourceprovider.Symbol{dexSymbol}, []string{direction})[0]
+	amountOut, err := c.dexSourceProvider.Web3Service().GetPrice(dexSymbol, tradePath.AmountIn(amountIn), direction)
+
+	return amountOut.Float64(), err
+}
+
+// dexDirection ... returns the DEX trade direction to sell (or buy) the CEX base asset
+func (c *CexDexArbitrageCalculator) dexDirection(market cexDexMarket, sellBase bool) string {
+	if sellBase == market.baseIsDexBase {
+		return "baseToQuote"
+	}
+
+	return "quoteToBase"
+}
+
+// dexFeeRate ... returns the swap fee of the pool
+func (c *CexDexArbitrageCalculator) dexFeeRate(dexSymbol sourceprovider.Symbol) float64 {
+	if dexSymbol.FeeTier > 0 {
+		// Uniswap V3 fee tiers are in hundredths of a bip
+		return float64(dexSymbol.FeeTier) / 1e6
+	}
+
+	return PancakeswapV2Fee
+}
+
+// getGasCost ... returns the cost of a swap in the given CEX quote asset
+func (c *CexDexArbitrageCalculator) getGasCost(quoteAsset string) (float64, error) {
+	var gas = config.Get().ActiveNetwork().Gas
+	gasPrice, err := c.dexSourceProvider.Web3Service().GetGasPrice()
+
+	if err != nil {
+		return 0, fmt.Errorf("error getting gas price: %w", err)
+	}
+
+	var gasWei = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas.SwapGasUnits))
+	var gasNative = units.NewAmount(gasWei, 18).Float64()
+
+	if gas.NativeTicker == quoteAsset {
+		return gasNative, nil
+	}
+	if price := c.cexSourceProvider.GetSymbolPrice(gas.NativeTicker + quoteAsset); price != nil {
+		return gasNative * (price.BestBid + price.BestAsk) / 2, nil
+	}
+	if price := c.cexSourceProvider.GetSymbolPrice(quoteAsset + gas.NativeTicker); price != nil {
+		return gasNative / ((price.BestBid + price.BestAsk) / 2), nil
+	}
+
+	return 0, fmt.Errorf("can't price %s in %s", gas.NativeTicker, quoteAsset)
+}
+
+// orderbookFill ... the result of consuming orderbook levels
+type orderbookFill struct {
+	baseAmount  float64
+	quoteAmount float64
+	levels      int
+	filled      bool
+}
+
+// vwap ... volume weighted average price of the fill
+func (o orderbookFill) vwap() float64 {
+	if o.baseAmount == 0 {
+		return 0
+	}
+
+	return o.quoteAmount / o.baseAmount
+}
+
+// buyOnOrderbook ... spends quoteAmount of the quote asset on the asks
+func buyOnOrderbook(asks []*sourceprovider.OrderbookEntry, quoteAmount float64) orderbookFill {
+	var fill orderbookFill
+	var remaining = quoteAmount
+
+	for _, level := range asks {
+		if remaining <= 0 {
+			break
+		}
+		var levelQuote = math.Min(remaining, level.Price*level.Quantity)
+		fill.quoteAmount += levelQuote
+		fill.baseAmount += levelQuote / level.Price
+		fill.levels++
+		remaining -= levelQuote
+	}
+	fill.filled = remaining <= 0
+
+	return fill
+}
+
+// sellOnOrderbook ... sells baseAmount of the base asset on the bids
+func sellOnOrderbook(bids []*sourceprovider.OrderbookEntry, baseAmount float64) orderbookFill {
+	var fill orderbookFill
+	var remaining = baseAmount
+
+	for _, level := range bids {
+		if remaining <= 0 {
+			break
+		}
+		var levelBase = math.Min(remaining, level.Quantity)
+		fill.baseAmount += levelBase
+		fill.quoteAmount += levelBase * level.Price
+		fill.levels++
+		remaining -= levelBase
+	}
+	fill.filled = remaining <= 0
+
+	return fill
+}
@@ -16,6 +16,9 @@ type TriangularDexPrice struct {
 	pairBToken1 float64
 	pairCToken0 float64
 	pairCToken1 float64
+	blockNumber uint64
 }
 
 const MinSurfaceRate float64 = 0.0 // the rate that indicates the arbitrage is profitable or not (and to prevent tiny wins)
+
+const PancakeswapV2Fee float64 = 0.0025 // swap fee of the PancakeSwap V2 pools (0.25%)
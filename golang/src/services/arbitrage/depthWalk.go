@@ -0,0 +1,107 @@
+package arbitrage
+
+import (
+	"arbitrage-bot/models"
+	"arbitrage-bot/services/sourceprovider"
+	"math"
+)
+
+// walkOrderbook ... consumes the levels of a reformatted orderbook (price = output per unit of input, quantity = input
+// absorbed by the level) with amountIn, the part the book can't absorb is reported as unfilled instead of failing
+// the whole trade
+func walkOrderbook(amountIn float64, orderbook []*sourceprovider.OrderbookEntry) models.TriangularArbDepthLeg {
+	var leg = models.TriangularArbDepthLeg{Unfilled: amountIn}
+
+	for _, level := range orderbook {
+		if leg.Unfilled <= 0 {
+			break
+		}
+		if level.Price <= 0 || level.Quantity <= 0 {
+			continue
+		}
+
+		var quantity = math.Min(leg.Unfilled, level.Quantity)
+		leg.AmountIn += quantity
+		leg.AmountOut += quantity * level.Price
+		leg.Unfilled -= quantity
+		leg.WorstPrice = level.Price
+		leg.Levels++
+	}
+
+	leg.Unfilled = math.Max(leg.Unfilled, 0)
+	leg.Filled = leg.Unfilled == 0
+
+	if leg.AmountIn > 0 {
+		leg.VWAP = leg.AmountOut / leg.AmountIn
+	}
+	if bestPrice := bestLevelPrice(orderbook); bestPrice > 0 && leg.VWAP > 0 {
+		// the output per unit of input only gets worse deeper in the book
+		leg.PriceImpact = 1 - leg.VWAP/bestPrice
+	}
+
+	return leg
+}
+
+// orderbookCapacity ... returns the input the whole orderbook can absorb
+func orderbookCapacity(orderbook []*sourceprovider.OrderbookEntry) float64 {
+	var capacity float64
+
+	for _, level := range orderbook {
+		if level.Price > 0 && level.Quantity > 0 {
+			capacity += level.Quantity
+		}
+	}
+
+	return capacity
+}
+
+// inputForOutput ... returns the input needed to receive amountOut from the orderbook (the capacity of the book if it
+// can't produce that much)
+func inputForOutput(amountOut float64, orderbook []*sourceprovider.OrderbookEntry) float64 {
+	var remaining = amountOut
+	var amountIn float64
+
+	for _, level := range orderbook {
+		if remaining <= 0 {
+			break
+		}
+		if level.Price <= 0 || level.Quantity <= 0 {
+			continue
+		}
+
+		var quantity = math.Min(remaining/level.Price, level.Quantity)
+		amountIn += quantity
+		remaining -= quantity * level.Price
+	}
+
+	return amountIn
+}
+
+// maxFillableAmount ... returns the largest starting amount every leg can fill completely: walking the legs backwards,
+// the input a leg can take is capped by the output the next leg can absorb
+func maxFillableAmount(orderbooks [][]*sourceprovider.OrderbookEntry) float64 {
+	var maxAmount = math.Inf(1)
+
+	for i := len(orderbooks) - 1; i >= 0; i-- {
+		var capacity = orderbookCapacity(orderbooks[i])
+
+		if !math.IsInf(maxAmount, 1) {
+			// the output of this leg is the input of the next one
+			capacity = math.Min(capacity, inputForOutput(maxAmount, orderbooks[i]))
+		}
+		maxAmount = capacity
+	}
+
+	return maxAmount
+}
+
+// bestLevelPrice ... returns the price of the first usable level
+func bestLevelPrice(orderbook []*sourceprovider.OrderbookEntry) float64 {
+	for _, level := range orderbook {
+		if level.Price > 0 && level.Quantity > 0 {
+			return level.Price
+		}
+	}
+
+	return 0
+}
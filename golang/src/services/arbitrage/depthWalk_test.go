@@ -0,0 +1,89 @@
+package arbitrage
+
+import (
+	"arbitrage-bot/services/sourceprovider"
+	"math"
+	"testing"
+)
+
+// approx ... whether two floats are equal up to the rounding of the walk
+func approx(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+func TestReformatOrderbook(t *testing.T) {
+	var calculator = &ArbitrageCalculator{}
+	var orderbook = &sourceprovider.SymbolOrderbookDepth{
+		Asks: []*sourceprovider.OrderbookEntry{{Price: 100, Quantity: 2}, {Price: 125, Quantity: 4}},
+		Bids: []*sourceprovider.OrderbookEntry{{Price: 99, Quantity: 3}},
+	}
+
+	// buying the base with the quote: a level absorbs price * quantity quote & gives 1/price base per quote, 200 quote
+	// buy the 2 base of the first ask (the previous quantity * 1/price gave 0.02 quote)
+	var asks = calculator.reformatOrderbook("baseToQuote", orderbook)
+
+	if len(asks) != 2 || !approx(asks[0].Price, 0.01) || !approx(asks[0].Quantity, 200) ||
+		!approx(asks[1].Price, 0.008) || !approx(asks[1].Quantity, 500) {
+		t.Fatalf("baseToQuote levels = %v %v, want 0.01/200 & 0.008/500", *asks[0], *asks[1])
+	}
+	if leg := walkOrderbook(200, asks); !leg.Filled || !approx(leg.AmountOut, 2) {
+		t.Errorf("walk of 200 quote = %+v, want the 2 base of the first ask", leg)
+	}
+
+	// selling the base: a bid absorbs its quantity of base at its price
+	var bids = calculator.reformatOrderbook("quoteToBase", orderbook)
+
+	if len(bids) != 1 || bids[0].Price != 99 || bids[0].Quantity != 3 {
+		t.Fatalf("quoteToBase levels = %v, want 99/3", *bids[0])
+	}
+}
+
+func TestWalkOrderbook(t *testing.T) {
+	var orderbook = []*sourceprovider.OrderbookEntry{
+		{Price: 2, Quantity: 10},
+		{Price: 0, Quantity: 5}, // unusable
+		{Price: 1.5, Quantity: 10},
+	}
+
+	for _, test := range []struct {
+		name      string
+		amountIn  float64
+		amountOut float64
+		unfilled  float64
+		levels    int
+		worst     float64
+	}{
+		{name: "first level", amountIn: 5, amountOut: 10, levels: 1, worst: 2},
+		{name: "two levels", amountIn: 15, amountOut: 27.5, levels: 2, worst: 1.5},
+		{name: "partial fill", amountIn: 30, amountOut: 35, unfilled: 10, levels: 2, worst: 1.5},
+	} {
+		var leg = walkOrderbook(test.amountIn, orderbook)
+
+		if !approx(leg.AmountOut, test.amountOut) || !approx(leg.Unfilled, test.unfilled) ||
+			leg.Filled != (test.unfilled == 0) || leg.Levels != test.levels || leg.WorstPrice != test.worst {
+			t.Errorf("%s: walk = %+v", test.name, leg)
+		}
+		var vwap = leg.AmountOut / leg.AmountIn
+
+		if !approx(leg.VWAP, vwap) || !approx(leg.PriceImpact, 1-vwap/2) {
+			t.Errorf("%s: VWAP %v & impact %v, want %v & %v", test.name, leg.VWAP, leg.PriceImpact, vwap, 1-vwap/2)
+		}
+	}
+
+	if leg := walkOrderbook(1, nil); leg.Filled || leg.Unfilled != 1 || leg.VWAP != 0 {
+		t.Errorf("walk of an empty book = %+v, want unfilled", leg)
+	}
+}
+
+func TestMaxFillableAmount(t *testing.T) {
+	// the first leg takes 100 & gives 2 per unit, the second only absorbs 50 (the output of 25)
+	var orderbooks = [][]*sourceprovider.OrderbookEntry{
+		{{Price: 2, Quantity: 100}},
+		{{Price: 1, Quantity: 50}},
+		{{Price: 3, Quantity: 1000}},
+	}
+
+	if amount := maxFillableAmount(orderbooks); !approx(amount, 25) {
+		t.Errorf("maxFillableAmount = %v, want 25", amount)
+	}
+}
@@ -0,0 +1,231 @@
+package arbitrage
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/models"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+	"fmt"
+	"math"
+)
+
+// SpatialSymbolMatch ... the same asset pair listed on several CEXs (provider name -> symbol)
+type SpatialSymbolMatch map[string]*sourceprovider.Symbol
+
+// SpatialArbitrageCalculator ... calculator for the arbitrage of the same asset pair across CEXs (buy low on one
+// venue, sell high on another)
+type SpatialArbitrageCalculator struct {
+	sourceProviders map[string]cex.ISourceProvider
+}
+
+// NewSpatialArbitrageCalculator ... creates a new instance of the SpatialArbitrageCalculator
+func NewSpatialArbitrageCalculator(sourceProviders ...cex.ISourceProvider) *SpatialArbitrageCalculator {
+	var providers = make(map[string]cex.ISourceProvider)
+
+	for _, sourceProvider := range sourceProviders {
+		providers[sourceProvider.GetName()] = sourceProvider
+	}
+
+	return &SpatialArbitrageCalculator{sourceProviders: providers}
+}
+
+// MatchSymbols ... groups the symbols of every provider by canonical asset pair, keeping the pairs listed on 2+
+// providers
+func (s *SpatialArbitrageCalculator) MatchSymbols(
+	symbolsByProvider map[string][]*sourceprovider.Symbol,
+) []SpatialSymbolMatch {
+	var matchesByPair = make(map[string]SpatialSymbolMatch)
+	var pairs []string
+
+	for providerName, symbols := range symbolsByProvider {
+		if _, ok := s.sourceProviders[providerName]; !ok {
+			continue
+		}
+
+		for _, symbol := range symbols {
+			var pair = symbol.GetBaseAssetID() + "/" + symbol.GetQuoteAssetID()
+
+			if _, ok := matchesByPair[pair]; !ok {
+				matchesByPair[pair] = make(SpatialSymbolMatch)
+				pairs = append(pairs, pair)
+			}
+			matchesByPair[pair][providerName] = symbol
+		}
+	}
+
+	var matches []SpatialSymbolMatch
+
+	for _, pair := range pairs {
+		if len(matchesByPair[pair]) > 1 {
+			matches = append(matches, matchesByPair[pair])
+		}
+	}
+
+	return matches
+}
+
+// CalcSpatialArb ... finds the most profitable buy/sell venue combination for the matched symbols
+func (s *SpatialArbitrageCalculator) CalcSpatialArb(match SpatialSymbolMatch) (models.SpatialArbResult, error) {
+	var bestResult models.SpatialArbResult
+	var found = false
+
+	for buyExchange, buySymbol := range match {
+		for sellExchange, sellSymbol := range match {
+			if buyExchange == sellExchange {
+				continue
+			}
+
+			result, err := s.calcDirection(buyExchange, sellExchange, buySymbol, sellSymbol)
+
+			if err == nil && (!found || result.NetProfit > bestResult.NetProfit) {
+				bestResult = result
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return bestResult, fmt.Errorf("no profitable spatial arbitrage found")
+	}
+
+	return bestResult, nil
+}
+
+// calcDirection ... calculates the opportunity of buying on buyExchange and selling on sellExchange
+func (s *SpatialArbitrageCalculator) calcDirection(
+	buyExchange string,
+	sellExchange string,
+	buySymbol *sourceprovider.Symbol,
+	sellSymbol *sourceprovider.Symbol,
+) (models.SpatialArbResult, error) {
+	var result models.SpatialArbResult
+	var buyProvider = s.sourceProviders[buyExchange]
+	var sellProvider = s.sourceProviders[sellExchange]
+	var buyConfig = config.Get().Exchange(buyExchange)
+	var sellConfig = config.Get().Exchange(sellExchange)
+	var buyPrice = buyProvider.GetSymbolPrice(buySymbol.Symbol)
+	var sellPrice = sellProvider.GetSymbolPrice(sellSymbol.Symbol)
+
+	if buyPrice == nil {
+		return result, fmt.Errorf("symbol %s not found on %s", buySymbol.Symbol, buyExchange)
+	} else if sellPrice == nil {
+		return result, fmt.Errorf("symbol %s not found on %s", sellSymbol.Symbol, sellExchange)
+	}
+	metrics.ObservePriceStaleness(buyExchange, buyPrice.EventTime)
+	metrics.ObservePriceStaleness(sellExchange, sellPrice.EventTime)
+
+	// the bid on the sell venue must exceed the ask on the buy venue after both taker fees
+	if sellPrice.BestBid*(1-sellConfig.TakerFee) <= buyPrice.BestAsk*(1+buyConfig.TakerFee) {
+		return result, fmt.Errorf("bid on %s doesn't exceed ask on %s", sellExchange, buyExchange)
+	}
+
+	var buyDepth = buyProvider.GetSymbolOrderbookDepth(buySymbol.Symbol)
+	var sellDepth = sellProvider.GetSymbolOrderbookDepth(sellSymbol.Symbol)
+
+	if buyDepth == nil {
+		return result, fmt.Errorf("orderbook of %s not found on %s", buySymbol.Symbol, buyExchange)
+	} else if sellDepth == nil {
+		return result, fmt.Errorf("orderbook of %s not found on %s", sellSymbol.Symbol, sellExchange)
+	}
+
+	// walk the asks of the buy venue & the bids of the sell venue while the marginal level is still profitable
+	var asks = s.copyLevels(buyDepth.Asks)
+	var bids = s.copyLevels(sellDepth.Bids)
+	var askIndex, bidIndex = 0, 0
+	var quantity, buyNotional, sellNotional float64
+	var levelsConsumed = 0
+
+	for askIndex < len(asks) && bidIndex < len(bids) {
+		var ask = asks[askIndex]
+		var bid = bids[bidIndex]
+
+		if bid.Price*(1-sellConfig.TakerFee) <= ask.Price*(1+buyConfig.TakerFee) {
+			break
+		}
+
+		var levelQuantity = math.Min(ask.Quantity, bid.Quantity)
+		quantity += levelQuantity
+		buyNotional += levelQuantity * ask.Price
+		sellNotional += levelQuantity * bid.Price
+		ask.Quantity -= levelQuantity
+		bid.Quantity -= levelQuantity
+
+		if ask.Quantity <= 0 {
+			askIndex++
+			levelsConsumed++
+		}
+		if bid.Quantity <= 0 {
+			bidIndex++
+			levelsConsumed++
+		}
+	}
+
+	if quantity == 0 {
+		return result, fmt.Errorf("orderbooks of %s/%s don't overlap", buyExchange, sellExchange)
+	}
+
+	// only a quantity both venues accept is traded (the VWAPs don't change)
+	var legalQuantity = roundLegInput(sellSymbol, "quoteToBase", roundLegOutput(buySymbol, "baseToQuote", quantity))
+	buyNotional *= legalQuantity / quantity
+	sellNotional *= legalQuantity / quantity
+	quantity = legalQuantity
+
+	if err := checkLegRules(buySymbol, "baseToQuote", buyNotional, quantity); err != nil {
+		return result, err
+	} else if err := checkLegRules(sellSymbol, "quoteToBase", quantity, sellNotional); err != nil {
+		return result, err
+	}
+
+	var buyVWAP = buyNotional / quantity
+	var sellVWAP = sellNotional / quantity
+	var buyTakerFee = buyNotional * buyConfig.TakerFee
+	var sellTakerFee = sellNotional * sellConfig.TakerFee
+	var withdrawalFee = buyConfig.WithdrawalFees[buySymbol.BaseAsset]
+	var withdrawalFeeQuote = withdrawalFee * sellVWAP
+	var buyCost = buyNotional + buyTakerFee
+	var sellProceeds = sellNotional - sellTakerFee
+	var grossProfit = sellNotional - buyNotional
+	var netProfit = sellProceeds - buyCost - withdrawalFeeQuote
+
+	if netProfit <= MinSurfaceRate {
+		return result, fmt.Errorf("no profitable spatial arbitrage found after fees")
+	}
+
+	return models.SpatialArbResult{
+		BaseAsset:          buySymbol.BaseAsset,
+		QuoteAsset:         buySymbol.QuoteAsset,
+		BuyExchange:        buyExchange,
+		SellExchange:       sellExchange,
+		BuySymbol:          *buySymbol,
+		SellSymbol:         *sellSymbol,
+		BestAsk:            buyPrice.BestAsk,
+		BestBid:            sellPrice.BestBid,
+		Quantity:           quantity,
+		BuyVWAP:            buyVWAP,
+		SellVWAP:           sellVWAP,
+		BuyCost:            buyCost,
+		SellProceeds:       sellProceeds,
+		BuyTakerFee:        buyTakerFee,
+		SellTakerFee:       sellTakerFee,
+		LevelsConsumed:     levelsConsumed,
+		TransferAsset:      buySymbol.BaseAsset,
+		WithdrawalFee:      withdrawalFee,
+		WithdrawalFeeQuote: withdrawalFeeQuote,
+		TransferTime:       buyConfig.TransferTime,
+		GrossProfit:        grossProfit,
+		NetProfit:          netProfit,
+		NetProfitPerc:      netProfit / buyCost * 100,
+	}, nil
+}
+
+// copyLevels ... copies the orderbook levels so the walk doesn't modify the provider's data
+func (s *SpatialArbitrageCalculator) copyLevels(levels []*sourceprovider.OrderbookEntry) []*sourceprovider.OrderbookEntry {
+	var result = make([]*sourceprovider.OrderbookEntry, len(levels))
+
+	for i, level := range levels {
+		result[i] = &sourceprovider.OrderbookEntry{Price: level.Price, Quantity: level.Quantity}
+	}
+
+	return result
+}
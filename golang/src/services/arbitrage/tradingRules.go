@@ -0,0 +1,92 @@
+package arbitrage
+
+import (
+	"arbitrage-bot/services/sourceprovider"
+	"fmt"
+)
+
+// roundLegInput ... rounds the input of a CEX trade to what the exchange accepts: the base quantity sold by a
+// quoteToBase trade is floored to the step size, the quote amount spent by a baseToQuote trade to the quote precision
+func roundLegInput(symbol *sourceprovider.Symbol, directionTrade string, amountIn float64) float64 {
+	if symbol == nil || symbol.Rules == nil {
+		return amountIn
+	}
+	if directionTrade == "quoteToBase" {
+		return symbol.Rules.RoundQuantity(amountIn)
+	}
+
+	return symbol.Rules.RoundQuoteAmount(amountIn)
+}
+
+// roundLegOutput ... floors the base quantity bought by a baseToQuote trade to the step size (spending a quote
+// amount only buys whole steps)
+func roundLegOutput(symbol *sourceprovider.Symbol, directionTrade string, amountOut float64) float64 {
+	if symbol == nil || symbol.Rules == nil || directionTrade != "baseToQuote" {
+		return amountOut
+	}
+
+	return symbol.Rules.RoundQuantity(amountOut)
+}
+
+// checkLegRules ... rejects a trade the exchange would refuse: a halted symbol, a quantity below the lot size or a
+// notional below the minimum (the notional is the quote side, the input of a baseToQuote trade & the output of a
+// quoteToBase trade)
+func checkLegRules(symbol *sourceprovider.Symbol, directionTrade string, amountIn float64, amountOut float64) error {
+	if symbol == nil || symbol.Rules == nil {
+		return nil
+	}
+	if !symbol.Rules.IsTradable() {
+		return fmt.Errorf("%s isn't tradable (status %s)", symbol.Symbol, symbol.Rules.Status)
+	}
+	if amountIn <= 0 || amountOut <= 0 {
+		return fmt.Errorf("%s trade of %v is below the lot size", symbol.Symbol, amountIn)
+	}
+
+	var notional = amountOut
+
+	if directionTrade == "baseToQuote" {
+		notional = amountIn
+	}
+	if notional < symbol.Rules.MinNotional {
+		return fmt.Errorf("%s trade of %v %s is below the min notional %v", symbol.Symbol, notional, symbol.QuoteAsset,
+			symbol.Rules.MinNotional)
+	}
+
+	return nil
+}
+
+// simulateLegalTrades ... replays the trades of a surface path at their rates with every amount rounded to the
+// trading rules of the symbol, returns the acquired coin of each trade
+func simulateLegalTrades(
+	startingAmount float64, symbols [3]*sourceprovider.Symbol, directionTrades [3]string, swapRates [3]float64,
+) ([3]float64, error) {
+	var acquiredCoins [3]float64
+	var amount = startingAmount
+
+	for i := range symbols {
+		var amountIn = roundLegInput(symbols[i], directionTrades[i], amount)
+		var amountOut = roundLegOutput(symbols[i], directionTrades[i], amountIn*swapRates[i])
+
+		if err := checkLegRules(symbols[i], directionTrades[i], amountIn, amountOut); err != nil {
+			return acquiredCoins, err
+		}
+		acquiredCoins[i] = amountOut
+		amount = amountOut
+	}
+
+	return acquiredCoins, nil
+}
+
+// roundFillQuantity ... floors the base quantity of an orderbook fill to the step size of the symbol, the quote amount
+// is scaled with it (the VWAP doesn't change)
+func roundFillQuantity(symbol *sourceprovider.Symbol, fill orderbookFill) orderbookFill {
+	if symbol == nil || symbol.Rules == nil || fill.baseAmount <= 0 {
+		return fill
+	}
+
+	var quantity = symbol.Rules.RoundQuantity(fill.baseAmount)
+	fill.quoteAmount *= quantity / fill.baseAmount
+	fill.baseAmount = quantity
+
+	return fill
+}
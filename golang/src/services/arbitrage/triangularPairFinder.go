@@ -2,21 +2,64 @@ package arbitrage
 
 import (
 	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokensafety"
 	"slices"
 	"sort"
 	"strings"
 )
 
-type TriangularPairFinder struct{}
+type TriangularPairFinder struct {
+	SafetyList *tokensafety.List // optional, pairs with a denied token are skipped
+}
 
 func (t *TriangularPairFinder) Handle(pairsList []*sourceprovider.Symbol) [][3]*sourceprovider.Symbol {
-	// find a list of 3 arbitrage pairs (f.e. SEIBNB BNBBTC SEIBTC)
+	pairsList = t.filterDenied(pairsList)
+
+	return t.find(pairsList, pairsList, nil)
+}
+
+// HandleNewPairs ... updates the cached triangular pairs: drops the ones with a pair missing from pairsList & adds
+// the ones containing one of newPairs (every pair of a triangle shares an asset with the 2 others, so a new triangle
+// is always found starting from its new pair)
+func (t *TriangularPairFinder) HandleNewPairs(
+	pairsList []*sourceprovider.Symbol,
+	newPairs []*sourceprovider.Symbol,
+	cachedTriangularPairs [][3]*sourceprovider.Symbol,
+) [][3]*sourceprovider.Symbol {
+	pairsList = t.filterDenied(pairsList)
+	newPairs = t.filterDenied(newPairs)
+	var pairKeys = make(map[string]bool)
 	var triangularPairsList [][3]*sourceprovider.Symbol
+
+	for _, pair := range pairsList {
+		pairKeys[t.pairKey(pair)] = true
+	}
+	for _, triangularPair := range cachedTriangularPairs {
+		if pairKeys[t.pairKey(triangularPair[0])] && pairKeys[t.pairKey(triangularPair[1])] &&
+			pairKeys[t.pairKey(triangularPair[2])] {
+			triangularPairsList = append(triangularPairsList, triangularPair)
+		}
+	}
+
+	return t.find(newPairs, pairsList, triangularPairsList)
+}
+
+// find ... finds the triangles starting from the pairs of startPairs, appended to the (deduplicated) existing ones
+func (t *TriangularPairFinder) find(
+	startPairs []*sourceprovider.Symbol,
+	pairsList []*sourceprovider.Symbol,
+	triangularPairsList [][3]*sourceprovider.Symbol,
+) [][3]*sourceprovider.Symbol {
+	// find a list of 3 arbitrage pairs (f.e. SEIBNB BNBBTC SEIBTC), assets are matched by their canonical ids
 	var removeDuplicatesMap = make(map[string]bool)
 
+	for _, triangularPair := range triangularPairsList {
+		removeDuplicatesMap[t.triangleKey(triangularPair[0], triangularPair[1], triangularPair[2])] = true
+	}
+
 	// get pair A
-	for _, pairA := range pairsList {
-		var aPairBox = []string{pairA.BaseAsset, pairA.QuoteAsset}
+	for _, pairA := range startPairs {
+		var aPairBox = []string{pairA.GetBaseAssetID(), pairA.GetQuoteAssetID()}
 
 		// get pair B
 		for _, pairB := range pairsList {
@@ -25,7 +68,7 @@ func (t *TriangularPairFinder) Handle(pairsList []*sourceprovider.Symbol) [][3]*
 			}
 
 			// if three pairs form a cycle, continue
-			if slices.Contains(aPairBox, pairB.BaseAsset) || slices.Contains(aPairBox, pairB.QuoteAsset) {
+			if slices.Contains(aPairBox, pairB.GetBaseAssetID()) || slices.Contains(aPairBox, pairB.GetQuoteAssetID()) {
 				// get pair C
 				for _, pairC := range pairsList {
 					if pairC == pairA || pairC == pairB || pairA == pairB {
@@ -33,33 +76,29 @@ func (t *TriangularPairFinder) Handle(pairsList []*sourceprovider.Symbol) [][3]*
 					}
 
 					pairBox := []string{
-						pairA.BaseAsset,
-						pairA.QuoteAsset,
-						pairB.BaseAsset,
-						pairB.QuoteAsset,
-						pairC.BaseAsset,
-						pairC.QuoteAsset,
+						pairA.GetBaseAssetID(),
+						pairA.GetQuoteAssetID(),
+						pairB.GetBaseAssetID(),
+						pairB.GetQuoteAssetID(),
+						pairC.GetBaseAssetID(),
+						pairC.GetQuoteAssetID(),
 					}
 
 					var countsCBase = 0
 					var countsCQuote = 0
 
 					for _, value := range pairBox {
-						if value == pairC.BaseAsset {
+						if value == pairC.GetBaseAssetID() {
 							countsCBase++
 						}
-						if value == pairC.QuoteAsset {
+						if value == pairC.GetQuoteAssetID() {
 							countsCQuote++
 						}
 					}
 
 					// found a triangular match
-					if pairC.BaseAsset != pairC.QuoteAsset && countsCBase == 2 && countsCQuote == 2 {
-						var pairSymbols = []string{pairA.Symbol, pairB.Symbol, pairC.Symbol}
-						sort.Slice(pairSymbols, func(i, j int) bool {
-							return pairSymbols[i] < pairSymbols[j]
-						})
-						var key = strings.Join(pairSymbols, "_")
+					if pairC.GetBaseAssetID() != pairC.GetQuoteAssetID() && countsCBase == 2 && countsCQuote == 2 {
+						var key = t.triangleKey(pairA, pairB, pairC)
 
 						if _, ok := removeDuplicatesMap[key]; !ok {
 							triangularPairsList = append(triangularPairsList, [3]*sourceprovider.Symbol{
@@ -77,3 +116,39 @@ func (t *TriangularPairFinder) Handle(pairsList []*sourceprovider.Symbol) [][3]*
 
 	return triangularPairsList
 }
+
+// filterDenied ... drops the pairs containing a token denied by the safety list
+func (t *TriangularPairFinder) filterDenied(pairsList []*sourceprovider.Symbol) []*sourceprovider.Symbol {
+	if t.SafetyList == nil {
+		return pairsList
+	}
+
+	var allowedPairs []*sourceprovider.Symbol
+
+	for _, pair := range pairsList {
+		if t.SafetyList.IsSymbolAllowed(pair) {
+			allowedPairs = append(allowedPairs, pair)
+		}
+	}
+
+	return allowedPairs
+}
+
+// triangleKey ... identifies a triangle regardless of the order of its pairs
+func (t *TriangularPairFinder) triangleKey(pairA, pairB, pairC *sourceprovider.Symbol) string {
+	var pairSymbols = []string{t.pairKey(pairA), t.pairKey(pairB), t.pairKey(pairC)}
+	sort.Slice(pairSymbols, func(i, j int) bool {
+		return pairSymbols[i] < pairSymbols[j]
+	})
+
+	return strings.Join(pairSymbols, "_")
+}
+
+// pairKey ... identifies a pair by its pool address (DEX) or its symbol (CEX), display names can be shared
+func (t *TriangularPairFinder) pairKey(pair *sourceprovider.Symbol) string {
+	if pair.Address != "" {
+		return pair.Address
+	}
+
+	return pair.Symbol
+}
@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/rpcpool"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"math/big"
+)
+
+// PoolCreatedEvent ... Represents the pool creation event of a factory
+type PoolCreatedEvent struct {
+	Signature     string
+	PoolWordIndex int // index of the 32 bytes word of the log data holding the pool address
+}
+
+// PancakeswapPairCreated ... PairCreated(token0 indexed, token1 indexed, pair, index) of the V2 factory
+var PancakeswapPairCreated = PoolCreatedEvent{Signature: "PairCreated(address,address,address,uint256)", PoolWordIndex: 0}
+
+// UniswapPoolCreated ... PoolCreated(token0 indexed, token1 indexed, fee indexed, tickSpacing, pool) of the V3 factory
+var UniswapPoolCreated = PoolCreatedEvent{Signature: "PoolCreated(address,address,uint24,int24,address)", PoolWordIndex: 1}
+
+// EventScanner ... reads the pools created by a factory from its logs, block range by block range
+type EventScanner struct {
+	client    *ethclient.Client
+	factory   common.Address
+	event     PoolCreatedEvent
+	chunkSize uint64
+}
+
+// NewEventScanner ... creates a new EventScanner for a factory of the selected network
+func NewEventScanner(factory string, event PoolCreatedEvent) *EventScanner {
+	var network = config.Get().ActiveNetwork()
+
+	return &EventScanner{
+		client:    rpcpool.Get().Client(),
+		factory:   common.HexToAddress(factory),
+		event:     event,
+		chunkSize: network.Discovery.LogChunkSize,
+	}
+}
+
+// LatestBlock ... returns the latest block number
+func (e *EventScanner) LatestBlock() (uint64, error) {
+	return e.client.BlockNumber(context.Background())
+}
+
+// Scan ... scans the blocks (fromBlock, toBlock] in chunks, handle receives the pools created in a chunk & its last
+// block (to checkpoint it), scanning stops at the first error
+func (e *EventScanner) Scan(
+	fromBlock uint64,
+	toBlock uint64,
+	handle func(pools []common.Address, lastBlock uint64) error,
+) error {
+	var topic = crypto.Keccak256Hash([]byte(e.event.Signature))
+
+	for start := fromBlock + 1; start <= toBlock; start += e.chunkSize {
+		var end = min(start+e.chunkSize-1, toBlock)
+		logs, err := e.client.FilterLogs(context.Background(), ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{e.factory},
+			Topics:    [][]common.Hash{{topic}},
+		})
+
+		if err != nil {
+			return fmt.Errorf("error reading %s logs of blocks %d-%d: %w", e.event.Signature, start, end, err)
+		}
+
+		var pools []common.Address
+
+		for _, log := range logs {
+			var offset = e.event.PoolWordIndex * 32
+
+			if len(log.Data) < offset+32 {
+				continue
+			}
+			pools = append(pools, common.BytesToAddress(log.Data[offset:offset+32]))
+		}
+		if err = handle(pools, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/web3"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ReserveReader ... reads the reserves of pools in bulk (implemented by the DEX web3 services)
+type ReserveReader interface {
+	GetPoolsReserves(symbols []*sp.Symbol) (map[string]web3.PoolReserves, error)
+}
+
+// PoolFilterResult ... Represents the decision of the filter for a pool
+type PoolFilterResult struct {
+	Pool      string  `json:"pool"`
+	Symbol    string  `json:"symbol"`
+	Liquidity float64 `json:"liquidity"` // in the quote currency, 0 if it couldn't be priced
+	Volume    float64 `json:"volume"`    // in the quote currency, -1 if the source has no volume
+	Kept      bool    `json:"kept"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// PoolFilter ... filter pipeline of the discovered pools: reserves -> common quote currency -> minimum liquidity &
+// volume -> top N pools per token
+type PoolFilter struct {
+	settings config.LiquidityConfig
+	reader   ReserveReader
+}
+
+// NewPoolFilter ... creates a new PoolFilter with the settings of the selected network
+func NewPoolFilter(reader ReserveReader) *PoolFilter {
+	return &PoolFilter{
+		settings: config.Get().ActiveNetwork().Discovery.Liquidity,
+		reader:   reader,
+	}
+}
+
+// FilterReportPath ... returns the path of the filter report, stored next to the cached pairs
+func FilterReportPath(arbitragePairCachePath string) string {
+	return strings.TrimSuffix(arbitragePairCachePath, ".json") + "Filter.json"
+}
+
+// Handle ... returns the pools passing the filter & the decision for every pool, volumes (pool address -> volume in
+// the quote currency) are optional
+func (f *PoolFilter) Handle(symbols []*sp.Symbol, volumes map[string]float64) ([]*sp.Symbol, []*PoolFilterResult) {
+	var reserves = f.fetchReserves(symbols)
+	var prices = f.priceTokens(symbols, reserves)
+	var results = make([]*PoolFilterResult, len(symbols))
+	var candidates []int
+
+	for i, symbol := range symbols {
+		var result = &PoolFilterResult{Pool: symbol.Address, Symbol: symbol.Symbol, Volume: -1}
+		results[i] = result
+
+		if volume, ok := volumes[symbol.Address]; ok {
+			result.Volume = volume
+		}
+
+		poolReserves, ok := reserves[symbol.Address]
+
+		if !ok {
+			result.Reason = "reserves unavailable"
+			continue
+		}
+
+		liquidity, ok := f.getLiquidity(symbol, poolReserves, prices)
+
+		if !ok {
+			result.Reason = "no price path to the quote assets"
+			continue
+		}
+		result.Liquidity = liquidity
+
+		if liquidity < f.settings.MinLiquidity {
+			result.Reason = fmt.Sprintf("liquidity %.2f below %.2f", liquidity, f.settings.MinLiquidity)
+		} else if result.Volume >= 0 && result.Volume < f.settings.MinVolume {
+			result.Reason = fmt.Sprintf("volume %.2f below %.2f", result.Volume, f.settings.MinVolume)
+		} else {
+			candidates = append(candidates, i)
+		}
+	}
+
+	var kept = f.keepTopPools(symbols, results, candidates)
+	slog.Info("Filtered pools", slog.Int("total", len(symbols)), slog.Int("kept", len(kept)))
+
+	return kept, results
+}
+
+// SaveResults ... writes the filter decisions next to the cached pairs
+func (f *PoolFilter) SaveResults(arbitragePairCachePath string, results []*PoolFilterResult) error {
+	return jsonHelper.WriteJSONFile(FilterReportPath(arbitragePairCachePath), results)
+}
+
+// fetchReserves ... reads the reserves of every pool (pool address -> reserves), a failed read leaves every pool
+// unpriced
+func (f *PoolFilter) fetchReserves(symbols []*sp.Symbol) map[string]web3.PoolReserves {
+	reserves, err := f.reader.GetPoolsReserves(symbols)
+
+	if err != nil {
+		slog.Warn("Error fetching reserves", slog.Any("error", err))
+		return map[string]web3.PoolReserves{}
+	}
+
+	return reserves
+}
+
+// priceTokens ... prices the tokens in the quote currency (canonical id -> price), starting from the quote assets &
+// walking the pools, the deepest pool connecting a token to a priced token sets its price
+func (f *PoolFilter) priceTokens(symbols []*sp.Symbol, reserves map[string]web3.PoolReserves) map[string]float64 {
+	var prices = make(map[string]float64)
+
+	for _, quoteAsset := range f.settings.QuoteAssets {
+		prices[quoteAsset] = 1
+	}
+
+	for {
+		var bestPrices = make(map[string]float64)
+		var bestDepths = make(map[string]float64)
+
+		for _, symbol := range symbols {
+			var poolReserves, ok = reserves[symbol.Address]
+
+			if !ok || poolReserves.Price <= 0 {
+				continue
+			}
+
+			var baseID, quoteID = symbol.GetBaseAssetID(), symbol.GetQuoteAssetID()
+			var basePrice, baseOk = prices[baseID]
+			var quotePrice, quoteOk = prices[quoteID]
+			var token string
+			var price, depth float64
+
+			if baseOk && !quoteOk {
+				token, price, depth = quoteID, basePrice/poolReserves.Price, poolReserves.Reserve0*basePrice
+			} else if quoteOk && !baseOk {
+				token, price, depth = baseID, quotePrice*poolReserves.Price, poolReserves.Reserve1*quotePrice
+			} else {
+				continue
+			}
+
+			// a dust pool would give the token any price
+			if depth*2 >= f.settings.MinLiquidity && depth > bestDepths[token] {
+				bestPrices[token] = price
+				bestDepths[token] = depth
+			}
+		}
+
+		if len(bestPrices) == 0 {
+			return prices
+		}
+		for token, price := range bestPrices {
+			prices[token] = price
+		}
+	}
+}
+
+// getLiquidity ... returns the value of the pool reserves in the quote currency (the priced side counts twice if
+// only one token has a price)
+func (f *PoolFilter) getLiquidity(
+	symbol *sp.Symbol,
+	poolReserves web3.PoolReserves,
+	prices map[string]float64,
+) (float64, bool) {
+	var basePrice, baseOk = prices[symbol.GetBaseAssetID()]
+	var quotePrice, quoteOk = prices[symbol.GetQuoteAssetID()]
+
+	if baseOk && quoteOk {
+		return poolReserves.Reserve0*basePrice + poolReserves.Reserve1*quotePrice, true
+	} else if baseOk {
+		return poolReserves.Reserve0 * basePrice * 2, true
+	} else if quoteOk {
+		return poolReserves.Reserve1 * quotePrice * 2, true
+	}
+
+	return 0, false
+}
+
+// keepTopPools ... keeps the candidates ranking in the top N pools of at least one of their tokens (so the only pool
+// of a small token isn't dropped because of the many pools of its pair token)
+func (f *PoolFilter) keepTopPools(symbols []*sp.Symbol, results []*PoolFilterResult, candidates []int) []*sp.Symbol {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return results[candidates[i]].Liquidity > results[candidates[j]].Liquidity
+	})
+
+	var poolsPerToken = make(map[string]int)
+	var kept []int
+
+	for _, index := range candidates {
+		var symbol = symbols[index]
+		var baseID, quoteID = symbol.GetBaseAssetID(), symbol.GetQuoteAssetID()
+		poolsPerToken[baseID]++
+		poolsPerToken[quoteID]++
+
+		if f.settings.TopPoolsPerToken > 0 &&
+			poolsPerToken[baseID] > f.settings.TopPoolsPerToken &&
+			poolsPerToken[quoteID] > f.settings.TopPoolsPerToken {
+			results[index].Reason = fmt.Sprintf("not in the top %d pools of its tokens", f.settings.TopPoolsPerToken)
+			continue
+		}
+		results[index].Kept = true
+		kept = append(kept, index)
+	}
+
+	// keep the discovery order
+	slices.Sort(kept)
+	var keptSymbols = make([]*sp.Symbol, len(kept))
+
+	for i, index := range kept {
+		keptSymbols[i] = symbols[index]
+	}
+
+	return keptSymbols
+}
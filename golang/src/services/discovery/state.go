@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"arbitrage-bot/config"
+	fileHelper "arbitrage-bot/helpers/file"
+	jsonHelper "arbitrage-bot/helpers/json"
+	sp "arbitrage-bot/services/sourceprovider"
+	"strings"
+)
+
+// State ... Represents the progress of an incremental pool discovery, saved after every batch so an interrupted run
+// resumes where it stopped
+type State struct {
+	NextIndex     uint64       `json:"nextIndex"`     // next factory index to read (index based backfill)
+	Block         uint64       `json:"block"`         // last block scanned for pool creation events
+	Symbols       []*sp.Symbol `json:"symbols"`       // every discovered pool
+	TrianglePools []string     `json:"trianglePools"` // pools the cached triangular pairs were computed from
+}
+
+// StatePath ... returns the path of the discovery state of a provider on the selected network
+func StatePath(provider string) string {
+	return "data/" + config.Get().Network + "/" + provider + "Discovery.json"
+}
+
+// LoadState ... reads the discovery state, a missing file gives an empty state
+func LoadState(path string) (*State, error) {
+	var state State
+
+	if !fileHelper.PathExists(path) {
+		return &state, nil
+	}
+	if err := jsonHelper.ReadJSONFile(path, &state); err != nil {
+		return nil, err
+	}
+	// states saved before the fee tier was part of the Uniswap names
+	sp.MigrateSymbolNames(state.Symbols...)
+
+	return &state, nil
+}
+
+// Save ... writes the state (atomically)
+func (s *State) Save(path string) error {
+	return jsonHelper.WriteJSONFileAtomic(path, s)
+}
+
+// AddSymbols ... adds the pools which aren't known yet, returns the added ones
+func (s *State) AddSymbols(symbols []*sp.Symbol) []*sp.Symbol {
+	var known = make(map[string]bool)
+	var added []*sp.Symbol
+
+	for _, symbol := range s.Symbols {
+		known[strings.ToLower(symbol.Address)] = true
+	}
+	for _, symbol := range symbols {
+		var address = strings.ToLower(symbol.Address)
+
+		if !known[address] {
+			known[address] = true
+			s.Symbols = append(s.Symbols, symbol)
+			added = append(added, symbol)
+		}
+	}
+
+	return added
+}
+
+// AffectedPools ... returns the pools of the list which weren't used for the cached triangular pairs
+func (s *State) AffectedPools(symbols []*sp.Symbol) []*sp.Symbol {
+	var previous = make(map[string]bool)
+	var affected []*sp.Symbol
+
+	for _, address := range s.TrianglePools {
+		previous[strings.ToLower(address)] = true
+	}
+	for _, symbol := range symbols {
+		if !previous[strings.ToLower(symbol.Address)] {
+			affected = append(affected, symbol)
+		}
+	}
+
+	return affected
+}
+
+// SetTrianglePools ... records the pools the cached triangular pairs were computed from
+func (s *State) SetTrianglePools(symbols []*sp.Symbol) {
+	s.TrianglePools = make([]string, len(symbols))
+
+	for i, symbol := range symbols {
+		s.TrianglePools[i] = symbol.Address
+	}
+}
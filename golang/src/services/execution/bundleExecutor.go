@@ -0,0 +1,281 @@
+package execution
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"arbitrage-bot/services/web3"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// inclusionPollInterval ... interval between two checks of a submitted bundle
+const inclusionPollInterval = 3 * time.Second
+
+// BundleExecutor ... Executes DEX plans as bundles sent to a private relay, the inclusion is tracked until the last
+// target block is mined
+type BundleExecutor struct {
+	submitter   *web3.BundleSubmitter
+	web3Service web3.DEXWeb3Service // prices the tip in the native token
+	symbols     []*sp.Symbol
+	tipShare    float64
+	chainID     int64
+	nativeID    string // canonical id of the native token
+	statuses    *statusBook
+
+	mutex   sync.Mutex
+	bundles map[string]*web3.Bundle // plan id -> bundle waiting for inclusion
+
+	tracking sync.WaitGroup
+	closing  chan struct{} // closed when the executor gives up tracking the bundles
+	once     sync.Once
+}
+
+// NewBundleExecutor ... creates a new bundle executor for the relay of the selected network
+func NewBundleExecutor(symbols []*sp.Symbol, web3Service web3.DEXWeb3Service) (*BundleExecutor, error) {
+	var network = config.Get().ActiveNetwork()
+	submitter, err := web3.NewBundleSubmitter()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BundleExecutor{
+		submitter:   submitter,
+		web3Service: web3Service,
+		symbols:     symbols,
+		tipShare:    network.Relay.TipShare,
+		chainID:     network.ChainID,
+		nativeID:    tokenregistry.Get().IDForTicker("", network.Gas.NativeTicker),
+		statuses:    newStatusBook(BundleExecutorName),
+		bundles:     make(map[string]*web3.Bundle),
+		closing:     make(chan struct{}),
+	}, nil
+}
+
+// GetName ... returns the name of the executor
+func (b *BundleExecutor) GetName() string {
+	return BundleExecutorName
+}
+
+// Prepare ... checks the plan has an on-chain route & a token to borrow
+func (b *BundleExecutor) Prepare(plan *Plan) error {
+	var _, err = newFlashSwapRoute(plan, b.symbols)
+	b.statuses.set(plan, StatePrepared, err)
+
+	return err
+}
+
+// Simulate ... estimates the gas of the swapIn call, a route no longer profitable reverts
+func (b *BundleExecutor) Simulate(plan *Plan) (*Status, error) {
+	route, err := newFlashSwapRoute(plan, b.symbols)
+
+	if err != nil {
+		return b.statuses.set(plan, StateSimulated, err), err
+	}
+
+	gas, err := b.submitter.Executor().EstimateArbitrage(route.tradePaths, plan.ExactAmountIn, route.loanAddress)
+
+	if err != nil {
+		err = fmt.Errorf("error simulating plan %s: %w", plan.ID, err)
+		return b.statuses.set(plan, StateSimulated, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSimulated,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+		Reference:  fmt.Sprintf("gas %d", gas),
+	}
+	b.statuses.put(status)
+
+	return status, nil
+}
+
+// Execute ... submits the swapIn transaction as a bundle with a tip from the expected profit, the plan stays
+// submitted until the transaction is mined or the target blocks are
+func (b *BundleExecutor) Execute(plan *Plan) (*Status, error) {
+	route, err := newFlashSwapRoute(plan, b.symbols)
+
+	if err != nil {
+		return b.statuses.set(plan, StateFailed, err), err
+	}
+
+	var tip = b.tip(plan, route.tradePaths[0])
+	bundle, err := b.submitter.SubmitArbitrage(
+		route.tradePaths, plan.ExactAmountIn, route.loanAddress, tip, plan.BackRun,
+	)
+
+	if err != nil {
+		return b.statuses.set(plan, StateFailed, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSubmitted,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+		Reference:  bundle.TxHash.Hex(),
+	}
+	b.statuses.put(status)
+
+	b.mutex.Lock()
+	b.bundles[plan.ID] = bundle
+	b.mutex.Unlock()
+	slog.Info("Submitted bundle", slog.String("planId", plan.ID), slog.String("txHash", bundle.TxHash.Hex()),
+		slog.String("bundleHash", bundle.BundleHash), slog.Any("targetBlocks", bundle.TargetBlocks),
+		slog.String("tip", bundle.Tip.String()))
+	b.tracking.Add(1)
+	go b.trackInclusion(plan.ID)
+
+	return status, nil
+}
+
+// Status ... returns the last known state of a plan, a submitted bundle is checked on-chain
+func (b *BundleExecutor) Status(planID string) (*Status, bool) {
+	if status, ok := b.statuses.get(planID); ok && status.State == StateSubmitted {
+		b.refresh(planID)
+	}
+
+	return b.statuses.get(planID)
+}
+
+// Close ... waits for the submitted bundles to be included or expired, their tracking stops once the context is
+// done, then saves the nonces of the wallet
+func (b *BundleExecutor) Close(ctx context.Context) error {
+	var tracked = make(chan struct{})
+
+	go func() {
+		b.tracking.Wait()
+		close(tracked)
+	}()
+
+	var err error
+
+	select {
+	case <-tracked:
+	case <-ctx.Done():
+		err = fmt.Errorf("bundles still submitted: %w", ctx.Err())
+	}
+	b.once.Do(func() { close(b.closing) })
+
+	return errors.Join(err, b.submitter.Close())
+}
+
+// trackInclusion ... checks a submitted bundle until it's included or expired
+func (b *BundleExecutor) trackInclusion(planID string) {
+	defer b.tracking.Done()
+
+	var ticker = time.NewTicker(inclusionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.closing:
+			return
+		case <-ticker.C:
+		}
+
+		if status := b.refresh(planID); status == nil || status.State != StateSubmitted {
+			return
+		}
+	}
+}
+
+// refresh ... updates the state of a submitted bundle from its inclusion, returns the status (nil if unknown)
+func (b *BundleExecutor) refresh(planID string) *Status {
+	b.mutex.Lock()
+	var bundle, ok = b.bundles[planID]
+	b.mutex.Unlock()
+
+	status, found := b.statuses.get(planID)
+
+	if !ok || !found {
+		return status
+	}
+
+	inclusion, blockNumber, err := b.submitter.InclusionStatus(bundle)
+
+	if err != nil {
+		slog.Debug("Error checking bundle inclusion", slog.String("planId", planID), slog.Any("error", err))
+		return status
+	}
+
+	switch inclusion {
+	case web3.BundleIncluded:
+		status.State = StateSucceeded
+	case web3.BundleReverted:
+		status.State = StateFailed
+		status.Error = fmt.Sprintf("reverted in block %d", blockNumber)
+	case web3.BundleExpired:
+		status.State = StateFailed
+		status.Error = fmt.Sprintf("not included in blocks %v", bundle.TargetBlocks)
+	default:
+		return status
+	}
+
+	// a failed bundle traded nothing, a mined one paid its gas
+	if status.State == StateFailed {
+		status.AmountOut, status.ProfitLoss = 0, 0
+	}
+	if bundle.GasCost != nil {
+		status.GasCost = units.NewAmount(bundle.GasCost, nativeDecimals).Float64()
+	}
+
+	b.statuses.put(status)
+	b.mutex.Lock()
+	delete(b.bundles, planID)
+	b.mutex.Unlock()
+	slog.Info("Bundle settled", slog.String("planId", planID), slog.String("txHash", bundle.TxHash.Hex()),
+		slog.String("inclusion", inclusion), slog.Uint64("blockNumber", blockNumber))
+
+	return status
+}
+
+// tip ... returns the share of the expected profit transferred to block.coinbase in wei of the native token, nil if
+// there's no profit or it can't be priced (no tip is sent)
+func (b *BundleExecutor) tip(plan *Plan, firstPath sp.TradePath) *big.Int {
+	if b.tipShare <= 0 || plan.ProfitLoss <= 0 {
+		return nil
+	}
+
+	var profitTip = plan.ProfitLoss * b.tipShare
+
+	if plan.StartingAssetID == b.nativeID {
+		return units.FromFloat(profitTip, nativeDecimals).Wei()
+	}
+
+	var native, ok = tokenregistry.Get().GetTokenByID(b.chainID, b.nativeID)
+
+	if !ok {
+		slog.Debug("Can't price the bundle tip, the native token isn't registered", slog.String("asset", b.nativeID))
+		return nil
+	}
+
+	var path = sp.TradePath{
+		BaseAssetAddress:   firstPath.BaseAssetAddress,
+		BaseAssetDecimals:  firstPath.BaseAssetDecimals,
+		QuoteAssetAddress:  common.HexToAddress(native.Address),
+		QuoteAssetDecimals: native.Decimals,
+	}
+	tip, err := b.web3Service.GetPriceMultiplePaths([]sp.TradePath{path}, path.AmountIn(profitTip))
+
+	if err != nil {
+		slog.Debug("Can't price the bundle tip", slog.Any("error", err))
+		return nil
+	} else if tip.Sign() <= 0 {
+		return nil
+	}
+
+	return tip.Wei()
+}
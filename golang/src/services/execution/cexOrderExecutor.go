@@ -0,0 +1,145 @@
+package execution
+
+import (
+	"arbitrage-bot/services/trading"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CEXOrderExecutor ... Executes CEX plans as a sequence of orders on the exchange account
+type CEXOrderExecutor struct {
+	client   *trading.BinanceTradingClient
+	statuses *statusBook
+}
+
+// NewCEXOrderExecutor ... creates a new executor placing the orders with the Binance trading client
+func NewCEXOrderExecutor(client *trading.BinanceTradingClient) *CEXOrderExecutor {
+	return &CEXOrderExecutor{client: client, statuses: newStatusBook(client.GetName())}
+}
+
+// GetName ... returns the name of the executor (the exchange)
+func (c *CEXOrderExecutor) GetName() string {
+	return c.client.GetName()
+}
+
+// Prepare ... checks the plan was priced on the exchange & its symbols are tradable
+func (c *CEXOrderExecutor) Prepare(plan *Plan) error {
+	var _, err = c.legs(plan)
+	c.statuses.set(plan, StatePrepared, err)
+
+	return err
+}
+
+// Simulate ... checks the account holds the input of the first order, the expected output is the plan's
+func (c *CEXOrderExecutor) Simulate(plan *Plan) (*Status, error) {
+	legs, err := c.legs(plan)
+
+	if err != nil {
+		return c.statuses.set(plan, StateSimulated, err), err
+	}
+
+	balances, err := c.client.GetBalances()
+
+	if err != nil {
+		err = fmt.Errorf("error simulating plan %s: %w", plan.ID, err)
+		return c.statuses.set(plan, StateSimulated, err), err
+	}
+
+	var asset = legs[0].InputAsset()
+	var free float64
+
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			free = balance.Free
+		}
+	}
+	if free < plan.AmountIn {
+		err = fmt.Errorf("plan %s needs %v %s, %v available", plan.ID, plan.AmountIn, asset, free)
+		return c.statuses.set(plan, StateSimulated, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSimulated,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+	}
+	c.statuses.put(status)
+
+	return status, nil
+}
+
+// Execute ... places the orders of the plan one after the other, a leg failing stops the triangle
+func (c *CEXOrderExecutor) Execute(plan *Plan) (*Status, error) {
+	legs, err := c.legs(plan)
+
+	if err != nil {
+		return c.statuses.set(plan, StateFailed, err), err
+	}
+
+	var result = c.client.ExecuteTriangle(legs, plan.AmountIn)
+	var orderIDs []string
+	var status = &Status{PlanID: plan.ID, State: StateSucceeded, AmountIn: result.AmountIn}
+
+	for _, leg := range result.Legs {
+		if leg.Order != nil {
+			orderIDs = append(orderIDs, strconv.FormatInt(leg.Order.OrderID, 10))
+		}
+		if leg.Error != "" {
+			err = fmt.Errorf("plan %s stopped at %s: %s", plan.ID, leg.Symbol, leg.Error)
+		}
+	}
+	status.Reference = strings.Join(orderIDs, ",")
+
+	if !result.Completed {
+		if err == nil {
+			err = fmt.Errorf("plan %s wasn't completed", plan.ID)
+		}
+		status.State = StateFailed
+		status.Error = err.Error()
+		c.statuses.put(status)
+
+		return status, err
+	}
+	status.AmountOut = result.AmountOut
+	status.ProfitLoss = result.AmountOut - result.AmountIn
+	c.statuses.put(status)
+
+	return status, nil
+}
+
+// Status ... returns the last known state of a plan
+func (c *CEXOrderExecutor) Status(planID string) (*Status, bool) {
+	return c.statuses.get(planID)
+}
+
+// Close ... nothing to wait for, the orders are placed synchronously
+func (c *CEXOrderExecutor) Close(ctx context.Context) error {
+	return nil
+}
+
+// legs ... converts the steps of a plan priced on the exchange to orders
+func (c *CEXOrderExecutor) legs(plan *Plan) ([]trading.TriangleLeg, error) {
+	if plan.Venue != c.client.GetName() {
+		return nil, fmt.Errorf("plan %s priced on %s can't be executed on %s", plan.ID, plan.Venue, c.client.GetName())
+	}
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("plan %s has no steps", plan.ID)
+	}
+
+	var legs []trading.TriangleLeg
+
+	for i := range plan.Steps {
+		var step = &plan.Steps[i]
+
+		if step.Symbol.Rules != nil && !step.Symbol.Rules.IsTradable() {
+			return nil, fmt.Errorf("%s isn't tradable (status %s)", step.Symbol.Symbol, step.Symbol.Rules.Status)
+		}
+		legs = append(legs, trading.NewTriangleLeg(&step.Symbol, step.Direction, step.Rate))
+	}
+
+	return legs, nil
+}
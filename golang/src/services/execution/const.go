@@ -0,0 +1,89 @@
+package execution
+
+import (
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"context"
+	"github.com/ethereum/go-ethereum/core/types"
+	"time"
+)
+
+// Executor names
+const (
+	FlashSwapExecutorName string = "flashswap"
+	PaperExecutorName     string = "paper"
+	BundleExecutorName    string = "bundle"
+)
+
+// nativeDecimals ... decimals of the native token
+const nativeDecimals int = 18
+
+// Execution states of a plan
+const (
+	StatePrepared  string = "prepared"
+	StateSimulated string = "simulated"
+	StateSubmitted string = "submitted" // sent, the outcome isn't known yet (f.e. a bundle waiting for inclusion)
+	StateSucceeded string = "succeeded"
+	StateFailed    string = "failed"
+)
+
+// Executor ... executes the plans of confirmed opportunities on a venue (flash swap, CEX orders, paper trading...)
+type Executor interface {
+	GetName() string
+	// Prepare ... checks the back-end can execute the plan & resolves its venue specifics (route, orders...)
+	Prepare(plan *Plan) error
+	// Simulate ... dry-runs a prepared plan, nothing is traded
+	Simulate(plan *Plan) (*Status, error)
+	// Execute ... executes a prepared plan
+	Execute(plan *Plan) (*Status, error)
+	// Status ... returns the last known state of a plan
+	Status(planID string) (*Status, bool)
+	// Close ... waits for the executions in flight & releases the resources, gives up once the context is done
+	Close(ctx context.Context) error
+}
+
+// Plan ... Represents the trades of a confirmed opportunity, independent of the venue executing them
+type Plan struct {
+	ID              string     `json:"id"`
+	TriangleID      string     `json:"triangleId"`
+	Venue           string     `json:"venue"` // provider of the prices
+	CreatedAt       time.Time  `json:"createdAt"`
+	BlockNumber     uint64     `json:"blockNumber"` // Only used in DEX
+	StartingAssetID string     `json:"startingAssetId"`
+	AmountIn        float64    `json:"amountIn"`  // amount of the starting asset spent by the first step
+	AmountOut       float64    `json:"amountOut"` // expected amount of the starting asset received by the last step
+	ProfitLoss      float64    `json:"profitLoss"`
+	ProfitLossPerc  float64    `json:"profitLossPerc"`
+	Steps           []PlanStep `json:"steps"`
+
+	// Only used in DEX, exact amounts of the route
+	ExactAmountIn  units.Amount `json:"exactAmountIn"`
+	ExactAmountOut units.Amount `json:"exactAmountOut"`
+
+	// Only used in DEX, pending swap the plan back-runs (bundled right before the plan)
+	BackRun *types.Transaction `json:"-"`
+}
+
+// PlanStep ... Represents one trade of a plan
+type PlanStep struct {
+	Symbol    sp.Symbol     `json:"symbol"`
+	Direction string        `json:"direction"`      // direction of the calculator (baseToQuote or quoteToBase)
+	AssetIn   string        `json:"assetIn"`        // canonical id of the asset spent
+	AssetOut  string        `json:"assetOut"`       // canonical id of the asset received
+	Rate      float64       `json:"rate"`           // expected output per unit of input
+	Path      *sp.TradePath `json:"path,omitempty"` // Only used in DEX, tokens of the swap
+}
+
+// Status ... Represents the state of the execution of a plan
+type Status struct {
+	PlanID     string    `json:"planId"`
+	Executor   string    `json:"executor"`
+	State      string    `json:"state"`
+	AmountIn   float64   `json:"amountIn"`
+	AmountOut  float64   `json:"amountOut"`           // expected for a simulation, received once executed
+	ProfitLoss float64   `json:"profitLoss"`          // expected for a simulation, realized once settled
+	GasCost    float64   `json:"gasCost"`             // in units of the native token, paid by the mined transactions
+	Reference  string    `json:"reference,omitempty"` // transaction hash, order ids...
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,242 @@
+package execution
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/web3"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
+	"sync"
+)
+
+// flashSwapRoute ... Represents the swaps of a plan & the token borrowed by the flash swap
+type flashSwapRoute struct {
+	tradePaths  []sp.TradePath
+	loanAddress common.Address
+}
+
+// FlashSwapExecutor ... Executes DEX plans through the flash swap of the arbitrage executor contract, the swapIn
+// transactions are sent to the public mempool (wallet.public) or only dry-run
+type FlashSwapExecutor struct {
+	service   *web3.ArbitrageExecutorWeb3Service
+	submitter *web3.PublicSubmitter // nil to dry-run the swapIn calls
+	symbols   []*sp.Symbol          // symbols the borrowed token is picked from
+	statuses  *statusBook
+
+	mutex sync.Mutex
+	sent  map[string]*web3.PublicTx // plan id -> transaction not mined yet
+}
+
+// NewFlashSwapExecutor ... creates a new flash swap executor for the selected network
+func NewFlashSwapExecutor(symbols []*sp.Symbol) (*FlashSwapExecutor, error) {
+	service, err := web3.NewArbitrageExecutorWeb3Service()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var executor = &FlashSwapExecutor{
+		service:  service,
+		symbols:  symbols,
+		statuses: newStatusBook(FlashSwapExecutorName),
+		sent:     make(map[string]*web3.PublicTx),
+	}
+
+	if config.Get().ActiveNetwork().Wallet.Public {
+		if executor.submitter, err = web3.NewPublicSubmitter(); err != nil {
+			return nil, err
+		}
+	}
+
+	return executor, nil
+}
+
+// GetName ... returns the name of the executor
+func (f *FlashSwapExecutor) GetName() string {
+	return FlashSwapExecutorName
+}
+
+// Prepare ... checks the plan has an on-chain route & a token to borrow, a back-run is rejected (it's only sent as a
+// bundle)
+func (f *FlashSwapExecutor) Prepare(plan *Plan) error {
+	var _, err = newFlashSwapRoute(plan, f.symbols)
+
+	if err == nil && plan.BackRun != nil {
+		err = fmt.Errorf("plan %s back-runs %s, only the bundle executor sends back-runs", plan.ID, plan.BackRun.Hash())
+	}
+	f.statuses.set(plan, StatePrepared, err)
+
+	return err
+}
+
+// Simulate ... estimates the gas of the swapIn call, a route no longer profitable reverts
+func (f *FlashSwapExecutor) Simulate(plan *Plan) (*Status, error) {
+	route, err := newFlashSwapRoute(plan, f.symbols)
+
+	if err != nil {
+		return f.statuses.set(plan, StateSimulated, err), err
+	}
+
+	gas, err := f.service.EstimateArbitrage(route.tradePaths, plan.ExactAmountIn, route.loanAddress)
+
+	if err != nil {
+		err = fmt.Errorf("error simulating plan %s: %w", plan.ID, err)
+		return f.statuses.set(plan, StateSimulated, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSimulated,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+		Reference:  fmt.Sprintf("gas %d", gas),
+	}
+	f.statuses.put(status)
+
+	return status, nil
+}
+
+// Execute ... sends the swapIn transaction of the plan to the public mempool, the plan stays submitted until it's
+// mined. Without a submitter swapIn is only dry-run (eth_call) & the plan stays simulated
+func (f *FlashSwapExecutor) Execute(plan *Plan) (*Status, error) {
+	route, err := newFlashSwapRoute(plan, f.symbols)
+
+	if err != nil {
+		return f.statuses.set(plan, StateFailed, err), err
+	}
+	if f.submitter != nil {
+		return f.send(plan, route)
+	}
+	if err = f.service.ExecuteArbitrage(route.tradePaths, plan.ExactAmountIn, route.loanAddress); err != nil {
+		return f.statuses.set(plan, StateFailed, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSimulated,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+	}
+	f.statuses.put(status)
+
+	return status, nil
+}
+
+// Status ... returns the last known state of a plan, a submitted transaction is checked on-chain
+func (f *FlashSwapExecutor) Status(planID string) (*Status, bool) {
+	if status, ok := f.statuses.get(planID); ok && status.State == StateSubmitted {
+		f.refresh(planID)
+	}
+
+	return f.statuses.get(planID)
+}
+
+// Close ... saves the nonces of the wallet, the transactions still pending stay in flight (the nonce manager of the
+// next run keeps replacing them)
+func (f *FlashSwapExecutor) Close(ctx context.Context) error {
+	if f.submitter == nil {
+		return nil
+	}
+
+	return f.submitter.Close()
+}
+
+// send ... submits the swapIn transaction of a plan to the public mempool
+func (f *FlashSwapExecutor) send(plan *Plan, route flashSwapRoute) (*Status, error) {
+	tx, err := f.submitter.SubmitArbitrage(route.tradePaths, plan.ExactAmountIn, route.loanAddress)
+
+	if err != nil {
+		return f.statuses.set(plan, StateFailed, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSubmitted,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  plan.AmountOut,
+		ProfitLoss: plan.ProfitLoss,
+		Reference:  tx.TxHash.Hex(),
+	}
+	f.statuses.put(status)
+
+	f.mutex.Lock()
+	f.sent[plan.ID] = tx
+	f.mutex.Unlock()
+	slog.Info("Sent transaction", slog.String("planId", plan.ID), slog.String("txHash", tx.TxHash.Hex()),
+		slog.Uint64("nonce", tx.Nonce))
+
+	return status, nil
+}
+
+// refresh ... updates the state of a submitted transaction once it's mined or dropped
+func (f *FlashSwapExecutor) refresh(planID string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	tx, ok := f.sent[planID]
+	status, found := f.statuses.get(planID)
+
+	if !ok || !found {
+		return
+	}
+
+	state, blockNumber, err := f.submitter.TransactionStatus(tx)
+
+	if err != nil {
+		slog.Debug("Error checking transaction", slog.String("planId", planID), slog.Any("error", err))
+		return
+	}
+
+	status.Reference = tx.TxHash.Hex()
+
+	switch state {
+	case web3.TxMined:
+		status.State = StateSucceeded
+	case web3.TxReverted:
+		status.State = StateFailed
+		status.Error = fmt.Sprintf("reverted in block %d", blockNumber)
+	case web3.TxCancelled:
+		status.State = StateFailed
+		status.Error = fmt.Sprintf("stuck, cancelled in block %d", blockNumber)
+	case web3.TxDropped:
+		status.State = StateFailed
+		status.Error = fmt.Sprintf("nonce %d mined by another transaction", tx.Nonce)
+	default:
+		f.statuses.put(status)
+		return
+	}
+
+	// a failed transaction traded nothing, a mined one paid its gas
+	if status.State == StateFailed {
+		status.AmountOut, status.ProfitLoss = 0, 0
+	}
+	if tx.GasCost != nil {
+		status.GasCost = units.NewAmount(tx.GasCost, nativeDecimals).Float64()
+	}
+	f.statuses.put(status)
+	delete(f.sent, planID)
+	slog.Info("Transaction settled", slog.String("planId", planID), slog.String("txHash", tx.TxHash.Hex()),
+		slog.String("state", state), slog.Uint64("blockNumber", blockNumber))
+}
+
+// newFlashSwapRoute ... returns the swaps of the plan & the token borrowed by the flash swap
+func newFlashSwapRoute(plan *Plan, symbols []*sp.Symbol) (flashSwapRoute, error) {
+	tradePaths, err := plan.TradePaths()
+
+	if err != nil {
+		return flashSwapRoute{}, err
+	}
+
+	loanAddress, err := web3.FindLoanAddress(symbols, tradePaths)
+
+	if err != nil {
+		return flashSwapRoute{}, fmt.Errorf("plan %s: %w", plan.ID, err)
+	}
+
+	return flashSwapRoute{tradePaths: tradePaths, loanAddress: loanAddress}, nil
+}
@@ -0,0 +1,99 @@
+package execution
+
+import (
+	"arbitrage-bot/services/papertrading"
+	"context"
+	"fmt"
+)
+
+// PaperExecutor ... Executes DEX plans against the virtual ledger of the paper-trading mode, nothing is sent on-chain
+type PaperExecutor struct {
+	paperTrading *papertrading.PaperTradingExecutor
+	statuses     *statusBook
+}
+
+// NewPaperExecutor ... creates a new executor simulating the fills with the paper-trading executor
+func NewPaperExecutor(paperTrading *papertrading.PaperTradingExecutor) *PaperExecutor {
+	return &PaperExecutor{paperTrading: paperTrading, statuses: newStatusBook(PaperExecutorName)}
+}
+
+// GetName ... returns the name of the executor
+func (p *PaperExecutor) GetName() string {
+	return PaperExecutorName
+}
+
+// Prepare ... checks the plan has an on-chain route, a back-run is rejected (the quotes don't see its pending swap)
+func (p *PaperExecutor) Prepare(plan *Plan) error {
+	var _, err = plan.TradePaths()
+
+	if err == nil && plan.BackRun != nil {
+		err = fmt.Errorf("plan %s back-runs %s, only the bundle executor sends back-runs", plan.ID, plan.BackRun.Hash())
+	}
+	p.statuses.set(plan, StatePrepared, err)
+
+	return err
+}
+
+// Simulate ... quotes the route at the current pool state
+func (p *PaperExecutor) Simulate(plan *Plan) (*Status, error) {
+	tradePaths, err := plan.TradePaths()
+
+	if err != nil {
+		return p.statuses.set(plan, StateSimulated, err), err
+	}
+
+	amountOut, err := p.paperTrading.Quote(tradePaths, plan.ExactAmountIn)
+
+	if err != nil {
+		return p.statuses.set(plan, StateSimulated, err), err
+	}
+
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSimulated,
+		AmountIn:   plan.AmountIn,
+		AmountOut:  amountOut.Float64(),
+		ProfitLoss: amountOut.Float64() - plan.AmountIn,
+	}
+	p.statuses.put(status)
+
+	return status, nil
+}
+
+// Execute ... fills the plan after the simulated latency & records it in the ledger
+func (p *PaperExecutor) Execute(plan *Plan) (*Status, error) {
+	tradePaths, err := plan.TradePaths()
+
+	if err != nil {
+		return p.statuses.set(plan, StateFailed, err), err
+	}
+
+	trade, err := p.paperTrading.ExecuteArbitrage(tradePaths, plan.ExactAmountIn)
+	var status = &Status{
+		PlanID:     plan.ID,
+		State:      StateSucceeded,
+		AmountIn:   trade.AmountIn.Float64(),
+		AmountOut:  trade.AmountOut.Float64(),
+		ProfitLoss: trade.ProfitLoss,
+		GasCost:    trade.GasCost,
+		Reference:  fmt.Sprintf("%s, gas %v", trade.Status, trade.GasCost),
+	}
+
+	if err != nil {
+		status.State = StateFailed
+		status.Error = err.Error()
+	}
+	p.statuses.put(status)
+
+	return status, err
+}
+
+// Status ... returns the last known state of a plan
+func (p *PaperExecutor) Status(planID string) (*Status, bool) {
+	return p.statuses.get(planID)
+}
+
+// Close ... saves the ledger & logs the final summary
+func (p *PaperExecutor) Close(ctx context.Context) error {
+	return p.paperTrading.Close()
+}
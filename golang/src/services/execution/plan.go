@@ -0,0 +1,95 @@
+package execution
+
+import (
+	ethersHelper "arbitrage-bot/helpers/ethers"
+	"arbitrage-bot/models"
+	sp "arbitrage-bot/services/sourceprovider"
+	"fmt"
+	"time"
+)
+
+// NewPlan ... builds the plan of a confirmed opportunity from its surface & depth results, the steps follow the
+// trade paths of a DEX result & the orderbook trades of a CEX result (baseToQuote spends the quote asset)
+func NewPlan(
+	venue string,
+	surfaceResult models.TriangularArbSurfaceResult,
+	depthResult models.TriangularArbDepthResult,
+) *Plan {
+	var symbols = []sp.Symbol{surfaceResult.Symbol1, surfaceResult.Symbol2, surfaceResult.Symbol3}
+	var directions = []string{surfaceResult.DirectionTrade1, surfaceResult.DirectionTrade2, surfaceResult.DirectionTrade3}
+	var rates = []float64{surfaceResult.Swap1Rate, surfaceResult.Swap2Rate, surfaceResult.Swap3Rate}
+	var now = time.Now()
+	var plan = &Plan{
+		ID:             fmt.Sprintf("%s-%d", surfaceResult.TriangleID(), now.UnixNano()),
+		TriangleID:     surfaceResult.TriangleID(),
+		Venue:          venue,
+		CreatedAt:      now,
+		BlockNumber:    surfaceResult.BlockNumber,
+		ProfitLoss:     depthResult.ProfitLoss,
+		ProfitLossPerc: depthResult.ProfitLossPerc,
+	}
+
+	if len(depthResult.TradePaths) > 0 {
+		var tradePaths = ethersHelper.GetTradePaths(symbols, directions)
+		plan.AmountIn = depthResult.AmountIn.Float64()
+		plan.AmountOut = depthResult.AmountOut.Float64()
+		plan.ExactAmountIn = depthResult.AmountIn
+		plan.ExactAmountOut = depthResult.AmountOut
+
+		for i := range symbols {
+			var step = PlanStep{Symbol: symbols[i], Direction: directions[i], Rate: rates[i], Path: &tradePaths[i]}
+			step.AssetIn, step.AssetOut = symbols[i].GetBaseAssetID(), symbols[i].GetQuoteAssetID()
+
+			if directions[i] == "quoteToBase" {
+				step.AssetIn, step.AssetOut = step.AssetOut, step.AssetIn
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+	} else {
+		plan.AmountIn = depthResult.StartingAmount
+		plan.AmountOut = depthResult.StartingAmount + depthResult.ProfitLoss
+
+		for i := range symbols {
+			var step = PlanStep{Symbol: symbols[i], Direction: directions[i], Rate: rates[i]}
+			step.AssetIn, step.AssetOut = symbols[i].GetBaseAssetID(), symbols[i].GetQuoteAssetID()
+
+			if directions[i] == "baseToQuote" {
+				step.AssetIn, step.AssetOut = step.AssetOut, step.AssetIn
+			}
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+	if len(plan.Steps) > 0 {
+		plan.StartingAssetID = plan.Steps[0].AssetIn
+	}
+
+	return plan
+}
+
+// TradePaths ... returns the swaps of a DEX plan
+func (p *Plan) TradePaths() ([]sp.TradePath, error) {
+	var tradePaths []sp.TradePath
+
+	for _, step := range p.Steps {
+		if step.Path == nil {
+			return nil, fmt.Errorf("step %s of plan %s has no on-chain route", step.Symbol.Symbol, p.ID)
+		}
+		tradePaths = append(tradePaths, *step.Path)
+	}
+	if len(tradePaths) == 0 || p.ExactAmountIn.IsZero() {
+		return nil, fmt.Errorf("plan %s has no on-chain route", p.ID)
+	}
+
+	return tradePaths, nil
+}
+
+// AssetIDs ... returns the canonical ids of every asset traded by the plan
+func (p *Plan) AssetIDs() []string {
+	var assetIDs []string
+
+	for _, step := range p.Steps {
+		assetIDs = append(assetIDs, step.AssetOut)
+	}
+
+	return assetIDs
+}
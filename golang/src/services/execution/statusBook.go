@@ -0,0 +1,76 @@
+package execution
+
+import (
+	"sync"
+	"time"
+)
+
+// maxStatuses ... statuses kept by an executor, the oldest plans are forgotten first
+const maxStatuses int = 1000
+
+// statusBook ... Keeps the last known state of the plans of an executor
+type statusBook struct {
+	executor string
+	mutex    sync.RWMutex
+	statuses map[string]*Status
+	order    []string // plan ids, oldest first
+}
+
+// newStatusBook ... creates an empty status book for an executor
+func newStatusBook(executor string) *statusBook {
+	return &statusBook{executor: executor, statuses: make(map[string]*Status)}
+}
+
+// set ... records the state of a plan, err (if any) marks it as failed
+func (s *statusBook) set(plan *Plan, state string, err error) *Status {
+	var status = &Status{
+		PlanID:    plan.ID,
+		Executor:  s.executor,
+		State:     state,
+		AmountIn:  plan.AmountIn,
+		UpdatedAt: time.Now(),
+	}
+
+	if err != nil {
+		status.State = StateFailed
+		status.Error = err.Error()
+	}
+	s.put(status)
+
+	return status
+}
+
+// put ... stores a status, forgetting the oldest plan once full
+func (s *statusBook) put(status *Status) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status.Executor = s.executor
+	status.UpdatedAt = time.Now()
+
+	if _, ok := s.statuses[status.PlanID]; !ok {
+		s.order = append(s.order, status.PlanID)
+	}
+	s.statuses[status.PlanID] = status
+
+	if len(s.order) > maxStatuses {
+		delete(s.statuses, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// get ... returns a copy of the last known state of a plan
+func (s *statusBook) get(planID string) (*Status, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var status, ok = s.statuses[planID]
+
+	if !ok {
+		return nil, false
+	}
+
+	var copied = *status
+
+	return &copied, true
+}
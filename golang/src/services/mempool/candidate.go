@@ -0,0 +1,38 @@
+package mempool
+
+import (
+	ethersHelper "arbitrage-bot/helpers/ethers"
+	"arbitrage-bot/models"
+	"arbitrage-bot/services/web3"
+	"fmt"
+)
+
+// Candidate ... Represents a triangle made profitable by a pending swap, executing it right after the swap back-runs
+// the swap
+type Candidate struct {
+	Swap          *PendingSwap
+	SurfaceResult models.TriangularArbSurfaceResult
+	model         *ReserveModel
+	reserves      map[string]web3.PoolReserves // after the swap
+}
+
+// CalcDepth ... calculates the depth on the reserves after the pending swap, the quote of the router doesn't see the
+// swap before it's mined
+func (c Candidate) CalcDepth(surfaceResult models.TriangularArbSurfaceResult) (models.TriangularArbDepthResult, error) {
+	var tradePaths = ethersHelper.GetTradePathsFromSurfaceResult(surfaceResult)
+	var amountIn = tradePaths[0].AmountIn(surfaceResult.StartingAmount)
+	var amountOut = c.model.AmountsOut(c.reserves, tradePaths, amountIn)
+	profitLoss, err := amountOut.SubE(amountIn)
+
+	if err != nil {
+		return models.TriangularArbDepthResult{}, fmt.Errorf("error calculating back-run depth: %w", err)
+	}
+
+	return models.TriangularArbDepthResult{
+		ProfitLoss:     profitLoss.Float64(),
+		ProfitLossPerc: profitLoss.Ratio(amountIn) * 100,
+		TradePaths:     tradePaths,
+		AmountIn:       amountIn,
+		AmountOut:      amountOut,
+	}, nil
+}
@@ -0,0 +1,22 @@
+package mempool
+
+import (
+	"arbitrage-bot/services/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// PendingSwap ... Represents a router swap of a pending transaction
+type PendingSwap struct {
+	Tx       *types.Transaction
+	Method   string
+	Path     []common.Address // tokens swapped through, input first
+	AmountIn *big.Int         // base units of the first token of the path
+}
+
+// affectedPool ... Represents a watched pool traded by a pending swap
+type affectedPool struct {
+	address  string
+	reserves web3.PoolReserves // after the swap
+}
@@ -0,0 +1,235 @@
+package mempool
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/arbitrage"
+	"arbitrage-bot/services/metrics"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/dex"
+	"arbitrage-bot/services/web3"
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"log/slog"
+	"time"
+)
+
+// WatcherName ... name of the mempool watcher (logs & metrics)
+const WatcherName = "mempool"
+
+// resubscribeDelay ... wait before subscribing again to the pending transactions after the stream failed
+const resubscribeDelay = 3 * time.Second
+
+// MempoolWatcher ... Watches the pending swaps of the router & evaluates the triangles of the pools they trade on the
+// reserves after the swap, the profitable ones are emitted as back-run candidates
+type MempoolWatcher struct {
+	settings    config.MempoolConfig
+	decoder     *RouterDecoder
+	calculator  *arbitrage.AmmArbitrageCalculator
+	web3Service web3.DEXWeb3Service
+	model       *ReserveModel
+
+	symbols        []*sp.Symbol
+	poolSymbols    map[string]*sp.Symbol // pool address -> symbol
+	poolTriangles  map[string][]int      // pool address -> index of the triangles trading it
+	triangles      [][3]*sp.Symbol
+	startingAmount float64
+	candidates     chan Candidate
+}
+
+// NewMempoolWatcher ... creates a new watcher of the pending swaps trading the pools of the triangles
+func NewMempoolWatcher(
+	calculator *arbitrage.AmmArbitrageCalculator, web3Service web3.DEXWeb3Service, triangles [][3]*sp.Symbol,
+) (*MempoolWatcher, error) {
+	var network = config.Get().ActiveNetwork()
+	decoder, err := NewRouterDecoder()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var watcher = &MempoolWatcher{
+		settings:       network.Mempool,
+		decoder:        decoder,
+		calculator:     calculator,
+		web3Service:    web3Service,
+		poolSymbols:    make(map[string]*sp.Symbol),
+		poolTriangles:  make(map[string][]int),
+		triangles:      triangles,
+		startingAmount: network.Thresholds.StartingAmount,
+		candidates:     make(chan Candidate, network.Mempool.Candidates),
+	}
+
+	for i, triangle := range triangles {
+		for _, symbol := range triangle {
+			if _, ok := watcher.poolSymbols[symbol.Address]; !ok {
+				watcher.poolSymbols[symbol.Address] = symbol
+				watcher.symbols = append(watcher.symbols, symbol)
+			}
+			watcher.poolTriangles[symbol.Address] = append(watcher.poolTriangles[symbol.Address], i)
+		}
+	}
+	watcher.model = NewReserveModel(watcher.symbols)
+
+	return watcher, nil
+}
+
+// Candidates ... returns the channel of the back-run candidates
+func (m *MempoolWatcher) Candidates() <-chan Candidate {
+	return m.candidates
+}
+
+// Watch ... subscribes to the full pending transactions & handles them until the context is done, the subscription
+// is re-established when the stream fails (blocking)
+func (m *MempoolWatcher) Watch(ctx context.Context) {
+	var log = logger.WithProvider(WatcherName)
+
+	for {
+		if err := m.subscribe(ctx); err != nil {
+			log.Warn("Pending transaction stream failed", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+		metrics.IncWebSocketReconnects(WatcherName)
+	}
+}
+
+// subscribe ... streams the full pending transactions until the subscription fails or the context is done (the second
+// parameter of newPendingTransactions asks for the transactions instead of their hashes)
+func (m *MempoolWatcher) subscribe(ctx context.Context) error {
+	client, err := rpc.DialContext(ctx, m.settings.WsURL)
+
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var transactions = make(chan *types.Transaction, 1024)
+	subscription, err := client.EthSubscribe(ctx, transactions, "newPendingTransactions", true)
+
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	logger.WithProvider(WatcherName).Info("Watching the pending swaps", slog.Int("pools", len(m.symbols)))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-subscription.Err():
+			return err
+		case tx := <-transactions:
+			m.HandleTransaction(tx)
+		}
+	}
+}
+
+// HandleTransaction ... evaluates the triangles of the pools traded by a pending swap of the router, other
+// transactions are ignored
+func (m *MempoolWatcher) HandleTransaction(tx *types.Transaction) {
+	var swap, ok = m.decoder.Decode(tx)
+
+	if !ok {
+		return
+	}
+
+	var log = logger.WithProvider(WatcherName).With(slog.String("txHash", tx.Hash().Hex()))
+
+	if m.model.Age() > m.settings.ReservesMaxAge {
+		if err := m.refreshReserves(); err != nil {
+			log.Warn("Error reading the reserves", slog.Any("error", err))
+			return
+		}
+	}
+
+	var reserves, affected = m.model.ApplySwap(swap.Path, swap.AmountIn)
+
+	if len(affected) == 0 {
+		return
+	}
+
+	// prices of the traded pools after the swap, the other pools keep the prices of the source provider
+	var overrides = make(map[string]*dex.SymbolPrice)
+	var evaluated = make(map[int]bool)
+	var blockNumber = m.model.BlockNumber()
+
+	for _, pool := range affected {
+		var symbol = m.poolSymbols[pool.address]
+		var oneBase = units.FromFloat(1, symbol.BaseAssetDecimals).Wei()
+		var amountOut, _ = swapOutput(symbol, pool.reserves, common.HexToAddress(symbol.BaseAssetAddress), oneBase)
+		var price = units.NewAmount(amountOut, symbol.QuoteAssetDecimals).Float64()
+
+		if price <= 0 {
+			continue
+		}
+		overrides[symbol.Symbol] = &dex.SymbolPrice{
+			Symbol:      symbol,
+			Token0Price: 1.0 / price,
+			Token1Price: price,
+			EventTime:   time.Now(),
+			BlockNumber: blockNumber,
+		}
+	}
+
+	for _, pool := range affected {
+		for _, i := range m.poolTriangles[pool.address] {
+			if evaluated[i] {
+				continue
+			}
+			evaluated[i] = true
+
+			surfaceResult, err := m.calculator.CalcTriangularArbSurfaceRateWithPrices(
+				m.triangles[i], m.startingAmount, overrides,
+			)
+
+			if err != nil || surfaceResult.ProfitLoss <= 0 {
+				continue
+			}
+			m.emit(Candidate{Swap: swap, SurfaceResult: surfaceResult, model: m.model, reserves: reserves}, log)
+		}
+	}
+}
+
+// refreshReserves ... reads the reserves of the watched pools
+func (m *MempoolWatcher) refreshReserves() error {
+	blockNumber, err := m.web3Service.GetBlockNumber()
+
+	if err != nil {
+		return err
+	}
+
+	reserves, err := m.web3Service.GetPoolsReserves(m.symbols)
+
+	if err != nil {
+		return err
+	}
+	m.model.Update(reserves, blockNumber)
+
+	return nil
+}
+
+// emit ... queues a candidate for the main loop, the candidate is dropped if the queue is full (the swap is likely
+// mined before the queue drains)
+func (m *MempoolWatcher) emit(candidate Candidate, log *slog.Logger) {
+	select {
+	case m.candidates <- candidate:
+		metrics.IncBackrunCandidates(metrics.OutcomeSuccess)
+		log.Debug(
+			"Back-run candidate",
+			slog.String("method", candidate.Swap.Method),
+			slog.Float64("surfaceProfitLossPerc", candidate.SurfaceResult.ProfitLossPerc),
+		)
+	default:
+		metrics.IncBackrunCandidates(metrics.OutcomeSkipped)
+		log.Debug("Candidate queue full, dropping the candidate")
+	}
+}
@@ -0,0 +1,190 @@
+package mempool
+
+import (
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/arbitrage"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/web3"
+	"bytes"
+	"github.com/ethereum/go-ethereum/common"
+	"maps"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// feeNumerator & feeDenominator ... share of the input left after the swap fee of the PancakeSwap V2 pools (9975/10000)
+var feeDenominator = big.NewInt(10000)
+var feeNumerator = big.NewInt(int64(math.Round((1 - arbitrage.PancakeswapV2Fee) * 10000)))
+
+// ReserveModel ... Local copy of the reserves of the watched pools, the pending swaps are replayed on it with the
+// constant product formula of the PancakeSwap V2 pools
+type ReserveModel struct {
+	pools map[[2]common.Address]*sp.Symbol // sorted token pair -> pool
+
+	mutex       sync.RWMutex
+	reserves    map[string]web3.PoolReserves // pool address -> reserves
+	blockNumber uint64
+	updatedAt   time.Time
+}
+
+// NewReserveModel ... creates an empty model of the pools of the symbols
+func NewReserveModel(symbols []*sp.Symbol) *ReserveModel {
+	var model = &ReserveModel{
+		pools:    make(map[[2]common.Address]*sp.Symbol),
+		reserves: make(map[string]web3.PoolReserves),
+	}
+
+	for _, symbol := range symbols {
+		var key = pairKey(common.HexToAddress(symbol.BaseAssetAddress), common.HexToAddress(symbol.QuoteAssetAddress))
+		model.pools[key] = symbol
+	}
+
+	return model
+}
+
+// Update ... replaces the reserves with the ones read at a block
+func (r *ReserveModel) Update(reserves map[string]web3.PoolReserves, blockNumber uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.reserves = reserves
+	r.blockNumber = blockNumber
+	r.updatedAt = time.Now()
+}
+
+// Age ... returns the age of the reserves
+func (r *ReserveModel) Age() time.Duration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return time.Since(r.updatedAt)
+}
+
+// BlockNumber ... returns the block the reserves were read at
+func (r *ReserveModel) BlockNumber() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.blockNumber
+}
+
+// Pool ... returns the watched pool of a token pair
+func (r *ReserveModel) Pool(tokenA common.Address, tokenB common.Address) (*sp.Symbol, bool) {
+	var symbol, ok = r.pools[pairKey(tokenA, tokenB)]
+
+	return symbol, ok
+}
+
+// ApplySwap ... returns the reserves after a swap along the path & the watched pools it trades, the replay stops at
+// the first pool which isn't watched (its output is unknown)
+func (r *ReserveModel) ApplySwap(
+	path []common.Address, amountIn *big.Int,
+) (map[string]web3.PoolReserves, []affectedPool) {
+	r.mutex.RLock()
+	var reserves = maps.Clone(r.reserves)
+	r.mutex.RUnlock()
+
+	var affected []affectedPool
+	var amount = amountIn
+
+	for i := 0; i+1 < len(path); i++ {
+		var symbol, ok = r.Pool(path[i], path[i+1])
+
+		if !ok {
+			break
+		}
+
+		poolReserves, ok := reserves[symbol.Address]
+
+		if !ok || poolReserves.Reserve0Wei == nil || poolReserves.Reserve1Wei == nil {
+			break
+		}
+		amount, poolReserves = swapOutput(symbol, poolReserves, path[i], amount)
+		reserves[symbol.Address] = poolReserves
+		affected = append(affected, affectedPool{address: symbol.Address, reserves: poolReserves})
+	}
+
+	return reserves, affected
+}
+
+// AmountsOut ... returns the output of the trade paths on the reserves (in base units of the last token), 0 if a pool
+// isn't watched
+func (r *ReserveModel) AmountsOut(
+	reserves map[string]web3.PoolReserves, tradePaths []sp.TradePath, amountIn units.Amount,
+) units.Amount {
+	var amount = amountIn.Wei()
+	var decimals = tradePaths[len(tradePaths)-1].QuoteAssetDecimals
+
+	for _, tradePath := range tradePaths {
+		var symbol, ok = r.Pool(tradePath.BaseAssetAddress, tradePath.QuoteAssetAddress)
+
+		if !ok {
+			return units.Zero(decimals)
+		}
+
+		poolReserves, ok := reserves[symbol.Address]
+
+		if !ok || poolReserves.Reserve0Wei == nil || poolReserves.Reserve1Wei == nil {
+			return units.Zero(decimals)
+		}
+		amount, _ = swapOutput(symbol, poolReserves, tradePath.BaseAssetAddress, amount)
+	}
+
+	return units.NewAmount(amount, decimals)
+}
+
+// swapOutput ... returns the output of a swap of a pool (getAmountOut of the V2 library, in base units) & its reserves
+// after the swap (the fee stays in the pool)
+func swapOutput(
+	symbol *sp.Symbol, reserves web3.PoolReserves, tokenIn common.Address, amountIn *big.Int,
+) (*big.Int, web3.PoolReserves) {
+	var reserveIn, reserveOut = reserves.Reserve0Wei, reserves.Reserve1Wei
+	var baseIn = common.HexToAddress(symbol.BaseAssetAddress) == tokenIn
+
+	if !baseIn {
+		reserveIn, reserveOut = reserveOut, reserveIn
+	}
+
+	var amountOut = getAmountOut(amountIn, reserveIn, reserveOut)
+	reserveIn = new(big.Int).Add(reserveIn, amountIn)
+	reserveOut = new(big.Int).Sub(reserveOut, amountOut)
+
+	if baseIn {
+		reserves.Reserve0Wei, reserves.Reserve1Wei = reserveIn, reserveOut
+	} else {
+		reserves.Reserve0Wei, reserves.Reserve1Wei = reserveOut, reserveIn
+	}
+	reserves.Reserve0 = units.NewAmount(reserves.Reserve0Wei, symbol.BaseAssetDecimals).Float64()
+	reserves.Reserve1 = units.NewAmount(reserves.Reserve1Wei, symbol.QuoteAssetDecimals).Float64()
+	reserves.Price = 0
+
+	if reserves.Reserve0 > 0 {
+		reserves.Price = reserves.Reserve1 / reserves.Reserve0
+	}
+
+	return amountOut, reserves
+}
+
+// getAmountOut ... returns the output of a constant product swap after the fee, rounded down like the pools
+func getAmountOut(amountIn *big.Int, reserveIn *big.Int, reserveOut *big.Int) *big.Int {
+	if amountIn.Sign() <= 0 || reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return new(big.Int)
+	}
+
+	var amountInWithFee = new(big.Int).Mul(amountIn, feeNumerator)
+	var numerator = new(big.Int).Mul(amountInWithFee, reserveOut)
+	var denominator = new(big.Int).Add(new(big.Int).Mul(reserveIn, feeDenominator), amountInWithFee)
+
+	return numerator.Div(numerator, denominator)
+}
+
+// pairKey ... returns the key of a token pair, independent of the order
+func pairKey(tokenA common.Address, tokenB common.Address) [2]common.Address {
+	if bytes.Compare(tokenA.Bytes(), tokenB.Bytes()) > 0 {
+		tokenA, tokenB = tokenB, tokenA
+	}
+
+	return [2]common.Address{tokenA, tokenB}
+}
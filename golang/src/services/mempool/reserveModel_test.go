@@ -0,0 +1,98 @@
+package mempool
+
+import (
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/web3"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+// wei ... parses a base unit amount of the test vectors
+func wei(t *testing.T, value string) *big.Int {
+	var amount, ok = new(big.Int).SetString(value, 10)
+
+	if !ok {
+		t.Fatalf("invalid amount %s", value)
+	}
+
+	return amount
+}
+
+func TestGetAmountOut(t *testing.T) {
+	// expected outputs of getAmountOut of the PancakeSwap V2 library (25 bps fee)
+	for _, test := range []struct {
+		name       string
+		amountIn   string
+		reserveIn  string
+		reserveOut string
+		amountOut  string
+	}{
+		{name: "1% of the pool", amountIn: "1000000000000000000", reserveIn: "100000000000000000000",
+			reserveOut: "200000000000000000000", amountOut: "1975296418228173964"},
+		{name: "mixed decimals", amountIn: "1000000", reserveIn: "5000000000000",
+			reserveOut: "3000000000000000000000", amountOut: "598499880599273"},
+		{name: "half of the pool", amountIn: "500000000000000000000", reserveIn: "1000000000000000000000",
+			reserveOut: "1000000000000000000000", amountOut: "332777314428690575479"},
+		{name: "rounded down to 0", amountIn: "1", reserveIn: "1000000000000000000",
+			reserveOut: "1000000000000000000", amountOut: "0"},
+		{name: "zero input", amountIn: "0", reserveIn: "100", reserveOut: "100", amountOut: "0"},
+		{name: "empty pool", amountIn: "100", reserveIn: "0", reserveOut: "100", amountOut: "0"},
+	} {
+		var amountOut = getAmountOut(wei(t, test.amountIn), wei(t, test.reserveIn), wei(t, test.reserveOut))
+
+		if amountOut.String() != test.amountOut {
+			t.Errorf("%s: getAmountOut = %s, want %s", test.name, amountOut, test.amountOut)
+		}
+	}
+}
+
+func TestApplySwap(t *testing.T) {
+	var wbnbCake = &sp.Symbol{Address: "0xpool1", BaseAssetAddress: testWBNB.Hex(), BaseAssetDecimals: 18,
+		QuoteAssetAddress: testCAKE.Hex(), QuoteAssetDecimals: 18}
+	var usdtCake = &sp.Symbol{Address: "0xpool2", BaseAssetAddress: testUSDT.Hex(), BaseAssetDecimals: 18,
+		QuoteAssetAddress: testCAKE.Hex(), QuoteAssetDecimals: 18}
+	var model = NewReserveModel([]*sp.Symbol{wbnbCake, usdtCake})
+	var reserves = map[string]web3.PoolReserves{
+		wbnbCake.Address: {Reserve0Wei: wei(t, "100000000000000000000"), Reserve1Wei: wei(t, "200000000000000000000")},
+		usdtCake.Address: {Reserve0Wei: wei(t, "1000000000000000000000"), Reserve1Wei: wei(t, "1000000000000000000000")},
+	}
+	model.Update(reserves, 1)
+
+	// WBNB -> CAKE -> USDT trades both pools, CAKE is the quote of the second one
+	var after, affected = model.ApplySwap([]common.Address{testWBNB, testCAKE, testUSDT}, wei(t, "1000000000000000000"))
+
+	if len(affected) != 2 || affected[0].address != wbnbCake.Address || affected[1].address != usdtCake.Address {
+		t.Fatalf("affected = %v, want both pools", affected)
+	}
+	if after[wbnbCake.Address].Reserve0Wei.String() != "101000000000000000000" ||
+		after[wbnbCake.Address].Reserve1Wei.String() != "198024703581771826036" {
+		t.Errorf("WBNB/CAKE reserves = %v, want the input added & the output removed", after[wbnbCake.Address])
+	}
+	if after[usdtCake.Address].Reserve1Wei.String() != "1001975296418228173964" {
+		t.Errorf("USDT/CAKE CAKE reserve = %s, want the output of the first pool added",
+			after[usdtCake.Address].Reserve1Wei)
+	}
+	if reserves[wbnbCake.Address].Reserve0Wei.String() != "100000000000000000000" {
+		t.Errorf("the model reserves changed to %v", reserves[wbnbCake.Address])
+	}
+
+	// CAKE -> WBNB -> USDT stops at the WBNB/USDT pool, which isn't watched
+	after, affected = model.ApplySwap([]common.Address{testCAKE, testWBNB, testUSDT}, wei(t, "2000000000000000000"))
+
+	if len(affected) != 1 || affected[0].address != wbnbCake.Address {
+		t.Fatalf("affected = %v, want only the WBNB/CAKE pool", affected)
+	}
+	if after[wbnbCake.Address].Reserve1Wei.String() != "202000000000000000000" {
+		t.Errorf("WBNB/CAKE CAKE reserve = %s, want the input added", after[wbnbCake.Address].Reserve1Wei)
+	}
+	if after[usdtCake.Address].Reserve0Wei != reserves[usdtCake.Address].Reserve0Wei ||
+		after[usdtCake.Address].Reserve1Wei != reserves[usdtCake.Address].Reserve1Wei {
+		t.Errorf("USDT/CAKE reserves = %v, want them unchanged", after[usdtCake.Address])
+	}
+
+	// an unwatched first pool leaves every reserve unchanged
+	if _, affected = model.ApplySwap([]common.Address{testUSDT, testWBNB}, wei(t, "1")); len(affected) != 0 {
+		t.Errorf("affected = %v, want none", affected)
+	}
+}
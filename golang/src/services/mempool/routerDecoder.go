@@ -0,0 +1,86 @@
+package mempool
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// exactInputMethods ... router methods swapping an exact input, the ETH variants spend the value of the transaction
+var exactInputMethods = map[string]bool{
+	"swapExactTokensForTokens":                              false,
+	"swapExactTokensForTokensSupportingFeeOnTransferTokens": false,
+	"swapExactTokensForETH":                                 false,
+	"swapExactTokensForETHSupportingFeeOnTransferTokens":    false,
+	"swapExactETHForTokens":                                 true,
+	"swapExactETHForTokensSupportingFeeOnTransferTokens":    true,
+}
+
+// RouterDecoder ... Decodes the exact input swaps sent to the PancakeSwap router
+type RouterDecoder struct {
+	routerAddress common.Address
+	routerABI     abi.ABI
+}
+
+// NewRouterDecoder ... creates a new decoder for the router of the selected network
+func NewRouterDecoder() (*RouterDecoder, error) {
+	var network = config.Get().ActiveNetwork()
+	routerABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapRouter)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the router abi: %w", err)
+	}
+
+	return &RouterDecoder{
+		routerAddress: common.HexToAddress(network.Contracts.PancakeswapRouter),
+		routerABI:     routerABI,
+	}, nil
+}
+
+// Decode ... returns the swap of a transaction calling one of the exact input methods of the router
+func (d *RouterDecoder) Decode(tx *types.Transaction) (*PendingSwap, bool) {
+	if tx.To() == nil || *tx.To() != d.routerAddress || len(tx.Data()) < 4 {
+		return nil, false
+	}
+
+	method, err := d.routerABI.MethodById(tx.Data()[:4])
+
+	if err != nil {
+		return nil, false
+	}
+
+	payable, ok := exactInputMethods[method.Name]
+
+	if !ok {
+		return nil, false
+	}
+
+	var args = make(map[string]interface{})
+
+	if err := method.Inputs.UnpackIntoMap(args, tx.Data()[4:]); err != nil {
+		return nil, false
+	}
+
+	path, ok := args["path"].([]common.Address)
+
+	if !ok || len(path) < 2 {
+		return nil, false
+	}
+
+	var amountIn = tx.Value()
+
+	if !payable {
+		if amountIn, ok = args["amountIn"].(*big.Int); !ok {
+			return nil, false
+		}
+	}
+	if amountIn.Sign() <= 0 {
+		return nil, false
+	}
+
+	return &PendingSwap{Tx: tx, Method: method.Name, Path: path, AmountIn: amountIn}, true
+}
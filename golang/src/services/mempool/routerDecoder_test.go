@@ -0,0 +1,137 @@
+package mempool
+
+import (
+	jsonHelper "arbitrage-bot/helpers/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+)
+
+var testRouter = common.HexToAddress("0x10ED43C718714eb63d5aA57B78B54704E256024E")
+var testWBNB = common.HexToAddress("0xbb4CdB9CBd36B01bD1cBaEBF2De08d9173bc095c")
+var testCAKE = common.HexToAddress("0x0E09FaBB73Bd3Ade0a17ECC321fD13a19e81cE82")
+var testUSDT = common.HexToAddress("0x55d398326f99059fF775485246999027B3197955")
+
+// newTestRouterDecoder ... creates a decoder of the router abi (paths are relative to src)
+func newTestRouterDecoder(t *testing.T) *RouterDecoder {
+	routerABI, err := jsonHelper.ReadJSONABIFile("../../data/web3/pancakeswapRouterABI.json")
+
+	if err != nil {
+		t.Fatalf("error reading the router abi: %v", err)
+	}
+
+	return &RouterDecoder{routerAddress: testRouter, routerABI: routerABI}
+}
+
+// newTestRouterTx ... returns a transaction calling a method of the router abi
+func newTestRouterTx(t *testing.T, decoder *RouterDecoder, to common.Address, value *big.Int, method string,
+	args ...interface{}) *types.Transaction {
+	data, err := decoder.routerABI.Pack(method, args...)
+
+	if err != nil {
+		t.Fatalf("error packing %s: %v", method, err)
+	}
+
+	return types.NewTx(&types.LegacyTx{To: &to, Value: value, Gas: 300000, GasPrice: big.NewInt(1), Data: data})
+}
+
+func TestRouterDecoderDecode(t *testing.T) {
+	var decoder = newTestRouterDecoder(t)
+	var deadline = big.NewInt(1700000000)
+	var recipient = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	var path = []common.Address{testWBNB, testCAKE, testUSDT}
+	var amountIn = big.NewInt(5e17)
+	var value = big.NewInt(2e18)
+	var truncated = newTestRouterTx(t, decoder, testRouter, nil, "swapExactTokensForTokens",
+		amountIn, big.NewInt(0), path, recipient, deadline)
+
+	for _, test := range []struct {
+		name     string
+		tx       *types.Transaction
+		ok       bool
+		method   string
+		amountIn *big.Int
+	}{
+		{
+			name: "tokens for tokens spend amountIn",
+			tx: newTestRouterTx(t, decoder, testRouter, value, "swapExactTokensForTokens",
+				amountIn, big.NewInt(0), path, recipient, deadline),
+			ok: true, method: "swapExactTokensForTokens", amountIn: amountIn,
+		},
+		{
+			name: "tokens for ETH spend amountIn",
+			tx: newTestRouterTx(t, decoder, testRouter, nil, "swapExactTokensForETHSupportingFeeOnTransferTokens",
+				amountIn, big.NewInt(0), path, recipient, deadline),
+			ok: true, method: "swapExactTokensForETHSupportingFeeOnTransferTokens", amountIn: amountIn,
+		},
+		{
+			name: "ETH for tokens spend the value",
+			tx: newTestRouterTx(t, decoder, testRouter, value, "swapExactETHForTokens",
+				big.NewInt(0), path, recipient, deadline),
+			ok: true, method: "swapExactETHForTokens", amountIn: value,
+		},
+		{
+			name: "ETH for fee on transfer tokens spend the value",
+			tx: newTestRouterTx(t, decoder, testRouter, value, "swapExactETHForTokensSupportingFeeOnTransferTokens",
+				big.NewInt(0), path, recipient, deadline),
+			ok: true, method: "swapExactETHForTokensSupportingFeeOnTransferTokens", amountIn: value,
+		},
+		{
+			name: "ETH for tokens without value",
+			tx: newTestRouterTx(t, decoder, testRouter, big.NewInt(0), "swapExactETHForTokens",
+				big.NewInt(0), path, recipient, deadline),
+		},
+		{
+			name: "not the router",
+			tx: newTestRouterTx(t, decoder, testCAKE, nil, "swapExactTokensForTokens",
+				amountIn, big.NewInt(0), path, recipient, deadline),
+		},
+		{
+			name: "contract creation",
+			tx:   types.NewTx(&types.LegacyTx{Value: value, Data: truncated.Data()}),
+		},
+		{
+			name: "exact output swap",
+			tx: newTestRouterTx(t, decoder, testRouter, nil, "swapTokensForExactTokens",
+				amountIn, big.NewInt(1e18), path, recipient, deadline),
+		},
+		{
+			name: "short path",
+			tx: newTestRouterTx(t, decoder, testRouter, nil, "swapExactTokensForTokens",
+				amountIn, big.NewInt(0), []common.Address{testWBNB}, recipient, deadline),
+		},
+		{
+			name: "zero amountIn",
+			tx: newTestRouterTx(t, decoder, testRouter, nil, "swapExactTokensForTokens",
+				big.NewInt(0), big.NewInt(0), path, recipient, deadline),
+		},
+		{
+			name: "truncated calldata",
+			tx:   types.NewTx(&types.LegacyTx{To: &testRouter, Data: truncated.Data()[:68]}),
+		},
+		{
+			name: "short selector",
+			tx:   types.NewTx(&types.LegacyTx{To: &testRouter, Data: truncated.Data()[:3]}),
+		},
+		{
+			name: "unknown selector",
+			tx:   types.NewTx(&types.LegacyTx{To: &testRouter, Data: []byte{0xde, 0xad, 0xbe, 0xef}}),
+		},
+	} {
+		var swap, ok = decoder.Decode(test.tx)
+
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if swap.Method != test.method || swap.AmountIn.Cmp(test.amountIn) != 0 || len(swap.Path) != len(path) ||
+			swap.Path[0] != path[0] || swap.Path[2] != path[2] || swap.Tx != test.tx {
+			t.Errorf("%s: swap = %s %v %v, want %s %v %v", test.name, swap.Method, swap.AmountIn, swap.Path,
+				test.method, test.amountIn, path)
+		}
+	}
+}
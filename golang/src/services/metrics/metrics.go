@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"arbitrage-bot/config"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "arbitrage_bot"
+
+var labelNames = []string{"network", "provider"}
+
+var (
+	evaluationsPerCycle = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "evaluations_per_cycle",
+		Help:      "Number of triangular pairs evaluated in one cycle of the main loop.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, labelNames)
+	surfaceOpportunities = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "surface_opportunities_total",
+		Help:      "Number of triangular pairs with a profitable surface rate.",
+	}, labelNames)
+	depthConfirmations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "depth_confirmations_total",
+		Help:      "Number of surface opportunities confirmed by the depth calculation.",
+	}, labelNames)
+	executions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_total",
+		Help:      "Number of arbitrage executions by outcome.",
+	}, append(labelNames, "outcome"))
+	rpcLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_call_duration_seconds",
+		Help:      "Latency of RPC calls by contract method.",
+		Buckets:   prometheus.DefBuckets,
+	}, append(labelNames, "method"))
+	rpcEndpointRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_endpoint_requests_total",
+		Help:      "Number of requests sent to an RPC endpoint by outcome.",
+	}, []string{"network", "endpoint", "outcome"})
+	wsReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_reconnects_total",
+		Help:      "Number of times a WebSocket data stream was re-established.",
+	}, labelNames)
+	priceStaleness = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "price_staleness_seconds",
+		Help:      "Age of the price data at the moment it is used in a calculation.",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, labelNames)
+	inventoryBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inventory_balance",
+		Help:      "Free balance of an asset held on a venue (wallet, contract or CEX account).",
+	}, []string{"network", "venue", "asset"})
+	riskDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "risk_decisions_total",
+		Help:      "Number of risk checks of confirmed opportunities by reason (allowed or the limit rejecting it).",
+	}, []string{"network", "reason"})
+	backrunCandidates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backrun_candidates_total",
+		Help:      "Number of back-run candidates found in the mempool by outcome (queued or dropped).",
+	}, []string{"network", "outcome"})
+	stuckTransactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stuck_transactions_total",
+		Help:      "Number of stuck transactions of the executor wallet by action (replaced, cancelled or released).",
+	}, []string{"network", "action"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		evaluationsPerCycle,
+		surfaceOpportunities,
+		depthConfirmations,
+		executions,
+		rpcLatency,
+		rpcEndpointRequests,
+		wsReconnects,
+		priceStaleness,
+		inventoryBalance,
+		riskDecisions,
+		backrunCandidates,
+		stuckTransactions,
+	)
+}
+
+// Execution outcomes
+const (
+	OutcomeSuccess   = "success"
+	OutcomeFailure   = "failure"
+	OutcomeSkipped   = "skipped"
+	OutcomeSimulated = "simulated" // dry-run only, nothing was sent
+)
+
+// network ... returns the network label of the running bot
+func network() string {
+	return config.Get().Network
+}
+
+// Serve ... exposes the /metrics endpoint on the given address until the context is done (blocking)
+func Serve(ctx context.Context, address string) error {
+	var mux = http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	var server = &http.Server{Addr: address, Handler: mux}
+	var stop = context.AfterFunc(ctx, func() {
+		server.Shutdown(context.Background())
+	})
+	defer stop()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// ObserveEvaluations ... records the number of triangular pairs evaluated in a cycle
+func ObserveEvaluations(provider string, count int) {
+	evaluationsPerCycle.WithLabelValues(network(), provider).Observe(float64(count))
+}
+
+// IncSurfaceOpportunities ... counts profitable surface results
+func IncSurfaceOpportunities(provider string, count int) {
+	surfaceOpportunities.WithLabelValues(network(), provider).Add(float64(count))
+}
+
+// IncDepthConfirmations ... counts surface results confirmed by the depth calculation
+func IncDepthConfirmations(provider string) {
+	depthConfirmations.WithLabelValues(network(), provider).Inc()
+}
+
+// IncExecutions ... counts executions by outcome
+func IncExecutions(provider string, outcome string) {
+	executions.WithLabelValues(network(), provider, outcome).Inc()
+}
+
+// ObserveRPCLatency ... records the time elapsed since start for an RPC method
+func ObserveRPCLatency(provider string, method string, start time.Time) {
+	rpcLatency.WithLabelValues(network(), provider, method).Observe(time.Since(start).Seconds())
+}
+
+// IncRPCEndpointRequests ... counts the requests sent to an RPC endpoint (host) by outcome
+func IncRPCEndpointRequests(endpoint string, outcome string) {
+	rpcEndpointRequests.WithLabelValues(network(), endpoint, outcome).Inc()
+}
+
+// IncWebSocketReconnects ... counts re-established WebSocket streams
+func IncWebSocketReconnects(provider string) {
+	wsReconnects.WithLabelValues(network(), provider).Inc()
+}
+
+// ObservePriceStaleness ... records the age of a price at the time it is used
+func ObservePriceStaleness(provider string, eventTime time.Time) {
+	priceStaleness.WithLabelValues(network(), provider).Observe(time.Since(eventTime).Seconds())
+}
+
+// SetInventoryBalance ... records the free balance of an asset on a venue
+func SetInventoryBalance(venue string, asset string, balance float64) {
+	inventoryBalance.WithLabelValues(network(), venue, asset).Set(balance)
+}
+
+// IncRiskDecisions ... counts a risk check by reason
+func IncRiskDecisions(reason string) {
+	riskDecisions.WithLabelValues(network(), reason).Inc()
+}
+
+// IncBackrunCandidates ... counts the back-run candidates queued (success) or dropped (skipped)
+func IncBackrunCandidates(outcome string) {
+	backrunCandidates.WithLabelValues(network(), outcome).Inc()
+}
+
+// IncStuckTransactions ... counts a stuck transaction by action
+func IncStuckTransactions(action string) {
+	stuckTransactions.WithLabelValues(network(), action).Inc()
+}
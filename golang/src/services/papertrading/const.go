@@ -0,0 +1,45 @@
+package papertrading
+
+import (
+	"arbitrage-bot/helpers/units"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+// Statuses of a simulated trade
+const (
+	TradeFilled   string = "filled"
+	TradeReverted string = "reverted" // the triangle wasn't profitable anymore at the fill, only the gas is spent
+)
+
+// PaperTrade ... Represents a simulated execution of a triangle
+type PaperTrade struct {
+	Time        time.Time      `json:"time"`
+	TokenIn     common.Address `json:"tokenIn"`
+	AssetID     string         `json:"assetId"`     // canonical id of the starting token
+	AmountIn    units.Amount   `json:"amountIn"`    // amount of the starting token
+	ExpectedOut units.Amount   `json:"expectedOut"` // quoted at the decision
+	AmountOut   units.Amount   `json:"amountOut"`   // quoted after the latency (the fill)
+	ProfitLoss  float64        `json:"profitLoss"`  // in units of the starting token, 0 if reverted
+	Slippage    float64        `json:"slippage"`    // relative loss of the fill compared with the decision
+	GasCost     float64        `json:"gasCost"`     // in units of the native token
+	Status      string         `json:"status"`
+}
+
+// Ledger ... Represents the virtual balances & the simulated trades, persisted between runs
+type Ledger struct {
+	StartedAt time.Time               `json:"startedAt"`
+	Balances  map[string]units.Amount `json:"balances"` // canonical asset id -> virtual balance
+	Trades    []PaperTrade            `json:"trades"`
+}
+
+// PaperSummary ... Represents the profit & loss of the simulated trades
+type PaperSummary struct {
+	Since      time.Time          `json:"since"`
+	Trades     int                `json:"trades"`
+	Filled     int                `json:"filled"`
+	Reverted   int                `json:"reverted"`
+	ProfitLoss map[string]float64 `json:"profitLoss"` // canonical asset id -> profit & loss of the filled trades
+	GasCost    float64            `json:"gasCost"`    // in units of the native token
+	Balances   map[string]float64 `json:"balances"`
+}
@@ -0,0 +1,242 @@
+package papertrading
+
+import (
+	"arbitrage-bot/config"
+	fileHelper "arbitrage-bot/helpers/file"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"arbitrage-bot/services/web3"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// nativeDecimals ... decimals of the native token paying the gas
+const nativeDecimals int = 18
+
+// PaperTradingExecutor ... Simulates the executions against the pools: the triangle is quoted at the decision & again
+// after the latency (the fill), the result is kept in a virtual balance ledger
+type PaperTradingExecutor struct {
+	web3Service web3.DEXWeb3Service
+	settings    config.PaperTradingConfig
+	gas         config.GasConfig
+	chainID     int64
+	ledgerPath  string
+
+	mutex  sync.Mutex
+	ledger *Ledger
+}
+
+// NewPaperTradingExecutor ... creates a new paper-trading executor quoting the fills with the web3 service, the
+// ledger of a previous run is resumed
+func NewPaperTradingExecutor(web3Service web3.DEXWeb3Service) *PaperTradingExecutor {
+	var network = config.Get().ActiveNetwork()
+	var executor = &PaperTradingExecutor{
+		web3Service: web3Service,
+		settings:    network.Paper,
+		gas:         network.Gas,
+		chainID:     network.ChainID,
+		ledgerPath:  LedgerPath(),
+		ledger:      &Ledger{StartedAt: time.Now(), Balances: make(map[string]units.Amount)},
+	}
+
+	if fileHelper.PathExists(executor.ledgerPath) {
+		var ledger Ledger
+
+		if err := jsonHelper.ReadJSONFile(executor.ledgerPath, &ledger); err != nil {
+			slog.Warn("Error reading the paper trading ledger, starting a new one", slog.Any("error", err))
+		} else {
+			if ledger.Balances == nil {
+				ledger.Balances = make(map[string]units.Amount)
+			}
+			executor.ledger = &ledger
+		}
+	}
+
+	return executor
+}
+
+// LedgerPath ... returns the path of the paper trading ledger of the selected network
+func LedgerPath() string {
+	return "data/" + config.Get().Network + "/paperLedger.json"
+}
+
+// Quote ... returns the output of the trade paths at the current pool state
+func (p *PaperTradingExecutor) Quote(tradePaths []sp.TradePath, amountIn units.Amount) (units.Amount, error) {
+	return p.web3Service.GetPriceMultiplePaths(tradePaths, amountIn)
+}
+
+// ExecuteArbitrage ... simulates the flash swap of the trade paths, a triangle no longer profitable after the latency
+// reverts (the gas is still spent)
+func (p *PaperTradingExecutor) ExecuteArbitrage(tradePaths []sp.TradePath, amountIn units.Amount) (PaperTrade, error) {
+	expectedOut, err := p.Quote(tradePaths, amountIn)
+
+	if err != nil {
+		return PaperTrade{}, err
+	}
+	if p.settings.Latency > 0 {
+		time.Sleep(p.settings.Latency)
+	}
+
+	amountOut, err := p.Quote(tradePaths, amountIn)
+
+	if err != nil {
+		return PaperTrade{}, err
+	}
+	var tokenIn = tradePaths[0].BaseAssetAddress
+	var trade = PaperTrade{
+		Time:        time.Now(),
+		TokenIn:     tokenIn,
+		AssetID:     tokenregistry.Get().IDForAddress(p.chainID, tokenIn.Hex()),
+		AmountIn:    amountIn,
+		ExpectedOut: expectedOut,
+		AmountOut:   amountOut,
+		GasCost:     p.gasCost(len(tradePaths)).Float64(),
+		Status:      TradeFilled,
+	}
+
+	if !expectedOut.IsZero() {
+		trade.Slippage = 1 - amountOut.Ratio(expectedOut)
+	}
+
+	// the triangle starts & ends with the same token, the profit is exact
+	profitLoss, err := amountOut.SubE(amountIn)
+
+	if err != nil {
+		return PaperTrade{}, fmt.Errorf("error simulating the fill: %w", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if profitLoss.Sign() <= 0 {
+		trade.Status = TradeReverted
+		err = fmt.Errorf("simulated swap reverted: %s out for %s in", amountOut, amountIn)
+	} else {
+		trade.ProfitLoss = profitLoss.Float64()
+		err = p.credit(trade.AssetID, profitLoss)
+	}
+	if gasErr := p.credit(p.gas.NativeTicker, units.FromFloat(-trade.GasCost, nativeDecimals)); gasErr != nil {
+		slog.Warn("Error crediting the gas cost", slog.Any("error", gasErr))
+	}
+	p.ledger.Trades = append(p.ledger.Trades, trade)
+
+	if saveErr := jsonHelper.WriteJSONFileAtomic(p.ledgerPath, p.ledger); saveErr != nil {
+		slog.Warn("Error saving the paper trading ledger", slog.Any("error", saveErr))
+	}
+
+	return trade, err
+}
+
+// Summary ... returns the profit & loss of every simulated trade of the ledger
+func (p *PaperTradingExecutor) Summary() PaperSummary {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var summary = PaperSummary{
+		Since:      p.ledger.StartedAt,
+		Trades:     len(p.ledger.Trades),
+		ProfitLoss: make(map[string]float64),
+		Balances:   make(map[string]float64),
+	}
+
+	for _, trade := range p.ledger.Trades {
+		if trade.Status == TradeReverted {
+			summary.Reverted++
+		} else {
+			summary.Filled++
+			summary.ProfitLoss[trade.AssetID] += trade.ProfitLoss
+		}
+		summary.GasCost += trade.GasCost
+	}
+	for assetID, balance := range p.ledger.Balances {
+		summary.Balances[assetID] = balance.Float64()
+	}
+
+	return summary
+}
+
+// LogSummary ... logs the profit & loss of the simulated trades
+func (p *PaperTradingExecutor) LogSummary() {
+	var summary = p.Summary()
+
+	slog.Info(
+		"Paper trading summary",
+		slog.Time("since", summary.Since),
+		slog.Int("trades", summary.Trades),
+		slog.Int("filled", summary.Filled),
+		slog.Int("reverted", summary.Reverted),
+		slog.Any("profitLoss", summary.ProfitLoss),
+		slog.Float64("gasCost", summary.GasCost),
+		slog.Any("balances", summary.Balances),
+	)
+}
+
+// ReportSummaries ... logs a summary every summary interval until the context is done (blocking, returns at once if
+// the interval is 0)
+func (p *PaperTradingExecutor) ReportSummaries(ctx context.Context) {
+	if p.settings.SummaryInterval <= 0 {
+		return
+	}
+
+	var ticker = time.NewTicker(p.settings.SummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.LogSummary()
+		}
+	}
+}
+
+// Close ... saves the ledger & logs the final summary
+func (p *PaperTradingExecutor) Close() error {
+	p.mutex.Lock()
+	var err = jsonHelper.WriteJSONFileAtomic(p.ledgerPath, p.ledger)
+	p.mutex.Unlock()
+
+	p.LogSummary()
+
+	return err
+}
+
+// credit ... adds an amount to the virtual balance of an asset, starting from the configured balance (a balance of
+// the ledger in other decimals is an error, f.e. an asset id mapped to another token)
+func (p *PaperTradingExecutor) credit(assetID string, amount units.Amount) error {
+	var balance, ok = p.ledger.Balances[assetID]
+
+	if !ok {
+		balance = units.FromFloat(p.settings.Balances[assetID], amount.Decimals())
+	}
+
+	balance, err := balance.AddE(amount)
+
+	if err != nil {
+		return fmt.Errorf("error crediting %s: %w", assetID, err)
+	}
+	p.ledger.Balances[assetID] = balance
+
+	return nil
+}
+
+// gasCost ... returns the gas of the swaps in units of the native token, zero if the gas price can't be read
+func (p *PaperTradingExecutor) gasCost(swaps int) units.Amount {
+	gasPrice, err := p.web3Service.GetGasPrice()
+
+	if err != nil {
+		slog.Debug("Error getting gas price", slog.Any("error", err))
+		return units.Zero(nativeDecimals)
+	}
+
+	var gasUnits = new(big.Int).SetUint64(p.gas.SwapGasUnits * uint64(swaps))
+
+	return units.NewAmount(new(big.Int).Mul(gasPrice, gasUnits), nativeDecimals)
+}
@@ -0,0 +1,38 @@
+package portfolio
+
+import (
+	"arbitrage-bot/services/trading"
+)
+
+// On-chain venues, the CEX accounts are named after their provider
+const (
+	VenueWallet   string = "wallet"   // executor wallet
+	VenueContract string = "contract" // executor contract
+	VenueOnChain  string = "onchain"  // wallet & contract, compared with the CEX accounts when rebalancing
+)
+
+// OnChainVenues ... venues an on-chain trade can spend from
+var OnChainVenues = []string{VenueWallet, VenueContract}
+
+// Holding ... Represents an asset held on a venue
+type Holding struct {
+	Venue   string  `json:"venue"`
+	AssetID string  `json:"assetId"` // canonical asset id (see tokenregistry)
+	Asset   string  `json:"asset"`   // display name (token symbol or CEX ticker)
+	Free    float64 `json:"free"`
+	Locked  float64 `json:"locked"`
+}
+
+// RebalanceFlag ... Represents a venue holding too little of an asset compared with the other venues
+type RebalanceFlag struct {
+	AssetID string  `json:"assetId"`
+	Venue   string  `json:"venue"` // VenueOnChain or a CEX provider
+	Share   float64 `json:"share"` // share of the total free balance held by the venue
+	Total   float64 `json:"total"` // free balance across the venues
+}
+
+// BalanceReader ... reads the balances of a CEX account
+type BalanceReader interface {
+	GetName() string
+	GetBalances() ([]trading.AccountBalance, error)
+}
@@ -0,0 +1,334 @@
+package portfolio
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"arbitrage-bot/services/trading"
+	"arbitrage-bot/services/web3"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PortfolioService ... Tracks the funds held on every venue: the ERC20 balances of the executor wallet & contract,
+// the balances of the CEX accounts with an API key
+type PortfolioService struct {
+	settings config.PortfolioConfig
+	chainID  int64
+	erc20    *web3.ERC20Web3Service
+	owners   map[string]common.Address // on-chain venue -> address
+	readers  []BalanceReader
+
+	mutex     sync.RWMutex
+	tokens    []common.Address               // ERC20 tokens read on-chain
+	assetIDs  map[string]bool                // canonical ids of the tracked tokens
+	holdings  map[string]map[string]*Holding // asset id -> venue -> holding
+	updatedAt time.Time
+}
+
+// NewPortfolioService ... creates a new portfolio service for the selected network
+func NewPortfolioService() *PortfolioService {
+	var cfg = config.Get()
+	var network = cfg.ActiveNetwork()
+	var portfolio = &PortfolioService{
+		settings: network.Portfolio,
+		chainID:  network.ChainID,
+		owners:   make(map[string]common.Address),
+		assetIDs: make(map[string]bool),
+		holdings: make(map[string]map[string]*Holding),
+	}
+
+	if network.Portfolio.Wallet != "" {
+		portfolio.owners[VenueWallet] = common.HexToAddress(network.Portfolio.Wallet)
+	}
+	if network.Contracts.ArbitrageExecutor != "" {
+		portfolio.owners[VenueContract] = common.HexToAddress(network.Contracts.ArbitrageExecutor)
+	}
+	if len(portfolio.owners) > 0 {
+		erc20, err := web3.NewERC20Web3Service()
+
+		if err != nil {
+			slog.Warn("The on-chain balances won't be read", slog.Any("error", err))
+		}
+		portfolio.erc20 = erc20
+	}
+	// only the accounts with an API key are read
+	if settings := cfg.Exchange(sourceprovider.BinanceProviderName); settings.APIKey != "" {
+		portfolio.readers = append(portfolio.readers, trading.NewBinanceTradingClient())
+	}
+	if settings := cfg.Exchange(sourceprovider.MEXCProviderName); settings.APIKey != "" {
+		portfolio.readers = append(portfolio.readers, trading.NewMEXCAccountClient())
+	}
+
+	return portfolio
+}
+
+// Enabled ... whether the starting amounts are capped by the inventory
+func (p *PortfolioService) Enabled() bool {
+	return p.settings.Enabled
+}
+
+// TrackSymbols ... reads the balances of the tokens of the DEX symbols from now on
+func (p *PortfolioService) TrackSymbols(symbols []*sourceprovider.Symbol) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, symbol := range symbols {
+		for _, address := range []string{symbol.BaseAssetAddress, symbol.QuoteAssetAddress} {
+			if !common.IsHexAddress(address) {
+				continue
+			}
+
+			var token = common.HexToAddress(address)
+
+			if !slices.Contains(p.tokens, token) {
+				p.tokens = append(p.tokens, token)
+				p.assetIDs[tokenregistry.Get().IDForAddress(p.chainID, address)] = true
+			}
+		}
+	}
+}
+
+// Refresh ... reads the balances of every venue, a venue failing to answer keeps its previous balances
+func (p *PortfolioService) Refresh() error {
+	var errs []error
+	var holdings []*Holding
+	var failedVenues []string
+
+	if onChain, err := p.readOnChain(); err != nil {
+		errs = append(errs, err)
+		failedVenues = append(failedVenues, OnChainVenues...)
+	} else {
+		holdings = append(holdings, onChain...)
+	}
+	for _, reader := range p.readers {
+		if cexHoldings, err := p.readCEX(reader); err != nil {
+			errs = append(errs, err)
+			failedVenues = append(failedVenues, reader.GetName())
+		} else {
+			holdings = append(holdings, cexHoldings...)
+		}
+	}
+
+	p.mutex.Lock()
+	var current = make(map[string]map[string]*Holding)
+
+	for assetID, venues := range p.holdings {
+		for venue, holding := range venues {
+			if slices.Contains(failedVenues, venue) {
+				if current[assetID] == nil {
+					current[assetID] = make(map[string]*Holding)
+				}
+				current[assetID][venue] = holding
+			}
+		}
+	}
+	for _, holding := range holdings {
+		if current[holding.AssetID] == nil {
+			current[holding.AssetID] = make(map[string]*Holding)
+		}
+		current[holding.AssetID][holding.Venue] = holding
+		metrics.SetInventoryBalance(holding.Venue, holding.Asset, holding.Free)
+	}
+	p.holdings = current
+	p.updatedAt = time.Now()
+	p.mutex.Unlock()
+
+	for _, flag := range p.RebalanceFlags() {
+		slog.Warn("Inventory needs rebalancing", slog.String("asset", flag.AssetID), slog.String("venue", flag.Venue),
+			slog.Float64("share", flag.Share), slog.Float64("total", flag.Total))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RefreshIfStale ... refreshes the balances once they're older than the refresh interval
+func (p *PortfolioService) RefreshIfStale() error {
+	p.mutex.RLock()
+	var age = time.Since(p.updatedAt)
+	p.mutex.RUnlock()
+
+	if age < p.settings.RefreshInterval {
+		return nil
+	}
+
+	return p.Refresh()
+}
+
+// Holdings ... returns the holdings of every venue
+func (p *PortfolioService) Holdings() []Holding {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var holdings []Holding
+
+	for _, venues := range p.holdings {
+		for _, holding := range venues {
+			holdings = append(holdings, *holding)
+		}
+	}
+
+	return holdings
+}
+
+// Available ... returns the free balance of an asset on the venues (every venue if none is given)
+func (p *PortfolioService) Available(assetID string, venues ...string) float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var available float64
+
+	for venue, holding := range p.holdings[assetID] {
+		if len(venues) == 0 || slices.Contains(venues, venue) {
+			available += holding.Free
+		}
+	}
+
+	return available
+}
+
+// CapStartingAmount ... caps a starting amount by the share of the inventory a trade may use (unchanged if the
+// portfolio is disabled), 0 means there's nothing to trade with
+func (p *PortfolioService) CapStartingAmount(assetID string, amount float64, venues ...string) float64 {
+	if !p.Enabled() {
+		return amount
+	}
+
+	return math.Min(amount, p.Available(assetID, venues...)*p.settings.MaxShare)
+}
+
+// PickStartingAsset ... returns the first candidate asset with enough inventory for amount
+func (p *PortfolioService) PickStartingAsset(candidates []string, amount float64, venues ...string) (string, bool) {
+	for _, assetID := range candidates {
+		if p.CapStartingAmount(assetID, amount, venues...) >= amount {
+			return assetID, true
+		}
+	}
+
+	return "", false
+}
+
+// RebalanceFlags ... returns the venues (the on-chain venues count as one) holding less than the rebalance share
+// of an asset, for the tracked tokens & the assets held on several venues
+func (p *PortfolioService) RebalanceFlags() []RebalanceFlag {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var groups = p.venueGroups()
+	var flags []RebalanceFlag
+
+	if len(groups) < 2 || p.settings.RebalanceShare <= 0 {
+		return nil
+	}
+
+	for assetID, venues := range p.holdings {
+		var balances = make(map[string]float64)
+		var total float64
+
+		for venue, holding := range venues {
+			balances[venueGroup(venue)] += holding.Free
+			total += holding.Free
+		}
+		if total <= 0 || (!p.assetIDs[assetID] && len(balances) < 2) {
+			continue
+		}
+		for _, group := range groups {
+			if share := balances[group] / total; share < p.settings.RebalanceShare {
+				flags = append(flags, RebalanceFlag{AssetID: assetID, Venue: group, Share: share, Total: total})
+			}
+		}
+	}
+
+	return flags
+}
+
+// venueGroups ... returns the venues compared when rebalancing
+func (p *PortfolioService) venueGroups() []string {
+	var groups []string
+
+	if len(p.owners) > 0 {
+		groups = append(groups, VenueOnChain)
+	}
+	for _, reader := range p.readers {
+		groups = append(groups, reader.GetName())
+	}
+
+	return groups
+}
+
+// venueGroup ... returns the rebalancing group of a venue
+func venueGroup(venue string) string {
+	if slices.Contains(OnChainVenues, venue) {
+		return VenueOnChain
+	}
+
+	return venue
+}
+
+// readOnChain ... reads the ERC20 balances of the wallet & the contract
+func (p *PortfolioService) readOnChain() ([]*Holding, error) {
+	p.mutex.RLock()
+	var tokens = slices.Clone(p.tokens)
+	p.mutex.RUnlock()
+
+	if p.erc20 == nil || len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var venues = make(map[common.Address]string)
+	var owners []common.Address
+
+	for venue, owner := range p.owners {
+		venues[owner] = venue
+		owners = append(owners, owner)
+	}
+
+	balances, err := p.erc20.GetBalances(tokens, owners)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the on-chain balances: %w", err)
+	}
+
+	var holdings []*Holding
+
+	for _, balance := range balances {
+		holdings = append(holdings, &Holding{
+			Venue:   venues[balance.Owner],
+			AssetID: tokenregistry.Get().IDForAddress(p.chainID, balance.Token.Hex()),
+			Asset:   balance.Symbol,
+			Free:    balance.Balance.Float64(),
+		})
+	}
+
+	return holdings, nil
+}
+
+// readCEX ... reads the balances of a CEX account
+func (p *PortfolioService) readCEX(reader BalanceReader) ([]*Holding, error) {
+	balances, err := reader.GetBalances()
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the %s balances: %w", reader.GetName(), err)
+	}
+
+	var holdings []*Holding
+
+	for _, balance := range balances {
+		holdings = append(holdings, &Holding{
+			Venue:   reader.GetName(),
+			AssetID: tokenregistry.Get().IDForTicker(reader.GetName(), balance.Asset),
+			Asset:   balance.Asset,
+			Free:    balance.Free,
+			Locked:  balance.Locked,
+		})
+	}
+
+	return holdings, nil
+}
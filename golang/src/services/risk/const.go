@@ -0,0 +1,35 @@
+package risk
+
+// Reasons of a risk decision
+const (
+	ReasonAllowed     string = "allowed"
+	ReasonKillSwitch  string = "kill_switch"
+	ReasonBlacklisted string = "blacklisted"
+	ReasonMaxNotional string = "max_notional"
+	ReasonNoLimit     string = "no_limit" // the starting or the native token has no configured limit
+	ReasonRateLimit   string = "rate_limit"
+	ReasonDailyLoss   string = "daily_loss"
+	ReasonCooldown    string = "cooldown"
+)
+
+// Opportunity ... Represents a confirmed opportunity about to be executed
+type Opportunity struct {
+	TriangleID      string   `json:"triangleId"`
+	StartingAssetID string   `json:"startingAssetId"` // canonical id of the token spent by the first trade
+	AssetIDs        []string `json:"assetIds"`        // canonical ids of every token of the triangle
+	Notional        float64  `json:"notional"`        // amount of the starting token traded (limited per asset)
+}
+
+// Outcome ... Represents the realized result of a settled execution
+type Outcome struct {
+	ProfitLoss float64 // in units of the starting token, 0 if nothing was traded
+	GasCost    float64 // in units of the native token, 0 if nothing was mined
+	Err        error   // reason of a failure (cooldown of the triangle)
+}
+
+// Decision ... Represents the verdict of the risk manager on an opportunity
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	Detail  string `json:"detail"`
+}
@@ -0,0 +1,271 @@
+package risk
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/tokenregistry"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RiskManager ... Guards the execution of the confirmed opportunities: notional, trade rate, daily loss, blacklisted
+// tokens, cooldown of the failing triangles & a kill switch halting the execution (the evaluation keeps running)
+type RiskManager struct {
+	settings     config.RiskConfig
+	blacklist    map[string]bool    // canonical asset ids
+	maxNotional  map[string]float64 // canonical asset id -> limit
+	maxDailyLoss map[string]float64 // canonical asset id -> limit
+	nativeID     string             // canonical id of the native token, the gas is paid with
+
+	mutex     sync.Mutex
+	halted    string               // reason of a manual halt, empty if running
+	trades    []time.Time          // executions of the last minute
+	cooldowns map[string]time.Time // triangle id -> end of the cooldown
+	day       string               // UTC day of the PnL
+	dailyPnL  map[string]float64   // asset id -> realized profit & loss of the day, net of gas
+}
+
+// NewRiskManager ... creates a new risk manager for the selected network
+func NewRiskManager() *RiskManager {
+	var network = config.Get().ActiveNetwork()
+	var blacklist = make(map[string]bool)
+
+	for _, entry := range network.Risk.Blacklist {
+		if common.IsHexAddress(entry) {
+			entry = tokenregistry.Get().IDForAddress(network.ChainID, entry)
+		}
+		blacklist[strings.ToUpper(entry)] = true
+	}
+
+	return &RiskManager{
+		settings:     network.Risk,
+		blacklist:    blacklist,
+		maxNotional:  upperKeys(network.Risk.MaxNotional),
+		maxDailyLoss: upperKeys(network.Risk.MaxDailyLoss),
+		nativeID:     strings.ToUpper(tokenregistry.Get().IDForTicker("", network.Gas.NativeTicker)),
+		cooldowns:    make(map[string]time.Time),
+		dailyPnL:     make(map[string]float64),
+	}
+}
+
+// Check ... decides whether an opportunity may be executed, the decision is logged
+func (r *RiskManager) Check(opportunity Opportunity) Decision {
+	var decision = r.decide(opportunity)
+	var attrs = []any{
+		slog.String("triangleId", opportunity.TriangleID),
+		slog.String("asset", opportunity.StartingAssetID),
+		slog.Float64("notional", opportunity.Notional),
+		slog.Bool("allowed", decision.Allowed),
+		slog.String("reason", decision.Reason),
+	}
+
+	if decision.Detail != "" {
+		attrs = append(attrs, slog.String("detail", decision.Detail))
+	}
+	metrics.IncRiskDecisions(decision.Reason)
+
+	if decision.Allowed {
+		slog.Info("Risk check passed", attrs...)
+	} else {
+		slog.Warn("Risk check rejected the opportunity", attrs...)
+	}
+
+	return decision
+}
+
+// RecordExecution ... counts an execution against the trade rate, a settled execution is recorded right away (see
+// RecordSettlement), a submitted one once its outcome is known
+func (r *RiskManager) RecordExecution(opportunity Opportunity, outcome *Outcome) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.trades = append(r.trades, time.Now())
+
+	if outcome != nil {
+		r.recordOutcome(opportunity, *outcome)
+	}
+}
+
+// RecordSettlement ... adds the realized profit & loss of a settled execution to the daily PnL of the starting asset,
+// its gas to the loss of the native token, and starts the cooldown of the triangle if it failed
+func (r *RiskManager) RecordSettlement(opportunity Opportunity, outcome Outcome) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.recordOutcome(opportunity, outcome)
+}
+
+// Halt ... halts the execution until Resume is called
+func (r *RiskManager) Halt(reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if reason == "" {
+		reason = "manual halt"
+	}
+	r.halted = reason
+	slog.Warn("Kill switch engaged", slog.String("reason", reason))
+}
+
+// Resume ... lifts a halt (the kill switch file still applies)
+func (r *RiskManager) Resume() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.halted = ""
+	slog.Info("Kill switch released")
+}
+
+// Halted ... whether the execution is halted, with the reason
+func (r *RiskManager) Halted() (bool, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.haltReason() != "", r.haltReason()
+}
+
+// DailyPnL ... returns the realized profit & loss of an asset over the current UTC day, net of gas
+func (r *RiskManager) DailyPnL(assetID string) float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.resetDay(time.Now())
+
+	return r.dailyPnL[strings.ToUpper(assetID)]
+}
+
+// decide ... applies the kill switch then the limits (if enabled) to an opportunity
+func (r *RiskManager) decide(opportunity Opportunity) Decision {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if reason := r.haltReason(); reason != "" {
+		return Decision{Reason: ReasonKillSwitch, Detail: reason}
+	}
+	if !r.settings.Enabled {
+		return Decision{Allowed: true, Reason: ReasonAllowed}
+	}
+
+	var now = time.Now()
+	r.resetDay(now)
+
+	for _, assetID := range opportunity.AssetIDs {
+		if r.blacklist[strings.ToUpper(assetID)] {
+			return Decision{Reason: ReasonBlacklisted, Detail: assetID}
+		}
+	}
+	var startingAssetID = strings.ToUpper(opportunity.StartingAssetID)
+
+	// a token without limits could lose without bound, it isn't traded
+	if _, ok := r.maxNotional[startingAssetID]; !ok {
+		return Decision{Reason: ReasonNoLimit, Detail: "no maxNotional for " + startingAssetID}
+	}
+	for _, assetID := range []string{startingAssetID, r.nativeID} {
+		if _, ok := r.maxDailyLoss[assetID]; !ok {
+			return Decision{Reason: ReasonNoLimit, Detail: "no maxDailyLoss for " + assetID}
+		}
+	}
+	if limit := r.maxNotional[startingAssetID]; opportunity.Notional > limit {
+		return Decision{
+			Reason: ReasonMaxNotional,
+			Detail: fmt.Sprintf("%v %s above the limit of %v", opportunity.Notional, startingAssetID, limit),
+		}
+	}
+	if until, ok := r.cooldowns[opportunity.TriangleID]; ok {
+		if now.Before(until) {
+			return Decision{Reason: ReasonCooldown, Detail: fmt.Sprintf("until %s", until.Format(time.RFC3339))}
+		}
+		delete(r.cooldowns, opportunity.TriangleID)
+	}
+	if r.settings.MaxTradesPerMinute > 0 {
+		r.trades = slices.DeleteFunc(r.trades, func(trade time.Time) bool {
+			return now.Sub(trade) >= time.Minute
+		})
+
+		if len(r.trades) >= r.settings.MaxTradesPerMinute {
+			return Decision{
+				Reason: ReasonRateLimit,
+				Detail: fmt.Sprintf("%d trades in the last minute", len(r.trades)),
+			}
+		}
+	}
+	// the gas of every execution is paid with the native token
+	for _, assetID := range []string{startingAssetID, r.nativeID} {
+		if limit := r.maxDailyLoss[assetID]; -r.dailyPnL[assetID] >= limit {
+			return Decision{
+				Reason: ReasonDailyLoss,
+				Detail: fmt.Sprintf("lost %v %s of the limit of %v today", -r.dailyPnL[assetID], assetID, limit),
+			}
+		}
+	}
+
+	return Decision{Allowed: true, Reason: ReasonAllowed}
+}
+
+// haltReason ... returns the reason of the halt (manual or kill switch file), empty if running
+func (r *RiskManager) haltReason() string {
+	if r.halted != "" {
+		return r.halted
+	}
+	if r.settings.KillSwitchFile != "" {
+		if _, err := os.Stat(r.settings.KillSwitchFile); err == nil {
+			return fmt.Sprintf("kill switch file %s exists", r.settings.KillSwitchFile)
+		}
+	}
+
+	return ""
+}
+
+// resetDay ... clears the PnL when the UTC day changes
+func (r *RiskManager) resetDay(now time.Time) {
+	var day = now.UTC().Format(time.DateOnly)
+
+	if day != r.day {
+		r.day = day
+		r.dailyPnL = make(map[string]float64)
+	}
+}
+
+// recordOutcome ... adds a settled execution to the daily PnL & starts the cooldown of a failed triangle, the mutex
+// must be held
+func (r *RiskManager) recordOutcome(opportunity Opportunity, outcome Outcome) {
+	var now = time.Now()
+	var startingAssetID = strings.ToUpper(opportunity.StartingAssetID)
+	r.resetDay(now)
+	r.dailyPnL[startingAssetID] += outcome.ProfitLoss
+	r.dailyPnL[r.nativeID] -= outcome.GasCost
+
+	var attrs = []any{
+		slog.String("triangleId", opportunity.TriangleID),
+		slog.String("asset", startingAssetID),
+		slog.Float64("profitLoss", outcome.ProfitLoss),
+		slog.Float64("gasCost", outcome.GasCost),
+		slog.Float64("dailyPnL", r.dailyPnL[startingAssetID]),
+		slog.Float64("dailyGasPnL", r.dailyPnL[r.nativeID]),
+	}
+
+	if outcome.Err != nil && r.settings.FailureCooldown > 0 {
+		r.cooldowns[opportunity.TriangleID] = now.Add(r.settings.FailureCooldown)
+		slog.Warn("Triangle cooling down after a failed execution",
+			append(attrs, slog.Duration("cooldown", r.settings.FailureCooldown), slog.Any("error", outcome.Err))...)
+		return
+	}
+	slog.Info("Recorded execution", attrs...)
+}
+
+// upperKeys ... returns the limits keyed by the upper-cased asset ids
+func upperKeys(limits map[string]float64) map[string]float64 {
+	var upper = make(map[string]float64, len(limits))
+
+	for assetID, limit := range limits {
+		upper[strings.ToUpper(assetID)] = limit
+	}
+
+	return upper
+}
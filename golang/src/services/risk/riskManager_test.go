@@ -0,0 +1,244 @@
+package risk
+
+import (
+	"arbitrage-bot/config"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testSettings ... limits of a BNB & USDT triangle, the gas is paid in BNB
+var testSettings = config.RiskConfig{
+	Enabled:            true,
+	MaxNotional:        map[string]float64{"BNB": 10, "usdt": 5000},
+	MaxTradesPerMinute: 2,
+	MaxDailyLoss:       map[string]float64{"BNB": 0.5, "USDT": 100},
+	FailureCooldown:    time.Minute,
+}
+
+// newTestRiskManager ... creates a risk manager of the settings, the blacklist is given as canonical asset ids
+func newTestRiskManager(settings config.RiskConfig, blacklist ...string) *RiskManager {
+	var manager = &RiskManager{
+		settings:     settings,
+		blacklist:    make(map[string]bool),
+		maxNotional:  upperKeys(settings.MaxNotional),
+		maxDailyLoss: upperKeys(settings.MaxDailyLoss),
+		nativeID:     "BNB",
+		cooldowns:    make(map[string]time.Time),
+		dailyPnL:     make(map[string]float64),
+	}
+
+	for _, assetID := range blacklist {
+		manager.blacklist[assetID] = true
+	}
+
+	return manager
+}
+
+// testOpportunity ... returns an opportunity of the test triangle starting with an asset
+func testOpportunity(startingAssetID string, notional float64) Opportunity {
+	return Opportunity{
+		TriangleID:      "BNB-USDT-CAKE",
+		StartingAssetID: startingAssetID,
+		AssetIDs:        []string{"BNB", "USDT", "CAKE"},
+		Notional:        notional,
+	}
+}
+
+func TestDecide(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		settings    *config.RiskConfig // testSettings if nil
+		blacklist   []string
+		opportunity Opportunity
+		setup       func(r *RiskManager)
+		reason      string
+	}{
+		{name: "allowed", opportunity: testOpportunity("BNB", 10), reason: ReasonAllowed},
+		{name: "asset id case", opportunity: testOpportunity("usdt", 5000), reason: ReasonAllowed},
+		{name: "notional", opportunity: testOpportunity("BNB", 10.01), reason: ReasonMaxNotional},
+		{name: "no notional limit", opportunity: testOpportunity("CAKE", 1), reason: ReasonNoLimit},
+		{
+			name: "no daily loss limit", opportunity: testOpportunity("USDT", 1), reason: ReasonNoLimit,
+			settings: &config.RiskConfig{
+				Enabled:      true,
+				MaxNotional:  map[string]float64{"USDT": 5000},
+				MaxDailyLoss: map[string]float64{"USDT": 100},
+			},
+		},
+		{name: "blacklisted", blacklist: []string{"CAKE"}, opportunity: testOpportunity("BNB", 1), reason: ReasonBlacklisted},
+		{
+			name: "rate", opportunity: testOpportunity("BNB", 1), reason: ReasonRateLimit,
+			setup: func(r *RiskManager) {
+				r.trades = []time.Time{time.Now().Add(-30 * time.Second), time.Now()}
+			},
+		},
+		{
+			name: "rate window passed", opportunity: testOpportunity("BNB", 1), reason: ReasonAllowed,
+			setup: func(r *RiskManager) {
+				r.trades = []time.Time{time.Now().Add(-61 * time.Second), time.Now()}
+			},
+		},
+		{
+			name: "daily loss", opportunity: testOpportunity("USDT", 1), reason: ReasonDailyLoss,
+			setup: func(r *RiskManager) {
+				r.recordOutcome(testOpportunity("USDT", 1000), Outcome{ProfitLoss: -100})
+			},
+		},
+		{
+			name: "gas counts against the native token", opportunity: testOpportunity("USDT", 1), reason: ReasonDailyLoss,
+			setup: func(r *RiskManager) {
+				r.recordOutcome(testOpportunity("USDT", 1000), Outcome{ProfitLoss: 20, GasCost: 0.5})
+			},
+		},
+		{
+			name: "loss of another asset", opportunity: testOpportunity("BNB", 1), reason: ReasonAllowed,
+			setup: func(r *RiskManager) {
+				r.recordOutcome(testOpportunity("USDT", 1000), Outcome{ProfitLoss: -150})
+			},
+		},
+		{
+			name: "disabled", opportunity: testOpportunity("CAKE", 1e9), reason: ReasonAllowed,
+			settings: &config.RiskConfig{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var settings = testSettings
+
+			if test.settings != nil {
+				settings = *test.settings
+			}
+
+			var manager = newTestRiskManager(settings, test.blacklist...)
+
+			if test.setup != nil {
+				test.setup(manager)
+			}
+
+			var decision = manager.decide(test.opportunity)
+
+			if decision.Reason != test.reason || decision.Allowed != (test.reason == ReasonAllowed) {
+				t.Errorf("decide = %+v, want %s", decision, test.reason)
+			}
+		})
+	}
+}
+
+func TestRecordExecutionRate(t *testing.T) {
+	var manager = newTestRiskManager(testSettings)
+	var opportunity = testOpportunity("BNB", 1)
+
+	// a submitted execution counts against the rate before its outcome is known
+	manager.RecordExecution(opportunity, nil)
+	manager.RecordExecution(opportunity, &Outcome{ProfitLoss: 0.1})
+
+	if decision := manager.decide(opportunity); decision.Reason != ReasonRateLimit {
+		t.Errorf("decide after 2 trades = %+v, want %s", decision, ReasonRateLimit)
+	}
+	if pnl := manager.DailyPnL("bnb"); pnl != 0.1 {
+		t.Errorf("DailyPnL = %v, want 0.1", pnl)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	var manager = newTestRiskManager(testSettings)
+	var opportunity = testOpportunity("BNB", 1)
+
+	manager.RecordSettlement(opportunity, Outcome{Err: errors.New("reverted")})
+
+	if decision := manager.decide(opportunity); decision.Reason != ReasonCooldown {
+		t.Fatalf("decide after a failure = %+v, want %s", decision, ReasonCooldown)
+	}
+
+	// another triangle isn't cooling down
+	var other = opportunity
+	other.TriangleID = "BNB-USDT-ETH"
+
+	if decision := manager.decide(other); !decision.Allowed {
+		t.Errorf("decide of another triangle = %+v, want allowed", decision)
+	}
+
+	// the cooldown expired
+	manager.cooldowns[opportunity.TriangleID] = time.Now().Add(-time.Second)
+
+	if decision := manager.decide(opportunity); !decision.Allowed {
+		t.Errorf("decide after the cooldown = %+v, want allowed", decision)
+	}
+	if _, ok := manager.cooldowns[opportunity.TriangleID]; ok {
+		t.Error("expired cooldown wasn't dropped")
+	}
+}
+
+func TestDailyReset(t *testing.T) {
+	var manager = newTestRiskManager(testSettings)
+	var opportunity = testOpportunity("USDT", 1)
+
+	manager.RecordSettlement(opportunity, Outcome{ProfitLoss: -100, GasCost: 0.1})
+
+	if decision := manager.decide(opportunity); decision.Reason != ReasonDailyLoss {
+		t.Fatalf("decide after the loss = %+v, want %s", decision, ReasonDailyLoss)
+	}
+
+	// the loss was recorded on the previous UTC day
+	manager.day = time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	if decision := manager.decide(opportunity); !decision.Allowed {
+		t.Errorf("decide on the next day = %+v, want allowed", decision)
+	}
+	if pnl := manager.DailyPnL("USDT"); pnl != 0 {
+		t.Errorf("DailyPnL on the next day = %v, want 0", pnl)
+	}
+}
+
+func TestKillSwitch(t *testing.T) {
+	var settings = testSettings
+	settings.KillSwitchFile = filepath.Join(t.TempDir(), "KILL")
+	var manager = newTestRiskManager(settings)
+	var opportunity = testOpportunity("BNB", 1)
+
+	if halted, _ := manager.Halted(); halted {
+		t.Fatal("halted without a kill switch file")
+	}
+	if err := os.WriteFile(settings.KillSwitchFile, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if decision := manager.decide(opportunity); decision.Reason != ReasonKillSwitch {
+		t.Errorf("decide with the kill switch file = %+v, want %s", decision, ReasonKillSwitch)
+	}
+
+	// a resume doesn't lift the kill switch file
+	manager.Resume()
+
+	if halted, reason := manager.Halted(); !halted || reason == "" {
+		t.Errorf("Halted = %v (%s), want the kill switch file", halted, reason)
+	}
+	if err := os.Remove(settings.KillSwitchFile); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if decision := manager.decide(opportunity); !decision.Allowed {
+		t.Errorf("decide without the kill switch file = %+v, want allowed", decision)
+	}
+}
+
+func TestHaltResume(t *testing.T) {
+	// the kill switch applies even with the limits disabled
+	var manager = newTestRiskManager(config.RiskConfig{})
+	var opportunity = testOpportunity("BNB", 1)
+
+	manager.Halt("")
+
+	if halted, reason := manager.Halted(); !halted || reason != "manual halt" {
+		t.Errorf("Halted = %v (%s), want the manual halt", halted, reason)
+	}
+	if decision := manager.decide(opportunity); decision.Reason != ReasonKillSwitch || decision.Detail != "manual halt" {
+		t.Errorf("decide while halted = %+v, want %s", decision, ReasonKillSwitch)
+	}
+
+	manager.Resume()
+
+	if decision := manager.decide(opportunity); !decision.Allowed {
+		t.Errorf("decide after Resume = %+v, want allowed", decision)
+	}
+}
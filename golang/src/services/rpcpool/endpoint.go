@@ -0,0 +1,112 @@
+package rpcpool
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// scoreSmoothing ... weight of the latest request in the latency & error rate averages
+const scoreSmoothing = 0.2
+
+// errorWeight ... an endpoint failing every request scores as if it was errorWeight+1 times slower
+const errorWeight = 10
+
+// maxFailures ... failures in a row putting an endpoint in cooldown
+const maxFailures = 3
+
+// tokenBucket ... rate limiter allowing burst requests at once, refilled at rate requests per second
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve ... takes a token, returns how long to wait before it's available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// endpoint ... Represents an RPC endpoint & its health
+type endpoint struct {
+	mutex         sync.Mutex
+	url           *url.URL
+	bucket        tokenBucket
+	latency       float64 // moving average in seconds, 0 until the first success
+	errorRate     float64 // moving average of the failed requests
+	failures      int     // failures in a row
+	cooldownUntil time.Time
+}
+
+// newEndpoint ... creates an endpoint with a full token bucket
+func newEndpoint(endpointURL *url.URL, rate float64, burst int) *endpoint {
+	return &endpoint{
+		url:    endpointURL,
+		bucket: tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()},
+	}
+}
+
+// score ... the lower the better, unmeasured endpoints are tried first (the error rate is also added as seconds so
+// an endpoint which never succeeded doesn't keep a perfect score)
+func (e *endpoint) score() float64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.latency*(1+errorWeight*e.errorRate) + e.errorRate
+}
+
+// available ... whether the endpoint isn't in cooldown
+func (e *endpoint) available(now time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return !now.Before(e.cooldownUntil)
+}
+
+// reserve ... takes a token of the endpoint bucket, returns how long to wait before sending the request
+func (e *endpoint) reserve() time.Duration {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.bucket.reserve(time.Now())
+}
+
+// success ... records a successful request
+func (e *endpoint) success(latency time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.latency == 0 {
+		e.latency = latency.Seconds()
+	} else {
+		e.latency += scoreSmoothing * (latency.Seconds() - e.latency)
+	}
+	e.errorRate -= scoreSmoothing * e.errorRate
+	e.failures = 0
+}
+
+// failure ... records a failed request, returns whether the endpoint was put in cooldown
+func (e *endpoint) failure(cooldown time.Duration) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.errorRate += scoreSmoothing * (1 - e.errorRate)
+	e.failures++
+
+	if e.failures >= maxFailures {
+		e.failures = 0
+		e.cooldownUntil = time.Now().Add(cooldown)
+		return true
+	}
+
+	return false
+}
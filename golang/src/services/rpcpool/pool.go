@@ -0,0 +1,316 @@
+package rpcpool
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/metrics"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transientStatuses ... HTTP statuses worth retrying on another endpoint
+var transientStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// transientErrorCodes ... JSON-RPC error codes of a successful HTTP response worth retrying on another endpoint (limit
+// exceeded)
+var transientErrorCodes = []int{-32005}
+
+// transientErrorMessages ... JSON-RPC error messages of an endpoint lagging behind or rate limiting the requests
+var transientErrorMessages = []string{"header not found", "rate limit", "too many requests"}
+
+// rpcResponse ... error of a JSON-RPC response
+type rpcResponse struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var (
+	instance *Pool
+	initErr  error
+	once     sync.Once
+)
+
+// Init ... creates the pool of the selected network, the entry points call it to report an invalid rpc configuration
+// before starting the services
+func Init() error {
+	once.Do(func() {
+		var network = config.Get().ActiveNetwork()
+		instance, initErr = NewPool(network.RPCURLs, network.RPC)
+	})
+
+	return initErr
+}
+
+// Get ... returns the pool created by Init, shared by every web3 service (created on first use, panics if it can't be
+// created as the entry points already reported it)
+func Get() *Pool {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+
+	return instance
+}
+
+// Pool ... spreads the JSON-RPC requests over several HTTP endpoints: every endpoint is rate limited by a token
+// bucket, requests go to the endpoint with the best latency & error rate score & failed requests are retried on
+// another endpoint with an exponential backoff (an endpoint failing repeatedly is skipped for a cooldown)
+type Pool struct {
+	settings  config.RPCConfig
+	endpoints []*endpoint
+	transport http.RoundTripper
+	rpcClient *rpc.Client
+	client    *ethclient.Client
+}
+
+// NewPool ... creates a pool of HTTP(S) endpoints & the clients sending their requests through it
+func NewPool(urls []string, settings config.RPCConfig) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("at least one rpc url is required")
+	}
+
+	var pool = &Pool{
+		settings:  settings,
+		transport: &http.Transport{Proxy: http.ProxyFromEnvironment, ResponseHeaderTimeout: settings.Timeout},
+	}
+
+	for _, rawURL := range urls {
+		endpointURL, err := url.Parse(rawURL)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpc url: %w", err)
+		} else if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+			return nil, fmt.Errorf("rpc url %s: only http(s) endpoints can be pooled", endpointURL.Host)
+		}
+		pool.endpoints = append(pool.endpoints, newEndpoint(endpointURL, settings.RateLimit, settings.Burst))
+	}
+
+	// the url is only used to select the HTTP transport, RoundTrip picks the endpoint of every request
+	rpcClient, err := rpc.DialOptions(context.Background(), urls[0], rpc.WithHTTPClient(&http.Client{Transport: pool}))
+
+	if err != nil {
+		return nil, err
+	}
+	pool.rpcClient = rpcClient
+	pool.client = ethclient.NewClient(rpcClient)
+
+	return pool, nil
+}
+
+// Client ... returns the shared ethclient
+func (p *Pool) Client() *ethclient.Client {
+	return p.client
+}
+
+// RPCClient ... returns the shared raw RPC client (f.e. for eth_call with state overrides)
+func (p *Pool) RPCClient() *rpc.Client {
+	return p.rpcClient
+}
+
+// Close ... closes the shared clients & the idle connections of the endpoints
+func (p *Pool) Close() {
+	p.rpcClient.Close()
+
+	if transport, ok := p.transport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// RoundTrip ... sends the request to the best endpoint, retrying transient failures (transport errors, transient
+// HTTP statuses & rate limit or lagging node JSON-RPC errors) on the next best ones
+func (p *Pool) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	var err error
+
+	if request.Body != nil {
+		body, err = io.ReadAll(request.Body)
+		request.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tried = make(map[*endpoint]bool)
+	var lastErr error
+
+	for attempt := 0; attempt <= p.settings.MaxRetries; attempt++ {
+		if attempt > 0 {
+			var backoff = time.Duration(float64(p.settings.RetryBackoff) * math.Pow(2, float64(attempt-1)))
+
+			if err = sleep(request.Context(), backoff); err != nil {
+				return nil, err
+			}
+		}
+
+		var selected = p.pick(tried)
+
+		if err = sleep(request.Context(), selected.reserve()); err != nil {
+			return nil, err
+		}
+
+		var start = time.Now()
+		response, err := p.transport.RoundTrip(p.retarget(request, selected.url, body))
+
+		if err == nil && !slices.Contains(transientStatuses, response.StatusCode) {
+			// a rate limited or lagging endpoint still answers 200 with a JSON-RPC error
+			var responseBody []byte
+
+			responseBody, readErr := io.ReadAll(response.Body)
+			response.Body.Close()
+			response.Body = io.NopCloser(bytes.NewReader(responseBody))
+			response.ContentLength = int64(len(responseBody))
+
+			if err = readErr; err == nil {
+				err = transientRPCError(responseBody)
+			}
+			if err == nil {
+				selected.success(time.Since(start))
+				metrics.IncRPCEndpointRequests(selected.url.Host, metrics.OutcomeSuccess)
+				return response, nil
+			} else if readErr == nil && attempt == p.settings.MaxRetries {
+				// the last JSON-RPC error is returned as is so the caller can tell it from a transport error
+				metrics.IncRPCEndpointRequests(selected.url.Host, metrics.OutcomeFailure)
+				selected.failure(p.settings.Cooldown)
+				return response, nil
+			}
+		} else if err == nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			err = fmt.Errorf("unexpected status %s", response.Status)
+		}
+
+		metrics.IncRPCEndpointRequests(selected.url.Host, metrics.OutcomeFailure)
+		tried[selected] = true
+		lastErr = err
+
+		if selected.failure(p.settings.Cooldown) {
+			slog.Warn("RPC endpoint in cooldown", slog.String("endpoint", selected.url.Host), slog.Any("error", err))
+		}
+		if request.Context().Err() != nil {
+			return nil, request.Context().Err()
+		}
+	}
+
+	return nil, fmt.Errorf("rpc request failed after %d attempts: %w", p.settings.MaxRetries+1, lastErr)
+}
+
+// transientRPCError ... returns the JSON-RPC error of a response (or of a batch element) worth retrying on another
+// endpoint, the other errors (f.e. execution reverted) are left to the caller
+func transientRPCError(body []byte) error {
+	var responses []rpcResponse
+	var trimmed = bytes.TrimSpace(body)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if json.Unmarshal(trimmed, &responses) != nil {
+			return nil
+		}
+	} else {
+		var response rpcResponse
+
+		if json.Unmarshal(trimmed, &response) != nil {
+			return nil
+		}
+		responses = append(responses, response)
+	}
+
+	for _, response := range responses {
+		if response.Error == nil {
+			continue
+		}
+
+		var message = strings.ToLower(response.Error.Message)
+
+		if slices.Contains(transientErrorCodes, response.Error.Code) || slices.ContainsFunc(
+			transientErrorMessages, func(transient string) bool { return strings.Contains(message, transient) },
+		) {
+			return fmt.Errorf("rpc error %d: %s", response.Error.Code, response.Error.Message)
+		}
+	}
+
+	return nil
+}
+
+// pick ... returns the best scoring endpoint which isn't in cooldown & wasn't tried yet (the best scoring one if none
+// is left)
+func (p *Pool) pick(tried map[*endpoint]bool) *endpoint {
+	var now = time.Now()
+	var best *endpoint
+	var bestScore float64
+
+	for _, candidates := range [][]*endpoint{p.filter(tried, now, true), p.filter(tried, now, false), p.endpoints} {
+		for _, candidate := range candidates {
+			if score := candidate.score(); best == nil || score < bestScore {
+				best, bestScore = candidate, score
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+
+	return best
+}
+
+// filter ... returns the endpoints which weren't tried yet, only the ones out of cooldown if available is set
+func (p *Pool) filter(tried map[*endpoint]bool, now time.Time, available bool) []*endpoint {
+	var endpoints []*endpoint
+
+	for _, candidate := range p.endpoints {
+		if !tried[candidate] && (!available || candidate.available(now)) {
+			endpoints = append(endpoints, candidate)
+		}
+	}
+
+	return endpoints
+}
+
+// retarget ... copies the request for an endpoint
+func (p *Pool) retarget(request *http.Request, endpointURL *url.URL, body []byte) *http.Request {
+	var target = request.Clone(request.Context())
+	target.URL = endpointURL
+	target.Host = endpointURL.Host
+	target.Body = io.NopCloser(bytes.NewReader(body))
+	target.ContentLength = int64(len(body))
+	target.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return target
+}
+
+// sleep ... waits for the duration unless the context is done first
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	var timer = time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,6 +2,7 @@ package sourceprovider
 
 // ISourceProvider ... Interface for the source provider
 type ISourceProvider interface {
+	GetName() string
 	GetArbitragePairCachePath() string
 	//GetSymbols(force bool) ([]*Symbol, error)
 }
@@ -1,14 +1,20 @@
 package cex
 
 import (
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/metrics"
 	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"context"
+	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
-	"arbitrage-bot/helpers"
 	fileHelper "arbitrage-bot/helpers/file"
 	ioHelper "arbitrage-bot/helpers/io"
 	jsonHelper "arbitrage-bot/helpers/json"
@@ -23,15 +29,27 @@ type BinanceSourceProviderService struct {
 	// we'll get (fatal error: concurrent map read and map write) if using regular map
 	symbolPriceData     sync.Map
 	symbolOrderbookData sync.Map
+	// the lost streams are reopened until the context of the subscription is done
+	ctx          context.Context
+	stopClose    func() bool  // stops closing the streams with the context of the previous subscription
+	streamsMutex sync.Mutex   // held while the streams or the symbols are replaced
+	symbolsMutex sync.RWMutex // the handlers read the symbols while they're (un)subscribed
+	reconnecting atomic.Bool
 }
 
 // NewBinanceSourceProviderService ... creates a new Binance source provider
 func NewBinanceSourceProviderService() *BinanceSourceProviderService {
 	return &BinanceSourceProviderService{
 		symbols: make(map[string]*sourceprovider.Symbol),
+		ctx:     context.Background(),
 	}
 }
 
+// GetName ... returns the provider name
+func (b *BinanceSourceProviderService) GetName() string {
+	return sourceprovider.BinanceProviderName
+}
+
 // GetArbitragePairCachePath ... returns the path to the arbitrage pair cache
 func (b *BinanceSourceProviderService) GetArbitragePairCachePath() string {
 	return BinanceArbitragePairPath
@@ -57,51 +75,73 @@ func (b *BinanceSourceProviderService) GetSymbolOrderbookDepth(symbol string) *s
 
 // GetSymbols ... returns all the symbols
 func (b *BinanceSourceProviderService) GetSymbols(force bool) ([]*sourceprovider.Symbol, error) {
-	// get a list of all symbols on Binance & save to file as cache
+	// the cache is the raw exchangeInfo symbol list, parsed the same way as a fresh one (f.e. for the trading rules)
+	var symbols []interface{}
+
 	if !force && fileHelper.PathExists(BinanceTokenListPath) {
-		var symbols []*sourceprovider.Symbol
-		err := jsonHelper.ReadJSONFile(BinanceTokenListPath, &symbols)
+		if err := jsonHelper.ReadJSONFile(BinanceTokenListPath, &symbols); err != nil {
+			return nil, err
+		}
 
-		return symbols, err
+		return b.parseSymbols(symbols), nil
 	}
 
 	var data = make(map[string]interface{})
-	err := ioHelper.Get(BinanceAPIURL+"/exchangeInfo", &data)
-	helpers.Panic(err)
+	if err := ioHelper.Get(BinanceAPIURL+"/exchangeInfo", &data); err != nil {
+		return nil, fmt.Errorf("error getting the exchange info: %w", err)
+	}
 
-	dataMap := make([]*sourceprovider.Symbol, 0)
 	// Type assertion (a way to retrieve the dynamic type of an interface)
 	symbols, ok := data["symbols"].([]interface{})
 
 	if ok {
-		for _, symbol := range symbols {
-			s, ok := symbol.(map[string]interface{})
-			var quoteAsset = s["quoteAsset"].(string)
-
-			// skip other USD assets but USDT and USDC because they don't seem to be reliable
-			if ok && strings.Contains(quoteAsset, "USD") && quoteAsset != "USDT" && quoteAsset != "USDC" {
-				continue
-			}
-
-			if ok && s["isSpotTradingAllowed"].(bool) {
-				dataMap = append(dataMap, &sourceprovider.Symbol{
-					Symbol:     s["symbol"].(string),
-					BaseAsset:  s["baseAsset"].(string),
-					QuoteAsset: s["quoteAsset"].(string),
-				})
-			}
-		}
 		// save to file
 		jsonHelper.WriteJSONFile(BinanceTokenListPath, symbols)
 	}
 
-	return dataMap, err
+	return b.parseSymbols(symbols), nil
+}
+
+// parseSymbols ... keeps the spot symbols of an exchangeInfo symbol list, with their trading rules
+func (b *BinanceSourceProviderService) parseSymbols(symbols []interface{}) []*sourceprovider.Symbol {
+	dataMap := make([]*sourceprovider.Symbol, 0)
+
+	for _, symbol := range symbols {
+		s, ok := symbol.(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+		var quoteAsset, _ = s["quoteAsset"].(string)
+
+		// skip other USD assets but USDT and USDC because they don't seem to be reliable
+		if strings.Contains(quoteAsset, "USD") && quoteAsset != "USDT" && quoteAsset != "USDC" {
+			continue
+		}
+
+		if spotTradingAllowed, _ := s["isSpotTradingAllowed"].(bool); spotTradingAllowed {
+			var rules = ParseBinanceTradingRules(s)
+			dataMap = append(dataMap, &sourceprovider.Symbol{
+				Symbol:     s["symbol"].(string),
+				BaseAsset:  s["baseAsset"].(string),
+				QuoteAsset: quoteAsset,
+				Rules:      &rules,
+			})
+			tokenregistry.Get().AnnotateCEXSymbol(b.GetName(), dataMap[len(dataMap)-1])
+		}
+	}
+
+	return dataMap
 }
 
 // SubscribeSymbols ... subscribes to the symbols
-func (b *BinanceSourceProviderService) SubscribeSymbols(symbols []*sourceprovider.Symbol) {
+func (b *BinanceSourceProviderService) SubscribeSymbols(ctx context.Context, symbols []*sourceprovider.Symbol) error {
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
 	// subscribe a new data stream for a new symbol
 	// check if symbol already exists
+	b.symbolsMutex.Lock()
 	for _, symbol := range symbols {
 		if _, ok := b.symbols[symbol.Symbol]; ok {
 			continue
@@ -109,13 +149,98 @@ func (b *BinanceSourceProviderService) SubscribeSymbols(symbols []*sourceprovide
 
 		b.symbols[symbol.Symbol] = symbol
 	}
+	b.symbolsMutex.Unlock()
+
+	b.stopTickerDataStream()
+	b.stopOrderbookDepthStream()
+
+	// the streams are closed with the context, once per context
+	if ctx != b.ctx {
+		if b.stopClose != nil {
+			b.stopClose()
+		}
+		b.ctx = ctx
+		b.stopClose = context.AfterFunc(ctx, b.Close)
+	}
+
+	return b.startDataStreams()
+}
+
+// getSymbol ... returns a subscribed symbol, the data streams are handled while the symbols are (un)subscribed
+func (b *BinanceSourceProviderService) getSymbol(name string) *sourceprovider.Symbol {
+	b.symbolsMutex.RLock()
+	defer b.symbolsMutex.RUnlock()
+
+	return b.symbols[name]
+}
+
+// startDataStreams ... opens the ticker & order book data streams of the symbols
+func (b *BinanceSourceProviderService) startDataStreams() error {
+	if err := b.startTickerDataStream(); err != nil {
+		return err
+	}
+
+	return b.startOrderbookDepthStream()
+}
+
+// handleStreamError ... drops the prices of the streams (they aren't updated anymore) & reopens the streams in the
+// background
+func (b *BinanceSourceProviderService) handleStreamError(err error) {
+	logger.WithProvider(b.GetName()).Warn("Data stream lost, reconnecting", slog.Any("error", err))
+	clearPrices(&b.symbolPriceData)
+	clearPrices(&b.symbolOrderbookData)
+
+	if b.reconnecting.CompareAndSwap(false, true) {
+		go b.reconnect()
+	}
+}
+
+// reconnect ... reopens the data streams every streamReconnectDelay until it succeeds or the subscription is done
+func (b *BinanceSourceProviderService) reconnect() {
+	defer b.reconnecting.Store(false)
+
+	var log = logger.WithProvider(b.GetName())
+	b.streamsMutex.Lock()
+	var ctx = b.ctx
+	b.streamsMutex.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(streamReconnectDelay):
+		}
+
+		b.streamsMutex.Lock()
+
+		// closed while waiting, the streams stay stopped
+		if ctx.Err() != nil {
+			b.streamsMutex.Unlock()
+			return
+		}
+		b.stopTickerDataStream()
+		b.stopOrderbookDepthStream()
+		var err = b.startDataStreams()
+		b.streamsMutex.Unlock()
+
+		if err == nil {
+			log.Info("Data streams reconnected")
+			return
+		}
+		log.Warn("Error reconnecting the data streams", slog.Any("error", err))
+	}
+}
+
+// Close ... closes the data streams
+func (b *BinanceSourceProviderService) Close() {
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
 	b.stopTickerDataStream()
 	b.stopOrderbookDepthStream()
-	b.startTickerDataStream()
-	b.startOrderbookDepthStream()
 }
 
-func (b *BinanceSourceProviderService) startTickerDataStream() {
+func (b *BinanceSourceProviderService) startTickerDataStream() error {
 	// subscribe to multiple data streams using one connection (ticker topic)
 	// https://developers.binance.com/docs/binance-spot-api-docs/web-socket-streams#individual-symbol-ticker-streams
 	var symbolString string
@@ -126,13 +251,24 @@ func (b *BinanceSourceProviderService) startTickerDataStream() {
 	}
 
 	if charCount = utf8.RuneCountInString(symbolString); charCount == 0 {
-		return
+		return nil
 	}
 
 	symbolString = string([]rune(symbolString)[:charCount-1])
 	var endpoint = BinanceWsURL + "/stream?streams=" + symbolString
-	b.streamTicker = ioHelper.NewWebSocketClient(endpoint)
-	b.streamTicker.Start(b.handleTickerDataStream)
+
+	if b.streamTicker != nil {
+		metrics.IncWebSocketReconnects(b.GetName())
+	}
+	stream, err := ioHelper.NewWebSocketClient(endpoint)
+
+	if err != nil {
+		return err
+	}
+	b.streamTicker = stream
+	b.streamTicker.Start(b.handleTickerDataStream, b.handleStreamError)
+
+	return nil
 }
 
 func (b *BinanceSourceProviderService) handleTickerDataStream(data *[]byte) {
@@ -144,7 +280,7 @@ func (b *BinanceSourceProviderService) handleTickerDataStream(data *[]byte) {
 	bestBid, _ := strconv.ParseFloat(ticker.Data.BestBidPrice, 64)
 
 	b.symbolPriceData.Store(ticker.Data.Symbol, &SymbolPrice{
-		Symbol:    b.symbols[ticker.Data.Symbol],
+		Symbol:    b.getSymbol(ticker.Data.Symbol),
 		BestBid:   bestBid,
 		BestAsk:   bestAsk,
 		EventTime: time.Unix(0, ticker.Data.EventTime*1000000),
@@ -162,16 +298,22 @@ func (b *BinanceSourceProviderService) stopTickerDataStream() {
 }
 
 // UnsubscribeSymbol ... unsubscribes from the symbol
-func (b *BinanceSourceProviderService) UnsubscribeSymbol(symbol *sourceprovider.Symbol) {
+func (b *BinanceSourceProviderService) UnsubscribeSymbol(symbol *sourceprovider.Symbol) error {
 	// unsubscribe a symbol from the data stream (remove symbol from the map -> restart data stream)
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
+	b.symbolsMutex.Lock()
 	delete(b.symbols, symbol.Symbol)
+	b.symbolsMutex.Unlock()
+
 	b.stopTickerDataStream()
 	b.stopOrderbookDepthStream()
-	b.startTickerDataStream()
-	b.startOrderbookDepthStream()
+
+	return b.startDataStreams()
 }
 
-func (b *BinanceSourceProviderService) startOrderbookDepthStream() {
+func (b *BinanceSourceProviderService) startOrderbookDepthStream() error {
 	// subscribe to multiple data streams using one connection (order book/depth topic)
 	var symbolString string
 	var charCount int = 0
@@ -181,13 +323,24 @@ func (b *BinanceSourceProviderService) startOrderbookDepthStream() {
 	}
 
 	if charCount = utf8.RuneCountInString(symbolString); charCount == 0 {
-		return
+		return nil
 	}
 
 	symbolString = string([]rune(symbolString)[:charCount-1])
 	var endpoint string = BinanceWsURL + "/stream?streams=" + symbolString
-	b.streamOrderbookDepth = ioHelper.NewWebSocketClient(endpoint)
-	b.streamOrderbookDepth.Start(b.handleOrderbookDepthStream)
+
+	if b.streamOrderbookDepth != nil {
+		metrics.IncWebSocketReconnects(b.GetName())
+	}
+	stream, err := ioHelper.NewWebSocketClient(endpoint)
+
+	if err != nil {
+		return err
+	}
+	b.streamOrderbookDepth = stream
+	b.streamOrderbookDepth.Start(b.handleOrderbookDepthStream, b.handleStreamError)
+
+	return nil
 }
 
 func (b *BinanceSourceProviderService) handleOrderbookDepthStream(data *[]byte) {
@@ -195,7 +348,7 @@ func (b *BinanceSourceProviderService) handleOrderbookDepthStream(data *[]byte)
 	var orderbookDepth BinanceOrderbookDepth
 	jsonHelper.Unmarshal(*data, &orderbookDepth)
 	var symbolOrderbookDepth sourceprovider.SymbolOrderbookDepth = sourceprovider.SymbolOrderbookDepth{
-		Symbol:       b.symbols[orderbookDepth.GetSymbol()],
+		Symbol:       b.getSymbol(orderbookDepth.GetSymbol()),
 		LastUpdateID: orderbookDepth.Data.LastUpdateID,
 		Asks:         make([]*sourceprovider.OrderbookEntry, len(orderbookDepth.Data.Asks)),
 		Bids:         make([]*sourceprovider.OrderbookEntry, len(orderbookDepth.Data.Bids)),
@@ -231,3 +384,46 @@ func (b *BinanceSourceProviderService) stopOrderbookDepthStream() {
 		b.streamOrderbookDepth.Stop()
 	}
 }
+
+// ParseBinanceTradingRules ... reads the status & the LOT_SIZE, PRICE_FILTER & (MIN_)NOTIONAL filters of an
+// exchangeInfo symbol
+func ParseBinanceTradingRules(symbol map[string]interface{}) sourceprovider.TradingRules {
+	var rules sourceprovider.TradingRules
+	rules.Status, _ = symbol["status"].(string)
+
+	if precision, ok := symbol["quoteAssetPrecision"].(float64); ok {
+		rules.QuotePrecision = int(precision)
+	}
+
+	var filters, _ = symbol["filters"].([]interface{})
+
+	for _, item := range filters {
+		var filter, ok = item.(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+		switch filter["filterType"] {
+		case "LOT_SIZE":
+			rules.MinQty = parseFilterValue(filter, "minQty")
+			rules.MaxQty = parseFilterValue(filter, "maxQty")
+			rules.StepSize = parseFilterValue(filter, "stepSize")
+		case "PRICE_FILTER":
+			rules.MinPrice = parseFilterValue(filter, "minPrice")
+			rules.MaxPrice = parseFilterValue(filter, "maxPrice")
+			rules.TickSize = parseFilterValue(filter, "tickSize")
+		case "NOTIONAL", "MIN_NOTIONAL":
+			rules.MinNotional = parseFilterValue(filter, "minNotional")
+		}
+	}
+
+	return rules
+}
+
+// parseFilterValue ... parses a decimal string of an exchangeInfo filter (0 if it's missing)
+func parseFilterValue(filter map[string]interface{}, key string) float64 {
+	var text, _ = filter[key].(string)
+	var value, _ = strconv.ParseFloat(text, 64)
+
+	return value
+}
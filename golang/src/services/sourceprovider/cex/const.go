@@ -2,10 +2,16 @@ package cex
 
 import (
 	"arbitrage-bot/services/sourceprovider"
+	"context"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
+// streamReconnectDelay ... wait before a lost data stream is reopened (& between the failed attempts)
+const streamReconnectDelay = 5 * time.Second
+
 // SymbolPrice ... Represents the price of a symbol
 type SymbolPrice struct {
 	Symbol    *sourceprovider.Symbol `json:"symbol"`
@@ -14,6 +20,14 @@ type SymbolPrice struct {
 	EventTime time.Time              `json:"eventTime"`
 }
 
+// clearPrices ... drops every entry of a price or order book map
+func clearPrices(prices *sync.Map) {
+	prices.Range(func(key any, _ any) bool {
+		prices.Delete(key)
+		return true
+	})
+}
+
 // BinanceAPIURL ... Binance API URL
 const BinanceAPIURL string = "https://api.binance.com/api/v3"
 
@@ -105,7 +119,21 @@ type MEXCSymbolTicker struct {
 // ISourceProvider ... Interface for the CEX source provider
 type ISourceProvider interface {
 	sourceprovider.ISourceProvider
-	SubscribeSymbols(symbols []*sourceprovider.Symbol)
+	SubscribeSymbols(ctx context.Context, symbols []*sourceprovider.Symbol) error
+	Close()
 	GetSymbolPrice(symbol string) *SymbolPrice
 	GetSymbolOrderbookDepth(symbol string) *sourceprovider.SymbolOrderbookDepth
+	GetSymbols(force bool) ([]*sourceprovider.Symbol, error)
+}
+
+// NewSourceProvider ... creates the source provider of a CEX by name (binance, mexc)
+func NewSourceProvider(name string) (ISourceProvider, error) {
+	switch name {
+	case sourceprovider.BinanceProviderName:
+		return NewBinanceSourceProviderService(), nil
+	case sourceprovider.MEXCProviderName:
+		return NewMEXCSourceProviderService(), nil
+	}
+
+	return nil, fmt.Errorf("unknown CEX %s", name)
 }
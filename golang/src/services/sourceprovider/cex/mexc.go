@@ -5,11 +5,18 @@ import (
 	fileHelper "arbitrage-bot/helpers/file"
 	ioHelper "arbitrage-bot/helpers/io"
 	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/metrics"
 	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"context"
 	"fmt"
+	"log/slog"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,15 +28,27 @@ type MEXCSourceProviderService struct {
 	symbols               map[string]*sourceprovider.Symbol
 	symbolPriceData       sync.Map
 	symbolOrderbookData   sync.Map
+	// the lost streams are reopened until the context of the subscription is done
+	ctx          context.Context
+	stopClose    func() bool  // stops closing the streams with the context of the previous subscription
+	streamsMutex sync.Mutex   // held while the streams or the symbols are replaced
+	symbolsMutex sync.RWMutex // the handlers read the symbols while they're (un)subscribed
+	reconnecting atomic.Bool
 }
 
 // NewMEXCSourceProviderService ... creates a new MEXC source provider
 func NewMEXCSourceProviderService() *MEXCSourceProviderService {
 	return &MEXCSourceProviderService{
 		symbols: make(map[string]*sourceprovider.Symbol),
+		ctx:     context.Background(),
 	}
 }
 
+// GetName ... returns the provider name
+func (b *MEXCSourceProviderService) GetName() string {
+	return sourceprovider.MEXCProviderName
+}
+
 // GetArbitragePairCachePath implements sourceprovider.ICexSourceProvider.
 func (b *MEXCSourceProviderService) GetArbitragePairCachePath() string {
 	return MEXCArbitragePairPath
@@ -55,51 +74,74 @@ func (b *MEXCSourceProviderService) GetSymbolOrderbookDepth(symbol string) *sour
 
 // GetSymbols ... returns a list of symbols
 func (b *MEXCSourceProviderService) GetSymbols(force bool) ([]*sourceprovider.Symbol, error) {
-	// get a list of all symbols on Binance & save to file as cache
+	// the cache is the raw exchangeInfo symbol list, parsed the same way as a fresh one (f.e. for the trading rules)
+	var symbols []interface{}
+
 	if !force && fileHelper.PathExists(MEXCTokenListPath) {
-		var symbols []*sourceprovider.Symbol
-		err := jsonHelper.ReadJSONFile(MEXCTokenListPath, &symbols)
+		if err := jsonHelper.ReadJSONFile(MEXCTokenListPath, &symbols); err != nil {
+			return nil, err
+		}
 
-		return symbols, err
+		return b.parseSymbols(symbols), nil
 	}
+
 	var data = make(map[string]interface{})
-	err := ioHelper.Get(MEXCAPIURL+"/exchangeInfo", &data)
-	helpers.Panic(err)
+	if err := ioHelper.Get(MEXCAPIURL+"/exchangeInfo", &data); err != nil {
+		return nil, fmt.Errorf("error getting the exchange info: %w", err)
+	}
 
-	dataMap := make([]*sourceprovider.Symbol, 0)
 	// Type assertion (a way to retrieve the dynamic type of an interface)
 	symbols, ok := data["symbols"].([]interface{})
 
 	if ok {
-		for _, symbol := range symbols {
-			s, ok := symbol.(map[string]interface{})
-			var quoteAsset string = s["quoteAsset"].(string)
-
-			// skip other USD assets but USDT and USDC because they don't seem to be reliable
-			if ok && strings.Contains(quoteAsset, "USD") && quoteAsset != "USDT" && quoteAsset != "USDC" {
-				continue
-			}
-
-			if ok && s["isSpotTradingAllowed"].(bool) {
-				dataMap = append(dataMap, &sourceprovider.Symbol{
-					Symbol:     s["symbol"].(string),
-					BaseAsset:  s["baseAsset"].(string),
-					QuoteAsset: s["quoteAsset"].(string),
-				})
-				fmt.Println("dataMap", *dataMap[len(dataMap)-1])
-			}
-		}
 		// save to file
 		jsonHelper.WriteJSONFile(MEXCTokenListPath, symbols)
 	}
 
-	return dataMap, err
+	return b.parseSymbols(symbols), nil
+}
+
+// parseSymbols ... keeps the spot symbols of an exchangeInfo symbol list, with their trading rules
+func (b *MEXCSourceProviderService) parseSymbols(symbols []interface{}) []*sourceprovider.Symbol {
+	dataMap := make([]*sourceprovider.Symbol, 0)
+
+	for _, symbol := range symbols {
+		s, ok := symbol.(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+		var quoteAsset, _ = s["quoteAsset"].(string)
+
+		// skip other USD assets but USDT and USDC because they don't seem to be reliable
+		if strings.Contains(quoteAsset, "USD") && quoteAsset != "USDT" && quoteAsset != "USDC" {
+			continue
+		}
+
+		if spotTradingAllowed, _ := s["isSpotTradingAllowed"].(bool); spotTradingAllowed {
+			var rules = ParseMEXCTradingRules(s)
+			dataMap = append(dataMap, &sourceprovider.Symbol{
+				Symbol:     s["symbol"].(string),
+				BaseAsset:  s["baseAsset"].(string),
+				QuoteAsset: quoteAsset,
+				Rules:      &rules,
+			})
+			tokenregistry.Get().AnnotateCEXSymbol(b.GetName(), dataMap[len(dataMap)-1])
+			logger.WithProvider(b.GetName()).Debug("Found symbol", slog.String("symbol", s["symbol"].(string)))
+		}
+	}
+
+	return dataMap
 }
 
 // SubscribeSymbols ... subscribes to a list of symbols
-func (b *MEXCSourceProviderService) SubscribeSymbols(symbols []*sourceprovider.Symbol) {
+func (b *MEXCSourceProviderService) SubscribeSymbols(ctx context.Context, symbols []*sourceprovider.Symbol) error {
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
 	// subscribe a new data stream for a new symbol
 	// check if symbol already exists
+	b.symbolsMutex.Lock()
 	for _, symbol := range symbols {
 		if _, ok := b.symbols[symbol.Symbol]; ok {
 			continue
@@ -107,17 +149,109 @@ func (b *MEXCSourceProviderService) SubscribeSymbols(symbols []*sourceprovider.S
 
 		b.symbols[symbol.Symbol] = symbol
 	}
+	b.symbolsMutex.Unlock()
+
 	b.stopTickerDataStream()
 	b.stopOrderbookDepthStream()
-	b.startTickerDataStream()
-	b.startOrderbookDepthStream()
+
+	// the streams are closed with the context, once per context
+	if ctx != b.ctx {
+		if b.stopClose != nil {
+			b.stopClose()
+		}
+		b.ctx = ctx
+		b.stopClose = context.AfterFunc(ctx, b.Close)
+	}
+
+	return b.startDataStreams()
 }
 
-func (b *MEXCSourceProviderService) startTickerDataStream() {
+// getSymbol ... returns a subscribed symbol, the data streams are handled while the symbols are (un)subscribed
+func (b *MEXCSourceProviderService) getSymbol(name string) *sourceprovider.Symbol {
+	b.symbolsMutex.RLock()
+	defer b.symbolsMutex.RUnlock()
+
+	return b.symbols[name]
+}
+
+// startDataStreams ... opens the ticker & order book data streams of the symbols
+func (b *MEXCSourceProviderService) startDataStreams() error {
+	if err := b.startTickerDataStream(); err != nil {
+		return err
+	}
+
+	return b.startOrderbookDepthStream()
+}
+
+// handleStreamError ... drops the prices of the streams (they aren't updated anymore) & reopens the streams in the
+// background
+func (b *MEXCSourceProviderService) handleStreamError(err error) {
+	logger.WithProvider(b.GetName()).Warn("Data stream lost, reconnecting", slog.Any("error", err))
+	clearPrices(&b.symbolPriceData)
+	clearPrices(&b.symbolOrderbookData)
+
+	if b.reconnecting.CompareAndSwap(false, true) {
+		go b.reconnect()
+	}
+}
+
+// reconnect ... reopens the data streams every streamReconnectDelay until it succeeds or the subscription is done
+func (b *MEXCSourceProviderService) reconnect() {
+	defer b.reconnecting.Store(false)
+
+	var log = logger.WithProvider(b.GetName())
+	b.streamsMutex.Lock()
+	var ctx = b.ctx
+	b.streamsMutex.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(streamReconnectDelay):
+		}
+
+		b.streamsMutex.Lock()
+
+		// closed while waiting, the streams stay stopped
+		if ctx.Err() != nil {
+			b.streamsMutex.Unlock()
+			return
+		}
+		b.stopTickerDataStream()
+		b.stopOrderbookDepthStream()
+		var err = b.startDataStreams()
+		b.streamsMutex.Unlock()
+
+		if err == nil {
+			log.Info("Data streams reconnected")
+			return
+		}
+		log.Warn("Error reconnecting the data streams", slog.Any("error", err))
+	}
+}
+
+// Close ... closes the data streams
+func (b *MEXCSourceProviderService) Close() {
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
+	b.stopTickerDataStream()
+	b.stopOrderbookDepthStream()
+}
+
+func (b *MEXCSourceProviderService) startTickerDataStream() error {
 	// subscribe to multiple data streams using one connection (ticker topic)
 	// https://developers.binance.com/docs/binance-spot-api-docs/web-socket-streams#individual-symbol-ticker-streams
 	var symbols []string
 
+	// the previous clients are stopped before they're replaced, Stop is a no-op for the stopped ones
+	if len(b.streamsTicker) > 0 {
+		metrics.IncWebSocketReconnects(b.GetName())
+		b.stopTickerDataStream()
+		b.streamsTicker = nil
+	}
+
 	for symbol := range b.symbols {
 		symbols = append(symbols, "spot@public.bookTicker.v3.api@"+symbol)
 	}
@@ -127,11 +261,20 @@ func (b *MEXCSourceProviderService) startTickerDataStream() {
 			Method: "SUBSCRIPTION",
 			Params: paramBatch,
 		}
-		streamTicker := ioHelper.NewWebSocketClient(MEXCWsURL)
-		streamTicker.Start(b.handleTickerDataStream)
-		streamTicker.WriteJSON(subscriptionEvent)
+		streamTicker, err := ioHelper.NewWebSocketClient(MEXCWsURL)
+
+		if err != nil {
+			return err
+		}
+		streamTicker.Start(b.handleTickerDataStream, b.handleStreamError)
 		b.streamsTicker = append(b.streamsTicker, streamTicker)
+
+		if err = streamTicker.WriteJSON(subscriptionEvent); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 func (b *MEXCSourceProviderService) handleTickerDataStream(data *[]byte) {
@@ -141,7 +284,7 @@ func (b *MEXCSourceProviderService) handleTickerDataStream(data *[]byte) {
 	bestBid, _ := strconv.ParseFloat(ticker.Data.BestBidPrice, 64)
 
 	b.symbolPriceData.Store(ticker.Symbol, &SymbolPrice{
-		Symbol:    b.symbols[ticker.Symbol],
+		Symbol:    b.getSymbol(ticker.Symbol),
 		BestBid:   bestBid,
 		BestAsk:   bestAsk,
 		EventTime: time.Unix(0, ticker.Time*1000000),
@@ -155,16 +298,23 @@ func (b *MEXCSourceProviderService) stopTickerDataStream() {
 }
 
 // UnsubscribeSymbol ... unsubscribes from a symbol
-func (b *MEXCSourceProviderService) UnsubscribeSymbol(symbol *sourceprovider.Symbol) {
+func (b *MEXCSourceProviderService) UnsubscribeSymbol(symbol *sourceprovider.Symbol) error {
+	b.streamsMutex.Lock()
+	defer b.streamsMutex.Unlock()
+
+	b.symbolsMutex.Lock()
 	delete(b.symbols, symbol.Symbol)
+	b.symbolsMutex.Unlock()
+
 	b.stopTickerDataStream()
 	b.stopOrderbookDepthStream()
-	b.startTickerDataStream()
-	b.startOrderbookDepthStream()
+
+	return b.startDataStreams()
 }
 
-func (b *MEXCSourceProviderService) startOrderbookDepthStream() {
+func (b *MEXCSourceProviderService) startOrderbookDepthStream() error {
 	// Stop because the arbitrage rate is negative
+	return nil
 }
 
 func (b *MEXCSourceProviderService) handleOrderbookDepthStream(data *[]byte) {
@@ -174,3 +324,24 @@ func (b *MEXCSourceProviderService) handleOrderbookDepthStream(data *[]byte) {
 func (b *MEXCSourceProviderService) stopOrderbookDepthStream() {
 	// Stop because the arbitrage rate is negative
 }
+
+// ParseMEXCTradingRules ... reads the trading rules of an exchangeInfo symbol, MEXC doesn't publish filters but the
+// precisions (decimals of the quantity & the price), the min order quantity & the min order amount in quote
+func ParseMEXCTradingRules(symbol map[string]interface{}) sourceprovider.TradingRules {
+	var rules sourceprovider.TradingRules
+	rules.Status, _ = symbol["status"].(string)
+	rules.MinQty = parseFilterValue(symbol, "baseSizePrecision")
+	rules.MinNotional = parseFilterValue(symbol, "quoteAmountPrecision")
+
+	if precision, ok := symbol["baseAssetPrecision"].(float64); ok {
+		rules.StepSize = math.Pow10(-int(precision))
+	}
+	if precision, ok := symbol["quotePrecision"].(float64); ok {
+		rules.TickSize = math.Pow10(-int(precision))
+	}
+	if precision, ok := symbol["quoteAssetPrecision"].(float64); ok {
+		rules.QuotePrecision = int(precision)
+	}
+
+	return rules
+}
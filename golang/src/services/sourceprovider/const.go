@@ -1,23 +1,93 @@
 package sourceprovider
 
-import "github.com/ethereum/go-ethereum/common"
+import (
+	"arbitrage-bot/helpers/units"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+// Provider names (used as labels in metrics and logs)
+const (
+	BinanceProviderName     string = "binance"
+	MEXCProviderName        string = "mexc"
+	UniswapProviderName     string = "uniswap"
+	PancakeswapProviderName string = "pancakeswap"
+)
+
+// ErrSymbolNotFound ... the symbol isn't subscribed by the provider
+var ErrSymbolNotFound = errors.New("symbol not found")
 
 // Symbol ... Represents a symbol
 type Symbol struct {
-	Address            string `json:"address"`
-	Symbol             string `json:"symbol"`
-	FeeTier            int    `json:"feeTier"` // Only used in Uniswap V3
-	BaseAsset          string `json:"baseAsset"`
-	BaseAssetAddress   string `json:"baseAssetAddress"`
-	BaseAssetDecimals  int    `json:"baseAssetDecimals"`
-	QuoteAsset         string `json:"quoteAsset"`
-	QuoteAssetAddress  string `json:"quoteAssetAddress"`
-	QuoteAssetDecimals int    `json:"quoteAssetDecimals"`
+	Address            string        `json:"address"`
+	Symbol             string        `json:"symbol"`
+	FeeTier            int           `json:"feeTier"` // Only used in Uniswap V3
+	BaseAsset          string        `json:"baseAsset"`
+	BaseAssetAddress   string        `json:"baseAssetAddress"`
+	BaseAssetDecimals  int           `json:"baseAssetDecimals"`
+	QuoteAsset         string        `json:"quoteAsset"`
+	QuoteAssetAddress  string        `json:"quoteAssetAddress"`
+	QuoteAssetDecimals int           `json:"quoteAssetDecimals"`
+	BaseAssetID        string        `json:"baseAssetId"`     // canonical asset id (see tokenregistry)
+	QuoteAssetID       string        `json:"quoteAssetId"`    // canonical asset id (see tokenregistry)
+	Rules              *TradingRules `json:"rules,omitempty"` // exchange filters, only used in CEX
+}
+
+// UniswapSymbolName ... returns the name of a Uniswap V3 pool, the fee tier is part of it as a pair can have a pool
+// per fee tier (f.e. DAIUSDC_100)
+func UniswapSymbolName(baseAsset string, quoteAsset string, feeTier int) string {
+	return fmt.Sprintf("%s%s_%d", baseAsset, quoteAsset, feeTier)
+}
+
+// MigrateSymbolNames ... renames the Uniswap V3 symbols cached before the fee tier was part of their name (f.e.
+// DAIUSDC -> DAIUSDC_100), the other symbols are kept, returns whether a symbol was renamed
+func MigrateSymbolNames(symbols ...*Symbol) bool {
+	var migrated = false
+
+	for _, symbol := range symbols {
+		if symbol.FeeTier > 0 && symbol.Symbol == symbol.BaseAsset+symbol.QuoteAsset {
+			symbol.Symbol = UniswapSymbolName(symbol.BaseAsset, symbol.QuoteAsset, symbol.FeeTier)
+			migrated = true
+		}
+	}
+
+	return migrated
+}
+
+// GetBaseAssetID ... returns the canonical id of the base asset (the display name if it's not annotated)
+func (s *Symbol) GetBaseAssetID() string {
+	if s.BaseAssetID != "" {
+		return s.BaseAssetID
+	}
+
+	return s.BaseAsset
+}
+
+// GetQuoteAssetID ... returns the canonical id of the quote asset (the display name if it's not annotated)
+func (s *Symbol) GetQuoteAssetID() string {
+	if s.QuoteAssetID != "" {
+		return s.QuoteAssetID
+	}
+
+	return s.QuoteAsset
 }
 
+// TradePath ... Represents a swap, the base asset is the input token & the quote asset the output token
 type TradePath struct {
 	BaseAssetAddress   common.Address
 	BaseAssetDecimals  int
 	QuoteAssetAddress  common.Address
 	QuoteAssetDecimals int
 }
+
+// AmountIn ... returns an amount of the input token (f.e. a configured starting amount)
+func (t TradePath) AmountIn(value float64) units.Amount {
+	return units.FromFloat(value, t.BaseAssetDecimals)
+}
+
+// AmountOut ... returns an amount of the output token from base units (f.e. a quote)
+func (t TradePath) AmountOut(wei *big.Int) units.Amount {
+	return units.NewAmount(wei, t.QuoteAssetDecimals)
+}
@@ -1,9 +1,10 @@
 package dex
 
 import (
+	"arbitrage-bot/config"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/web3"
-	"os"
+	"context"
 	"time"
 )
 
@@ -13,11 +14,13 @@ type SymbolPrice struct {
 	Token0Price float64                `json:"token0Price"`
 	Token1Price float64                `json:"token1Price"`
 	EventTime   time.Time              `json:"eventTime"`
+	BlockNumber uint64                 `json:"blockNumber"`
 }
 
 // UniswapGraphQLURL ... Uniswap GraphQL endpoint
 func UniswapGraphQLURL() string {
-	return "https://gateway.thegraph.com/api/" + os.Getenv("SUBGRAPH_API_KEY") + "/subgraphs/id/" + os.Getenv("SUBGRAPH_UNISWAP_ID")
+	var subgraph = config.Get().ActiveNetwork().Subgraph
+	return "https://gateway.thegraph.com/api/" + subgraph.APIKey + "/subgraphs/id/" + subgraph.UniswapID
 }
 
 // SubgraphPoolItem ... Uniswap subgraph pool item
@@ -37,13 +40,16 @@ type SubgraphPoolItem struct {
 		ID       string `json:"id"`
 		Symbol   string `json:"symbol"`
 	} `json:"token1"`
+	PoolDayData []struct {
+		VolumeUSD string `json:"volumeUSD"`
+	} `json:"poolDayData"` // latest day only
 }
 
 // ISourceProvider ... Interface for the DEX source provider
 type ISourceProvider interface {
 	Web3Service() web3.DEXWeb3Service
 	sourceprovider.ISourceProvider
-	SubscribeSymbols(symbols []*sourceprovider.Symbol, pingChannel chan bool, verbose bool)
-	GetSymbol(symbol string) sourceprovider.Symbol
+	SubscribeSymbols(ctx context.Context, symbols []*sourceprovider.Symbol, pingChannel chan bool)
+	GetSymbol(symbol string) (sourceprovider.Symbol, error)
 	GetSymbolPrice(symbol string) *SymbolPrice
 }
@@ -1,9 +1,13 @@
 package dex
 
 import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/logger"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/web3"
-	"os"
+	"context"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -14,21 +18,32 @@ type PancakeswapSourceProvider struct {
 	symbols         map[string]*sourceprovider.Symbol
 }
 
-func NewPancakeswapSourceProvider() *PancakeswapSourceProvider {
+// NewPancakeswapSourceProvider ... creates a new PancakeSwap source provider
+func NewPancakeswapSourceProvider() (*PancakeswapSourceProvider, error) {
+	web3Service, err := web3.NewPancakeswapWeb3Service()
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &PancakeswapSourceProvider{
 		symbols:     make(map[string]*sourceprovider.Symbol),
-		web3Service: web3.NewPancakeswapWeb3Service(),
-	}
+		web3Service: web3Service,
+	}, nil
 }
 
 func (p *PancakeswapSourceProvider) Web3Service() web3.DEXWeb3Service {
 	return p.web3Service
 }
 
+// GetName ... returns the provider name
+func (p *PancakeswapSourceProvider) GetName() string {
+	return sourceprovider.PancakeswapProviderName
+}
+
 // GetArbitragePairCachePath ... returns the path to the token list cache
 func (p *PancakeswapSourceProvider) GetArbitragePairCachePath() string {
-	var network = os.Getenv("NETWORK_NAME")
-	return "data/" + network + "/pancakeswapArbitragePairs.json"
+	return "data/" + config.Get().Network + "/pancakeswapArbitragePairs.json"
 }
 
 // GetSymbolPrice ... returns the aggregated price for a given symbol
@@ -39,13 +54,22 @@ func (p *PancakeswapSourceProvider) GetSymbolPrice(symbol string) *SymbolPrice {
 	return nil
 }
 
-// GetSymbol ... returns the symbol
-func (p *PancakeswapSourceProvider) GetSymbol(symbol string) sourceprovider.Symbol {
-	return *p.symbols[symbol]
+// GetSymbol ... returns a subscribed symbol
+func (p *PancakeswapSourceProvider) GetSymbol(symbol string) (sourceprovider.Symbol, error) {
+	if result, ok := p.symbols[symbol]; ok {
+		return *result, nil
+	}
+
+	return sourceprovider.Symbol{}, fmt.Errorf("%w: %s", sourceprovider.ErrSymbolNotFound, symbol)
 }
 
-// SubscribeSymbols ... subscribes to the symbols
-func (p *PancakeswapSourceProvider) SubscribeSymbols(symbols []*sourceprovider.Symbol, pingChannel chan bool, verbose bool) {
+// SubscribeSymbols ... polls the prices of the symbols & pings after every update until the context is done, the ping
+// channel is closed on return
+func (p *PancakeswapSourceProvider) SubscribeSymbols(
+	ctx context.Context, symbols []*sourceprovider.Symbol, pingChannel chan bool,
+) {
+	defer close(pingChannel)
+
 	var tokenPairs []string
 
 	for _, symbol := range symbols {
@@ -54,18 +78,32 @@ func (p *PancakeswapSourceProvider) SubscribeSymbols(symbols []*sourceprovider.S
 	}
 
 	for {
-		aggregatedPrices := p.web3Service.AggregatePrices(symbols, verbose)
+		blockNumber, err := p.web3Service.GetBlockNumber()
+		if err != nil {
+			logger.WithProvider(p.GetName()).Warn("Error getting block number", slog.Any("error", err))
+		}
+		aggregatedPrices := p.web3Service.AggregatePrices(symbols)
 		aggregatedPrices.Range(func(key any, value any) bool {
 			p.symbolPriceData.Store(key, &SymbolPrice{
 				Symbol:      p.symbols[key.(string)],
 				Token0Price: 1.0 / value.(float64),
 				Token1Price: value.(float64),
 				EventTime:   time.Now(),
+				BlockNumber: blockNumber,
 			})
 			return true
 		})
-		pingChannel <- true
-		// Fetch the data every 60 seconds
-		time.Sleep(10 * time.Second)
+
+		select {
+		case pingChannel <- true:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(config.Get().ActiveNetwork().Polling.PriceInterval):
+		case <-ctx.Done():
+			return
+		}
 	}
 }
@@ -1,12 +1,17 @@
 package dex
 
 import (
-	"arbitrage-bot/helpers"
+	"arbitrage-bot/config"
 	ioHelper "arbitrage-bot/helpers/io"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/discovery"
 	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
 	"arbitrage-bot/services/web3"
+	"context"
 	"encoding/json"
-	"os"
+	"fmt"
+	"log/slog"
 	"slices"
 	"strconv"
 	"sync"
@@ -18,8 +23,7 @@ const surfaceRateQuery string = `
 	pools(
 		orderBy:totalValueLockedETH,
 		orderDirection: desc,
-		first: 1000,
-		skip: 4
+		first: 1000
 	) {
 		token0 {id symbol decimals}
 		token1 {id symbol decimals}
@@ -28,6 +32,7 @@ const surfaceRateQuery string = `
 		token1Price
 		token0Price
 		feeTier
+		poolDayData(first: 1, orderBy: date, orderDirection: desc) {volumeUSD}
 	}
 }`
 
@@ -43,21 +48,31 @@ type UniswapSourceProviderService struct {
 }
 
 // NewUniswapSourceProviderService ... creates a new Uniswap source provider
-func NewUniswapSourceProviderService() *UniswapSourceProviderService {
+func NewUniswapSourceProviderService() (*UniswapSourceProviderService, error) {
+	web3Service, err := web3.NewUniswapWeb3Service()
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &UniswapSourceProviderService{
 		symbols:     make(map[string]*sourceprovider.Symbol),
-		web3Service: web3.NewUniswapWeb3Service(),
-	}
+		web3Service: web3Service,
+	}, nil
 }
 
 func (u *UniswapSourceProviderService) Web3Service() web3.DEXWeb3Service {
 	return u.web3Service
 }
 
+// GetName ... returns the provider name
+func (u *UniswapSourceProviderService) GetName() string {
+	return sourceprovider.UniswapProviderName
+}
+
 // GetArbitragePairCachePath ... returns the path to the token list cache
 func (u *UniswapSourceProviderService) GetArbitragePairCachePath() string {
-	var network = os.Getenv("NETWORK_NAME")
-	return "data/" + network + "/uniswapArbitragePairs.json"
+	return "data/" + config.Get().Network + "/uniswapArbitragePairs.json"
 }
 
 // GetSymbolPrice ... returns the aggregated price for a given symbol
@@ -101,16 +116,26 @@ func (u *UniswapSourceProviderService) getSubgraphPoolData() ([]SubgraphPoolItem
 	return subgraphPoolItems, nil
 }
 
-// GetSymbol ... returns the symbol
-func (u *UniswapSourceProviderService) GetSymbol(symbol string) sourceprovider.Symbol {
-	return *u.symbols[symbol]
+// GetSymbol ... returns a subscribed symbol
+func (u *UniswapSourceProviderService) GetSymbol(symbol string) (sourceprovider.Symbol, error) {
+	if result, ok := u.symbols[symbol]; ok {
+		return *result, nil
+	}
+
+	return sourceprovider.Symbol{}, fmt.Errorf("%w: %s", sourceprovider.ErrSymbolNotFound, symbol)
 }
 
-// GetSymbols ... returns the symbols
+// GetSymbols ... returns the symbols (filtered by liquidity & volume, or by the suitablePairs tickers if the filter
+// is disabled)
 func (u *UniswapSourceProviderService) GetSymbols(force bool) ([]*sourceprovider.Symbol, error) {
 	subgraphPoolItems, err := u.getSubgraphPoolData()
-	helpers.Panic(err)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting the subgraph pools: %w", err)
+	}
 	var symbols []*sourceprovider.Symbol
+	var volumes = make(map[string]float64)
+	var filterEnabled = config.Get().ActiveNetwork().Discovery.Liquidity.Enabled
 	uniqueSymbols := make(map[string]bool)
 
 	for _, item := range subgraphPoolItems {
@@ -119,14 +144,17 @@ func (u *UniswapSourceProviderService) GetSymbols(force bool) ([]*sourceprovider
 		quoteAssetDecimals, _ := strconv.Atoi(item.Token1.Decimals)
 		pair := item.Token0.Symbol + item.Token1.Symbol
 
-		if uniqueSymbols[pair] || (!slices.Contains(suitablePairs, item.Token0.Symbol) &&
+		if uniqueSymbols[pair] || (!filterEnabled && !slices.Contains(suitablePairs, item.Token0.Symbol) &&
 			!slices.Contains(suitablePairs, item.Token1.Symbol)) {
 			continue
 		}
+		if len(item.PoolDayData) > 0 {
+			volumes[item.ID], _ = strconv.ParseFloat(item.PoolDayData[0].VolumeUSD, 64)
+		}
 
 		symbols = append(symbols, &sourceprovider.Symbol{
 			Address:            item.ID,
-			Symbol:             pair,
+			Symbol:             sourceprovider.UniswapSymbolName(item.Token0.Symbol, item.Token1.Symbol, feeTier),
 			FeeTier:            feeTier,
 			BaseAsset:          item.Token0.Symbol,
 			BaseAssetAddress:   item.Token0.ID,
@@ -135,16 +163,30 @@ func (u *UniswapSourceProviderService) GetSymbols(force bool) ([]*sourceprovider
 			QuoteAssetAddress:  item.Token1.ID,
 			QuoteAssetDecimals: quoteAssetDecimals,
 		})
+		tokenregistry.Get().AnnotateDEXSymbol(config.Get().ActiveNetwork().ChainID, symbols[len(symbols)-1])
 		uniqueSymbols[pair] = true
 	}
 
+	if filterEnabled {
+		var poolFilter = discovery.NewPoolFilter(u.web3Service)
+		var results []*discovery.PoolFilterResult
+		symbols, results = poolFilter.Handle(symbols, volumes)
+
+		if err = poolFilter.SaveResults(u.GetArbitragePairCachePath(), results); err != nil {
+			return nil, err
+		}
+	}
+
 	return symbols, nil
 }
 
-// SubscribeSymbols ... subscribes to the symbols
+// SubscribeSymbols ... polls the prices of the symbols & pings after every update until the context is done, the ping
+// channel is closed on return
 func (u *UniswapSourceProviderService) SubscribeSymbols(
-	symbols []*sourceprovider.Symbol, pingChannel chan bool, verbose bool,
+	ctx context.Context, symbols []*sourceprovider.Symbol, pingChannel chan bool,
 ) {
+	defer close(pingChannel)
+
 	var tokenPairs []string
 
 	for _, symbol := range symbols {
@@ -153,19 +195,32 @@ func (u *UniswapSourceProviderService) SubscribeSymbols(
 	}
 	for {
 		// Fetch the data directly from the network
-		aggregatedPrices := u.web3Service.AggregatePrices(symbols, verbose)
+		blockNumber, err := u.web3Service.GetBlockNumber()
+		if err != nil {
+			logger.WithProvider(u.GetName()).Warn("Error getting block number", slog.Any("error", err))
+		}
+		aggregatedPrices := u.web3Service.AggregatePrices(symbols)
 		aggregatedPrices.Range(func(key any, value any) bool {
 			u.symbolPriceData.Store(key, &SymbolPrice{
 				Symbol:      u.symbols[key.(string)],
 				Token0Price: 1.0 / value.(float64),
 				Token1Price: value.(float64),
 				EventTime:   time.Now(),
+				BlockNumber: blockNumber,
 			})
 			return true
 		})
-		pingChannel <- true
 
-		// Fetch the data every 60 seconds
-		time.Sleep(10 * time.Second)
+		select {
+		case pingChannel <- true:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(config.Get().ActiveNetwork().Polling.PriceInterval):
+		case <-ctx.Done():
+			return
+		}
 	}
 }
@@ -0,0 +1,118 @@
+package sourceprovider
+
+import (
+	"math"
+	"strconv"
+)
+
+// roundingTolerance ... absorbs the float error of value/step (f.e. 0.3/0.1 = 2.9999999999999996)
+const roundingTolerance = 1e-9
+
+// TradingRules ... Represents the exchange filters of a CEX symbol (zero values mean no limit)
+type TradingRules struct {
+	Status         string  `json:"status"` // f.e. TRADING
+	MinQty         float64 `json:"minQty"`
+	MaxQty         float64 `json:"maxQty"`
+	StepSize       float64 `json:"stepSize"`
+	MinPrice       float64 `json:"minPrice"`
+	MaxPrice       float64 `json:"maxPrice"`
+	TickSize       float64 `json:"tickSize"`
+	MinNotional    float64 `json:"minNotional"`
+	QuotePrecision int     `json:"quotePrecision"` // decimals of a quote amount (f.e. a market buy spending quote)
+}
+
+// RoundQuantity ... rounds a base quantity down to the step size, 0 if it's below the minimum quantity
+func (r TradingRules) RoundQuantity(quantity float64) float64 {
+	var rounded = roundToStep(quantity, r.StepSize, false)
+
+	if r.MaxQty > 0 {
+		rounded = math.Min(rounded, roundToStep(r.MaxQty, r.StepSize, false))
+	}
+	if rounded < r.MinQty || rounded <= 0 {
+		return 0
+	}
+
+	return rounded
+}
+
+// RoundPrice ... rounds a price to the tick size, up for a buy limit (stays marketable) & down for a sell limit
+func (r TradingRules) RoundPrice(price float64, up bool) float64 {
+	return roundToStep(price, r.TickSize, up)
+}
+
+// RoundQuoteAmount ... rounds a quote amount down to the quote precision
+func (r TradingRules) RoundQuoteAmount(amount float64) float64 {
+	if r.QuotePrecision <= 0 {
+		return amount
+	}
+
+	return roundToStep(amount, math.Pow10(-r.QuotePrecision), false)
+}
+
+// IsTradable ... whether the symbol accepts orders (an unknown status is accepted)
+func (r TradingRules) IsTradable() bool {
+	return r.Status == "" || r.Status == "TRADING" || r.Status == "ENABLED" || r.Status == "1"
+}
+
+// MeetsMinNotional ... whether an order of quantity at price reaches the minimum notional
+func (r TradingRules) MeetsMinNotional(quantity float64, price float64) bool {
+	return quantity*price >= r.MinNotional
+}
+
+// FormatQuantity ... formats a quantity with the decimals of the step size (as the exchange expects it)
+func (r TradingRules) FormatQuantity(quantity float64) string {
+	return formatStep(quantity, r.StepSize)
+}
+
+// FormatPrice ... formats a price with the decimals of the tick size
+func (r TradingRules) FormatPrice(price float64) string {
+	return formatStep(price, r.TickSize)
+}
+
+// FormatQuoteAmount ... formats a quote amount with the quote precision
+func (r TradingRules) FormatQuoteAmount(amount float64) string {
+	if r.QuotePrecision <= 0 {
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+
+	return strconv.FormatFloat(amount, 'f', r.QuotePrecision, 64)
+}
+
+// roundToStep ... rounds a value to a multiple of step, up or down (a value already on the step doesn't move)
+func roundToStep(value float64, step float64, up bool) float64 {
+	if step <= 0 {
+		return value
+	}
+
+	var steps = math.Floor(value/step + roundingTolerance)
+
+	if up {
+		steps = math.Ceil(value/step - roundingTolerance)
+	}
+	var decimals = stepDecimals(step)
+	var result, _ = strconv.ParseFloat(strconv.FormatFloat(steps*step, 'f', decimals, 64), 64)
+
+	return result
+}
+
+// formatStep ... formats a value with the decimals of the step
+func formatStep(value float64, step float64) string {
+	if step <= 0 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	return strconv.FormatFloat(value, 'f', stepDecimals(step), 64)
+}
+
+// stepDecimals ... returns the decimals of a step (f.e. 0.001 -> 3)
+func stepDecimals(step float64) int {
+	var text = strconv.FormatFloat(step, 'f', -1, 64)
+
+	for i, char := range text {
+		if char == '.' {
+			return len(text) - i - 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,169 @@
+package tokenregistry
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/sourceprovider"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Token ... Represents a token of a chain and its identity across venues
+type Token struct {
+	ID         string            `json:"id"` // canonical asset id, shared by the same asset on every venue
+	ChainID    int64             `json:"chainId"`
+	Address    string            `json:"address"`
+	Symbol     string            `json:"symbol"`
+	Decimals   int               `json:"decimals"`
+	Aliases    []string          `json:"aliases"`    // other names of the asset (f.e. WBNB -> BNB)
+	CEXTickers map[string]string `json:"cexTickers"` // provider name -> ticker
+}
+
+// Registry ... token registry keyed by chain and address
+type Registry struct {
+	mutex  sync.RWMutex
+	tokens map[string]*Token
+}
+
+var (
+	instance *Registry
+	initErr  error
+	once     sync.Once
+)
+
+// Init ... loads the registry from the configured file, the entry points call it to report an invalid registry before
+// starting the services
+func Init() error {
+	once.Do(func() {
+		instance, initErr = Load(config.Get().TokenRegistry)
+	})
+
+	return initErr
+}
+
+// Get ... returns the registry loaded by Init (loaded on first use, panics if it can't be read as the entry points
+// already reported it)
+func Get() *Registry {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+
+	return instance
+}
+
+// NewRegistry ... creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{tokens: make(map[string]*Token)}
+}
+
+// Load ... reads the registry from a JSON file
+func Load(path string) (*Registry, error) {
+	var tokens []*Token
+	var registry = NewRegistry()
+
+	if err := jsonHelper.ReadJSONFile(path, &tokens); err != nil {
+		return nil, fmt.Errorf("error reading token registry %s: %w", path, err)
+	}
+	for _, token := range tokens {
+		if err := registry.Register(token); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// key ... returns the registry key of a token
+func key(chainID int64, address string) string {
+	return fmt.Sprintf("%d:%s", chainID, strings.ToLower(address))
+}
+
+// Register ... adds (or replaces) a token
+func (r *Registry) Register(token *Token) error {
+	if token.ID == "" || token.Address == "" {
+		return fmt.Errorf("token %s on chain %d needs an id and an address", token.Symbol, token.ChainID)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tokens[key(token.ChainID, token.Address)] = token
+
+	return nil
+}
+
+// GetToken ... returns the token of a chain by address
+func (r *Registry) GetToken(chainID int64, address string) (*Token, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	token, ok := r.tokens[key(chainID, address)]
+
+	return token, ok
+}
+
+// GetTokenByID ... returns the token of a chain by canonical id
+func (r *Registry) GetTokenByID(chainID int64, id string) (*Token, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, token := range r.tokens {
+		if token.ChainID == chainID && token.ID == id {
+			return token, true
+		}
+	}
+
+	return nil, false
+}
+
+// IDForAddress ... returns the canonical id of a token, unknown tokens are identified by chain and address so
+// tokens sharing a ticker are never confused
+func (r *Registry) IDForAddress(chainID int64, address string) string {
+	if token, ok := r.GetToken(chainID, address); ok {
+		return token.ID
+	}
+
+	return key(chainID, address)
+}
+
+// IDForTicker ... returns the canonical id of a CEX asset, unknown tickers are identified by the ticker itself
+func (r *Registry) IDForTicker(provider string, ticker string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, token := range r.tokens {
+		if token.CEXTickers[provider] == ticker {
+			return token.ID
+		}
+	}
+	for _, token := range r.tokens {
+		if slices.Contains(token.Aliases, ticker) {
+			return token.ID
+		}
+	}
+
+	return strings.ToUpper(ticker)
+}
+
+// CEXTicker ... returns the ticker of a token on a CEX
+func (r *Registry) CEXTicker(provider string, chainID int64, address string) (string, bool) {
+	token, ok := r.GetToken(chainID, address)
+
+	if !ok {
+		return "", false
+	}
+	ticker, ok := token.CEXTickers[provider]
+
+	return ticker, ok
+}
+
+// AnnotateDEXSymbol ... sets the canonical ids of a pool's tokens
+func (r *Registry) AnnotateDEXSymbol(chainID int64, symbol *sourceprovider.Symbol) {
+	symbol.BaseAssetID = r.IDForAddress(chainID, symbol.BaseAssetAddress)
+	symbol.QuoteAssetID = r.IDForAddress(chainID, symbol.QuoteAssetAddress)
+}
+
+// AnnotateCEXSymbol ... sets the canonical ids of a CEX symbol's assets
+func (r *Registry) AnnotateCEXSymbol(provider string, symbol *sourceprovider.Symbol) {
+	symbol.BaseAssetID = r.IDForTicker(provider, symbol.BaseAsset)
+	symbol.QuoteAssetID = r.IDForTicker(provider, symbol.QuoteAsset)
+}
@@ -0,0 +1,299 @@
+package tokensafety
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/rpcpool"
+	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+)
+
+// probeAddress ... recipient of the simulated transfers (runs the transfer checker through a state override)
+var probeAddress = common.HexToAddress("0x000000000000000000000000000000000000a11c")
+
+// simulationGas ... gas limit of the transfer simulation
+const simulationGas uint64 = 5_000_000
+
+// pausableSignatures ... functions allowing the owner to stop all transfers
+var pausableSignatures = []string{"pause()", "unpause()", "setPaused(bool)"}
+
+// blacklistSignatures ... functions allowing the owner to block the transfers of an address
+var blacklistSignatures = []string{
+	"blacklist(address)",
+	"addToBlacklist(address)",
+	"setBlacklist(address,bool)",
+	"blacklistAddress(address,bool)",
+	"isBlacklisted(address)",
+	"setBots(address[])",
+	"addBots(address[])",
+}
+
+// overrideAccount ... state override of an account in eth_call
+type overrideAccount struct {
+	Code hexutil.Bytes `json:"code"`
+}
+
+// callArgs ... message of an eth_call
+type callArgs struct {
+	To   common.Address `json:"to"`
+	Gas  hexutil.Uint64 `json:"gas"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+// Analyser ... detects honeypots, fee-on-transfer tokens & tokens with pause/blacklist controls in PancakeSwap pools
+type Analyser struct {
+	network        *config.NetworkProfile
+	settings       config.TokenSafetyConfig
+	client         *ethclient.Client
+	rpcClient      *rpc.Client
+	routerContract *bind.BoundContract
+	poolABI        abi.ABI
+}
+
+// NewAnalyser ... creates a new Analyser for the selected network
+func NewAnalyser() (*Analyser, error) {
+	var network = config.Get().ActiveNetwork()
+	routerABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapRouter)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the router abi: %w", err)
+	}
+	poolABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapPool)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the pool abi: %w", err)
+	}
+
+	var client = rpcpool.Get().Client()
+	var routerAddress = common.HexToAddress(network.Contracts.PancakeswapRouter)
+
+	return &Analyser{
+		network:        network,
+		settings:       network.Discovery.TokenSafety,
+		client:         client,
+		rpcClient:      rpcpool.Get().RPCClient(),
+		routerContract: bind.NewBoundContract(routerAddress, routerABI, client, client, client),
+		poolABI:        poolABI,
+	}, nil
+}
+
+// TokensToAnalyse ... returns the addresses of the pool tokens which aren't in the token registry (registered tokens
+// are trusted)
+func (a *Analyser) TokensToAnalyse(symbol *sp.Symbol) []string {
+	var addresses []string
+
+	for _, address := range []string{symbol.BaseAssetAddress, symbol.QuoteAssetAddress} {
+		if _, ok := tokenregistry.Get().GetToken(a.network.ChainID, address); !ok {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+// AnalyseToken ... simulates transfers & a round trip of a token through one of its pools & scans its bytecode
+func (a *Analyser) AnalyseToken(pool *sp.Symbol, tokenAddress string) *Report {
+	var token = common.HexToAddress(tokenAddress)
+	var partner = common.HexToAddress(pool.QuoteAssetAddress)
+	var report = &Report{Address: tokenAddress, Symbol: pool.BaseAsset, Pool: pool.Address}
+
+	if token != common.HexToAddress(pool.BaseAssetAddress) {
+		partner = common.HexToAddress(pool.BaseAssetAddress)
+		report.Symbol = pool.QuoteAsset
+	}
+
+	reserveToken, reservePartner, err := a.getReserves(pool, token)
+
+	if err != nil {
+		report.Reasons = append(report.Reasons, err.Error())
+		return report
+	}
+
+	// fee-on-transfer & honeypot: pool -> probe (buy) & probe -> pool (sell)
+	report.BuyTax, report.SellTax, err = a.simulateTransfers(token, common.HexToAddress(pool.Address), a.share(reserveToken))
+
+	if err != nil {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("transfer simulation failed: %v", err))
+	} else if tax := max(report.BuyTax, report.SellTax); tax > a.settings.MaxTransferTax {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("transfer tax %.4f exceeds %.4f", tax, a.settings.MaxTransferTax))
+	}
+
+	// buy & sell back through the router, the transfer taxes are applied on each leg
+	if err == nil {
+		report.RoundTripLoss, err = a.simulateRoundTrip(token, partner, a.share(reservePartner), report.BuyTax, report.SellTax)
+
+		if err != nil {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("round trip failed: %v", err))
+		} else if report.RoundTripLoss > a.settings.MaxRoundTripLoss {
+			report.Reasons = append(report.Reasons, fmt.Sprintf(
+				"round trip loss %.4f exceeds %.4f", report.RoundTripLoss, a.settings.MaxRoundTripLoss,
+			))
+		}
+	}
+
+	// owner controls
+	code, err := a.client.CodeAt(context.Background(), token, nil)
+
+	if err != nil {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("error getting bytecode: %v", err))
+		return report
+	}
+	report.Pausable = containsSelector(code, pausableSignatures)
+	report.Blacklist = containsSelector(code, blacklistSignatures)
+
+	if report.Pausable && a.settings.DenyPausable {
+		report.Reasons = append(report.Reasons, "token is pausable")
+	}
+	if report.Blacklist && a.settings.DenyBlacklist {
+		report.Reasons = append(report.Reasons, "token has a blacklist")
+	}
+
+	return report
+}
+
+// getReserves ... returns the reserves of the pool for the token & its partner
+func (a *Analyser) getReserves(pool *sp.Symbol, token common.Address) (*big.Int, *big.Int, error) {
+	var poolContract = bind.NewBoundContract(common.HexToAddress(pool.Address), a.poolABI, a.client, a.client, a.client)
+	var result []interface{}
+
+	if err := poolContract.Call(&bind.CallOpts{}, &result, "getReserves"); err != nil {
+		return nil, nil, fmt.Errorf("error getting reserves: %w", err)
+	}
+
+	var reserve0, reserve1 = result[0].(*big.Int), result[1].(*big.Int)
+
+	if reserve0.Sign() == 0 || reserve1.Sign() == 0 {
+		return nil, nil, fmt.Errorf("pool has no liquidity")
+	}
+	// token0 is the base asset of the pool symbol
+	if token == common.HexToAddress(pool.BaseAssetAddress) {
+		return reserve0, reserve1, nil
+	}
+
+	return reserve1, reserve0, nil
+}
+
+// simulateTransfers ... transfers amount from the pool to the probe & back with eth_call (both run the transfer
+// checker through a state override), returns the buy & sell taxes
+func (a *Analyser) simulateTransfers(token common.Address, pool common.Address, amount *big.Int) (float64, float64, error) {
+	var input []byte
+
+	for _, word := range [][]byte{token.Bytes(), probeAddress.Bytes(), amount.Bytes(), {1}} {
+		input = append(input, common.LeftPadBytes(word, 32)...)
+	}
+	var overrides = map[common.Address]overrideAccount{
+		pool:         {Code: transferCheckerCode},
+		probeAddress: {Code: transferCheckerCode},
+	}
+	var output hexutil.Bytes
+	var err = a.rpcClient.CallContext(
+		context.Background(),
+		&output,
+		"eth_call",
+		callArgs{To: pool, Gas: hexutil.Uint64(simulationGas), Data: input},
+		"latest",
+		overrides,
+	)
+
+	if err != nil {
+		return 0, 0, err
+	} else if len(output) != 64 {
+		return 0, 0, fmt.Errorf("unexpected simulation output %x", output)
+	}
+
+	var received = new(big.Int).SetBytes(output[:32])
+	var receivedBack = new(big.Int).SetBytes(output[32:])
+
+	if received.Sign() == 0 {
+		return 1, 1, nil
+	}
+
+	return 1 - ratio(received, amount), 1 - ratio(receivedBack, received), nil
+}
+
+// simulateRoundTrip ... buys the token with amountIn of the partner & sells it back, returns the loss
+func (a *Analyser) simulateRoundTrip(
+	token common.Address,
+	partner common.Address,
+	amountIn *big.Int,
+	buyTax float64,
+	sellTax float64,
+) (float64, error) {
+	bought, err := a.getAmountOut(amountIn, partner, token)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// the buy tax is taken on the transfer to the buyer, the sell tax on the transfer to the pool
+	var sellAmount = scale(scale(bought, 1-buyTax), 1-sellTax)
+
+	if sellAmount.Sign() == 0 {
+		return 1, nil
+	}
+	sold, err := a.getAmountOut(sellAmount, token, partner)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return 1 - ratio(sold, amountIn), nil
+}
+
+// getAmountOut ... calls getAmountsOut of the router for a single hop
+func (a *Analyser) getAmountOut(amountIn *big.Int, tokenIn common.Address, tokenOut common.Address) (*big.Int, error) {
+	var result []interface{}
+	var err = a.routerContract.Call(
+		&bind.CallOpts{}, &result, "getAmountsOut", amountIn, []common.Address{tokenIn, tokenOut},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	var amounts = result[0].([]*big.Int)
+
+	return amounts[len(amounts)-1], nil
+}
+
+// share ... returns the probe share of a reserve
+func (a *Analyser) share(reserve *big.Int) *big.Int {
+	return scale(reserve, a.settings.ProbeShare)
+}
+
+// scale ... multiplies an amount by a factor (rounded down)
+func scale(amount *big.Int, factor float64) *big.Int {
+	var result, _ = new(big.Float).Mul(new(big.Float).SetInt(amount), big.NewFloat(factor)).Int(nil)
+
+	return result
+}
+
+// ratio ... returns a / b
+func ratio(a *big.Int, b *big.Int) float64 {
+	var result, _ = new(big.Float).Quo(new(big.Float).SetInt(a), new(big.Float).SetInt(b)).Float64()
+
+	return result
+}
+
+// containsSelector ... whether the bytecode pushes the selector of one of the signatures (PUSH4 <selector>)
+func containsSelector(code []byte, signatures []string) bool {
+	for _, signature := range signatures {
+		var pattern = append([]byte{opPUSH4}, crypto.Keccak256([]byte(signature))[:4]...)
+
+		if bytes.Contains(code, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
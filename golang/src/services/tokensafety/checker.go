@@ -0,0 +1,131 @@
+package tokensafety
+
+// EVM opcodes used by the transfer checker
+const (
+	opSUB          byte = 0x03
+	opISZERO       byte = 0x15
+	opSHL          byte = 0x1b
+	opADDRESS      byte = 0x30
+	opCALLDATALOAD byte = 0x35
+	opMLOAD        byte = 0x51
+	opMSTORE       byte = 0x52
+	opJUMPI        byte = 0x57
+	opGAS          byte = 0x5a
+	opJUMPDEST     byte = 0x5b
+	opPUSH1        byte = 0x60
+	opPUSH2        byte = 0x61
+	opPUSH4        byte = 0x63
+	opCALL         byte = 0xf1
+	opRETURN       byte = 0xf3
+	opSTATICCALL   byte = 0xfa
+	opREVERT       byte = 0xfd
+)
+
+// transferCheckerCode ... runtime code placed (state override) on the pool & the probe address to simulate transfers.
+//
+// calldata: token, recipient, amount, returnLeg (32 bytes each)
+//  1. transfers amount of token to recipient & measures the balance change of the recipient (received)
+//  2. if returnLeg != 0, calls the recipient (running the same code) to transfer received back to this address
+//
+// returns: received, receivedBack (0 without a return leg), reverts if a transfer or balanceOf call fails
+var transferCheckerCode = buildTransferChecker()
+
+// assembler ... minimal EVM assembler resolving jump labels
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	jumps  map[int]string // offset of a PUSH2 operand -> label
+}
+
+// op ... appends opcodes
+func (a *assembler) op(ops ...byte) *assembler {
+	a.code = append(a.code, ops...)
+
+	return a
+}
+
+// push ... appends the smallest PUSH of value
+func (a *assembler) push(value uint64) *assembler {
+	var data []byte
+
+	for value > 0 {
+		data = append([]byte{byte(value)}, data...)
+		value >>= 8
+	}
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	a.code = append(a.code, opPUSH1+byte(len(data)-1))
+	a.code = append(a.code, data...)
+
+	return a
+}
+
+// pushLabel ... appends a PUSH2 of a label (resolved by build)
+func (a *assembler) pushLabel(label string) *assembler {
+	a.code = append(a.code, opPUSH2)
+	a.jumps[len(a.code)] = label
+	a.code = append(a.code, 0, 0)
+
+	return a
+}
+
+// label ... marks a jump destination
+func (a *assembler) label(label string) *assembler {
+	a.labels[label] = len(a.code)
+
+	return a.op(opJUMPDEST)
+}
+
+// build ... resolves the labels & returns the code
+func (a *assembler) build() []byte {
+	for offset, label := range a.jumps {
+		var destination = a.labels[label]
+		a.code[offset] = byte(destination >> 8)
+		a.code[offset+1] = byte(destination)
+	}
+
+	return a.code
+}
+
+// balanceOfRecipient ... stores token.balanceOf(recipient) at memory offset result
+func (a *assembler) balanceOfRecipient(result uint64) *assembler {
+	a.push(0x70a08231).push(0xe0).op(opSHL).push(0x00).op(opMSTORE)
+	a.push(0x20).op(opCALLDATALOAD).push(0x04).op(opMSTORE)
+	a.push(0x20).push(result).push(0x24).push(0x00).push(0x00).op(opCALLDATALOAD, opGAS, opSTATICCALL)
+
+	return a.op(opISZERO).pushLabel("fail").op(opJUMPI)
+}
+
+// buildTransferChecker ... assembles transferCheckerCode
+func buildTransferChecker() []byte {
+	var a = &assembler{labels: make(map[string]int), jumps: make(map[int]string)}
+
+	// memory: 0x00 call buffer, 0x80 balance before, 0xa0 received, 0xc0 received back, 0x100 return leg calldata
+	a.balanceOfRecipient(0x80)
+
+	// token.transfer(recipient, amount)
+	a.push(0xa9059cbb).push(0xe0).op(opSHL).push(0x00).op(opMSTORE)
+	a.push(0x20).op(opCALLDATALOAD).push(0x04).op(opMSTORE)
+	a.push(0x40).op(opCALLDATALOAD).push(0x24).op(opMSTORE)
+	a.push(0x00).push(0x00).push(0x44).push(0x00).push(0x00).push(0x00).op(opCALLDATALOAD, opGAS, opCALL)
+	a.op(opISZERO).pushLabel("fail").op(opJUMPI)
+
+	// received = balance after - balance before
+	a.balanceOfRecipient(0xa0)
+	a.push(0x80).op(opMLOAD).push(0xa0).op(opMLOAD, opSUB).push(0xa0).op(opMSTORE)
+
+	// return leg: recipient.check(token, this, received, 0) -> received back
+	a.push(0x60).op(opCALLDATALOAD, opISZERO).pushLabel("done").op(opJUMPI)
+	a.push(0x00).op(opCALLDATALOAD).push(0x100).op(opMSTORE)
+	a.op(opADDRESS).push(0x120).op(opMSTORE)
+	a.push(0xa0).op(opMLOAD).push(0x140).op(opMSTORE)
+	a.push(0x00).push(0x160).op(opMSTORE)
+	a.push(0x20).push(0xc0).push(0x80).push(0x100).push(0x00).push(0x20).op(opCALLDATALOAD, opGAS, opCALL)
+	a.op(opISZERO).pushLabel("fail").op(opJUMPI)
+
+	a.label("done").push(0x40).push(0xa0).op(opRETURN)
+	a.label("fail").push(0x00).push(0x00).op(opREVERT)
+
+	return a.build()
+}
@@ -0,0 +1,102 @@
+package tokensafety
+
+import (
+	"arbitrage-bot/config"
+	fileHelper "arbitrage-bot/helpers/file"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/sourceprovider"
+	"strings"
+	"sync"
+)
+
+// Report ... Represents the result of the safety analysis of a token
+type Report struct {
+	Address       string   `json:"address"`
+	Symbol        string   `json:"symbol"`
+	Pool          string   `json:"pool"` // pool used for the simulations
+	BuyTax        float64  `json:"buyTax"`
+	SellTax       float64  `json:"sellTax"`
+	RoundTripLoss float64  `json:"roundTripLoss"`
+	Pausable      bool     `json:"pausable"`
+	Blacklist     bool     `json:"blacklist"`
+	Reasons       []string `json:"reasons"` // reasons of the denial, empty if the token is allowed
+}
+
+// List ... allow/deny list of analysed tokens keyed by lowercase address
+type List struct {
+	mutex sync.RWMutex
+	Allow map[string]*Report `json:"allow"`
+	Deny  map[string]*Report `json:"deny"`
+}
+
+// ListPath ... returns the path of the allow/deny list of the selected network
+func ListPath() string {
+	return "data/" + config.Get().Network + "/tokenSafety.json"
+}
+
+// NewList ... creates an empty list
+func NewList() *List {
+	return &List{Allow: make(map[string]*Report), Deny: make(map[string]*Report)}
+}
+
+// LoadList ... reads the list from a file, a missing file gives an empty list
+func LoadList(path string) (*List, error) {
+	var list = NewList()
+
+	if !fileHelper.PathExists(path) {
+		return list, nil
+	}
+	if err := jsonHelper.ReadJSONFile(path, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Save ... writes the list to a file
+func (l *List) Save(path string) error {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return jsonHelper.WriteJSONFile(path, l)
+}
+
+// Add ... records the report of a token in the allow or deny list
+func (l *List) Add(report *Report) {
+	var address = strings.ToLower(report.Address)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.Allow, address)
+	delete(l.Deny, address)
+
+	if len(report.Reasons) > 0 {
+		l.Deny[address] = report
+	} else {
+		l.Allow[address] = report
+	}
+}
+
+// IsAnalysed ... whether the token already has a report
+func (l *List) IsAnalysed(address string) bool {
+	var key = strings.ToLower(address)
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	_, allowed := l.Allow[key]
+	_, denied := l.Deny[key]
+
+	return allowed || denied
+}
+
+// IsDenied ... whether the token failed the analysis
+func (l *List) IsDenied(address string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	_, ok := l.Deny[strings.ToLower(address)]
+
+	return ok
+}
+
+// IsSymbolAllowed ... whether none of the tokens of a pool is denied (symbols without addresses are always allowed)
+func (l *List) IsSymbolAllowed(symbol *sourceprovider.Symbol) bool {
+	return !l.IsDenied(symbol.BaseAssetAddress) && !l.IsDenied(symbol.QuoteAssetAddress)
+}
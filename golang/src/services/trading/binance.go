@@ -0,0 +1,421 @@
+package trading
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/models"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BinanceWsAPIURL ... Binance WebSocket API URL
+const BinanceWsAPIURL string = "wss://ws-api.binance.com:443/ws-api/v3"
+
+// partialFillTolerance ... relative difference between the input & the filled input still counted as a full fill
+// (the rounding to the step size always leaves dust)
+const partialFillTolerance = 0.01
+
+// BinanceTradingClient ... Places signed spot orders through the REST API or the WebSocket API of Binance
+type BinanceTradingClient struct {
+	rest        *restClient
+	wsAPIURL    string
+	useWsAPI    bool
+	orderType   string
+	maxSlippage float64
+
+	rules   map[string]sourceprovider.TradingRules
+	rulesMu sync.RWMutex
+
+	wsConn      *websocket.Conn
+	wsMu        sync.Mutex
+	wsRequestID int64
+}
+
+// NewBinanceTradingClient ... creates a new Binance trading client from the exchange settings
+func NewBinanceTradingClient() *BinanceTradingClient {
+	return newBinanceTradingClient(config.Get().Exchange(sourceprovider.BinanceProviderName))
+}
+
+// newBinanceTradingClient ... creates a Binance trading client from settings (f.e. pointing at a mock exchange)
+func newBinanceTradingClient(settings config.ExchangeConfig) *BinanceTradingClient {
+	var client = &BinanceTradingClient{
+		rest:        newRESTClient(settings, cex.BinanceAPIURL, "X-MBX-APIKEY"),
+		wsAPIURL:    settings.WsAPIURL,
+		useWsAPI:    settings.UseWsAPI,
+		orderType:   settings.OrderType,
+		maxSlippage: settings.MaxSlippage,
+		rules:       make(map[string]sourceprovider.TradingRules),
+	}
+
+	if client.wsAPIURL == "" {
+		client.wsAPIURL = BinanceWsAPIURL
+	}
+	if client.orderType == "" {
+		client.orderType = OrderTypeMarket
+	}
+
+	return client
+}
+
+// LoadRules ... fetches the trading rules of every symbol from exchangeInfo
+func (b *BinanceTradingClient) LoadRules() error {
+	var data struct {
+		Symbols []map[string]interface{} `json:"symbols"`
+	}
+
+	if err := b.rest.request(http.MethodGet, "/exchangeInfo", nil, false, &data); err != nil {
+		return fmt.Errorf("error fetching exchangeInfo: %w", err)
+	}
+
+	b.rulesMu.Lock()
+	defer b.rulesMu.Unlock()
+
+	for _, symbol := range data.Symbols {
+		if name, ok := symbol["symbol"].(string); ok {
+			b.rules[name] = cex.ParseBinanceTradingRules(symbol)
+		}
+	}
+	slog.Info("Loaded Binance trading rules", slog.Int("symbols", len(b.rules)))
+
+	return nil
+}
+
+// GetName ... returns the provider name
+func (b *BinanceTradingClient) GetName() string {
+	return sourceprovider.BinanceProviderName
+}
+
+// GetBalances ... returns the balances of the account
+func (b *BinanceTradingClient) GetBalances() ([]AccountBalance, error) {
+	return b.rest.getBalances()
+}
+
+// Rules ... returns the trading rules of a symbol
+func (b *BinanceTradingClient) Rules(symbol string) (sourceprovider.TradingRules, bool) {
+	b.rulesMu.RLock()
+	defer b.rulesMu.RUnlock()
+
+	var rules, ok = b.rules[symbol]
+
+	return rules, ok
+}
+
+// PlaceOrder ... rounds an order to the rules of its symbol & places it
+func (b *BinanceTradingClient) PlaceOrder(order Order) (*OrderResult, error) {
+	var params, err = b.orderParams(order)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var response binanceOrderResponse
+
+	if b.useWsAPI {
+		err = b.wsRequest("order.place", params, &response)
+	} else {
+		err = b.rest.request(http.MethodPost, "/order", params, true, &response)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response.toResult(), nil
+}
+
+// ExecuteTriangle ... places the legs one after the other, every leg spends the (net) output of the previous one.
+// A partial fill carries on with what was received, a rejected or empty leg stops the triangle
+func (b *BinanceTradingClient) ExecuteTriangle(legs []TriangleLeg, amountIn float64) *TriangleResult {
+	var result = &TriangleResult{AmountIn: amountIn}
+	var amount = amountIn
+
+	for _, leg := range legs {
+		var legResult = &LegResult{Leg: leg, Symbol: leg.Symbol.Symbol, Side: leg.Side}
+		result.Legs = append(result.Legs, legResult)
+
+		var order, err = b.PlaceOrder(b.legOrder(leg, amount))
+
+		if err != nil {
+			legResult.Error = err.Error()
+			slog.Error("Triangle leg failed", slog.String("symbol", leg.Symbol.Symbol), slog.String("side", leg.Side),
+				slog.Float64("amount", amount), slog.Any("error", err))
+
+			return result
+		}
+
+		legResult.Order = order
+		legResult.AmountIn, legResult.AmountOut = legAmounts(leg, order)
+		legResult.Partial = legResult.AmountIn < amount*(1-partialFillTolerance)
+		slog.Info("Triangle leg executed", slog.String("symbol", leg.Symbol.Symbol), slog.String("side", leg.Side),
+			slog.String("status", order.Status), slog.Float64("amountIn", legResult.AmountIn),
+			slog.Float64("amountOut", legResult.AmountOut), slog.Bool("partial", legResult.Partial))
+
+		if legResult.AmountOut <= 0 {
+			legResult.Error = "leg wasn't filled"
+
+			return result
+		}
+		amount = legResult.AmountOut
+	}
+
+	result.AmountOut = amount
+	result.Completed = true
+
+	return result
+}
+
+// Close ... closes the WebSocket API connection
+func (b *BinanceTradingClient) Close() {
+	b.wsMu.Lock()
+	defer b.wsMu.Unlock()
+
+	if b.wsConn != nil {
+		b.wsConn.Close()
+		b.wsConn = nil
+	}
+}
+
+// TriangleLegsFromSurfaceResult ... converts the trades of a surface result to orders: a baseToQuote trade spends the
+// quote asset (buy at the ask), a quoteToBase trade sells the base asset (at the bid)
+func TriangleLegsFromSurfaceResult(surfaceResult models.TriangularArbSurfaceResult) []TriangleLeg {
+	var symbols = []sourceprovider.Symbol{surfaceResult.Symbol1, surfaceResult.Symbol2, surfaceResult.Symbol3}
+	var directions = []string{surfaceResult.DirectionTrade1, surfaceResult.DirectionTrade2, surfaceResult.DirectionTrade3}
+	var rates = []float64{surfaceResult.Swap1Rate, surfaceResult.Swap2Rate, surfaceResult.Swap3Rate}
+	var legs = make([]TriangleLeg, 0, len(symbols))
+
+	for i := range symbols {
+		legs = append(legs, NewTriangleLeg(&symbols[i], directions[i], rates[i]))
+	}
+
+	return legs
+}
+
+// NewTriangleLeg ... converts a trade of the CEX calculator to a leg: baseToQuote spends the quote asset at the ask
+// (a buy, the rate is 1/ask), quoteToBase sells the base asset at the bid
+func NewTriangleLeg(symbol *sourceprovider.Symbol, directionTrade string, rate float64) TriangleLeg {
+	var leg = TriangleLeg{Symbol: symbol, Side: SideSell, Price: rate}
+
+	if directionTrade == "baseToQuote" {
+		leg.Side = SideBuy
+		if rate > 0 {
+			leg.Price = 1 / rate
+		}
+	}
+
+	return leg
+}
+
+// legOrder ... builds the order spending amount on a leg
+func (b *BinanceTradingClient) legOrder(leg TriangleLeg, amount float64) Order {
+	var order = Order{Symbol: leg.Symbol.Symbol, Side: leg.Side, Type: b.orderType}
+
+	if b.orderType == OrderTypeIOC {
+		if leg.Side == SideBuy {
+			order.Price = leg.Price * (1 + b.maxSlippage)
+			if order.Price > 0 {
+				order.Quantity = amount / order.Price
+			}
+		} else {
+			order.Price = leg.Price * (1 - b.maxSlippage)
+			order.Quantity = amount
+		}
+
+		return order
+	}
+
+	if leg.Side == SideBuy {
+		order.QuoteQuantity = amount
+	} else {
+		order.Quantity = amount
+		order.Price = leg.Price
+	}
+
+	return order
+}
+
+// legAmounts ... returns the input spent & the output received (net of the commission) by a leg
+func legAmounts(leg TriangleLeg, order *OrderResult) (float64, float64) {
+	if leg.Side == SideBuy {
+		return order.QuoteQty, math.Max(order.ExecutedQty-order.Commissions[leg.Symbol.BaseAsset], 0)
+	}
+
+	return order.ExecutedQty, math.Max(order.QuoteQty-order.Commissions[leg.Symbol.QuoteAsset], 0)
+}
+
+// orderParams ... rounds an order to the rules of its symbol & returns the request parameters
+func (b *BinanceTradingClient) orderParams(order Order) (url.Values, error) {
+	var rules, ok = b.Rules(order.Symbol)
+
+	if !ok {
+		return nil, fmt.Errorf("no trading rules for %s, call LoadRules first", order.Symbol)
+	}
+	if !rules.IsTradable() {
+		return nil, fmt.Errorf("%s isn't tradable (status %s)", order.Symbol, rules.Status)
+	}
+
+	var params = url.Values{}
+	params.Set("symbol", order.Symbol)
+	params.Set("side", order.Side)
+	params.Set("newOrderRespType", "FULL")
+
+	switch order.Type {
+	case OrderTypeIOC:
+		var price = rules.RoundPrice(order.Price, order.Side == SideBuy)
+		var quantity = rules.RoundQuantity(order.Quantity)
+
+		if price <= 0 || quantity <= 0 {
+			return nil, fmt.Errorf("%s %s order of %v at %v is below the lot size", order.Side, order.Symbol,
+				order.Quantity, order.Price)
+		}
+		if !rules.MeetsMinNotional(quantity, price) {
+			return nil, fmt.Errorf("%s %s order of %v at %v is below the min notional %v", order.Side, order.Symbol,
+				quantity, price, rules.MinNotional)
+		}
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", "IOC")
+		params.Set("price", rules.FormatPrice(price))
+		params.Set("quantity", rules.FormatQuantity(quantity))
+	case OrderTypeMarket:
+		params.Set("type", "MARKET")
+
+		if order.Side == SideBuy && order.QuoteQuantity > 0 {
+			var quoteQuantity = rules.RoundQuoteAmount(order.QuoteQuantity)
+
+			if quoteQuantity <= 0 || quoteQuantity < rules.MinNotional {
+				return nil, fmt.Errorf("%s %s order of %v quote is below the min notional %v", order.Side,
+					order.Symbol, order.QuoteQuantity, rules.MinNotional)
+			}
+			params.Set("quoteOrderQty", rules.FormatQuoteAmount(quoteQuantity))
+
+			break
+		}
+
+		var quantity = rules.RoundQuantity(order.Quantity)
+
+		if quantity <= 0 {
+			return nil, fmt.Errorf("%s %s order of %v is below the lot size", order.Side, order.Symbol, order.Quantity)
+		}
+		if order.Price > 0 && !rules.MeetsMinNotional(quantity, order.Price) {
+			return nil, fmt.Errorf("%s %s order of %v is below the min notional %v", order.Side, order.Symbol,
+				quantity, rules.MinNotional)
+		}
+		params.Set("quantity", rules.FormatQuantity(quantity))
+	default:
+		return nil, fmt.Errorf("unsupported order type %s", order.Type)
+	}
+
+	return params, nil
+}
+
+// wsRequest ... sends a signed WebSocket API request & waits for the response with the same id
+func (b *BinanceTradingClient) wsRequest(method string, params url.Values, responseData interface{}) error {
+	b.wsMu.Lock()
+	defer b.wsMu.Unlock()
+
+	if b.wsConn == nil {
+		conn, _, err := websocket.DefaultDialer.Dial(b.wsAPIURL, nil)
+		if err != nil {
+			return fmt.Errorf("error connecting to the WebSocket API: %w", err)
+		}
+		b.wsConn = conn
+	}
+
+	params.Set("apiKey", b.rest.apiKey)
+	params.Set("signature", b.rest.sign(params))
+
+	var wsParams = make(map[string]string, len(params))
+	for key := range params {
+		wsParams[key] = params.Get(key)
+	}
+
+	b.wsRequestID++
+	var id = strconv.FormatInt(b.wsRequestID, 10)
+
+	if err := b.wsConn.WriteJSON(map[string]interface{}{"id": id, "method": method, "params": wsParams}); err != nil {
+		b.resetWsConn()
+
+		return err
+	}
+
+	for {
+		b.wsConn.SetReadDeadline(time.Now().Add(b.rest.httpClient.Timeout))
+
+		var response struct {
+			ID     string          `json:"id"`
+			Status int             `json:"status"`
+			Result json.RawMessage `json:"result"`
+			Error  *APIError       `json:"error"`
+		}
+
+		if err := b.wsConn.ReadJSON(&response); err != nil {
+			b.resetWsConn()
+
+			return err
+		}
+		if response.ID != id {
+			// a late response of a timed out request
+			continue
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		if response.Status >= http.StatusBadRequest {
+			return fmt.Errorf("%s: status %d", method, response.Status)
+		}
+
+		return json.Unmarshal(response.Result, responseData)
+	}
+}
+
+// resetWsConn ... drops a broken WebSocket API connection, the next request reconnects (wsMu is held)
+func (b *BinanceTradingClient) resetWsConn() {
+	if b.wsConn != nil {
+		b.wsConn.Close()
+		b.wsConn = nil
+	}
+}
+
+// binanceOrderResponse ... Represents a FULL order response
+type binanceOrderResponse struct {
+	OrderID             int64  `json:"orderId"`
+	Symbol              string `json:"symbol"`
+	Side                string `json:"side"`
+	Status              string `json:"status"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Fills               []struct {
+		Price           string `json:"price"`
+		Qty             string `json:"qty"`
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+	} `json:"fills"`
+}
+
+// toResult ... parses the decimal strings of the response
+func (r binanceOrderResponse) toResult() *OrderResult {
+	var result = &OrderResult{
+		OrderID:     r.OrderID,
+		Symbol:      r.Symbol,
+		Side:        r.Side,
+		Status:      r.Status,
+		Commissions: make(map[string]float64),
+	}
+	result.ExecutedQty, _ = strconv.ParseFloat(r.ExecutedQty, 64)
+	result.QuoteQty, _ = strconv.ParseFloat(r.CummulativeQuoteQty, 64)
+
+	for _, fill := range r.Fills {
+		var commission, _ = strconv.ParseFloat(fill.Commission, 64)
+		result.Commissions[fill.CommissionAsset] += commission
+	}
+
+	return result
+}
@@ -0,0 +1,266 @@
+package trading
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/trading/tradingtest"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAPIKey    = "test-key"
+	testAPISecret = "test-secret"
+	testTakerFee  = 0.001
+)
+
+// newTestClient ... serves a mock exchange with the default symbols & returns a client loaded with its rules
+func newTestClient(t *testing.T, settings config.ExchangeConfig) (*BinanceTradingClient, *tradingtest.BinanceServer) {
+	t.Helper()
+
+	var mock = tradingtest.NewBinanceServer(testAPIKey, testAPISecret, testTakerFee, tradingtest.DefaultBinanceSymbols())
+	var server = httptest.NewServer(mock.Handler())
+	t.Cleanup(server.Close)
+
+	if settings.APIKey == "" {
+		settings.APIKey = testAPIKey
+	}
+	if settings.APISecret == "" {
+		settings.APISecret = testAPISecret
+	}
+	settings.APIURL = server.URL + "/api/v3"
+	settings.WsAPIURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws-api/v3"
+	settings.RecvWindow = 5 * time.Second
+
+	var client = newBinanceTradingClient(settings)
+	t.Cleanup(client.Close)
+
+	if err := client.LoadRules(); err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+
+	return client, mock
+}
+
+func assertFloat(t *testing.T, name string, got float64, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestSignBinancePayload(t *testing.T) {
+	// example of the Binance API documentation (SIGNED endpoint security)
+	var secret = "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"
+	var payload = "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000" +
+		"&timestamp=1499827319559"
+	var want = "c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71"
+
+	if got := SignBinancePayload(secret, payload); got != want {
+		t.Errorf("SignBinancePayload = %s, want %s", got, want)
+	}
+}
+
+func TestPlaceOrderRejectsInvalidCredentials(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		settings config.ExchangeConfig
+		code     int
+	}{
+		{name: "secret", settings: config.ExchangeConfig{APISecret: "wrong-secret"}, code: tradingtest.ErrCodeSignature},
+		{name: "key", settings: config.ExchangeConfig{APIKey: "wrong-key"}, code: tradingtest.ErrCodeUnauthorized},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var client, _ = newTestClient(t, test.settings)
+			var _, err = client.PlaceOrder(Order{Symbol: "ETHUSDT", Side: SideSell, Type: OrderTypeMarket, Quantity: 1})
+			var apiErr *APIError
+
+			if !errors.As(err, &apiErr) || apiErr.Code != test.code {
+				t.Fatalf("PlaceOrder error = %v, want exchange error %d", err, test.code)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{})
+	var rules, ok = client.Rules("BTCUSDT")
+
+	if !ok {
+		t.Fatal("no rules for BTCUSDT")
+	}
+	assertFloat(t, "StepSize", rules.StepSize, 0.00001)
+	assertFloat(t, "TickSize", rules.TickSize, 0.01)
+	assertFloat(t, "MinNotional", rules.MinNotional, 5)
+}
+
+func TestOrderParamsRounding(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{})
+
+	for _, test := range []struct {
+		name   string
+		order  Order
+		params map[string]string
+		err    string
+	}{
+		{
+			name:   "LOT_SIZE down & PRICE_FILTER up for a buy",
+			order:  Order{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeIOC, Quantity: 0.123456789, Price: 60000.123},
+			params: map[string]string{"type": "LIMIT", "timeInForce": "IOC", "quantity": "0.12345", "price": "60000.13"},
+		},
+		{
+			name:   "PRICE_FILTER down for a sell",
+			order:  Order{Symbol: "BTCUSDT", Side: SideSell, Type: OrderTypeIOC, Quantity: 0.1, Price: 60000.129},
+			params: map[string]string{"quantity": "0.10000", "price": "60000.12"},
+		},
+		{
+			name:   "quote amount of a market buy",
+			order:  Order{Symbol: "ETHUSDT", Side: SideBuy, Type: OrderTypeMarket, QuoteQuantity: 100.123456789},
+			params: map[string]string{"type": "MARKET", "quoteOrderQty": "100.12345678"},
+		},
+		{
+			name:  "below the lot size",
+			order: Order{Symbol: "BTCUSDT", Side: SideSell, Type: OrderTypeIOC, Quantity: 0.000001, Price: 60000},
+			err:   "lot size",
+		},
+		{
+			name:  "below the NOTIONAL filter",
+			order: Order{Symbol: "BTCUSDT", Side: SideSell, Type: OrderTypeIOC, Quantity: 0.00005, Price: 60000},
+			err:   "min notional",
+		},
+		{
+			name:  "quote amount below the NOTIONAL filter",
+			order: Order{Symbol: "ETHUSDT", Side: SideBuy, Type: OrderTypeMarket, QuoteQuantity: 1},
+			err:   "min notional",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var params, err = client.orderParams(test.order)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("orderParams error = %v, want %q", err, test.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("orderParams: %v", err)
+			}
+			for key, want := range test.params {
+				if got := params.Get(key); got != want {
+					t.Errorf("%s = %s, want %s", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceOrderPartialFill(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{})
+
+	// the asks are 5 @ 3010, 10 @ 3011 & 20 @ 3015, the IOC limit stops at 3011
+	var result, err = client.PlaceOrder(Order{Symbol: "ETHUSDT", Side: SideBuy, Type: OrderTypeIOC, Quantity: 20,
+		Price: 3011})
+
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if result.Status != OrderStatusExpired {
+		t.Errorf("Status = %s, want %s", result.Status, OrderStatusExpired)
+	}
+	assertFloat(t, "ExecutedQty", result.ExecutedQty, 15)
+	assertFloat(t, "QuoteQty", result.QuoteQty, 5*3010+10*3011)
+	assertFloat(t, "commission", result.Commissions["ETH"], 15*testTakerFee)
+}
+
+func TestPlaceOrderWsAPI(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{UseWsAPI: true})
+
+	for i := 0; i < 2; i++ {
+		// the second order reuses the connection
+		var result, err = client.PlaceOrder(Order{Symbol: "ETHUSDT", Side: SideSell, Type: OrderTypeMarket,
+			Quantity: 1, Price: 3009})
+
+		if err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		if result.Status != OrderStatusFilled || result.OrderID != int64(i+1) {
+			t.Errorf("order %d = %+v, want a filled order", i+1, result)
+		}
+		assertFloat(t, "QuoteQty", result.QuoteQty, 3009)
+	}
+
+	// the requests of the WebSocket API are signed too
+	var apiErr *APIError
+	client.rest.apiSecret = "wrong-secret"
+
+	if _, err := client.PlaceOrder(Order{Symbol: "ETHUSDT", Side: SideSell, Type: OrderTypeMarket,
+		Quantity: 1}); !errors.As(err, &apiErr) || apiErr.Code != tradingtest.ErrCodeSignature {
+		t.Fatalf("PlaceOrder error = %v, want exchange error %d", err, tradingtest.ErrCodeSignature)
+	}
+}
+
+func TestExecuteTriangle(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{OrderType: OrderTypeMarket})
+	var symbols = tradingtest.DefaultBinanceSymbols()
+	var legs = []TriangleLeg{
+		{Symbol: &sourceprovider.Symbol{Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT"}, Side: SideBuy,
+			Price: symbols[0].Asks[0].Price},
+		{Symbol: &sourceprovider.Symbol{Symbol: "ETHBTC", BaseAsset: "ETH", QuoteAsset: "BTC"}, Side: SideBuy,
+			Price: symbols[1].Asks[0].Price},
+		{Symbol: &sourceprovider.Symbol{Symbol: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT"}, Side: SideSell,
+			Price: symbols[2].Bids[0].Price},
+	}
+	var result = client.ExecuteTriangle(legs, 1000)
+
+	if !result.Completed {
+		t.Fatalf("triangle wasn't completed: %+v", result.Legs)
+	}
+	for _, leg := range result.Legs {
+		if leg.Partial {
+			t.Errorf("leg %s was partially filled", leg.Symbol)
+		}
+	}
+	// 1000 USDT -> BTC @ 60000 -> ETH @ 0.05 -> USDT @ 3009, minus the step rounding & 3 taker fees
+	var want = 1000.0 / 60000 / 0.05 * 3009 * math.Pow(1-testTakerFee, 3)
+
+	if result.AmountOut > want || result.AmountOut < want*0.999 {
+		t.Errorf("AmountOut = %v, want about %v", result.AmountOut, want)
+	}
+}
+
+func TestExecuteTriangleStopsOnRejectedLeg(t *testing.T) {
+	var client, _ = newTestClient(t, config.ExchangeConfig{OrderType: OrderTypeMarket})
+	var legs = []TriangleLeg{
+		{Symbol: &sourceprovider.Symbol{Symbol: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT"}, Side: SideBuy,
+			Price: 3010},
+		{Symbol: &sourceprovider.Symbol{Symbol: "SOLETH", BaseAsset: "SOL", QuoteAsset: "ETH"}, Side: SideBuy,
+			Price: 0.05},
+		{Symbol: &sourceprovider.Symbol{Symbol: "SOLUSDT", BaseAsset: "SOL", QuoteAsset: "USDT"}, Side: SideSell,
+			Price: 150},
+	}
+	var result = client.ExecuteTriangle(legs, 100)
+
+	if result.Completed || len(result.Legs) != 2 || result.Legs[1].Error == "" {
+		t.Fatalf("triangle = %+v, want a stop at the second leg", result)
+	}
+}
+
+func TestGetBalances(t *testing.T) {
+	var client, mock = newTestClient(t, config.ExchangeConfig{})
+	mock.SetBalance("USDT", 1500)
+
+	var balances, err = client.GetBalances()
+
+	if err != nil {
+		t.Fatalf("GetBalances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Asset != "USDT" {
+		t.Fatalf("balances = %+v, want USDT", balances)
+	}
+	assertFloat(t, "Free", balances[0].Free, 1500)
+}
@@ -0,0 +1,116 @@
+package trading
+
+import (
+	"arbitrage-bot/services/sourceprovider"
+	"fmt"
+)
+
+// Order sides
+const (
+	SideBuy  string = "BUY"
+	SideSell string = "SELL"
+)
+
+// Order types (config values)
+const (
+	OrderTypeMarket string = "market"
+	OrderTypeIOC    string = "ioc" // limit order, immediate or cancel
+)
+
+// Order statuses
+const (
+	OrderStatusFilled  string = "FILLED"
+	OrderStatusExpired string = "EXPIRED" // an IOC order which wasn't (fully) filled
+)
+
+// Order ... Represents an order to place, quantities & prices are rounded to the symbol rules before sending
+type Order struct {
+	Symbol        string
+	Side          string
+	Type          string
+	Quantity      float64 // base quantity (sells & IOC buys)
+	QuoteQuantity float64 // quote amount to spend (market buys)
+	Price         float64 // limit price (IOC orders), reference price of the min notional check (market sells)
+}
+
+// OrderResult ... Represents the execution of an order
+type OrderResult struct {
+	OrderID     int64              `json:"orderId"`
+	Symbol      string             `json:"symbol"`
+	Side        string             `json:"side"`
+	Status      string             `json:"status"`
+	ExecutedQty float64            `json:"executedQty"` // base quantity filled
+	QuoteQty    float64            `json:"quoteQty"`    // quote amount filled
+	Commissions map[string]float64 `json:"commissions"` // asset -> commission
+}
+
+// AvgPrice ... returns the average execution price
+func (o *OrderResult) AvgPrice() float64 {
+	if o.ExecutedQty == 0 {
+		return 0
+	}
+
+	return o.QuoteQty / o.ExecutedQty
+}
+
+// AccountBalance ... Represents the balance of an asset on a CEX account
+type AccountBalance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free"`   // available for trading
+	Locked float64 `json:"locked"` // held by open orders
+}
+
+// APIError ... Represents an error returned by the exchange (f.e. a filter failure)
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("exchange error %d: %s", e.Code, e.Message)
+}
+
+// TriangleLeg ... Represents a trade of a triangle, the input is the quote asset for a buy & the base asset for a sell
+type TriangleLeg struct {
+	Symbol *sourceprovider.Symbol
+	Side   string
+	Price  float64 // reference price (ask for a buy, bid for a sell) the limit price of an IOC order derives from
+}
+
+// InputAsset ... returns the asset spent by the leg
+func (l TriangleLeg) InputAsset() string {
+	if l.Side == SideBuy {
+		return l.Symbol.QuoteAsset
+	}
+
+	return l.Symbol.BaseAsset
+}
+
+// OutputAsset ... returns the asset received by the leg
+func (l TriangleLeg) OutputAsset() string {
+	if l.Side == SideBuy {
+		return l.Symbol.BaseAsset
+	}
+
+	return l.Symbol.QuoteAsset
+}
+
+// LegResult ... Represents the execution of a triangle leg
+type LegResult struct {
+	Leg       TriangleLeg  `json:"-"`
+	Symbol    string       `json:"symbol"`
+	Side      string       `json:"side"`
+	Order     *OrderResult `json:"order,omitempty"`
+	AmountIn  float64      `json:"amountIn"`  // input spent
+	AmountOut float64      `json:"amountOut"` // output received, net of the commission
+	Partial   bool         `json:"partial"`   // the input wasn't fully spent
+	Error     string       `json:"error,omitempty"`
+}
+
+// TriangleResult ... Represents the execution of a triangle, the legs after a failed one aren't placed
+type TriangleResult struct {
+	Legs      []*LegResult `json:"legs"`
+	AmountIn  float64      `json:"amountIn"`  // input of the first leg
+	AmountOut float64      `json:"amountOut"` // output of the last leg
+	Completed bool         `json:"completed"` // every leg was placed & filled (maybe partially)
+}
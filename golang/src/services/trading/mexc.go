@@ -0,0 +1,29 @@
+package trading
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/sourceprovider/cex"
+)
+
+// MEXCAccountClient ... Reads the MEXC account through the signed REST API (Binance compatible)
+type MEXCAccountClient struct {
+	rest *restClient
+}
+
+// NewMEXCAccountClient ... creates a new MEXC account client from the exchange settings
+func NewMEXCAccountClient() *MEXCAccountClient {
+	var settings = config.Get().Exchange(sourceprovider.MEXCProviderName)
+
+	return &MEXCAccountClient{rest: newRESTClient(settings, cex.MEXCAPIURL, "X-MEXC-APIKEY")}
+}
+
+// GetName ... returns the provider name
+func (m *MEXCAccountClient) GetName() string {
+	return sourceprovider.MEXCProviderName
+}
+
+// GetBalances ... returns the balances of the account
+func (m *MEXCAccountClient) GetBalances() ([]AccountBalance, error) {
+	return m.rest.getBalances()
+}
@@ -0,0 +1,160 @@
+package trading
+
+import (
+	"arbitrage-bot/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRecvWindow ... validity of a signed request if it's not configured
+const defaultRecvWindow = 5 * time.Second
+
+// restClient ... sends requests to a Binance compatible REST API (Binance, MEXC), the signed ones carry an
+// HMAC-SHA256 signature of their parameters & the API key in a header
+type restClient struct {
+	apiKey       string
+	apiSecret    string
+	apiURL       string
+	apiKeyHeader string
+	recvWindow   time.Duration
+	httpClient   *http.Client
+}
+
+// newRESTClient ... creates a REST client from the exchange settings, the API URL defaults to the exchange
+func newRESTClient(settings config.ExchangeConfig, defaultAPIURL string, apiKeyHeader string) *restClient {
+	var client = &restClient{
+		apiKey:       settings.APIKey,
+		apiSecret:    settings.APISecret,
+		apiURL:       strings.TrimSuffix(settings.APIURL, "/"),
+		apiKeyHeader: apiKeyHeader,
+		recvWindow:   settings.RecvWindow,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if client.apiURL == "" {
+		client.apiURL = defaultAPIURL
+	}
+	if client.recvWindow <= 0 {
+		client.recvWindow = defaultRecvWindow
+	}
+
+	return client
+}
+
+// sign ... adds the timestamp & the receive window, returns the HMAC-SHA256 signature of the encoded parameters
+func (c *restClient) sign(params url.Values) string {
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("recvWindow", strconv.FormatInt(c.recvWindow.Milliseconds(), 10))
+
+	return SignBinancePayload(c.apiSecret, params.Encode())
+}
+
+// SignBinancePayload ... returns the hex HMAC-SHA256 signature of a payload
+func SignBinancePayload(secret string, payload string) string {
+	var mac = hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// request ... sends a REST request, the parameters go in the query string of a GET & in the body otherwise
+func (c *restClient) request(method string, path string, params url.Values, signed bool,
+	responseData interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	var endpoint = c.apiURL + path
+	var body io.Reader
+	var payload string
+
+	if signed {
+		// the signature goes last, it covers the parameters before it
+		var signature = c.sign(params)
+		payload = params.Encode() + "&signature=" + signature
+	} else {
+		payload = params.Encode()
+	}
+	if method == http.MethodGet {
+		if payload != "" {
+			endpoint += "?" + payload
+		}
+	} else {
+		body = strings.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if signed {
+		req.Header.Set(c.apiKeyHeader, c.apiKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		var apiErr APIError
+
+		if json.Unmarshal(resBody, &apiErr) == nil && apiErr.Code != 0 {
+			return &apiErr
+		}
+
+		return fmt.Errorf("%s %s: status %d: %s", method, path, res.StatusCode, string(resBody))
+	}
+
+	return json.Unmarshal(resBody, responseData)
+}
+
+// getBalances ... reads the non-zero balances of the account (signed GET /account)
+func (c *restClient) getBalances() ([]AccountBalance, error) {
+	if c.apiKey == "" || c.apiSecret == "" {
+		return nil, fmt.Errorf("no API key configured for %s", c.apiURL)
+	}
+
+	var data struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+
+	if err := c.request(http.MethodGet, "/account", nil, true, &data); err != nil {
+		return nil, fmt.Errorf("error fetching the account: %w", err)
+	}
+
+	var balances []AccountBalance
+
+	for _, item := range data.Balances {
+		var balance = AccountBalance{Asset: item.Asset}
+		balance.Free, _ = strconv.ParseFloat(item.Free, 64)
+		balance.Locked, _ = strconv.ParseFloat(item.Locked, 64)
+
+		if balance.Free > 0 || balance.Locked > 0 {
+			balances = append(balances, balance)
+		}
+	}
+
+	return balances, nil
+}
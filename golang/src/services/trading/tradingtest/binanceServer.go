@@ -0,0 +1,593 @@
+// Package tradingtest ... serves a local exchange for testing the trading clients without touching real funds
+package tradingtest
+
+import (
+	"arbitrage-bot/services/sourceprovider"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Error codes of the Binance API reproduced by the exchange
+const (
+	ErrCodeUnauthorized  = -2015
+	ErrCodeSignature     = -1022
+	ErrCodeTimestamp     = -1021
+	ErrCodeInvalidSymbol = -1121
+	ErrCodeFilter        = -1013
+	ErrCodeParameter     = -1102
+)
+
+// Values of the Binance API
+const (
+	sideBuy       = "BUY"
+	sideSell      = "SELL"
+	statusFilled  = "FILLED"
+	statusExpired = "EXPIRED" // an IOC order which wasn't (fully) filled
+
+	defaultRecvWindow = 5 * time.Second // validity of a signed request without recvWindow
+)
+
+// apiError ... error response of the Binance API
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+// Symbol ... Represents a symbol of the mock exchange, the orderbook levels are consumed by the fills
+type Symbol struct {
+	Symbol     string
+	BaseAsset  string
+	QuoteAsset string
+	Rules      sourceprovider.TradingRules
+	Asks       []*sourceprovider.OrderbookEntry // ascending prices, quantities in base
+	Bids       []*sourceprovider.OrderbookEntry // descending prices, quantities in base
+}
+
+// BinanceServer ... Local exchange serving the subset of the Binance REST & WebSocket API used by the trading
+// client (exchangeInfo, signed order placement), for trying the execution without touching real funds
+type BinanceServer struct {
+	apiKey    string
+	apiSecret string
+	takerFee  float64
+	symbols   map[string]*Symbol
+	balances  map[string]float64 // asset -> free balance, moved by the fills (not enforced)
+
+	mu          sync.Mutex
+	nextOrderID int64
+	listener    net.Listener
+	server      *http.Server
+	upgrader    websocket.Upgrader
+}
+
+// NewBinanceServer ... creates a new mock exchange accepting the orders signed with apiKey & apiSecret
+func NewBinanceServer(apiKey string, apiSecret string, takerFee float64, symbols []*Symbol) *BinanceServer {
+	var server = &BinanceServer{
+		apiKey:      apiKey,
+		apiSecret:   apiSecret,
+		takerFee:    takerFee,
+		symbols:     make(map[string]*Symbol),
+		balances:    make(map[string]float64),
+		nextOrderID: 1,
+	}
+
+	for _, symbol := range symbols {
+		server.symbols[symbol.Symbol] = symbol
+	}
+
+	return server
+}
+
+// DefaultBinanceSymbols ... returns a BTCUSDT / ETHBTC / ETHUSDT triangle with a few levels per side
+func DefaultBinanceSymbols() []*Symbol {
+	var rules = func(stepSize float64, tickSize float64, minNotional float64) sourceprovider.TradingRules {
+		return sourceprovider.TradingRules{Status: "TRADING", MinQty: stepSize, MaxQty: 9000, StepSize: stepSize,
+			MinPrice: tickSize, MaxPrice: 1000000, TickSize: tickSize, MinNotional: minNotional, QuotePrecision: 8}
+	}
+	var levels = func(prices []float64, quantities []float64) []*sourceprovider.OrderbookEntry {
+		var entries = make([]*sourceprovider.OrderbookEntry, len(prices))
+		for i := range prices {
+			entries[i] = &sourceprovider.OrderbookEntry{Price: prices[i], Quantity: quantities[i]}
+		}
+		return entries
+	}
+
+	return []*Symbol{
+		{
+			Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT", Rules: rules(0.00001, 0.01, 5),
+			Asks: levels([]float64{60000, 60010, 60050}, []float64{0.5, 1, 2}),
+			Bids: levels([]float64{59990, 59980, 59950}, []float64{0.5, 1, 2}),
+		},
+		{
+			Symbol: "ETHBTC", BaseAsset: "ETH", QuoteAsset: "BTC", Rules: rules(0.0001, 0.00001, 0.0001),
+			Asks: levels([]float64{0.05, 0.05001, 0.05005}, []float64{5, 10, 20}),
+			Bids: levels([]float64{0.04999, 0.04998, 0.04995}, []float64{5, 10, 20}),
+		},
+		{
+			Symbol: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT", Rules: rules(0.0001, 0.01, 5),
+			Asks: levels([]float64{3010, 3011, 3015}, []float64{5, 10, 20}),
+			Bids: levels([]float64{3009, 3008, 3005}, []float64{5, 10, 20}),
+		},
+	}
+}
+
+// SetBalance ... sets the free balance of an asset
+func (m *BinanceServer) SetBalance(asset string, balance float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.balances[asset] = balance
+}
+
+// Handler ... returns the handler of the REST (/api/v3) & WebSocket (/ws-api/v3) APIs, f.e. for an httptest server
+func (m *BinanceServer) Handler() http.Handler {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/api/v3/exchangeInfo", m.handleExchangeInfo)
+	mux.HandleFunc("/api/v3/order", m.handleOrder)
+	mux.HandleFunc("/api/v3/account", m.handleAccount)
+	mux.HandleFunc("/ws-api/v3", m.handleWsAPI)
+
+	return mux
+}
+
+// Start ... listens on address (f.e. 127.0.0.1:0 for a random port) & serves in the background
+func (m *BinanceServer) Start(address string) error {
+	var listener, err = net.Listen("tcp", address)
+
+	if err != nil {
+		return err
+	}
+
+	m.listener = listener
+	m.server = &http.Server{Handler: m.Handler()}
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Mock exchange stopped", slog.Any("error", err))
+		}
+	}()
+	slog.Info("Mock Binance exchange listening", slog.String("apiUrl", m.APIURL()),
+		slog.String("wsApiUrl", m.WsAPIURL()))
+
+	return nil
+}
+
+// APIURL ... returns the REST API URL to configure as exchanges.binance.apiUrl
+func (m *BinanceServer) APIURL() string {
+	return "http://" + m.listener.Addr().String() + "/api/v3"
+}
+
+// WsAPIURL ... returns the WebSocket API URL to configure as exchanges.binance.wsApiUrl
+func (m *BinanceServer) WsAPIURL() string {
+	return "ws://" + m.listener.Addr().String() + "/ws-api/v3"
+}
+
+// Close ... stops the server
+func (m *BinanceServer) Close() error {
+	if m.server == nil {
+		return nil
+	}
+
+	return m.server.Close()
+}
+
+// handleExchangeInfo ... serves the symbols with their filters
+func (m *BinanceServer) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	var symbols = make([]map[string]interface{}, 0, len(m.symbols))
+
+	for _, symbol := range m.symbols {
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":              symbol.Symbol,
+			"status":              symbol.Rules.Status,
+			"baseAsset":           symbol.BaseAsset,
+			"quoteAsset":          symbol.QuoteAsset,
+			"quoteAssetPrecision": symbol.Rules.QuotePrecision,
+			"filters": []map[string]interface{}{
+				{
+					"filterType": "PRICE_FILTER",
+					"minPrice":   formatDecimal(symbol.Rules.MinPrice),
+					"maxPrice":   formatDecimal(symbol.Rules.MaxPrice),
+					"tickSize":   formatDecimal(symbol.Rules.TickSize),
+				},
+				{
+					"filterType": "LOT_SIZE",
+					"minQty":     formatDecimal(symbol.Rules.MinQty),
+					"maxQty":     formatDecimal(symbol.Rules.MaxQty),
+					"stepSize":   formatDecimal(symbol.Rules.StepSize),
+				},
+				{
+					"filterType":  "NOTIONAL",
+					"minNotional": formatDecimal(symbol.Rules.MinNotional),
+				},
+			},
+		})
+	}
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timezone": "UTC", "symbols": symbols})
+}
+
+// handleOrder ... places a signed REST order
+func (m *BinanceServer) handleOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, &apiError{Code: ErrCodeParameter, Message: "Unsupported method."})
+		return
+	}
+
+	var params, apiErr = m.verifyRequest(r)
+
+	if apiErr != nil {
+		writeJSON(w, http.StatusUnauthorized, apiErr)
+		return
+	}
+
+	response, apiErr := m.placeOrder(params)
+
+	if apiErr != nil {
+		writeJSON(w, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleAccount ... serves the balances of the account to a signed request
+func (m *BinanceServer) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if _, apiErr := m.verifyRequest(r); apiErr != nil {
+		writeJSON(w, http.StatusUnauthorized, apiErr)
+		return
+	}
+
+	m.mu.Lock()
+	var balances = make([]map[string]string, 0, len(m.balances))
+
+	for asset, balance := range m.balances {
+		balances = append(balances, map[string]string{
+			"asset":  asset,
+			"free":   formatDecimal(balance),
+			"locked": formatDecimal(0),
+		})
+	}
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"accountType": "SPOT", "balances": balances})
+}
+
+// verifyRequest ... checks the signature of a REST request, the signature is the last parameter of the query
+// string + body
+func (m *BinanceServer) verifyRequest(r *http.Request) (url.Values, *apiError) {
+	var body, _ = io.ReadAll(r.Body)
+	var payload = string(body)
+
+	if r.URL.RawQuery != "" && payload != "" {
+		payload = r.URL.RawQuery + "&" + payload
+	} else if r.URL.RawQuery != "" {
+		payload = r.URL.RawQuery
+	}
+
+	var index = strings.LastIndex(payload, "&signature=")
+
+	if index < 0 {
+		return nil, &apiError{Code: ErrCodeSignature, Message: "Signature is missing."}
+	}
+
+	var params, err = url.ParseQuery(payload[:index])
+
+	if err != nil {
+		return nil, &apiError{Code: ErrCodeParameter, Message: err.Error()}
+	}
+	if apiErr := m.authenticate(r.Header.Get("X-MBX-APIKEY"), payload[:index], payload[index+len("&signature="):],
+		params); apiErr != nil {
+		return nil, apiErr
+	}
+
+	return params, nil
+}
+
+// handleWsAPI ... serves the order.place method of the WebSocket API
+func (m *BinanceServer) handleWsAPI(w http.ResponseWriter, r *http.Request) {
+	var conn, err = m.upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	for {
+		var request struct {
+			ID     interface{}            `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		var params = url.Values{}
+		var signature string
+
+		for key, value := range request.Params {
+			if key == "signature" {
+				signature = fmt.Sprint(value)
+				continue
+			}
+			params.Set(key, fmt.Sprint(value))
+		}
+
+		var result interface{}
+		var status = http.StatusOK
+		var apiErr *apiError
+
+		if request.Method != "order.place" {
+			apiErr = &apiError{Code: ErrCodeParameter, Message: "Unknown method " + request.Method}
+		} else if apiErr = m.authenticate(params.Get("apiKey"), params.Encode(), signature, params); apiErr == nil {
+			params.Del("apiKey")
+			result, apiErr = m.placeOrder(params)
+		}
+
+		var response = map[string]interface{}{"id": request.ID}
+
+		if apiErr != nil {
+			status = http.StatusBadRequest
+			response["error"] = apiErr
+		} else {
+			response["result"] = result
+		}
+		response["status"] = status
+
+		if err := conn.WriteJSON(response); err != nil {
+			return
+		}
+	}
+}
+
+// authenticate ... checks the API key, the signature & the receive window of a signed request
+func (m *BinanceServer) authenticate(apiKey string, payload string, signature string, params url.Values) *apiError {
+	if apiKey != m.apiKey {
+		return &apiError{Code: ErrCodeUnauthorized, Message: "Invalid API-key, IP, or permissions for action."}
+	}
+	if !hmac.Equal([]byte(signature), []byte(signPayload(m.apiSecret, payload))) {
+		return &apiError{Code: ErrCodeSignature, Message: "Signature for this request is not valid."}
+	}
+
+	var timestamp, _ = strconv.ParseInt(params.Get("timestamp"), 10, 64)
+	var recvWindow, _ = strconv.ParseInt(params.Get("recvWindow"), 10, 64)
+
+	if recvWindow <= 0 {
+		recvWindow = defaultRecvWindow.Milliseconds()
+	}
+	if math.Abs(float64(time.Now().UnixMilli()-timestamp)) > float64(recvWindow) {
+		return &apiError{Code: ErrCodeTimestamp, Message: "Timestamp for this request is outside of the recvWindow."}
+	}
+
+	return nil
+}
+
+// placeOrder ... validates an order against the filters of its symbol & fills it against the orderbook
+func (m *BinanceServer) placeOrder(params url.Values) (map[string]interface{}, *apiError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var symbol, ok = m.symbols[params.Get("symbol")]
+
+	if !ok {
+		return nil, &apiError{Code: ErrCodeInvalidSymbol, Message: "Invalid symbol."}
+	}
+
+	var side = params.Get("side")
+	var orderType = params.Get("type")
+	var quantity, _ = strconv.ParseFloat(params.Get("quantity"), 64)
+	var quoteQuantity, _ = strconv.ParseFloat(params.Get("quoteOrderQty"), 64)
+	var price, _ = strconv.ParseFloat(params.Get("price"), 64)
+	var limit = price
+
+	if side != sideBuy && side != sideSell {
+		return nil, &apiError{Code: ErrCodeParameter, Message: "Invalid side."}
+	}
+
+	switch orderType {
+	case "LIMIT":
+		if params.Get("timeInForce") != "IOC" {
+			return nil, &apiError{Code: ErrCodeParameter, Message: "Only IOC limit orders are supported."}
+		}
+		if !onStep(price, symbol.Rules.TickSize) || price < symbol.Rules.MinPrice {
+			return nil, &apiError{Code: ErrCodeFilter, Message: "Filter failure: PRICE_FILTER"}
+		}
+	case "MARKET":
+		limit = 0
+	default:
+		return nil, &apiError{Code: ErrCodeParameter, Message: "Unsupported order type."}
+	}
+
+	if quoteQuantity > 0 {
+		if orderType != "MARKET" || side != sideBuy {
+			return nil, &apiError{Code: ErrCodeParameter, Message: "quoteOrderQty is only supported by market buys."}
+		}
+		if quoteQuantity < symbol.Rules.MinNotional {
+			return nil, &apiError{Code: ErrCodeFilter, Message: "Filter failure: NOTIONAL"}
+		}
+	} else {
+		if quantity < symbol.Rules.MinQty || quantity > symbol.Rules.MaxQty ||
+			!onStep(quantity, symbol.Rules.StepSize) {
+			return nil, &apiError{Code: ErrCodeFilter, Message: "Filter failure: LOT_SIZE"}
+		}
+
+		var notionalPrice = price
+		if orderType == "MARKET" {
+			notionalPrice = m.bestPrice(symbol, side)
+		}
+		if quantity*notionalPrice < symbol.Rules.MinNotional {
+			return nil, &apiError{Code: ErrCodeFilter, Message: "Filter failure: NOTIONAL"}
+		}
+	}
+
+	var fills, executedQty, quoteQty, complete = m.fill(symbol, side, limit, quantity, quoteQuantity)
+	var status = statusFilled
+
+	if !complete {
+		// an IOC (or a market order exhausting the book) expires with what it could fill
+		status = statusExpired
+	}
+
+	var commission = quoteQty * m.takerFee
+
+	if side == sideBuy {
+		commission = executedQty * m.takerFee
+		m.balances[symbol.QuoteAsset] -= quoteQty
+		m.balances[symbol.BaseAsset] += executedQty - commission
+	} else {
+		m.balances[symbol.BaseAsset] -= executedQty
+		m.balances[symbol.QuoteAsset] += quoteQty - commission
+	}
+
+	var orderID = m.nextOrderID
+	m.nextOrderID++
+
+	return map[string]interface{}{
+		"symbol":              symbol.Symbol,
+		"orderId":             orderID,
+		"transactTime":        time.Now().UnixMilli(),
+		"price":               formatDecimal(price),
+		"origQty":             formatDecimal(quantity),
+		"executedQty":         formatDecimal(executedQty),
+		"cummulativeQuoteQty": formatDecimal(quoteQty),
+		"status":              status,
+		"timeInForce":         params.Get("timeInForce"),
+		"type":                orderType,
+		"side":                side,
+		"fills":               fills,
+	}, nil
+}
+
+// fill ... consumes the levels of the opposite side of the book up to the limit price (0 for none), the taker fee is
+// charged on the received asset. The order is complete if nothing (but dust below the step size) is left
+func (m *BinanceServer) fill(symbol *Symbol, side string, limit float64, quantity float64,
+	quoteQuantity float64) ([]map[string]string, float64, float64, bool) {
+	var levels = symbol.Bids
+	var commissionAsset = symbol.QuoteAsset
+
+	if side == sideBuy {
+		levels = symbol.Asks
+		commissionAsset = symbol.BaseAsset
+	}
+
+	var fills = make([]map[string]string, 0)
+	var executedQty, quoteQty float64
+	var complete bool
+
+	for _, level := range levels {
+		if level.Quantity <= 0 {
+			continue
+		}
+		if limit > 0 && ((side == sideBuy && level.Price > limit) || (side == sideSell && level.Price < limit)) {
+			break
+		}
+
+		var levelQuantity = level.Quantity
+
+		if quoteQuantity > 0 {
+			// the base quantity bought with a quote amount is rounded down to the step size
+			var remaining = symbol.Rules.RoundQuantity((quoteQuantity - quoteQty) / level.Price)
+			if remaining <= 0 {
+				return fills, executedQty, quoteQty, true
+			}
+			levelQuantity = math.Min(levelQuantity, remaining)
+		} else {
+			var remaining = quantity - executedQty
+			if remaining <= quantity*1e-9 {
+				return fills, executedQty, quoteQty, true
+			}
+			levelQuantity = math.Min(levelQuantity, remaining)
+		}
+
+		level.Quantity -= levelQuantity
+		executedQty += levelQuantity
+		quoteQty += levelQuantity * level.Price
+
+		var received = levelQuantity * level.Price
+		if side == sideBuy {
+			received = levelQuantity
+		}
+		fills = append(fills, map[string]string{
+			"price":           formatDecimal(level.Price),
+			"qty":             formatDecimal(levelQuantity),
+			"commission":      formatDecimal(received * m.takerFee),
+			"commissionAsset": commissionAsset,
+		})
+	}
+
+	if quoteQuantity > 0 {
+		complete = symbol.Rules.RoundQuantity((quoteQuantity-quoteQty)/math.Max(m.bestPrice(symbol, side), 1e-18)) <= 0
+	} else {
+		complete = quantity-executedQty <= quantity*1e-9
+	}
+
+	return fills, executedQty, quoteQty, complete
+}
+
+// bestPrice ... returns the price of the first level of the opposite side of the book
+func (m *BinanceServer) bestPrice(symbol *Symbol, side string) float64 {
+	var levels = symbol.Bids
+
+	if side == sideBuy {
+		levels = symbol.Asks
+	}
+	for _, level := range levels {
+		if level.Quantity > 0 {
+			return level.Price
+		}
+	}
+
+	return 0
+}
+
+// onStep ... whether value is a multiple of step
+func onStep(value float64, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+
+	var steps = value / step
+
+	return math.Abs(steps-math.Round(steps)) < 1e-6
+}
+
+// formatDecimal ... formats a decimal the way the exchange does (a string with 8 decimals)
+func formatDecimal(value float64) string {
+	return strconv.FormatFloat(value, 'f', 8, 64)
+}
+
+// writeJSON ... writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("Error writing mock exchange response", slog.Any("error", err))
+	}
+}
+
+// signPayload ... returns the HMAC SHA256 signature of a payload, computed independently of the trading client
+func signPayload(secret string, payload string) string {
+	var mac = hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,9 +1,10 @@
 package web3
 
 import (
-	"arbitrage-bot/helpers"
-	ethersHelper "arbitrage-bot/helpers/ethers"
+	"arbitrage-bot/config"
 	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/rpcpool"
 	sp "arbitrage-bot/services/sourceprovider"
 	"context"
 	"fmt"
@@ -12,9 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"math"
 	"math/big"
-	"os"
 	"slices"
 )
 
@@ -32,15 +31,22 @@ type ArbitrageExecutorWeb3Service struct {
 	contractAddress common.Address
 }
 
-func NewArbitrageExecutorWeb3Service() *ArbitrageExecutorWeb3Service {
-	var networkRpcUrl = os.Getenv("NETWORK_RPC_URL")
-	var networkName = os.Getenv("NETWORK_NAME")
-	var contractAddress = ethersHelper.GetArbitrageExecutorAddresses(networkName)["v1"]
+// NewArbitrageExecutorWeb3Service ... creates a new ArbitrageExecutorWeb3Service for the executor contract of the
+// selected network
+func NewArbitrageExecutorWeb3Service() (*ArbitrageExecutorWeb3Service, error) {
+	var network = config.Get().ActiveNetwork()
 
-	client, err := ethclient.Dial(networkRpcUrl)
-	helpers.Panic(err)
-	contractABI, err := jsonHelper.ReadJSONABIFile("data/web3/arbitrageExecutorABI.json")
-	helpers.Panic(err)
+	if network.Contracts.ArbitrageExecutor == "" {
+		return nil, fmt.Errorf("contracts.arbitrageExecutor isn't set for %s", config.Get().Network)
+	}
+	var contractAddress = common.HexToAddress(network.Contracts.ArbitrageExecutor)
+
+	var client = rpcpool.Get().Client()
+	contractABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.ArbitrageExecutor)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the arbitrage executor abi: %w", err)
+	}
 	var contract = bind.NewBoundContract(contractAddress, contractABI, client, client, client)
 
 	return &ArbitrageExecutorWeb3Service{
@@ -48,15 +54,61 @@ func NewArbitrageExecutorWeb3Service() *ArbitrageExecutorWeb3Service {
 		contract:        contract,
 		contractAddress: contractAddress,
 		contractABI:     contractABI,
-	}
+	}, nil
 }
 
+// ExecuteArbitrage ... calls swapIn of the trade paths with eth_call (nothing is sent), an unprofitable route reverts
 func (a *ArbitrageExecutorWeb3Service) ExecuteArbitrage(
 	tradePaths []sp.TradePath,
-	amountIn float64,
+	amountIn units.Amount,
 	loanAddress common.Address,
 ) error {
-	var amountInParsed = big.NewInt(int64(amountIn * math.Pow(10, float64(tradePaths[0].BaseAssetDecimals))))
+	data, err := a.packSwapIn(tradePaths, amountIn, loanAddress)
+
+	if err != nil {
+		return err
+	}
+	message := ethereum.CallMsg{To: &a.contractAddress, Data: data}
+	// TODO: We've got work over here, what to do with the estimated gas?
+	//var startEstimatingTime = time.Now()
+	//estimatedGas, err := a.client.EstimateGas(context.Background(), message)
+	//fmt.Println("Estimating time:", time.Since(startEstimatingTime))
+	//fmt.Println("Estimated gas:", estimatedGas)
+	//helpers.Panic(err)
+	if _, err = a.client.CallContract(context.Background(), message, nil); err != nil {
+		return wrapCallError(err, "swapIn")
+	}
+
+	return nil
+}
+
+// EstimateArbitrage ... estimates the gas of the swapIn call, an unprofitable route reverts
+func (a *ArbitrageExecutorWeb3Service) EstimateArbitrage(
+	tradePaths []sp.TradePath,
+	amountIn units.Amount,
+	loanAddress common.Address,
+) (uint64, error) {
+	data, err := a.packSwapIn(tradePaths, amountIn, loanAddress)
+
+	if err != nil {
+		return 0, err
+	}
+
+	gas, err := a.client.EstimateGas(context.Background(), ethereum.CallMsg{To: &a.contractAddress, Data: data})
+
+	if err != nil {
+		return 0, wrapCallError(err, "swapIn")
+	}
+
+	return gas, nil
+}
+
+// packSwapIn ... packs the swapIn call of the trade paths
+func (a *ArbitrageExecutorWeb3Service) packSwapIn(
+	tradePaths []sp.TradePath,
+	amountIn units.Amount,
+	loanAddress common.Address,
+) ([]byte, error) {
 	var swapParams []SwapParams
 
 	for _, tradePath := range tradePaths {
@@ -68,23 +120,19 @@ func (a *ArbitrageExecutorWeb3Service) ExecuteArbitrage(
 		})
 	}
 
-	data, err := a.contractABI.Pack("swapIn", swapParams, amountInParsed, loanAddress)
-	helpers.Panic(err)
-	message := ethereum.CallMsg{To: &a.contractAddress, Data: data}
-	// TODO: We've got work over here, what to do with the estimated gas?
-	//var startEstimatingTime = time.Now()
-	//estimatedGas, err := a.client.EstimateGas(context.Background(), message)
-	//fmt.Println("Estimating time:", time.Since(startEstimatingTime))
-	//fmt.Println("Estimated gas:", estimatedGas)
-	//helpers.Panic(err)
-	_, err = a.client.CallContract(context.Background(), message, nil)
-
-	return err
+	return a.contractABI.Pack("swapIn", swapParams, amountIn.Wei(), loanAddress)
 }
 
 // GetLoanAddress ... gets other loan address as PancakeSwap or UniswapV2 don't support borrowing the token in the path
 // in FlashSwap
-func (a *ArbitrageExecutorWeb3Service) GetLoanAddress(symbols []*sp.Symbol, paths []sp.TradePath) common.Address {
+func (a *ArbitrageExecutorWeb3Service) GetLoanAddress(
+	symbols []*sp.Symbol, paths []sp.TradePath,
+) (common.Address, error) {
+	return FindLoanAddress(symbols, paths)
+}
+
+// FindLoanAddress ... returns the first token of the symbols outside the trade paths (the flash swap borrows it)
+func FindLoanAddress(symbols []*sp.Symbol, paths []sp.TradePath) (common.Address, error) {
 	var pathAddresses []common.Address
 	var result common.Address
 
@@ -107,8 +155,8 @@ func (a *ArbitrageExecutorWeb3Service) GetLoanAddress(symbols []*sp.Symbol, path
 	}
 
 	if (result == common.Address{}) {
-		helpers.Panic(fmt.Errorf("No loan address found"))
+		return result, fmt.Errorf("No loan address found")
 	}
 
-	return result
+	return result, nil
 }
@@ -1,12 +1,55 @@
 package web3
 
 import (
+	"arbitrage-bot/helpers/units"
 	sp "arbitrage-bot/services/sourceprovider"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"strings"
 	"sync"
 )
 
+// Web3 service errors, the underlying error is wrapped
+var (
+	ErrQuoteFailed    = errors.New("quote failed")    // the call reverted or the quote was invalid
+	ErrRPCUnavailable = errors.New("rpc unavailable") // the node couldn't be reached or failed to execute the call
+)
+
+// PoolReserves ... Represents the token amounts held by a pool & its spot price
+type PoolReserves struct {
+	Reserve0    float64  // in units of token0 (the base asset of the symbol)
+	Reserve1    float64  // in units of token1 (the quote asset of the symbol)
+	Price       float64  // spot price of token0 in token1
+	Reserve0Wei *big.Int // exact reserve of token0 in base units
+	Reserve1Wei *big.Int // exact reserve of token1 in base units
+}
+
 type DEXWeb3Service interface {
-	GetPrice(symbol sp.Symbol, amountIn float64, tradeDirection string, verbose bool) float64
-	GetPriceMultiplePaths(tradePaths []sp.TradePath, amountIn float64, verbose bool) float64
-	AggregatePrices(symbols []*sp.Symbol, verbose bool) *sync.Map
+	GetPrice(symbol sp.Symbol, amountIn units.Amount, tradeDirection string) (units.Amount, error)
+	GetPriceMultiplePaths(tradePaths []sp.TradePath, amountIn units.Amount) (units.Amount, error)
+	AggregatePrices(symbols []*sp.Symbol) *sync.Map
+	GetBlockNumber() (uint64, error)
+	GetGasPrice() (*big.Int, error)
+	GetPoolsReserves(symbols []*sp.Symbol) (map[string]PoolReserves, error)
+}
+
+// revertErrorCode ... JSON-RPC error code of a reverted call returning the revert data
+const revertErrorCode int = 3
+
+// revertErrorMessage ... message of a reverted call, some nodes answer it with the generic -32000 code
+const revertErrorMessage string = "execution reverted"
+
+// wrapCallError ... wraps the error of a contract call with ErrQuoteFailed if the call reverted & with
+// ErrRPCUnavailable otherwise (f.e. a transport error, a limit exceeded -32005 or an internal error -32603 of the node)
+func wrapCallError(err error, method string) error {
+	var rpcError rpc.Error
+
+	if errors.As(err, &rpcError) && (rpcError.ErrorCode() == revertErrorCode ||
+		strings.Contains(strings.ToLower(rpcError.Error()), revertErrorMessage)) {
+		return fmt.Errorf("%w: %s: %w", ErrQuoteFailed, method, err)
+	}
+
+	return fmt.Errorf("%w: %s: %w", ErrRPCUnavailable, method, err)
 }
@@ -0,0 +1,40 @@
+package web3
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testRPCError ... JSON-RPC error answered by a node
+type testRPCError struct {
+	code    int
+	message string
+}
+
+func (e testRPCError) Error() string  { return e.message }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+func TestWrapCallError(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "revert", err: testRPCError{code: 3, message: "execution reverted: K"}, want: ErrQuoteFailed},
+		{name: "revert -32000", err: testRPCError{code: -32000, message: "execution reverted"}, want: ErrQuoteFailed},
+		{name: "limit exceeded", err: testRPCError{code: -32005, message: "limit exceeded"}, want: ErrRPCUnavailable},
+		{name: "internal error", err: testRPCError{code: -32603, message: "internal error"}, want: ErrRPCUnavailable},
+		{name: "header not found", err: testRPCError{code: -32000, message: "header not found"},
+			want: ErrRPCUnavailable},
+		{name: "transport", err: errors.New("connection refused"), want: ErrRPCUnavailable},
+		{name: "wrapped revert", err: fmt.Errorf("eth_call: %w", testRPCError{code: 3, message: "execution reverted"}),
+			want: ErrQuoteFailed},
+	} {
+		var err = wrapCallError(test.err, "getAmountsOut")
+
+		if !errors.Is(err, test.want) || !errors.Is(err, test.err) {
+			t.Errorf("%s: wrapCallError = %v, want %v wrapping the error", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/rpcpool"
+	sp "arbitrage-bot/services/sourceprovider"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Inclusion states of a bundle
+const (
+	BundlePending  string = "pending"  // a target block isn't mined yet
+	BundleIncluded string = "included" // the transaction was mined & succeeded
+	BundleReverted string = "reverted" // the transaction was mined & reverted
+	BundleExpired  string = "expired"  // every target block was mined without the transaction
+)
+
+// FlashbotsSignatureHeader ... header authenticating the bundles (address:signature of the body hash)
+const FlashbotsSignatureHeader string = "X-Flashbots-Signature"
+
+// Bundle ... Represents a signed swapIn transaction submitted to the relay
+type Bundle struct {
+	TxHash       common.Hash `json:"txHash"`
+	Nonce        uint64      `json:"nonce"`
+	BundleHash   string      `json:"bundleHash"`
+	TargetBlocks []uint64    `json:"targetBlocks"`
+	GasLimit     uint64      `json:"gasLimit"`
+	Tip          *big.Int    `json:"tip"` // wei transferred to block.coinbase by swapIn if it succeeds
+	SubmittedAt  time.Time   `json:"submittedAt"`
+	GasCost      *big.Int    `json:"gasCost"` // wei paid for the gas & the tip once mined, nil before
+}
+
+// BundleSubmitter ... Signs the swapIn transactions & sends them to a private relay (eth_sendBundle), keeping them
+// out of the public mempool
+type BundleSubmitter struct {
+	client     *ethclient.Client
+	executor   *ArbitrageExecutorWeb3Service
+	relayURL   string
+	authKey    *ecdsa.PrivateKey // relay reputation
+	nonces     *NonceManager     // signs with the executor wallet, shared by the services using it
+	blocks     int
+	httpClient *http.Client
+
+	backRunGasLimit uint64 // the estimate of a back-run reverts before the pending swap is mined
+}
+
+// NewBundleSubmitter ... creates a new bundle submitter for the relay of the selected network
+func NewBundleSubmitter() (*BundleSubmitter, error) {
+	var network = config.Get().ActiveNetwork()
+	nonces, err := getWalletNonceManager()
+
+	if err != nil {
+		return nil, err
+	}
+	authKey, err := crypto.HexToECDSA(strings.TrimPrefix(network.Relay.AuthKey, "0x"))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay.authKey: %w", err)
+	}
+	executor, err := NewArbitrageExecutorWeb3Service()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BundleSubmitter{
+		client:     rpcpool.Get().Client(),
+		executor:   executor,
+		relayURL:   network.Relay.URL,
+		authKey:    authKey,
+		nonces:     nonces,
+		blocks:     network.Relay.Blocks,
+		httpClient: &http.Client{Timeout: network.RPC.Timeout},
+
+		backRunGasLimit: network.Mempool.GasLimit,
+	}, nil
+}
+
+// Executor ... returns the service packing & estimating the swapIn calls
+func (b *BundleSubmitter) Executor() *ArbitrageExecutorWeb3Service {
+	return b.executor
+}
+
+// SubmitArbitrage ... signs the swapIn transaction of the trade paths & sends it as a bundle targeting each of the
+// next blocks, the tip (in wei, nil for none) is sent with swapIn which transfers it to block.coinbase once the
+// arbitrage succeeded. A pending transaction to back-run (nil for none) is bundled right before the swapIn, which
+// gets the configured gas limit (the estimate doesn't see the pending swap)
+func (b *BundleSubmitter) SubmitArbitrage(
+	tradePaths []sp.TradePath,
+	amountIn units.Amount,
+	loanAddress common.Address,
+	tip *big.Int,
+	backRun *types.Transaction,
+) (*Bundle, error) {
+	data, err := b.executor.packSwapIn(tradePaths, amountIn, loanAddress)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := b.client.BlockNumber(context.Background())
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting block number: %w", err)
+	}
+
+	var gasLimit uint64 // estimated
+
+	if backRun != nil {
+		gasLimit = b.backRunGasLimit
+	}
+
+	tx, err := b.nonces.SignTransaction(b.executor.contractAddress, tip, data, gasLimit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rawTxs []string
+
+	for _, bundleTx := range []*types.Transaction{backRun, tx} {
+		if bundleTx == nil {
+			continue
+		}
+
+		rawTx, err := bundleTx.MarshalBinary()
+
+		if err != nil {
+			b.nonces.Release(tx.Nonce(), common.Hash{})
+			return nil, err
+		}
+		rawTxs = append(rawTxs, hexutil.Encode(rawTx))
+	}
+
+	// the nonce is persisted as in flight before the transaction leaves the process
+	if err := b.nonces.Track(tx, blockNumber, true); err != nil {
+		b.nonces.Release(tx.Nonce(), common.Hash{})
+		return nil, err
+	}
+
+	var bundle = &Bundle{
+		TxHash:      tx.Hash(),
+		Nonce:       tx.Nonce(),
+		GasLimit:    tx.Gas(),
+		Tip:         tx.Value(),
+		SubmittedAt: time.Now(),
+	}
+	var errs []error
+
+	// a bundle is only valid for one block, it's sent for each target
+	for i := 1; i <= b.blocks; i++ {
+		var targetBlock = blockNumber + uint64(i)
+		bundleHash, err := b.sendBundle(rawTxs, targetBlock)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("block %d: %w", targetBlock, err))
+			continue
+		}
+		bundle.BundleHash = bundleHash
+		bundle.TargetBlocks = append(bundle.TargetBlocks, targetBlock)
+	}
+	if len(bundle.TargetBlocks) == 0 {
+		b.nonces.Release(tx.Nonce(), tx.Hash())
+		return nil, fmt.Errorf("error sending bundle: %w", errors.Join(errs...))
+	}
+
+	return bundle, nil
+}
+
+// Close ... saves the nonces of the wallet & stops their watch
+func (b *BundleSubmitter) Close() error {
+	return b.nonces.Close()
+}
+
+// InclusionStatus ... returns the inclusion state of a bundle & the block including it, the gas cost of a mined
+// bundle is set
+func (b *BundleSubmitter) InclusionStatus(bundle *Bundle) (string, uint64, error) {
+	receipt, err := b.client.TransactionReceipt(context.Background(), bundle.TxHash)
+
+	if err == nil {
+		if receipt.EffectiveGasPrice != nil {
+			bundle.GasCost = new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		}
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			// the tip is only transferred by a successful swapIn
+			if bundle.GasCost != nil && bundle.Tip != nil {
+				bundle.GasCost.Add(bundle.GasCost, bundle.Tip)
+			}
+
+			return BundleIncluded, receipt.BlockNumber.Uint64(), nil
+		}
+
+		return BundleReverted, receipt.BlockNumber.Uint64(), nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return BundlePending, 0, fmt.Errorf("error getting receipt: %w", err)
+	}
+
+	blockNumber, err := b.client.BlockNumber(context.Background())
+
+	if err != nil {
+		return BundlePending, 0, fmt.Errorf("error getting block number: %w", err)
+	}
+	if len(bundle.TargetBlocks) > 0 && blockNumber > bundle.TargetBlocks[len(bundle.TargetBlocks)-1] {
+		// the transaction can't be mined anymore, its nonce is allocated again
+		b.nonces.Release(bundle.Nonce, bundle.TxHash)
+		return BundleExpired, 0, nil
+	}
+
+	return BundlePending, 0, nil
+}
+
+// sendBundle ... sends an eth_sendBundle request for a target block, returns the bundle hash
+func (b *BundleSubmitter) sendBundle(rawTxs []string, targetBlock uint64) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_sendBundle",
+		"params": []interface{}{map[string]interface{}{
+			"txs":         rawTxs,
+			"blockNumber": hexutil.EncodeUint64(targetBlock),
+		}},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := SignFlashbotsPayload(body, b.authKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, b.relayURL, bytes.NewReader(body))
+
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(FlashbotsSignatureHeader, signature)
+
+	response, err := b.httpClient.Do(request)
+
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Result *struct {
+			BundleHash string `json:"bundleHash"`
+		} `json:"result"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return "", fmt.Errorf("relay answered %d: %s", response.StatusCode, responseBody)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("relay error %d: %s", result.Error.Code, result.Error.Message)
+	}
+	if result.Result == nil {
+		return "", fmt.Errorf("relay answered %d without result", response.StatusCode)
+	}
+
+	return result.Result.BundleHash, nil
+}
+
+// SignFlashbotsPayload ... returns the X-Flashbots-Signature header of a request body: the address of the key & its
+// EIP-191 signature of the hex keccak256 hash of the body
+func SignFlashbotsPayload(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	var hash = crypto.Keccak256Hash(body).Hex()
+	signature, err := crypto.Sign(accounts.TextHash([]byte(hash)), key)
+
+	if err != nil {
+		return "", err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return crypto.PubkeyToAddress(key.PublicKey).Hex() + ":" + hexutil.Encode(signature), nil
+}
+
+// RecoverFlashbotsSigner ... returns the address which signed a request body with SignFlashbotsPayload
+func RecoverFlashbotsSigner(body []byte, header string) (common.Address, error) {
+	var address, encoded, ok = strings.Cut(header, ":")
+
+	if !ok || !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("malformed %s header", FlashbotsSignatureHeader)
+	}
+
+	signature, err := hexutil.Decode(encoded)
+
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("malformed %s signature", FlashbotsSignatureHeader)
+	}
+	signature[crypto.RecoveryIDOffset] -= 27
+
+	var hash = crypto.Keccak256Hash(body).Hex()
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(hash)), signature)
+
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	var signer = crypto.PubkeyToAddress(*publicKey)
+
+	if signer != common.HexToAddress(address) {
+		return common.Address{}, fmt.Errorf("signature of %s doesn't match %s", signer.Hex(), address)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,312 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/units"
+	sp "arbitrage-bot/services/sourceprovider"
+	"bytes"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestExecutor ... returns the web3 service of the arbitrage executor deployed at the test contract address
+func newTestExecutor(t *testing.T, client *ethclient.Client) *ArbitrageExecutorWeb3Service {
+	t.Helper()
+
+	contractABI, err := jsonHelper.ReadJSONABIFile("../../data/web3/arbitrageExecutorABI.json")
+
+	if err != nil {
+		t.Fatalf("ReadJSONABIFile: %v", err)
+	}
+
+	return &ArbitrageExecutorWeb3Service{client: client, contractABI: contractABI, contractAddress: testContractAddress}
+}
+
+// relayRequest ... Represents a request received by the relay stub
+type relayRequest struct {
+	body      []byte
+	signature string
+}
+
+// newTestSubmitter ... creates a bundle submitter sending to a relay stub targeting the blocks, returns the requests
+// received by the relay
+func newTestSubmitter(
+	t *testing.T, client *ethclient.Client, blocks int,
+) (*BundleSubmitter, *RelayStub, *[]relayRequest) {
+	t.Helper()
+
+	var relay = NewRelayStub()
+	var requests []relayRequest
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body, _ = io.ReadAll(r.Body)
+		requests = append(requests, relayRequest{body: body, signature: r.Header.Get(FlashbotsSignatureHeader)})
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		relay.handleRPC(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	var nonces = newTestNonceManager(t, client, config.NonceConfig{StuckBlocks: 5, FeeBump: 0.125})
+	authKey, err := crypto.GenerateKey()
+
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	return &BundleSubmitter{
+		client:     client,
+		executor:   newTestExecutor(t, client),
+		relayURL:   server.URL,
+		authKey:    authKey,
+		nonces:     nonces,
+		blocks:     blocks,
+		httpClient: server.Client(),
+
+		backRunGasLimit: testBackRunGas,
+	}, relay, &requests
+}
+
+// testRoute ... returns the swaps of a triangle & its amount in
+func testRoute() ([]sp.TradePath, units.Amount) {
+	var tokens = []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		common.HexToAddress("0x0000000000000000000000000000000000000002"),
+		common.HexToAddress("0x0000000000000000000000000000000000000003"),
+	}
+	var tradePaths []sp.TradePath
+
+	for i := range tokens {
+		tradePaths = append(tradePaths, sp.TradePath{
+			BaseAssetAddress:   tokens[i],
+			BaseAssetDecimals:  18,
+			QuoteAssetAddress:  tokens[(i+1)%len(tokens)],
+			QuoteAssetDecimals: 18,
+		})
+	}
+
+	return tradePaths, units.FromFloat(1, 18)
+}
+
+// signedTransfer ... returns a transfer signed by a new key (f.e. a pending swap of someone else)
+func signedTransfer(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+
+	var key, err = crypto.GenerateKey()
+
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(testChainID)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(testChainID),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10000000000),
+		Gas:       cancelGas,
+		To:        &testContractAddress,
+	})
+
+	if err != nil {
+		t.Fatalf("SignNewTx: %v", err)
+	}
+
+	return tx
+}
+
+func TestSubmitArbitragePayload(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var submitter, relay, requests = newTestSubmitter(t, client, 3)
+	var tradePaths, amountIn = testRoute()
+	var tip = big.NewInt(5000000000000000)
+	var backRun = signedTransfer(t, 7)
+
+	bundle, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"), tip, backRun)
+
+	if err != nil {
+		t.Fatalf("SubmitArbitrage: %v", err)
+	}
+
+	// one eth_sendBundle per target block, signed by the auth key of the relay
+	if len(*requests) != 3 {
+		t.Fatalf("relay received %d requests, want 3", len(*requests))
+	}
+	for i, request := range *requests {
+		var payload struct {
+			JSONRPC string `json:"jsonrpc"`
+			Method  string `json:"method"`
+			Params  []map[string]json.RawMessage
+		}
+
+		if err := json.Unmarshal(request.body, &payload); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if payload.JSONRPC != "2.0" || payload.Method != "eth_sendBundle" || len(payload.Params) != 1 {
+			t.Fatalf("request %d = %s, want one eth_sendBundle bundle", i, request.body)
+		}
+
+		var txs []string
+		var blockNumber string
+		_ = json.Unmarshal(payload.Params[0]["txs"], &txs)
+		_ = json.Unmarshal(payload.Params[0]["blockNumber"], &blockNumber)
+
+		if len(payload.Params[0]) != 2 || len(txs) != 2 {
+			t.Errorf("request %d params = %s, want the 2 txs & the block number", i, request.body)
+		}
+		if want := hexutil.EncodeUint64(node.blockNumber + uint64(i) + 1); blockNumber != want {
+			t.Errorf("request %d blockNumber = %s, want %s", i, blockNumber, want)
+		}
+
+		signer, err := RecoverFlashbotsSigner(request.body, request.signature)
+
+		if err != nil || signer != crypto.PubkeyToAddress(submitter.authKey.PublicKey) {
+			t.Errorf("request %d signed by %s (%v), want the auth key", i, signer.Hex(), err)
+		}
+	}
+
+	// the back-run is bundled right before the swapIn, which carries the coinbase tip as value
+	var bundles = relay.Bundles()
+
+	if len(bundles) != 3 {
+		t.Fatalf("relay accepted %d bundles, want 3", len(bundles))
+	}
+	for i, received := range bundles {
+		if received.BlockNumber != bundle.TargetBlocks[i] {
+			t.Errorf("bundle %d targets block %d, want %d", i, received.BlockNumber, bundle.TargetBlocks[i])
+		}
+		if received.Txs[0].Hash() != backRun.Hash() || received.Txs[1].Hash() != bundle.TxHash {
+			t.Errorf("bundle %d = %v, want the back-run then the swapIn", i, received.Txs)
+		}
+	}
+
+	var swapIn = bundles[0].Txs[1]
+
+	if *swapIn.To() != testContractAddress || swapIn.Value().Cmp(tip) != 0 || bundle.Tip.Cmp(tip) != 0 {
+		t.Errorf("swapIn to %s with value %s, want the executor contract & a tip of %s", swapIn.To(), swapIn.Value(), tip)
+	}
+	// the estimate would revert before the pending swap is mined
+	if len(node.estimates) != 0 || swapIn.Gas() != testBackRunGas {
+		t.Errorf("gas limit %d after %d estimates, want the back-run gas limit", swapIn.Gas(), len(node.estimates))
+	}
+	if swapIn.GasTipCap().Int64() != testPriorityFee {
+		t.Errorf("GasTipCap = %s, want the suggested priority fee", swapIn.GasTipCap())
+	}
+	if method, err := submitter.executor.contractABI.MethodById(swapIn.Data()); err != nil || method.Name != "swapIn" {
+		t.Errorf("swapIn data calls %v (%v)", method, err)
+	}
+
+	// the nonce is tracked as a private transaction
+	var inFlight = submitter.nonces.InFlight()
+
+	if len(inFlight) != 1 || inFlight[0].TxHash != bundle.TxHash || !inFlight[0].Private {
+		t.Errorf("in flight = %+v, want the private swapIn", inFlight)
+	}
+}
+
+func TestSubmitArbitrageEstimatesGas(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var submitter, _, _ = newTestSubmitter(t, client, 1)
+	var tradePaths, amountIn = testRoute()
+	var tip = big.NewInt(1000)
+
+	bundle, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"), tip, nil)
+
+	if err != nil {
+		t.Fatalf("SubmitArbitrage: %v", err)
+	}
+	// the swapIn is estimated with the tip it sends, plus a margin
+	if len(node.estimates) != 1 || node.estimates[0]["value"] != hexutil.EncodeBig(tip) {
+		t.Errorf("estimated %v, want the swapIn with the tip as value", node.estimates)
+	}
+	if want := uint64(float64(testEstimateGas) * gasLimitMargin); bundle.GasLimit != want {
+		t.Errorf("GasLimit = %d, want %d", bundle.GasLimit, want)
+	}
+}
+
+func TestSubmitArbitrageRelayError(t *testing.T) {
+	var _, client = newFakeNode(t)
+	var submitter, _, _ = newTestSubmitter(t, client, 2)
+	var tradePaths, amountIn = testRoute()
+
+	// the relay rejects a bundle whose signature doesn't match the header address
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var _, signature, _ = strings.Cut(r.Header.Get(FlashbotsSignatureHeader), ":")
+		r.Header.Set(FlashbotsSignatureHeader, testContractAddress.Hex()+":"+signature)
+		NewRelayStub().handleRPC(w, r)
+	}))
+	t.Cleanup(server.Close)
+	submitter.relayURL = server.URL
+
+	if _, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"), nil, nil); err == nil {
+		t.Fatal("SubmitArbitrage succeeded with a signature of another address")
+	}
+	// the nonce of a bundle no relay accepted is allocated again
+	if inFlight := submitter.nonces.InFlight(); len(inFlight) != 0 {
+		t.Errorf("in flight = %+v, want the nonce released", inFlight)
+	}
+}
+
+func TestInclusionStatus(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		mine      uint64 // blocks mined after the submission
+		mined     bool   // the swapIn is mined
+		status    uint64
+		inclusion string
+		gasCost   int64 // wei of the gas (& tip if included)
+	}{
+		{name: "pending", mine: 2, inclusion: BundlePending},
+		{name: "included", mine: 1, mined: true, status: types.ReceiptStatusSuccessful, inclusion: BundleIncluded,
+			gasCost: 150000*4000000000 + 1000},
+		{name: "reverted", mine: 1, mined: true, status: types.ReceiptStatusFailed, inclusion: BundleReverted,
+			gasCost: 150000 * 4000000000},
+		{name: "expired", mine: 3, inclusion: BundleExpired},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var node, client = newFakeNode(t)
+			var submitter, _, _ = newTestSubmitter(t, client, 2)
+			var tradePaths, amountIn = testRoute()
+
+			bundle, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"),
+				big.NewInt(1000), nil)
+
+			if err != nil {
+				t.Fatalf("SubmitArbitrage: %v", err)
+			}
+
+			var txHash common.Hash
+
+			if test.mined {
+				txHash = bundle.TxHash
+			}
+			node.mine(test.mine, txHash, test.status)
+			inclusion, blockNumber, err := submitter.InclusionStatus(bundle)
+
+			if err != nil || inclusion != test.inclusion {
+				t.Fatalf("InclusionStatus = %s (%v), want %s", inclusion, err, test.inclusion)
+			}
+			if test.mined && blockNumber != node.blockNumber {
+				t.Errorf("block = %d, want %d", blockNumber, node.blockNumber)
+			}
+			if test.gasCost > 0 && (bundle.GasCost == nil || bundle.GasCost.Int64() != test.gasCost) {
+				t.Errorf("GasCost = %v, want %d", bundle.GasCost, test.gasCost)
+			}
+
+			// only an expired bundle gives its nonce back
+			var released = len(submitter.nonces.InFlight()) == 0
+
+			if released != (test.inclusion == BundleExpired) {
+				t.Errorf("nonce released = %v after %s", released, test.inclusion)
+			}
+		})
+	}
+}
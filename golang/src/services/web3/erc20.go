@@ -0,0 +1,100 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/rpcpool"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+// erc20MetricsLabel ... provider label of the ERC20 reads in the RPC metrics
+const erc20MetricsLabel string = "erc20"
+
+// TokenBalance ... Represents the balance of a token held by an account
+type TokenBalance struct {
+	Token   common.Address
+	Symbol  string
+	Owner   common.Address
+	Balance units.Amount
+}
+
+// ERC20Web3Service ... Reads the ERC20 balances of accounts through multicall
+type ERC20Web3Service struct {
+	multicall *MulticallClient
+	erc20ABI  abi.ABI
+}
+
+// NewERC20Web3Service ... creates a new ERC20Web3Service for the selected network
+func NewERC20Web3Service() (*ERC20Web3Service, error) {
+	var network = config.Get().ActiveNetwork()
+	erc20ABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.ERC20)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the erc20 abi: %w", err)
+	}
+	multicall, err := NewMulticallClient(rpcpool.Get().Client(), erc20MetricsLabel)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ERC20Web3Service{
+		multicall: multicall,
+		erc20ABI:  erc20ABI,
+	}, nil
+}
+
+// GetBalances ... reads the balance of every token held by every owner, the tokens failing a call (f.e. not a
+// contract) are left out
+func (e *ERC20Web3Service) GetBalances(tokens []common.Address, owners []common.Address) ([]TokenBalance, error) {
+	tokenInfos, err := e.multicall.getTokens(tokens, e.erc20ABI)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []*Call
+	var pairs [][2]common.Address // token, owner of every call
+
+	for _, token := range tokens {
+		if _, ok := tokenInfos[token]; !ok {
+			continue
+		}
+		for _, owner := range owners {
+			call, err := NewCall(token, e.erc20ABI, "balanceOf", owner)
+
+			if err != nil {
+				return nil, err
+			}
+			calls = append(calls, call)
+			pairs = append(pairs, [2]common.Address{token, owner})
+		}
+	}
+	if err := e.multicall.Aggregate(calls); err != nil {
+		return nil, fmt.Errorf("error reading balances: %w", err)
+	}
+
+	var balances []TokenBalance
+
+	for i, call := range calls {
+		result, err := call.Unpack(e.erc20ABI, "balanceOf")
+
+		if err != nil {
+			continue
+		}
+
+		var token, owner = pairs[i][0], pairs[i][1]
+		balances = append(balances, TokenBalance{
+			Token:   token,
+			Symbol:  tokenInfos[token].Symbol,
+			Owner:   owner,
+			Balance: units.NewAmount(result[0].(*big.Int), tokenInfos[token].Decimals),
+		})
+	}
+
+	return balances, nil
+}
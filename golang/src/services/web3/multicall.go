@@ -0,0 +1,304 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/services/metrics"
+	sp "arbitrage-bot/services/sourceprovider"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+// multicallBatchSize ... calls aggregated in a single eth_call
+const multicallBatchSize = 500
+
+// Call ... Represents a call aggregated by the MulticallClient
+type Call struct {
+	Target     common.Address
+	CallData   []byte
+	Success    bool
+	ReturnData []byte
+	Err        error // error of the batch the call was aggregated in, the call wasn't executed
+}
+
+// NewCall ... packs a contract method call
+func NewCall(target common.Address, contractABI abi.ABI, method string, params ...interface{}) (*Call, error) {
+	callData, err := contractABI.Pack(method, params...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Call{Target: target, CallData: callData}, nil
+}
+
+// Unpack ... unpacks the outputs of a successful call
+func (c *Call) Unpack(contractABI abi.ABI, method string) ([]interface{}, error) {
+	if c.Err != nil {
+		return nil, fmt.Errorf("call %s to %s wasn't executed: %w", method, c.Target, c.Err)
+	} else if !c.Success {
+		return nil, fmt.Errorf("call %s to %s failed", method, c.Target)
+	}
+
+	return contractABI.Unpack(method, c.ReturnData)
+}
+
+// multicallCall ... Call3 struct of Multicall3
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// multicallResult ... Result struct of Multicall3
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// MulticallClient ... aggregates calls with Multicall3.aggregate3, a failing call (or batch) doesn't fail the others
+type MulticallClient struct {
+	client   ethereum.ContractCaller
+	address  common.Address
+	abi      abi.ABI
+	provider string // metrics label
+}
+
+// NewMulticallClient ... creates a new MulticallClient for the selected network
+func NewMulticallClient(client ethereum.ContractCaller, provider string) (*MulticallClient, error) {
+	var network = config.Get().ActiveNetwork()
+	multicallABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.Multicall3)
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the multicall abi: %w", err)
+	}
+
+	return &MulticallClient{
+		client:   client,
+		address:  common.HexToAddress(network.Contracts.Multicall3),
+		abi:      multicallABI,
+		provider: provider,
+	}, nil
+}
+
+// Aggregate ... executes the calls in batches of multicallBatchSize & sets their results, the calls of a failing batch
+// are marked as failed with its error (Call.Err) while the other batches are still executed, returns an error if
+// every batch failed
+func (m *MulticallClient) Aggregate(calls []*Call) error {
+	var errs []error
+	var batches = 0
+
+	for start := 0; start < len(calls); start += multicallBatchSize {
+		var batch = calls[start:min(start+multicallBatchSize, len(calls))]
+		batches++
+
+		if err := m.aggregateBatch(batch); err != nil {
+			for _, call := range batch {
+				call.Success = false
+				call.ReturnData = nil
+				call.Err = err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 && len(errs) == batches {
+		return errors.Join(errs...)
+	} else if len(errs) > 0 {
+		logger.WithProvider(m.provider).Warn(
+			"Error executing multicall batches",
+			slog.Int("failed", len(errs)),
+			slog.Int("batches", batches),
+			slog.Any("error", errors.Join(errs...)),
+		)
+	}
+
+	return nil
+}
+
+// aggregateBatch ... executes a batch of calls in a single eth_call
+func (m *MulticallClient) aggregateBatch(calls []*Call) error {
+	var batch = make([]multicallCall, len(calls))
+
+	for i, call := range calls {
+		batch[i] = multicallCall{Target: call.Target, AllowFailure: true, CallData: call.CallData}
+	}
+	data, err := m.abi.Pack("aggregate3", batch)
+
+	if err != nil {
+		return err
+	}
+
+	var start = time.Now()
+	output, err := m.client.CallContract(context.Background(), ethereum.CallMsg{To: &m.address, Data: data}, nil)
+	metrics.ObserveRPCLatency(m.provider, "aggregate3", start)
+
+	if err != nil {
+		return fmt.Errorf("error executing multicall: %w", err)
+	}
+	unpacked, err := m.abi.Unpack("aggregate3", output)
+
+	if err != nil {
+		return fmt.Errorf("error unpacking multicall: %w", err)
+	}
+
+	var results = *abi.ConvertType(unpacked[0], new([]multicallResult)).(*[]multicallResult)
+
+	if len(results) != len(calls) {
+		return fmt.Errorf("multicall returned %d results for %d calls", len(results), len(calls))
+	}
+	for i, result := range results {
+		calls[i].Success = result.Success
+		calls[i].ReturnData = result.ReturnData
+		calls[i].Err = nil
+	}
+
+	return nil
+}
+
+// tokenInfo ... ERC20 metadata of a token
+type tokenInfo struct {
+	Symbol   string
+	Decimals int
+}
+
+// getTokens ... reads the symbol & decimals of the tokens, the tokens failing a call are left out
+func (m *MulticallClient) getTokens(addresses []common.Address, erc20ABI abi.ABI) (map[common.Address]tokenInfo, error) {
+	var calls []*Call
+
+	for _, address := range addresses {
+		for _, method := range []string{"symbol", "decimals"} {
+			call, err := NewCall(address, erc20ABI, method)
+
+			if err != nil {
+				return nil, err
+			}
+			calls = append(calls, call)
+		}
+	}
+	if err := m.Aggregate(calls); err != nil {
+		return nil, err
+	}
+
+	var tokens = make(map[common.Address]tokenInfo)
+
+	for i, address := range addresses {
+		resultSymbol, errSymbol := calls[2*i].Unpack(erc20ABI, "symbol")
+		resultDecimals, errDecimals := calls[2*i+1].Unpack(erc20ABI, "decimals")
+
+		if errSymbol == nil && errDecimals == nil {
+			tokens[address] = tokenInfo{Symbol: resultSymbol[0].(string), Decimals: int(resultDecimals[0].(uint8))}
+		}
+	}
+
+	return tokens, nil
+}
+
+// getPoolsData ... reads the tokens of the pools (& their fee tier if withFee) then the tokens metadata in two rounds
+// of aggregates, the symbol names are left to the caller, pools failing a call are returned in failed
+func (m *MulticallClient) getPoolsData(
+	addresses []common.Address,
+	poolABI abi.ABI,
+	erc20ABI abi.ABI,
+	withFee bool,
+) ([]*sp.Symbol, map[common.Address]error, error) {
+	var methods = []string{"token0", "token1"}
+
+	if withFee {
+		methods = append(methods, "fee")
+	}
+
+	var calls []*Call
+
+	for _, address := range addresses {
+		for _, method := range methods {
+			call, err := NewCall(address, poolABI, method)
+
+			if err != nil {
+				return nil, nil, err
+			}
+			calls = append(calls, call)
+		}
+	}
+	if err := m.Aggregate(calls); err != nil {
+		return nil, nil, err
+	}
+
+	var failed = make(map[common.Address]error)
+	var poolTokens = make(map[common.Address][2]common.Address)
+	var feeTiers = make(map[common.Address]int)
+	var tokenAddresses []common.Address
+	var seen = make(map[common.Address]bool)
+
+	for i, address := range addresses {
+		var results = make([][]interface{}, len(methods))
+		var err error
+
+		for j, method := range methods {
+			if results[j], err = calls[i*len(methods)+j].Unpack(poolABI, method); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			failed[address] = fmt.Errorf("error getting pool tokens: %w", err)
+			continue
+		}
+
+		var tokens = [2]common.Address{results[0][0].(common.Address), results[1][0].(common.Address)}
+		poolTokens[address] = tokens
+
+		if withFee {
+			feeTiers[address] = int(results[2][0].(*big.Int).Int64())
+		}
+		for _, token := range tokens {
+			if !seen[token] {
+				seen[token] = true
+				tokenAddresses = append(tokenAddresses, token)
+			}
+		}
+	}
+
+	tokens, err := m.getTokens(tokenAddresses, erc20ABI)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var symbols []*sp.Symbol
+
+	for _, address := range addresses {
+		var pair, ok = poolTokens[address]
+
+		if !ok {
+			continue
+		}
+
+		var token0, ok0 = tokens[pair[0]]
+		var token1, ok1 = tokens[pair[1]]
+
+		if !ok0 || !ok1 {
+			failed[address] = fmt.Errorf("error getting tokens %s, %s", pair[0], pair[1])
+			continue
+		}
+		symbols = append(symbols, &sp.Symbol{
+			Address:            address.String(),
+			BaseAsset:          token0.Symbol,
+			BaseAssetAddress:   pair[0].String(),
+			BaseAssetDecimals:  token0.Decimals,
+			QuoteAsset:         token1.Symbol,
+			QuoteAssetAddress:  pair[1].String(),
+			QuoteAssetDecimals: token1.Decimals,
+			FeeTier:            feeTiers[address],
+		})
+	}
+
+	return symbols, failed, nil
+}
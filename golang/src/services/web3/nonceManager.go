@@ -0,0 +1,580 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	fileHelper "arbitrage-bot/helpers/file"
+	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/rpcpool"
+	"cmp"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stuck transaction actions
+const (
+	NonceReplaced  string = "replaced"
+	NonceCancelled string = "cancelled"
+	NonceReleased  string = "released" // private transaction never mined, the nonce is allocated again
+)
+
+// cancelGas ... gas of the 0 value transfer cancelling a transaction
+const cancelGas uint64 = 21000
+
+// gasLimitMargin ... margin added to the estimated gas of a transaction
+const gasLimitMargin float64 = 1.2
+
+// InFlightTx ... Represents a nonce allocated to a transaction which isn't mined yet, a nonce without transaction is
+// reserved (allocated but not signed yet)
+type InFlightTx struct {
+	Nonce        uint64          `json:"nonce"`
+	TxHash       common.Hash     `json:"txHash"`
+	To           *common.Address `json:"to"`
+	Value        *big.Int        `json:"value"`
+	Data         hexutil.Bytes   `json:"data"`
+	Gas          uint64          `json:"gas"`
+	GasTipCap    *big.Int        `json:"gasTipCap"`
+	GasFeeCap    *big.Int        `json:"gasFeeCap"`
+	Private      bool            `json:"private"` // sent to a private relay, it's never replaced in the public mempool
+	SentBlock    uint64          `json:"sentBlock"`
+	Replacements int             `json:"replacements"`
+	Cancelled    bool            `json:"cancelled"`
+	AllocatedAt  time.Time       `json:"allocatedAt"`
+}
+
+// nonceState ... Represents the persisted state of a wallet
+type nonceState struct {
+	Next     uint64                 `json:"next"`
+	InFlight map[uint64]*InFlightTx `json:"inFlight"`
+}
+
+// NonceManager ... Allocates the nonces of a wallet locally so concurrent transactions don't collide, the in-flight
+// transactions are persisted (a restart doesn't allocate a nonce twice), resynced with the node & replaced when stuck
+type NonceManager struct {
+	client   *ethclient.Client
+	key      *ecdsa.PrivateKey
+	from     common.Address
+	chainID  *big.Int
+	settings config.NonceConfig
+	path     string
+	cancel   context.CancelFunc // stops the watch started by GetNonceManager
+	watching sync.WaitGroup
+
+	mutex    sync.Mutex
+	synced   bool
+	base     uint64 // lowest nonce which may be free (not mined as of the last resync)
+	next     uint64
+	inFlight map[uint64]*InFlightTx
+}
+
+var nonceManagers = make(map[common.Address]*NonceManager)
+var nonceManagersMutex sync.Mutex
+
+// GetNonceManager ... returns the nonce manager of a wallet, the services signing with the same key share it (the
+// first call starts watching the stuck transactions until the manager is closed)
+func GetNonceManager(key *ecdsa.PrivateKey) *NonceManager {
+	nonceManagersMutex.Lock()
+	defer nonceManagersMutex.Unlock()
+
+	var from = crypto.PubkeyToAddress(key.PublicKey)
+
+	if manager, ok := nonceManagers[from]; ok {
+		return manager
+	}
+
+	var manager = NewNonceManager(key)
+	var ctx, cancel = context.WithCancel(context.Background())
+	manager.cancel = cancel
+	nonceManagers[from] = manager
+	manager.watching.Add(1)
+
+	go func() {
+		defer manager.watching.Done()
+		manager.Watch(ctx)
+	}()
+
+	return manager
+}
+
+// getWalletNonceManager ... returns the nonce manager of the executor wallet of the selected network
+func getWalletNonceManager() (*NonceManager, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(config.Get().ActiveNetwork().Wallet.PrivateKey, "0x"))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet.privateKey: %w", err)
+	}
+
+	return GetNonceManager(key), nil
+}
+
+// NewNonceManager ... creates a new nonce manager of a wallet, resuming the state persisted for the selected network
+func NewNonceManager(key *ecdsa.PrivateKey) *NonceManager {
+	var network = config.Get().ActiveNetwork()
+	var from = crypto.PubkeyToAddress(key.PublicKey)
+	var manager = &NonceManager{
+		client:   rpcpool.Get().Client(),
+		key:      key,
+		from:     from,
+		chainID:  big.NewInt(network.ChainID),
+		settings: network.Nonces,
+		path:     NonceStatePath(from),
+		inFlight: make(map[uint64]*InFlightTx),
+	}
+
+	if fileHelper.PathExists(manager.path) {
+		var state nonceState
+
+		if err := jsonHelper.ReadJSONFile(manager.path, &state); err != nil {
+			slog.Warn("Error reading the nonce state, resyncing with the node", slog.Any("error", err))
+		} else {
+			manager.next = state.Next
+
+			// a reserved nonce was never signed, it's allocated again
+			for nonce, tx := range state.InFlight {
+				if tx.TxHash != (common.Hash{}) {
+					manager.inFlight[nonce] = tx
+				}
+			}
+		}
+	}
+
+	return manager
+}
+
+// NonceStatePath ... returns the path of the nonce state of a wallet on the selected network
+func NonceStatePath(from common.Address) string {
+	return "data/" + config.Get().Network + "/nonces_" + from.Hex() + ".json"
+}
+
+// Address ... returns the address of the wallet
+func (n *NonceManager) Address() common.Address {
+	return n.from
+}
+
+// Allocate ... reserves the lowest nonce which isn't in flight, it's released if the transaction isn't sent
+func (n *NonceManager) Allocate() (uint64, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if !n.synced {
+		if err := n.resync(); err != nil {
+			return 0, err
+		}
+	}
+
+	var nonce = n.base
+
+	for ; nonce < n.next; nonce++ {
+		if _, ok := n.inFlight[nonce]; !ok {
+			break
+		}
+	}
+	if nonce == n.next {
+		n.next++
+	}
+	n.inFlight[nonce] = &InFlightTx{Nonce: nonce, AllocatedAt: time.Now()}
+
+	if err := n.save(); err != nil {
+		delete(n.inFlight, nonce)
+		return 0, err
+	}
+
+	return nonce, nil
+}
+
+// Track ... records a signed transaction of an allocated nonce, it's persisted before the transaction is sent
+func (n *NonceManager) Track(tx *types.Transaction, sentBlock uint64, private bool) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var inFlight, ok = n.inFlight[tx.Nonce()]
+
+	if !ok {
+		return fmt.Errorf("nonce %d isn't allocated", tx.Nonce())
+	}
+	inFlight.TxHash = tx.Hash()
+	inFlight.To = tx.To()
+	inFlight.Value = tx.Value()
+	inFlight.Data = tx.Data()
+	inFlight.Gas = tx.Gas()
+	inFlight.GasTipCap = tx.GasTipCap()
+	inFlight.GasFeeCap = tx.GasFeeCap()
+	inFlight.Private = private
+	inFlight.SentBlock = sentBlock
+
+	return n.save()
+}
+
+// Release ... gives back a nonce whose transaction wasn't sent (zero hash) or expired unmined in a private relay,
+// the next allocation fills the gap. Nothing is released if the nonce was allocated again to another transaction
+func (n *NonceManager) Release(nonce uint64, txHash common.Hash) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if inFlight, ok := n.inFlight[nonce]; !ok || inFlight.TxHash != txHash {
+		return
+	}
+	delete(n.inFlight, nonce)
+
+	if err := n.save(); err != nil {
+		slog.Warn("Error saving the nonce state", slog.Any("error", err))
+	}
+}
+
+// Lookup ... returns the in-flight transaction of a nonce, its hash follows the replacements
+func (n *NonceManager) Lookup(nonce uint64) (InFlightTx, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var inFlight, ok = n.inFlight[nonce]
+
+	if !ok {
+		return InFlightTx{}, false
+	}
+
+	return *inFlight, true
+}
+
+// InFlight ... returns the in-flight transactions sorted by nonce
+func (n *NonceManager) InFlight() []InFlightTx {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var result []InFlightTx
+
+	for _, tx := range n.inFlight {
+		result = append(result, *tx)
+	}
+	slices.SortFunc(result, func(a InFlightTx, b InFlightTx) int {
+		return cmp.Compare(a.Nonce, b.Nonce)
+	})
+
+	return result
+}
+
+// SignTransaction ... signs an EIP-1559 call of the wallet with an allocated nonce (released unless the transaction
+// is tracked), the priority fee is the suggested one & the fee cap covers twice the base fee. A 0 gas limit is
+// estimated (with a margin)
+func (n *NonceManager) SignTransaction(
+	to common.Address, value *big.Int, data []byte, gasLimit uint64,
+) (*types.Transaction, error) {
+	var ctx = context.Background()
+
+	if value == nil {
+		value = new(big.Int)
+	}
+	if gasLimit == 0 {
+		gas, err := n.client.EstimateGas(ctx, ethereum.CallMsg{From: n.from, To: &to, Value: value, Data: data})
+
+		if err != nil {
+			return nil, fmt.Errorf("error estimating gas: %w", err)
+		}
+		gasLimit = uint64(float64(gas) * gasLimitMargin)
+	}
+
+	header, err := n.client.HeaderByNumber(ctx, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest header: %w", err)
+	}
+
+	gasTipCap, err := n.client.SuggestGasTipCap(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting gas tip: %w", err)
+	}
+
+	var baseFee = new(big.Int)
+
+	if header.BaseFee != nil {
+		baseFee.Set(header.BaseFee)
+	}
+
+	nonce, err := n.Allocate()
+
+	if err != nil {
+		return nil, fmt.Errorf("error allocating nonce: %w", err)
+	}
+
+	// the base fee can rise by 12.5% per block, twice the current one covers the next blocks
+	var gasFeeCap = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), gasTipCap)
+	var tx = types.NewTx(&types.DynamicFeeTx{
+		ChainID:   n.chainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gasLimit,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	})
+
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(n.chainID), n.key)
+
+	if err != nil {
+		n.Release(nonce, common.Hash{})
+		return nil, err
+	}
+
+	return signedTx, nil
+}
+
+// Send ... tracks a signed transaction as public & sends it to the mempool of the node, the nonce is released if the
+// node rejects it. A transaction still pending after StuckBlocks blocks is replaced by the watch
+func (n *NonceManager) Send(tx *types.Transaction, sentBlock uint64) error {
+	// the nonce is persisted as in flight before the transaction leaves the process
+	if err := n.Track(tx, sentBlock, false); err != nil {
+		n.Release(tx.Nonce(), common.Hash{})
+		return err
+	}
+	if err := n.client.SendTransaction(context.Background(), tx); err != nil {
+		n.Release(tx.Nonce(), tx.Hash())
+		return fmt.Errorf("error sending transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Resync ... drops the mined nonces & moves the next nonce past the pending transactions of the node (f.e. sent by
+// another process with the same wallet)
+func (n *NonceManager) Resync() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.resync()
+}
+
+// resync ... see Resync, the mutex must be held
+func (n *NonceManager) resync() error {
+	var ctx = context.Background()
+	confirmed, err := n.client.NonceAt(ctx, n.from, nil)
+
+	if err != nil {
+		return fmt.Errorf("error getting nonce: %w", err)
+	}
+
+	pending, err := n.client.PendingNonceAt(ctx, n.from)
+
+	if err != nil {
+		return fmt.Errorf("error getting pending nonce: %w", err)
+	}
+
+	for nonce := range n.inFlight {
+		if nonce < confirmed {
+			delete(n.inFlight, nonce)
+		}
+	}
+
+	// the pending transactions beyond the local ones were sent by another process, the nonces below aren't free
+	if pending > n.next {
+		n.base = pending
+	} else {
+		n.base = confirmed
+	}
+	n.next = max(n.next, pending)
+	n.synced = true
+
+	return n.save()
+}
+
+// Watch ... resyncs & replaces the stuck transactions on every check interval until the context is done (blocking)
+func (n *NonceManager) Watch(ctx context.Context) {
+	var ticker = time.NewTicker(n.settings.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := n.Resync(); err != nil {
+			slog.Warn("Error resyncing the nonces", slog.String("wallet", n.from.Hex()), slog.Any("error", err))
+			continue
+		}
+		if err := n.ReplaceStuck(); err != nil {
+			slog.Warn("Error replacing stuck transactions", slog.String("wallet", n.from.Hex()), slog.Any("error", err))
+		}
+	}
+}
+
+// Close ... stops the watch & saves the state, the next GetNonceManager of the wallet resumes it
+func (n *NonceManager) Close() error {
+	nonceManagersMutex.Lock()
+
+	if nonceManagers[n.from] == n {
+		delete(nonceManagers, n.from)
+	}
+	nonceManagersMutex.Unlock()
+
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.watching.Wait()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.save()
+}
+
+// ReplaceStuck ... replaces the transactions pending for StuckBlocks blocks with bumped fees, a transaction replaced
+// MaxReplacements times is cancelled. A private transaction never reached the public mempool, its nonce is released
+func (n *NonceManager) ReplaceStuck() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	var ctx = context.Background()
+	header, err := n.client.HeaderByNumber(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("error getting latest header: %w", err)
+	}
+
+	var blockNumber = header.Number.Uint64()
+	var errs []error
+
+	for nonce, inFlight := range n.inFlight {
+		if inFlight.TxHash == (common.Hash{}) || blockNumber < inFlight.SentBlock+n.settings.StuckBlocks {
+			continue
+		}
+
+		var log = slog.With(slog.Uint64("nonce", nonce), slog.String("txHash", inFlight.TxHash.Hex()))
+
+		if inFlight.Private {
+			delete(n.inFlight, nonce)
+			metrics.IncStuckTransactions(NonceReleased)
+			log.Info("Private transaction wasn't mined, releasing its nonce")
+			continue
+		}
+
+		var action = NonceReplaced
+
+		if inFlight.Cancelled || inFlight.Replacements >= n.settings.MaxReplacements {
+			action = NonceCancelled
+		}
+
+		tx, err := n.replacement(inFlight, header.BaseFee, action == NonceCancelled)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("nonce %d: %w", nonce, err))
+			continue
+		}
+
+		// persisted before it's sent, a restart knows the replacement
+		var previous = *inFlight
+		inFlight.TxHash = tx.Hash()
+		inFlight.To = tx.To()
+		inFlight.Value = tx.Value()
+		inFlight.Data = tx.Data()
+		inFlight.Gas = tx.Gas()
+		inFlight.GasTipCap = tx.GasTipCap()
+		inFlight.GasFeeCap = tx.GasFeeCap()
+		inFlight.SentBlock = blockNumber
+		inFlight.Replacements++
+		inFlight.Cancelled = action == NonceCancelled
+
+		if err := n.save(); err != nil {
+			*inFlight = previous
+			errs = append(errs, err)
+			continue
+		}
+		if err := n.client.SendTransaction(ctx, tx); err != nil {
+			errs = append(errs, fmt.Errorf("nonce %d: error sending replacement: %w", nonce, err))
+			continue
+		}
+		metrics.IncStuckTransactions(action)
+		log.Info(
+			"Replaced stuck transaction",
+			slog.String("action", action),
+			slog.String("replacementHash", tx.Hash().Hex()),
+			slog.String("gasTipCap", tx.GasTipCap().String()),
+			slog.String("gasFeeCap", tx.GasFeeCap().String()),
+		)
+	}
+
+	if err := n.save(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// replacement ... signs the replacement of an in-flight transaction with both fees bumped (the fee cap covers twice
+// the base fee at least), a cancellation is a 0 value transfer to the wallet
+func (n *NonceManager) replacement(inFlight *InFlightTx, baseFee *big.Int, cancel bool) (*types.Transaction, error) {
+	var gasTipCap = bumpFee(inFlight.GasTipCap, n.settings.FeeBump)
+	var gasFeeCap = bumpFee(inFlight.GasFeeCap, n.settings.FeeBump)
+
+	if baseFee != nil {
+		var minFeeCap = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), gasTipCap)
+
+		if gasFeeCap.Cmp(minFeeCap) < 0 {
+			gasFeeCap = minFeeCap
+		}
+	}
+	if gasFeeCap.Cmp(gasTipCap) < 0 {
+		gasFeeCap = new(big.Int).Set(gasTipCap)
+	}
+
+	var tx = &types.DynamicFeeTx{
+		ChainID:   n.chainID,
+		Nonce:     inFlight.Nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       inFlight.Gas,
+		To:        inFlight.To,
+		Value:     inFlight.Value,
+		Data:      inFlight.Data,
+	}
+
+	if cancel {
+		tx.Gas = cancelGas
+		tx.To = &n.from
+		tx.Value = new(big.Int)
+		tx.Data = nil
+	}
+
+	return types.SignTx(types.NewTx(tx), types.LatestSignerForChainID(n.chainID), n.key)
+}
+
+// save ... persists the state, the mutex must be held
+func (n *NonceManager) save() error {
+	var state = nonceState{Next: n.next, InFlight: n.inFlight}
+
+	if err := jsonHelper.WriteJSONFileAtomic(n.path, state); err != nil {
+		return fmt.Errorf("error saving the nonce state: %w", err)
+	}
+
+	return nil
+}
+
+// bumpFee ... returns a fee raised by a share, by 1 wei at least (a 0 fee stays replaceable)
+func bumpFee(fee *big.Int, share float64) *big.Int {
+	if fee == nil {
+		fee = new(big.Int)
+	}
+
+	var bumped, _ = new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(1+share)).Int(nil)
+
+	if bumped.Cmp(fee) <= 0 {
+		bumped.Add(fee, big.NewInt(1))
+	}
+
+	return bumped
+}
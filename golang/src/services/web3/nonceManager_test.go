@@ -0,0 +1,399 @@
+package web3
+
+import (
+	"arbitrage-bot/config"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const (
+	testChainID     int64  = 56
+	testEstimateGas uint64 = 200000
+	testPriorityFee int64  = 1000000000
+	testBackRunGas  uint64 = 600000
+)
+
+var testContractAddress = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// fakeNode ... JSON-RPC node answering the calls of the bundle submitter & the nonce manager
+type fakeNode struct {
+	mutex        sync.Mutex
+	blockNumber  uint64
+	baseFee      *big.Int
+	nonce        uint64 // mined transactions of the wallet
+	pendingNonce uint64 // mined & pending transactions of the wallet
+	receipts     map[common.Hash]*types.Receipt
+	sent         []*types.Transaction // eth_sendRawTransaction
+	rejectSend   bool                 // eth_sendRawTransaction fails
+	estimates    []map[string]any     // eth_estimateGas
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *ethclient.Client) {
+	t.Helper()
+
+	var node = &fakeNode{
+		blockNumber: 100,
+		baseFee:     big.NewInt(3000000000),
+		receipts:    make(map[common.Hash]*types.Receipt),
+	}
+	var server = httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	client, err := ethclient.Dial(server.URL)
+
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	return node, client
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mutex.Lock()
+	var result, rpcErr = n.call(request.Method, request.Params)
+	n.mutex.Unlock()
+
+	var response = map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result}
+
+	if rpcErr != "" {
+		response = map[string]any{"jsonrpc": "2.0", "id": request.ID,
+			"error": map[string]any{"code": -32000, "message": rpcErr}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// call ... answers a method, the mutex must be held
+func (n *fakeNode) call(method string, params []json.RawMessage) (any, string) {
+	switch method {
+	case "eth_blockNumber":
+		return hexutil.Uint64(n.blockNumber), ""
+	case "eth_getBlockByNumber":
+		return &types.Header{
+			Number:     new(big.Int).SetUint64(n.blockNumber),
+			BaseFee:    n.baseFee,
+			Difficulty: new(big.Int),
+		}, ""
+	case "eth_maxPriorityFeePerGas":
+		return (*hexutil.Big)(big.NewInt(testPriorityFee)), ""
+	case "eth_estimateGas":
+		var msg map[string]any
+		_ = json.Unmarshal(params[0], &msg)
+		n.estimates = append(n.estimates, msg)
+
+		return hexutil.Uint64(testEstimateGas), ""
+	case "eth_getTransactionCount":
+		var block string
+		_ = json.Unmarshal(params[1], &block)
+
+		if block == "pending" {
+			return hexutil.Uint64(n.pendingNonce), ""
+		}
+
+		return hexutil.Uint64(n.nonce), ""
+	case "eth_sendRawTransaction":
+		if n.rejectSend {
+			return nil, "replacement transaction underpriced"
+		}
+
+		var raw hexutil.Bytes
+		var tx = new(types.Transaction)
+
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err.Error()
+		}
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err.Error()
+		}
+		n.sent = append(n.sent, tx)
+
+		return tx.Hash(), ""
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		_ = json.Unmarshal(params[0], &hash)
+
+		if receipt, ok := n.receipts[hash]; ok {
+			return receipt, ""
+		}
+
+		return nil, ""
+	}
+
+	return nil, "method not found: " + method
+}
+
+// mine ... advances the chain, the transaction (if any) is mined with a receipt of the status
+func (n *fakeNode) mine(blocks uint64, txHash common.Hash, status uint64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.blockNumber += blocks
+
+	if txHash != (common.Hash{}) {
+		n.receipts[txHash] = &types.Receipt{
+			Type:              types.DynamicFeeTxType,
+			Status:            status,
+			Logs:              []*types.Log{},
+			TxHash:            txHash,
+			GasUsed:           150000,
+			EffectiveGasPrice: big.NewInt(4000000000),
+			BlockNumber:       new(big.Int).SetUint64(n.blockNumber),
+		}
+	}
+}
+
+// newTestNonceManager ... creates a nonce manager of a new wallet persisting its state in a temporary directory
+func newTestNonceManager(t *testing.T, client *ethclient.Client, settings config.NonceConfig) *NonceManager {
+	t.Helper()
+
+	var key, err = crypto.GenerateKey()
+
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	return &NonceManager{
+		client:   client,
+		key:      key,
+		from:     crypto.PubkeyToAddress(key.PublicKey),
+		chainID:  big.NewInt(testChainID),
+		settings: settings,
+		path:     filepath.Join(t.TempDir(), "nonces.json"),
+		inFlight: make(map[uint64]*InFlightTx),
+	}
+}
+
+// TestMain ... loads the configuration of the repository (read by the metrics of the stuck transactions), its paths
+// are relative to the source root
+func TestMain(m *testing.M) {
+	var dir, err = os.Getwd()
+
+	if err == nil {
+		err = os.Chdir("../..")
+	}
+	if err == nil {
+		err = config.Init()
+	}
+	if err != nil || os.Chdir(dir) != nil {
+		slog.Error("Error loading the configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+// testNonceSettings ... replaces a transaction pending for 5 blocks with fees bumped by 12.5%, cancels it after
+// 1 replacement
+var testNonceSettings = config.NonceConfig{StuckBlocks: 5, FeeBump: 0.125, MaxReplacements: 1}
+
+// sendTestTransaction ... signs a call of the test contract with the next nonce & sends it to the public mempool
+func sendTestTransaction(t *testing.T, node *fakeNode, nonces *NonceManager) *types.Transaction {
+	t.Helper()
+
+	tx, err := nonces.SignTransaction(testContractAddress, big.NewInt(7), []byte{0x01, 0x02}, 0)
+
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+	if err := nonces.Send(tx, node.blockNumber); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	return tx
+}
+
+// atLeastBumped ... reports whether a fee was raised by 10% at least (the replacement rule of the nodes)
+func atLeastBumped(fee *big.Int, previous *big.Int) bool {
+	var minimum = new(big.Int).Div(new(big.Int).Mul(previous, big.NewInt(110)), big.NewInt(100))
+
+	return fee.Cmp(minimum) >= 0
+}
+
+func TestAllocateRelease(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+	node.nonce, node.pendingNonce = 3, 3
+
+	for want := uint64(3); want < 6; want++ {
+		if nonce, err := nonces.Allocate(); err != nil || nonce != want {
+			t.Fatalf("Allocate = %d (%v), want %d", nonce, err, want)
+		}
+	}
+
+	// a tracked transaction isn't released with another hash
+	var tx = signedTransfer(t, 3)
+
+	if err := nonces.Track(tx, node.blockNumber, false); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	nonces.Release(3, common.Hash{})
+
+	if _, ok := nonces.Lookup(3); !ok {
+		t.Fatal("nonce 3 released with a mismatched hash")
+	}
+
+	// the gap of a released nonce is filled first
+	nonces.Release(4, common.Hash{})
+
+	if nonce, err := nonces.Allocate(); err != nil || nonce != 4 {
+		t.Fatalf("Allocate after Release = %d (%v), want 4", nonce, err)
+	}
+	if nonce, err := nonces.Allocate(); err != nil || nonce != 6 {
+		t.Fatalf("Allocate = %d (%v), want 6", nonce, err)
+	}
+}
+
+func TestResync(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+
+	for range 3 {
+		if _, err := nonces.Allocate(); err != nil {
+			t.Fatalf("Allocate: %v", err)
+		}
+	}
+
+	// nonces 0 & 1 were mined, another process sent the nonces 3 to 8 with the same wallet
+	node.nonce, node.pendingNonce = 2, 9
+
+	if err := nonces.Resync(); err != nil {
+		t.Fatalf("Resync: %v", err)
+	}
+
+	var inFlight = nonces.InFlight()
+
+	if len(inFlight) != 1 || inFlight[0].Nonce != 2 {
+		t.Fatalf("in flight after Resync = %+v, want nonce 2 only", inFlight)
+	}
+	if nonce, err := nonces.Allocate(); err != nil || nonce != 9 {
+		t.Fatalf("Allocate after Resync = %d (%v), want 9", nonce, err)
+	}
+}
+
+func TestBumpFee(t *testing.T) {
+	for _, test := range []struct {
+		fee  int64
+		want int64
+	}{
+		{fee: 1000000000, want: 1125000000},
+		{fee: 8, want: 9},
+		{fee: 1, want: 2},
+		{fee: 0, want: 1},
+	} {
+		var bumped = bumpFee(big.NewInt(test.fee), 0.125)
+
+		if bumped.Int64() != test.want {
+			t.Errorf("bumpFee(%d) = %s, want %d", test.fee, bumped, test.want)
+		}
+		if test.fee >= 100 && !atLeastBumped(bumped, big.NewInt(test.fee)) {
+			t.Errorf("bumpFee(%d) = %s, bumped by less than 10%%", test.fee, bumped)
+		}
+	}
+
+	if bumped := bumpFee(nil, 0.125); bumped.Int64() != 1 {
+		t.Errorf("bumpFee(nil) = %s, want 1", bumped)
+	}
+}
+
+func TestReplaceStuck(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+	var tx = sendTestTransaction(t, node, nonces)
+
+	// not stuck yet
+	node.mine(testNonceSettings.StuckBlocks-1, common.Hash{}, 0)
+
+	if err := nonces.ReplaceStuck(); err != nil || len(node.sent) != 1 {
+		t.Fatalf("ReplaceStuck = %v, %d transactions sent, want the original only", err, len(node.sent))
+	}
+
+	node.mine(1, common.Hash{}, 0)
+
+	if err := nonces.ReplaceStuck(); err != nil {
+		t.Fatalf("ReplaceStuck: %v", err)
+	}
+	if len(node.sent) != 2 {
+		t.Fatalf("%d transactions sent, want the replacement", len(node.sent))
+	}
+
+	var replacement = node.sent[1]
+
+	if replacement.Nonce() != tx.Nonce() || *replacement.To() != testContractAddress ||
+		replacement.Value().Cmp(tx.Value()) != 0 || string(replacement.Data()) != string(tx.Data()) {
+		t.Errorf("replacement = %+v, want the call of nonce %d", replacement, tx.Nonce())
+	}
+	if !atLeastBumped(replacement.GasTipCap(), tx.GasTipCap()) ||
+		!atLeastBumped(replacement.GasFeeCap(), tx.GasFeeCap()) {
+		t.Errorf("replacement fees %s/%s, want 10%% over %s/%s", replacement.GasTipCap(), replacement.GasFeeCap(),
+			tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if inFlight, _ := nonces.Lookup(tx.Nonce()); inFlight.TxHash != replacement.Hash() || inFlight.Replacements != 1 {
+		t.Errorf("in flight = %+v, want the replacement hash & 1 replacement", inFlight)
+	}
+
+	// replaced MaxReplacements times, it's cancelled
+	node.mine(testNonceSettings.StuckBlocks, common.Hash{}, 0)
+
+	if err := nonces.ReplaceStuck(); err != nil {
+		t.Fatalf("ReplaceStuck: %v", err)
+	}
+
+	var cancellation = node.sent[2]
+
+	if cancellation.Nonce() != tx.Nonce() || *cancellation.To() != nonces.Address() ||
+		cancellation.Value().Sign() != 0 || len(cancellation.Data()) != 0 || cancellation.Gas() != cancelGas {
+		t.Errorf("cancellation = %+v, want a 0 value transfer to the wallet", cancellation)
+	}
+	if !atLeastBumped(cancellation.GasTipCap(), replacement.GasTipCap()) {
+		t.Errorf("cancellation tip %s, want 10%% over %s", cancellation.GasTipCap(), replacement.GasTipCap())
+	}
+	if inFlight, _ := nonces.Lookup(tx.Nonce()); !inFlight.Cancelled || inFlight.TxHash != cancellation.Hash() {
+		t.Errorf("in flight = %+v, want the cancellation", inFlight)
+	}
+}
+
+func TestReplaceStuckPrivate(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+
+	nonce, err := nonces.Allocate()
+
+	if err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+	if err := nonces.Track(signedTransfer(t, nonce), node.blockNumber, true); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	node.mine(testNonceSettings.StuckBlocks, common.Hash{}, 0)
+
+	if err := nonces.ReplaceStuck(); err != nil {
+		t.Fatalf("ReplaceStuck: %v", err)
+	}
+	if len(node.sent) != 0 || len(nonces.InFlight()) != 0 {
+		t.Errorf("%d transactions sent, in flight %+v, want the nonce released", len(node.sent), nonces.InFlight())
+	}
+}
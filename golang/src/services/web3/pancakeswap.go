@@ -1,187 +1,304 @@
 package web3
 
 import (
-	"arbitrage-bot/helpers"
+	"arbitrage-bot/config"
 	ethersHelper "arbitrage-bot/helpers/ethers"
 	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/rpcpool"
 	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
+	"context"
 	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"log/slog"
 	"math/big"
-	"os"
 	"sync"
+	"time"
 )
 
 type PancakeswapWeb3Service struct {
+	network         *config.NetworkProfile
 	client          *ethclient.Client
+	multicall       *MulticallClient
+	factoryAddress  common.Address
+	routerAddress   common.Address
+	factoryABI      abi.ABI
+	routerABI       abi.ABI
+	poolABI         abi.ABI
+	erc20ABI        abi.ABI
 	factoryContract *bind.BoundContract
 	routerContract  *bind.BoundContract
 }
 
 // NewPancakeswapWeb3Service ... creates a new PancakeswapWeb3Service
-func NewPancakeswapWeb3Service() *PancakeswapWeb3Service {
-	var networkRpcUrl = os.Getenv("NETWORK_RPC_URL")
-	var networkName = os.Getenv("NETWORK_NAME")
-	var pancakeswapAddresses = ethersHelper.GetPancakeSwapAddresses(networkName)
-	var factoryAddress = common.HexToAddress(pancakeswapAddresses["factory"])
-	var routerAddress = common.HexToAddress(pancakeswapAddresses["router"])
-	factoryABI, err := jsonHelper.ReadJSONABIFile("data/web3/pancakeswapFactoryV2ABI.json")
-	helpers.Panic(err)
-	routerABI, err := jsonHelper.ReadJSONABIFile("data/web3/pancakeswapRouterABI.json")
-	helpers.Panic(err)
-	client, err := ethclient.Dial(networkRpcUrl)
-	helpers.Panic(err)
+func NewPancakeswapWeb3Service() (*PancakeswapWeb3Service, error) {
+	var network = config.Get().ActiveNetwork()
+	var factoryAddress = common.HexToAddress(network.Contracts.PancakeswapFactory)
+	var routerAddress = common.HexToAddress(network.Contracts.PancakeswapRouter)
+	factoryABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapFactory)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the factory abi: %w", err)
+	}
+	routerABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapRouter)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the router abi: %w", err)
+	}
+	poolABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.PancakeswapPool)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the pool abi: %w", err)
+	}
+	erc20ABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.ERC20)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the erc20 abi: %w", err)
+	}
+	var client = rpcpool.Get().Client()
+	multicall, err := NewMulticallClient(client, sp.PancakeswapProviderName)
+
+	if err != nil {
+		return nil, err
+	}
 
 	var factoryContract = bind.NewBoundContract(factoryAddress, factoryABI, client, client, client)
 	var routerContract = bind.NewBoundContract(routerAddress, routerABI, client, client, client)
 
 	return &PancakeswapWeb3Service{
+		network:         network,
 		client:          client,
+		multicall:       multicall,
+		factoryAddress:  factoryAddress,
+		routerAddress:   routerAddress,
+		factoryABI:      factoryABI,
+		routerABI:       routerABI,
+		poolABI:         poolABI,
+		erc20ABI:        erc20ABI,
 		factoryContract: factoryContract,
 		routerContract:  routerContract,
-	}
+	}, nil
 }
 
-// GetPrice ... gets price
+// GetPrice ... returns the amount of the output token received for amountIn of the input token
 func (u *PancakeswapWeb3Service) GetPrice(
 	symbol sp.Symbol,
-	amountIn float64,
+	amountIn units.Amount,
 	tradeDirection string,
-	verbose bool,
-) float64 {
+) (units.Amount, error) {
 	var tradePath = ethersHelper.GetTradePaths([]sp.Symbol{symbol}, []string{tradeDirection})[0]
-	var amountInParsed = ethersHelper.EtherToWei(amountIn, tradePath.BaseAssetDecimals)
 	var result []interface{}
 	var path = []common.Address{tradePath.BaseAssetAddress, tradePath.QuoteAssetAddress}
-	var err = u.routerContract.Call(&bind.CallOpts{}, &result, "getAmountsOut", amountInParsed, path)
+	var start = time.Now()
+	var err = u.routerContract.Call(&bind.CallOpts{}, &result, "getAmountsOut", amountIn.Wei(), path)
+	metrics.ObserveRPCLatency(sp.PancakeswapProviderName, "getAmountsOut", start)
 
 	if err != nil {
-		helpers.VerboseLog(verbose, fmt.Sprintf("Error getting price for %s: %v", symbol.Symbol, err))
-		return 0
+		return units.Zero(tradePath.QuoteAssetDecimals), fmt.Errorf(
+			"%s: %w", symbol.Symbol, wrapCallError(err, "getAmountsOut"),
+		)
 	}
 
 	var priceList = result[0].([]*big.Int)
-	return ethersHelper.WeiToEther(priceList[len(priceList)-1], tradePath.QuoteAssetDecimals)
+	return tradePath.AmountOut(priceList[len(priceList)-1]), nil
 }
 
+// GetPriceMultiplePaths ... returns the amount of the last output token received for amountIn of the first input
+// token swapped through the paths
 func (u *PancakeswapWeb3Service) GetPriceMultiplePaths(
 	tradePaths []sp.TradePath,
-	amountIn float64,
-	verbose bool,
-) float64 {
+	amountIn units.Amount,
+) (units.Amount, error) {
+	var lastPath = tradePaths[len(tradePaths)-1]
 	var path = []common.Address{tradePaths[0].BaseAssetAddress}
 	for _, tradePath := range tradePaths {
 		path = append(path, tradePath.QuoteAssetAddress)
 	}
-	var amountInParsed = ethersHelper.EtherToWei(amountIn, tradePaths[0].BaseAssetDecimals)
 	var result []interface{}
-	var err = u.routerContract.Call(&bind.CallOpts{}, &result, "getAmountsOut", amountInParsed, path)
+	var start = time.Now()
+	var err = u.routerContract.Call(&bind.CallOpts{}, &result, "getAmountsOut", amountIn.Wei(), path)
+	metrics.ObserveRPCLatency(sp.PancakeswapProviderName, "getAmountsOut", start)
 
 	if err != nil {
-		helpers.VerboseLog(verbose, fmt.Sprintf("Error getting price: %v", err))
-		return 0
+		return units.Zero(lastPath.QuoteAssetDecimals), wrapCallError(err, "getAmountsOut")
 	}
 
 	var priceList = result[0].([]*big.Int)
-	return ethersHelper.WeiToEther(priceList[len(priceList)-1], tradePaths[len(tradePaths)-1].QuoteAssetDecimals)
+	return lastPath.AmountOut(priceList[len(priceList)-1]), nil
 }
 
-// AggregatePrices ... aggregates prices
-func (u *PancakeswapWeb3Service) AggregatePrices(symbols []*sp.Symbol, verbose bool) *sync.Map {
-	var channel = make(chan *sp.Symbol)
-	var concurrency = 8
+// AggregatePrices ... quotes 1 base asset of every symbol with getAmountsOut, batched with Multicall, the symbols
+// failing their quote (or batch) are left out
+func (u *PancakeswapWeb3Service) AggregatePrices(symbols []*sp.Symbol) *sync.Map {
 	var result sync.Map
-	var wg sync.WaitGroup
-	wg.Add(concurrency)
-
-	for range concurrency {
-		go func() {
-			defer wg.Done()
-			for symbol := range channel {
-				var price = u.GetPrice(*symbol, 1, "baseToQuote", verbose)
-				if price != 0 {
-					result.Store(symbol.Symbol, price)
-				}
-			}
-		}()
-	}
+	var calls []*Call
+	var callSymbols []*sp.Symbol
+
 	for _, symbol := range symbols {
-		channel <- symbol
+		var path = []common.Address{
+			common.HexToAddress(symbol.BaseAssetAddress), common.HexToAddress(symbol.QuoteAssetAddress),
+		}
+		call, err := NewCall(
+			u.routerAddress, u.routerABI, "getAmountsOut", units.FromFloat(1, symbol.BaseAssetDecimals).Wei(), path,
+		)
+
+		if err != nil {
+			logger.WithProvider(sp.PancakeswapProviderName).Warn(
+				"Error packing price call", slog.String("symbol", symbol.Symbol), slog.Any("error", err),
+			)
+			continue
+		}
+		calls = append(calls, call)
+		callSymbols = append(callSymbols, symbol)
+	}
+
+	if err := u.multicall.Aggregate(calls); err != nil {
+		logger.WithProvider(sp.PancakeswapProviderName).Warn("Error aggregating prices", slog.Any("error", err))
+		return &result
+	}
+
+	for i, symbol := range callSymbols {
+		output, err := calls[i].Unpack(u.routerABI, "getAmountsOut")
+
+		if err != nil {
+			logger.WithProvider(sp.PancakeswapProviderName).Debug(
+				"Error getting price", slog.String("symbol", symbol.Symbol), slog.Any("error", err),
+			)
+			continue
+		}
+
+		var amounts = output[0].([]*big.Int)
+		if price := units.NewAmount(amounts[len(amounts)-1], symbol.QuoteAssetDecimals).Float64(); price != 0 {
+			result.Store(symbol.Symbol, price)
+		}
 	}
-	close(channel)
-	wg.Wait()
 
 	return &result
 }
 
-// GetPoolDataByIndex ... get pool address by index (in factory) then get pool data
-func (u *PancakeswapWeb3Service) GetPoolDataByIndex(index int) (sp.Symbol, error) {
+// GetBlockNumber ... returns the latest block number
+func (u *PancakeswapWeb3Service) GetBlockNumber() (uint64, error) {
+	return u.client.BlockNumber(context.Background())
+}
+
+// GetGasPrice ... returns the suggested gas price (in wei)
+func (u *PancakeswapWeb3Service) GetGasPrice() (*big.Int, error) {
+	return u.client.SuggestGasPrice(context.Background())
+}
+
+// GetPoolsReserves ... returns the reserves of the pools (pool address -> reserves) batched with Multicall, the pools
+// failing the call are left out
+func (u *PancakeswapWeb3Service) GetPoolsReserves(symbols []*sp.Symbol) (map[string]PoolReserves, error) {
+	var calls = make([]*Call, len(symbols))
+
+	for i, symbol := range symbols {
+		call, err := NewCall(common.HexToAddress(symbol.Address), u.poolABI, "getReserves")
+
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = call
+	}
+	if err := u.multicall.Aggregate(calls); err != nil {
+		return nil, err
+	}
+
+	var poolsReserves = make(map[string]PoolReserves)
+
+	for i, symbol := range symbols {
+		result, err := calls[i].Unpack(u.poolABI, "getReserves")
+
+		if err != nil {
+			logger.WithProvider(sp.PancakeswapProviderName).Debug(
+				"Error getting reserves", slog.String("symbol", symbol.Symbol), slog.Any("error", err),
+			)
+			continue
+		}
+
+		var reserves = PoolReserves{
+			Reserve0:    units.NewAmount(result[0].(*big.Int), symbol.BaseAssetDecimals).Float64(),
+			Reserve1:    units.NewAmount(result[1].(*big.Int), symbol.QuoteAssetDecimals).Float64(),
+			Reserve0Wei: result[0].(*big.Int),
+			Reserve1Wei: result[1].(*big.Int),
+		}
+
+		if reserves.Reserve0 > 0 {
+			reserves.Price = reserves.Reserve1 / reserves.Reserve0
+		}
+		poolsReserves[symbol.Address] = reserves
+	}
+
+	return poolsReserves, nil
+}
+
+// GetPairsLength ... returns the number of pairs created by the factory
+func (u *PancakeswapWeb3Service) GetPairsLength() (uint64, error) {
 	var result []interface{}
-	var err = u.factoryContract.Call(&bind.CallOpts{}, &result, "allPairs", big.NewInt(int64(index)))
-	helpers.Panic(err)
-	var poolAddress = result[0].(common.Address)
 
-	return u.GetPoolData(poolAddress)
+	if err := u.factoryContract.Call(&bind.CallOpts{}, &result, "allPairsLength"); err != nil {
+		return 0, err
+	}
+
+	return result[0].(*big.Int).Uint64(), nil
 }
 
-func (u *PancakeswapWeb3Service) GetPoolData(address common.Address) (sp.Symbol, error) {
-	poolABI, err := jsonHelper.ReadJSONABIFile("data/web3/pancakeswapPoolABI.json")
-	helpers.Panic(err)
-	erc20ABI, err := jsonHelper.ReadJSONABIFile("data/web3/erc20.json")
-	helpers.Panic(err)
-
-	var poolContract = bind.NewBoundContract(address, poolABI, u.client, u.client, u.client)
-	var wg = sync.WaitGroup{}
-	wg.Add(2)
-	var symbol sp.Symbol
-	var resultToken0, resultToken1 []interface{}
-	var errToken0, errToken1 error
-	go ethersHelper.CallContractMethod(
-		&wg, poolContract, "token0", []interface{}{}, &resultToken0, &errToken0,
-	)
-	go ethersHelper.CallContractMethod(
-		&wg, poolContract, "token1", []interface{}{}, &resultToken1, &errToken1,
-	)
-	//go ethers.CallContractMethod(&wg, poolContract, "fee", []interface{}{}, &resultFee, &errFee)
-	wg.Wait()
-
-	if errToken0 != nil || errToken1 != nil {
-		return symbol, fmt.Errorf("error getting token addresses: %v, %v", errToken0, errToken1)
-	}
-
-	for _, result := range [][]interface{}{resultToken0, resultToken1} {
-		wg.Add(2)
-		var tokenAddress = result[0].(common.Address)
-		var tokenContract = bind.NewBoundContract(tokenAddress, erc20ABI, u.client, u.client, u.client)
-		var resultSymbol, resultDecimals []interface{}
-		var errSymbol, errDecimals error
-		go ethersHelper.CallContractMethod(
-			&wg, tokenContract, "symbol", []interface{}{}, &resultSymbol, &errSymbol,
-		)
-		go ethersHelper.CallContractMethod(
-			&wg, tokenContract, "decimals", []interface{}{}, &resultDecimals, &errDecimals,
-		)
-		wg.Wait()
+// GetPairAddresses ... returns the addresses of the pairs of the factory indices [from, to), batched with Multicall
+func (u *PancakeswapWeb3Service) GetPairAddresses(from uint64, to uint64) ([]common.Address, error) {
+	var calls []*Call
 
-		if errSymbol != nil || errDecimals != nil {
-			return symbol, fmt.Errorf("error getting token addresses: %v, %v", errSymbol, errDecimals)
+	for index := from; index < to; index++ {
+		call, err := NewCall(u.factoryAddress, u.factoryABI, "allPairs", new(big.Int).SetUint64(index))
+
+		if err != nil {
+			return nil, err
 		}
+		calls = append(calls, call)
+	}
+	if err := u.multicall.Aggregate(calls); err != nil {
+		return nil, err
+	}
+
+	var addresses []common.Address
+
+	for i, call := range calls {
+		result, err := call.Unpack(u.factoryABI, "allPairs")
 
-		if tokenAddress == resultToken0[0] {
-			symbol.BaseAsset = resultSymbol[0].(string)
-			symbol.BaseAssetAddress = tokenAddress.String()
-			symbol.BaseAssetDecimals = int(resultDecimals[0].(uint8))
-		} else {
-			symbol.QuoteAsset = resultSymbol[0].(string)
-			symbol.QuoteAssetAddress = tokenAddress.String()
-			symbol.QuoteAssetDecimals = int(resultDecimals[0].(uint8))
+		if err != nil {
+			return nil, fmt.Errorf("error getting pair %d: %w", from+uint64(i), err)
 		}
+		addresses = append(addresses, result[0].(common.Address))
+	}
+
+	return addresses, nil
+}
+
+// GetPoolsData ... returns the pool data of the addresses batched with Multicall, the pools which couldn't be read are
+// returned with their error
+func (u *PancakeswapWeb3Service) GetPoolsData(addresses []common.Address) ([]*sp.Symbol, map[common.Address]error, error) {
+	symbols, failed, err := u.multicall.getPoolsData(addresses, u.poolABI, u.erc20ABI, false)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, symbol := range symbols {
+		symbol.Symbol = symbol.BaseAsset + symbol.QuoteAsset
+		tokenregistry.Get().AnnotateDEXSymbol(u.network.ChainID, symbol)
+	}
+
+	return symbols, failed, nil
+}
+
+// GetPoolData ... returns the pool data of an address
+func (u *PancakeswapWeb3Service) GetPoolData(address common.Address) (sp.Symbol, error) {
+	symbols, failed, err := u.GetPoolsData([]common.Address{address})
+
+	if err != nil {
+		return sp.Symbol{}, err
+	} else if err = failed[address]; err != nil {
+		return sp.Symbol{}, err
 	}
-	symbol.Address = address.String()
-	symbol.Symbol = symbol.BaseAsset + symbol.QuoteAsset
-	//symbol.FeeTier = int(resultFee[0].(*big.Int).Int64())
 
-	return symbol, nil
+	return *symbols[0], nil
 }
@@ -0,0 +1,134 @@
+package web3
+
+import (
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/rpcpool"
+	sp "arbitrage-bot/services/sourceprovider"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"math/big"
+	"time"
+)
+
+// States of a public transaction
+const (
+	TxPending   string = "pending"   // not mined yet, a stuck transaction is replaced with bumped fees
+	TxMined     string = "mined"     // mined & succeeded
+	TxReverted  string = "reverted"  // mined & reverted
+	TxCancelled string = "cancelled" // replaced by a 0 value transfer after too many replacements
+	TxDropped   string = "dropped"   // the nonce was mined by a replacement which wasn't seen
+)
+
+// PublicTx ... Represents a swapIn transaction sent to the public mempool, the hash follows its replacements
+type PublicTx struct {
+	TxHash    common.Hash `json:"txHash"`
+	Nonce     uint64      `json:"nonce"`
+	Cancelled bool        `json:"cancelled"`
+	SentAt    time.Time   `json:"sentAt"`
+	GasCost   *big.Int    `json:"gasCost"` // wei paid for the gas once mined, nil before
+}
+
+// PublicSubmitter ... Signs the swapIn transactions & sends them to the public mempool (visible to front-runners), the
+// nonce manager replaces the stuck ones
+type PublicSubmitter struct {
+	client   *ethclient.Client
+	executor *ArbitrageExecutorWeb3Service
+	nonces   *NonceManager // signs with the executor wallet, shared by the services using it
+}
+
+// NewPublicSubmitter ... creates a new public submitter for the executor wallet of the selected network
+func NewPublicSubmitter() (*PublicSubmitter, error) {
+	nonces, err := getWalletNonceManager()
+
+	if err != nil {
+		return nil, err
+	}
+	executor, err := NewArbitrageExecutorWeb3Service()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicSubmitter{client: rpcpool.Get().Client(), executor: executor, nonces: nonces}, nil
+}
+
+// SubmitArbitrage ... signs the swapIn transaction of the trade paths & sends it to the mempool of the node
+func (p *PublicSubmitter) SubmitArbitrage(
+	tradePaths []sp.TradePath,
+	amountIn units.Amount,
+	loanAddress common.Address,
+) (*PublicTx, error) {
+	data, err := p.executor.packSwapIn(tradePaths, amountIn, loanAddress)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := p.client.BlockNumber(context.Background())
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting block number: %w", err)
+	}
+
+	tx, err := p.nonces.SignTransaction(p.executor.contractAddress, nil, data, 0)
+
+	if err != nil {
+		return nil, err
+	}
+	if err := p.nonces.Send(tx, blockNumber); err != nil {
+		return nil, err
+	}
+
+	return &PublicTx{TxHash: tx.Hash(), Nonce: tx.Nonce(), SentAt: time.Now()}, nil
+}
+
+// TransactionStatus ... returns the state of a public transaction & the block mining it, the hash of the last
+// replacement is followed & the gas cost of a mined transaction is set
+func (p *PublicSubmitter) TransactionStatus(tx *PublicTx) (string, uint64, error) {
+	var ctx = context.Background()
+
+	if inFlight, ok := p.nonces.Lookup(tx.Nonce); ok && inFlight.TxHash != (common.Hash{}) {
+		tx.TxHash, tx.Cancelled = inFlight.TxHash, inFlight.Cancelled
+	}
+
+	receipt, err := p.client.TransactionReceipt(ctx, tx.TxHash)
+
+	if err == nil {
+		if receipt.EffectiveGasPrice != nil {
+			tx.GasCost = new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		}
+
+		switch {
+		case tx.Cancelled:
+			return TxCancelled, receipt.BlockNumber.Uint64(), nil
+		case receipt.Status == types.ReceiptStatusSuccessful:
+			return TxMined, receipt.BlockNumber.Uint64(), nil
+		default:
+			return TxReverted, receipt.BlockNumber.Uint64(), nil
+		}
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return TxPending, 0, fmt.Errorf("error getting receipt: %w", err)
+	}
+
+	confirmed, err := p.client.NonceAt(ctx, p.nonces.Address(), nil)
+
+	if err != nil {
+		return TxPending, 0, fmt.Errorf("error getting nonce: %w", err)
+	}
+	if confirmed > tx.Nonce {
+		return TxDropped, 0, nil
+	}
+
+	return TxPending, 0, nil
+}
+
+// Close ... saves the nonces of the wallet & stops their watch
+func (p *PublicSubmitter) Close() error {
+	return p.nonces.Close()
+}
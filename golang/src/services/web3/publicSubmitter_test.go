@@ -0,0 +1,112 @@
+package web3
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"testing"
+)
+
+func TestPublicSubmitArbitrage(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+	var submitter = &PublicSubmitter{client: client, executor: newTestExecutor(t, client), nonces: nonces}
+	var tradePaths, amountIn = testRoute()
+
+	tx, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"))
+
+	if err != nil {
+		t.Fatalf("SubmitArbitrage: %v", err)
+	}
+	if len(node.sent) != 1 || node.sent[0].Hash() != tx.TxHash || *node.sent[0].To() != testContractAddress {
+		t.Fatalf("sent %d transactions, want the swapIn %s", len(node.sent), tx.TxHash)
+	}
+
+	var data, _ = submitter.executor.packSwapIn(tradePaths, amountIn, common.HexToAddress("0x04"))
+
+	if string(node.sent[0].Data()) != string(data) {
+		t.Errorf("data = %x, want the swapIn call %x", node.sent[0].Data(), data)
+	}
+	if inFlight, ok := nonces.Lookup(tx.Nonce); !ok || inFlight.Private || inFlight.TxHash != tx.TxHash {
+		t.Errorf("in flight = %+v (%v), want the public swapIn", inFlight, ok)
+	}
+	if status, _, err := submitter.TransactionStatus(tx); err != nil || status != TxPending {
+		t.Errorf("TransactionStatus = %s (%v), want %s", status, err, TxPending)
+	}
+}
+
+func TestPublicSubmitArbitrageRejected(t *testing.T) {
+	var node, client = newFakeNode(t)
+	var nonces = newTestNonceManager(t, client, testNonceSettings)
+	var submitter = &PublicSubmitter{client: client, executor: newTestExecutor(t, client), nonces: nonces}
+	var tradePaths, amountIn = testRoute()
+
+	// the node rejects the transaction, its nonce is allocated again
+	node.rejectSend = true
+
+	if _, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04")); err == nil {
+		t.Fatal("SubmitArbitrage succeeded, want the error of the node")
+	}
+	if inFlight := nonces.InFlight(); len(inFlight) != 0 {
+		t.Errorf("in flight = %+v, want the nonce released", inFlight)
+	}
+}
+
+func TestPublicTransactionStatus(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		replacements int  // stuck checks before the transaction is mined
+		mined        bool // the last transaction of the nonce is mined
+		status       uint64
+		want         string
+	}{
+		{name: "mined", mined: true, status: types.ReceiptStatusSuccessful, want: TxMined},
+		{name: "reverted", mined: true, status: types.ReceiptStatusFailed, want: TxReverted},
+		{name: "replaced", replacements: 1, mined: true, status: types.ReceiptStatusSuccessful, want: TxMined},
+		{name: "cancelled", replacements: 2, mined: true, status: types.ReceiptStatusSuccessful, want: TxCancelled},
+		{name: "dropped", want: TxDropped},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var node, client = newFakeNode(t)
+			var nonces = newTestNonceManager(t, client, testNonceSettings)
+			var submitter = &PublicSubmitter{client: client, executor: newTestExecutor(t, client), nonces: nonces}
+			var tradePaths, amountIn = testRoute()
+
+			tx, err := submitter.SubmitArbitrage(tradePaths, amountIn, common.HexToAddress("0x04"))
+
+			if err != nil {
+				t.Fatalf("SubmitArbitrage: %v", err)
+			}
+
+			for range test.replacements {
+				node.mine(testNonceSettings.StuckBlocks, common.Hash{}, 0)
+
+				if err := nonces.ReplaceStuck(); err != nil {
+					t.Fatalf("ReplaceStuck: %v", err)
+				}
+			}
+
+			var txHash common.Hash
+
+			if test.mined {
+				txHash = node.sent[len(node.sent)-1].Hash()
+			}
+			node.mine(1, txHash, test.status)
+			node.nonce = tx.Nonce + 1
+
+			status, blockNumber, err := submitter.TransactionStatus(tx)
+
+			if err != nil || status != test.want {
+				t.Fatalf("TransactionStatus = %s (%v), want %s", status, err, test.want)
+			}
+			if !test.mined {
+				return
+			}
+			if tx.TxHash != txHash || blockNumber != node.blockNumber {
+				t.Errorf("hash %s at block %d, want %s at block %d", tx.TxHash, blockNumber, txHash, node.blockNumber)
+			}
+			if tx.GasCost == nil || tx.GasCost.Int64() != 150000*4000000000 {
+				t.Errorf("GasCost = %v, want %d", tx.GasCost, 150000*4000000000)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package web3
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReceivedBundle ... Represents a bundle accepted by the relay stub
+type ReceivedBundle struct {
+	BundleHash  common.Hash          `json:"bundleHash"`
+	BlockNumber uint64               `json:"blockNumber"`
+	Signer      common.Address       `json:"signer"`
+	Txs         []*types.Transaction `json:"txs"`
+	ReceivedAt  time.Time            `json:"receivedAt"`
+}
+
+// RelayStub ... Local relay accepting the eth_sendBundle requests of the bundle submitter, for trying the submission
+// without a real relay (the bundles are recorded, never forwarded to a builder)
+type RelayStub struct {
+	mu       sync.Mutex
+	bundles  []*ReceivedBundle
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewRelayStub ... creates a new relay stub
+func NewRelayStub() *RelayStub {
+	return &RelayStub{}
+}
+
+// Start ... listens on address (f.e. 127.0.0.1:0 for a random port) & serves in the background
+func (r *RelayStub) Start(address string) error {
+	var listener, err = net.Listen("tcp", address)
+
+	if err != nil {
+		return err
+	}
+
+	r.listener = listener
+	r.server = &http.Server{Handler: http.HandlerFunc(r.handleRPC)}
+
+	go func() {
+		if err := r.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Relay stub stopped", slog.Any("error", err))
+		}
+	}()
+	slog.Info("Relay stub listening", slog.String("url", r.URL()))
+
+	return nil
+}
+
+// URL ... returns the URL to configure as relay.url
+func (r *RelayStub) URL() string {
+	return "http://" + r.listener.Addr().String()
+}
+
+// Bundles ... returns the bundles received so far
+func (r *RelayStub) Bundles() []*ReceivedBundle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*ReceivedBundle(nil), r.bundles...)
+}
+
+// Close ... stops the server
+func (r *RelayStub) Close() error {
+	if r.server == nil {
+		return nil
+	}
+
+	return r.server.Close()
+}
+
+// handleRPC ... serves eth_sendBundle, the request must carry a valid X-Flashbots-Signature
+func (r *RelayStub) handleRPC(w http.ResponseWriter, req *http.Request) {
+	var body, err = io.ReadAll(req.Body)
+
+	if err != nil {
+		writeRelayError(w, nil, -32700, err.Error())
+		return
+	}
+
+	var request struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params []struct {
+			Txs         []string `json:"txs"`
+			BlockNumber string   `json:"blockNumber"`
+		} `json:"params"`
+	}
+
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeRelayError(w, nil, -32700, "parse error")
+		return
+	}
+	if request.Method != "eth_sendBundle" {
+		writeRelayError(w, request.ID, -32601, "method not found")
+		return
+	}
+
+	signer, err := RecoverFlashbotsSigner(body, req.Header.Get(FlashbotsSignatureHeader))
+
+	if err != nil {
+		writeRelayError(w, request.ID, -32600, err.Error())
+		return
+	}
+	if len(request.Params) != 1 || len(request.Params[0].Txs) == 0 {
+		writeRelayError(w, request.ID, -32602, "expected one bundle with at least one transaction")
+		return
+	}
+
+	blockNumber, err := hexutil.DecodeUint64(request.Params[0].BlockNumber)
+
+	if err != nil {
+		writeRelayError(w, request.ID, -32602, "invalid blockNumber")
+		return
+	}
+
+	var bundle = &ReceivedBundle{BlockNumber: blockNumber, Signer: signer, ReceivedAt: time.Now()}
+	var txHashes []byte
+
+	for _, rawTx := range request.Params[0].Txs {
+		var tx = new(types.Transaction)
+		data, err := hexutil.Decode(rawTx)
+
+		if err == nil {
+			err = tx.UnmarshalBinary(data)
+		}
+		if err != nil {
+			writeRelayError(w, request.ID, -32602, "invalid transaction: "+err.Error())
+			return
+		}
+		bundle.Txs = append(bundle.Txs, tx)
+		txHashes = append(txHashes, tx.Hash().Bytes()...)
+	}
+	bundle.BundleHash = crypto.Keccak256Hash(txHashes)
+
+	r.mu.Lock()
+	r.bundles = append(r.bundles, bundle)
+	r.mu.Unlock()
+	slog.Info("Relay stub received bundle", slog.String("bundleHash", bundle.BundleHash.Hex()),
+		slog.Uint64("blockNumber", blockNumber), slog.String("signer", signer.Hex()), slog.Int("txs", len(bundle.Txs)))
+
+	writeRelayJSON(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      request.ID,
+		"result":  map[string]string{"bundleHash": bundle.BundleHash.Hex()},
+	})
+}
+
+// writeRelayError ... writes a JSON-RPC error
+func writeRelayError(w http.ResponseWriter, id json.RawMessage, code int, message string) {
+	writeRelayJSON(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+// writeRelayJSON ... writes a JSON-RPC response
+func writeRelayJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("Error writing relay stub response", slog.Any("error", err))
+	}
+}
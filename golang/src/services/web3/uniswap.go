@@ -1,255 +1,324 @@
 package web3
 
 import (
-	"arbitrage-bot/helpers"
+	"arbitrage-bot/config"
 	ethersHelper "arbitrage-bot/helpers/ethers"
 	jsonHelper "arbitrage-bot/helpers/json"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/metrics"
+	"arbitrage-bot/services/rpcpool"
 	sp "arbitrage-bot/services/sourceprovider"
+	"arbitrage-bot/services/tokenregistry"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"log/slog"
 	"math"
 	"math/big"
-	"os"
 	"sync"
+	"time"
 )
 
 type UniswapWeb3Service struct {
+	network        *config.NetworkProfile
 	client         *ethclient.Client
+	multicall      *MulticallClient
 	quoterAddress  common.Address
 	quoterABI      abi.ABI
 	quoterVersion  string
 	quoterContract *bind.BoundContract
+	poolABI        abi.ABI
+	erc20ABI       abi.ABI
 }
 
-func NewUniswapWeb3Service() *UniswapWeb3Service {
-	var networkRpcUrl = os.Getenv("NETWORK_RPC_URL")
-	var networkName = os.Getenv("NETWORK_NAME")
-	var quoterAddress = common.HexToAddress(os.Getenv("UNISWAP_QUOTER_ADDRESS"))
+// quoteExactInputSingleParams ... params of quoteExactInputSingle of the V2 quoter
+type quoteExactInputSingleParams struct {
+	TokenIn           common.Address `json:"tokenIn"`
+	TokenOut          common.Address `json:"tokenOut"`
+	AmountIn          *big.Int       `json:"amountIn"`
+	Fee               *big.Int       `json:"fee"`
+	SqrtPriceLimitX96 *big.Int       `json:"sqrtPriceLimitX96"`
+}
+
+// NewUniswapWeb3Service ... creates a new UniswapWeb3Service
+func NewUniswapWeb3Service() (*UniswapWeb3Service, error) {
+	var network = config.Get().ActiveNetwork()
+	var quoterAddress = common.HexToAddress(network.Contracts.UniswapQuoter)
 	var quoterABI abi.ABI
 	var quoterVersion string
 	var err error
 
-	if networkName == "blast" || networkName == "celo" {
-		// use quoterV2
-		quoterABI, err = jsonHelper.ReadJSONABIFile("data/web3/uniswapQuoterV2ABI.json")
+	if network.Contracts.UniswapQuoterVersion == "v2" {
+		quoterABI, err = jsonHelper.ReadJSONABIFile(network.ABIs.UniswapQuoterV2)
 		quoterVersion = "v2"
 	} else {
-		quoterABI, err = jsonHelper.ReadJSONABIFile("data/web3/uniswapQuoterABI.json")
+		quoterABI, err = jsonHelper.ReadJSONABIFile(network.ABIs.UniswapQuoter)
 		quoterVersion = "v1"
 	}
-	helpers.Panic(err)
-	client, err := ethclient.Dial(networkRpcUrl)
-	helpers.Panic(err)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the quoter abi: %w", err)
+	}
+	poolABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.UniswapPool)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the pool abi: %w", err)
+	}
+	erc20ABI, err := jsonHelper.ReadJSONABIFile(network.ABIs.ERC20)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the erc20 abi: %w", err)
+	}
+	var client = rpcpool.Get().Client()
+	multicall, err := NewMulticallClient(client, sp.UniswapProviderName)
+
+	if err != nil {
+		return nil, err
+	}
 
 	return &UniswapWeb3Service{
+		network:       network,
 		client:        client,
+		multicall:     multicall,
 		quoterAddress: quoterAddress,
 		quoterABI:     quoterABI,
 		quoterVersion: quoterVersion,
+		poolABI:       poolABI,
+		erc20ABI:      erc20ABI,
 		// not used yet
 		//quoterContract: bind.NewBoundContract(quoterAddress, quoterABI, client, client, client),
+	}, nil
+}
+
+// GetPoolsData ... returns the pool data of the addresses batched with Multicall, the fee tier is part of the symbol
+// name as a pair can have a pool per fee tier, the pools which couldn't be read are returned with their error
+func (u *UniswapWeb3Service) GetPoolsData(addresses []common.Address) ([]*sp.Symbol, map[common.Address]error, error) {
+	symbols, failed, err := u.multicall.getPoolsData(addresses, u.poolABI, u.erc20ABI, true)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, symbol := range symbols {
+		symbol.Symbol = sp.UniswapSymbolName(symbol.BaseAsset, symbol.QuoteAsset, symbol.FeeTier)
+		tokenregistry.Get().AnnotateDEXSymbol(u.network.ChainID, symbol)
 	}
+
+	return symbols, failed, nil
 }
 
 // GetPoolData ... returns the pool data for a given pool address
-func (u *UniswapWeb3Service) GetPoolData(address common.Address) sp.Symbol {
-	poolABI, err := jsonHelper.ReadJSONABIFile("data/web3/uniswapPoolABI.json")
-	helpers.Panic(err)
-	erc20ABI, err := jsonHelper.ReadJSONABIFile("data/web3/erc20.json")
-	helpers.Panic(err)
-
-	var poolContract = bind.NewBoundContract(address, poolABI, u.client, u.client, u.client)
-	var wg = sync.WaitGroup{}
-	wg.Add(3)
-	var symbol sp.Symbol
-	var resultToken0, resultToken1, resultFee []interface{}
-	var errToken0, errToken1, errFee error
-	go ethersHelper.CallContractMethod(&wg, poolContract, "token0", []interface{}{}, &resultToken0, &errToken0)
-	go ethersHelper.CallContractMethod(&wg, poolContract, "token1", []interface{}{}, &resultToken1, &errToken1)
-	go ethersHelper.CallContractMethod(&wg, poolContract, "fee", []interface{}{}, &resultFee, &errFee)
-	wg.Wait()
-	helpers.PanicBatch(errToken0, errToken1, errFee)
-
-	for _, result := range [][]interface{}{resultToken0, resultToken1} {
-		wg.Add(2)
-		var tokenAddress = result[0].(common.Address)
-		var tokenContract = bind.NewBoundContract(tokenAddress, erc20ABI, u.client, u.client, u.client)
-		var resultSymbol, resultDecimals []interface{}
-		var errSymbol, errDecimals error
-		go ethersHelper.CallContractMethod(&wg, tokenContract, "symbol", []interface{}{}, &resultSymbol, &errSymbol)
-		go ethersHelper.CallContractMethod(&wg, tokenContract, "decimals", []interface{}{}, &resultDecimals, &errDecimals)
-		wg.Wait()
-		helpers.PanicBatch(errSymbol, errDecimals)
-
-		if tokenAddress == resultToken0[0] {
-			symbol.BaseAsset = resultSymbol[0].(string)
-			symbol.BaseAssetAddress = tokenAddress.String()
-			symbol.BaseAssetDecimals = int(resultDecimals[0].(uint8))
-		} else {
-			symbol.QuoteAsset = resultSymbol[0].(string)
-			symbol.QuoteAssetAddress = tokenAddress.String()
-			symbol.QuoteAssetDecimals = int(resultDecimals[0].(uint8))
-		}
+func (u *UniswapWeb3Service) GetPoolData(address common.Address) (sp.Symbol, error) {
+	symbols, failed, err := u.GetPoolsData([]common.Address{address})
+
+	if err != nil {
+		return sp.Symbol{}, err
+	} else if err = failed[address]; err != nil {
+		return sp.Symbol{}, err
 	}
-	symbol.Address = address.String()
-	symbol.Symbol = symbol.BaseAsset + symbol.QuoteAsset
-	symbol.FeeTier = int(resultFee[0].(*big.Int).Int64())
 
-	return symbol
+	return *symbols[0], nil
 }
 
 // GetPrice ... returns the price for a given symbol
-func (u *UniswapWeb3Service) GetPrice(symbol sp.Symbol, amountIn float64, tradeDirection string, verbose bool) float64 {
+func (u *UniswapWeb3Service) GetPrice(
+	symbol sp.Symbol,
+	amountIn units.Amount,
+	tradeDirection string,
+) (units.Amount, error) {
 	var tradePath = ethersHelper.GetTradePaths([]sp.Symbol{symbol}, []string{tradeDirection})[0]
+	var zero = units.Zero(tradePath.QuoteAssetDecimals)
+	data, err := u.packQuote(symbol, amountIn, tradePath)
 
-	if u.quoterVersion == "v2" {
-		return u.quoteExactInputSingleV2(
-			symbol,
-			amountIn,
-			tradePath.BaseAssetAddress,
-			tradePath.QuoteAssetAddress,
-			tradePath.BaseAssetDecimals,
-			tradePath.QuoteAssetDecimals,
-			verbose,
-		)
-	} else {
-		return u.quoteExactInputSingleV1(
-			symbol,
-			amountIn,
-			tradePath.BaseAssetAddress,
-			tradePath.QuoteAssetAddress,
-			tradePath.BaseAssetDecimals,
-			tradePath.QuoteAssetDecimals,
-			verbose,
-		)
+	if err != nil {
+		return zero, fmt.Errorf("%w: %s: %w", ErrQuoteFailed, symbol.Symbol, err)
+	}
+
+	var message = ethereum.CallMsg{To: &u.quoterAddress, Data: data}
+	var start = time.Now()
+	result, err := u.client.CallContract(context.Background(), message, nil)
+	metrics.ObserveRPCLatency(sp.UniswapProviderName, "quoteExactInputSingle", start)
+
+	if err != nil {
+		return zero, fmt.Errorf("%s: %w", symbol.Symbol, wrapCallError(err, "quoteExactInputSingle"))
 	}
+	amountOut, err := u.unpackQuote(result)
+
+	if err != nil {
+		return zero, fmt.Errorf("%w: %s: %w", ErrQuoteFailed, symbol.Symbol, err)
+	}
+
+	return tradePath.AmountOut(amountOut), nil
 }
 
+// GetPriceMultiplePaths ... isn't supported by the quoter V1/V2 single pool calls yet
 func (u *UniswapWeb3Service) GetPriceMultiplePaths(
 	tradePaths []sp.TradePath,
-	amountIn float64,
-	verbose bool,
-) float64 {
-	return 0
+	amountIn units.Amount,
+) (units.Amount, error) {
+	return units.Zero(tradePaths[len(tradePaths)-1].QuoteAssetDecimals), fmt.Errorf(
+		"%w: multiple paths aren't supported by the uniswap quoter", ErrQuoteFailed,
+	)
 }
 
-func (u *UniswapWeb3Service) quoteExactInputSingleV1(
-	symbol sp.Symbol,
-	amountIn float64,
-	inputTokenA common.Address,
-	inputTokenB common.Address,
-	inputDecimalsA int,
-	inputDecimalsB int,
-	verbose bool,
-) float64 {
-	var amountInParsed = big.NewInt(int64(amountIn * math.Pow(10, float64(inputDecimalsA))))
-	data, err := u.quoterABI.Pack(
+// packQuote ... packs the quoteExactInputSingle call of the quoter version for a trade path
+func (u *UniswapWeb3Service) packQuote(symbol sp.Symbol, amountIn units.Amount, tradePath sp.TradePath) ([]byte, error) {
+	var amountInParsed = amountIn.Wei()
+
+	if u.quoterVersion == "v2" {
+		return u.quoterABI.Pack(
+			"quoteExactInputSingle",
+			quoteExactInputSingleParams{
+				tradePath.BaseAssetAddress,
+				tradePath.QuoteAssetAddress,
+				amountInParsed,
+				big.NewInt(int64(symbol.FeeTier)),
+				big.NewInt(0),
+			},
+		)
+	}
+
+	return u.quoterABI.Pack(
 		"quoteExactInputSingle",
-		inputTokenA,
-		inputTokenB,
+		tradePath.BaseAssetAddress,
+		tradePath.QuoteAssetAddress,
 		big.NewInt(int64(symbol.FeeTier)),
 		amountInParsed,
 		big.NewInt(0),
 	)
-	helpers.Panic(err)
+}
 
-	var message = ethereum.CallMsg{To: &u.quoterAddress, Data: data}
-	result, err := u.client.CallContract(context.Background(), message, nil)
+// unpackQuote ... returns the amount out of a quoteExactInputSingle result (the V2 quoter also returns the price
+// after the swap, the ticks crossed & a gas estimate)
+func (u *UniswapWeb3Service) unpackQuote(result []byte) (*big.Int, error) {
+	output, err := u.quoterABI.Unpack("quoteExactInputSingle", result)
 
 	if err != nil {
-		if verbose {
-			fmt.Println("Quoter error:", symbol.Symbol, err)
-		}
-		return 0
+		return nil, err
 	}
-	var quotedAmountOut = new(big.Int)
-	quotedAmountOut.SetBytes(result)
-	return ethersHelper.WeiToEther(quotedAmountOut, inputDecimalsB)
+
+	return output[0].(*big.Int), nil
 }
 
-func (u *UniswapWeb3Service) quoteExactInputSingleV2(
-	symbol sp.Symbol,
-	amountIn float64,
-	inputTokenA common.Address,
-	inputTokenB common.Address,
-	inputDecimalsA int,
-	inputDecimalsB int,
-	verbose bool,
-) float64 {
-	type QuoteExactInputSingleParams struct {
-		TokenIn           common.Address `json:"tokenIn"`
-		TokenOut          common.Address `json:"tokenOut"`
-		AmountIn          *big.Int       `json:"amountIn"`
-		Fee               *big.Int       `json:"fee"`
-		SqrtPriceLimitX96 *big.Int       `json:"sqrtPriceLimitX96"`
-	}
-
-	var amountInParsed = big.NewInt(int64(amountIn * math.Pow(10, float64(inputDecimalsA))))
-	data, err := u.quoterABI.Pack(
-		"quoteExactInputSingle",
-		QuoteExactInputSingleParams{
-			inputTokenA,
-			inputTokenB,
-			amountInParsed,
-			big.NewInt(int64(symbol.FeeTier)),
-			big.NewInt(0),
-		},
-	)
-	helpers.Panic(err)
+// GetBlockNumber ... returns the latest block number
+func (u *UniswapWeb3Service) GetBlockNumber() (uint64, error) {
+	return u.client.BlockNumber(context.Background())
+}
 
-	var message = ethereum.CallMsg{To: &u.quoterAddress, Data: data}
-	result, err := u.client.CallContract(context.Background(), message, nil)
+// GetGasPrice ... returns the suggested gas price (in wei)
+func (u *UniswapWeb3Service) GetGasPrice() (*big.Int, error) {
+	return u.client.SuggestGasPrice(context.Background())
+}
 
-	if err != nil {
-		if verbose {
-			fmt.Println("Quoter error:", symbol.Symbol, err)
+// GetPoolsReserves ... returns the token balances of the pools (V3 TVL) & their spot price from slot0 (pool address
+// -> reserves) batched with Multicall, the pools failing a call are left out
+func (u *UniswapWeb3Service) GetPoolsReserves(symbols []*sp.Symbol) (map[string]PoolReserves, error) {
+	var calls []*Call
+
+	for _, symbol := range symbols {
+		var poolAddress = common.HexToAddress(symbol.Address)
+		callSlot0, err := NewCall(poolAddress, u.poolABI, "slot0")
+
+		if err != nil {
+			return nil, err
 		}
-		return 0
-	}
-
-	// Unpack the result
-	var amountOut *big.Int
-	var sqrtPriceX96After *big.Int
-	var initializedTicksCrossed uint32
-	var gasEstimate *big.Int
-	err = u.quoterABI.UnpackIntoInterface(&[]interface{}{
-		&amountOut,
-		&sqrtPriceX96After,
-		&initializedTicksCrossed,
-		&gasEstimate,
-	}, "quoteExactInputSingle", result)
-
-	return ethersHelper.WeiToEther(amountOut, inputDecimalsB)
+		callBalance0, err := NewCall(common.HexToAddress(symbol.BaseAssetAddress), u.erc20ABI, "balanceOf", poolAddress)
+
+		if err != nil {
+			return nil, err
+		}
+		callBalance1, err := NewCall(common.HexToAddress(symbol.QuoteAssetAddress), u.erc20ABI, "balanceOf", poolAddress)
+
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, callSlot0, callBalance0, callBalance1)
+	}
+	if err := u.multicall.Aggregate(calls); err != nil {
+		return nil, err
+	}
+
+	var poolsReserves = make(map[string]PoolReserves)
+
+	for i, symbol := range symbols {
+		resultSlot0, errSlot0 := calls[3*i].Unpack(u.poolABI, "slot0")
+		resultBalance0, errBalance0 := calls[3*i+1].Unpack(u.erc20ABI, "balanceOf")
+		resultBalance1, errBalance1 := calls[3*i+2].Unpack(u.erc20ABI, "balanceOf")
+
+		if errSlot0 != nil || errBalance0 != nil || errBalance1 != nil {
+			logger.WithProvider(sp.UniswapProviderName).Debug(
+				"Error getting reserves",
+				slog.String("symbol", symbol.Symbol),
+				slog.Any("error", errors.Join(errSlot0, errBalance0, errBalance1)),
+			)
+			continue
+		}
+
+		// price = (sqrtPriceX96 / 2^96)^2, adjusted by the token decimals
+		var sqrtPrice, _ = new(big.Float).Quo(
+			new(big.Float).SetInt(resultSlot0[0].(*big.Int)),
+			new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 96)),
+		).Float64()
+
+		poolsReserves[symbol.Address] = PoolReserves{
+			Reserve0:    units.NewAmount(resultBalance0[0].(*big.Int), symbol.BaseAssetDecimals).Float64(),
+			Reserve1:    units.NewAmount(resultBalance1[0].(*big.Int), symbol.QuoteAssetDecimals).Float64(),
+			Price:       sqrtPrice * sqrtPrice * math.Pow10(symbol.BaseAssetDecimals-symbol.QuoteAssetDecimals),
+			Reserve0Wei: resultBalance0[0].(*big.Int),
+			Reserve1Wei: resultBalance1[0].(*big.Int),
+		}
+	}
+
+	return poolsReserves, nil
 }
 
-func (u *UniswapWeb3Service) AggregatePrices(symbols []*sp.Symbol, verbose bool) *sync.Map {
-	var channel = make(chan *sp.Symbol)
-	var concurrency = 8
+// AggregatePrices ... quotes 1 base asset of every symbol with the quoter, batched with Multicall, the symbols failing
+// their quote (or batch) are left out
+func (u *UniswapWeb3Service) AggregatePrices(symbols []*sp.Symbol) *sync.Map {
 	var result sync.Map
-	var wg sync.WaitGroup
-	wg.Add(concurrency)
-
-	for range concurrency {
-		go func() {
-			defer wg.Done()
-			for symbol := range channel {
-				var price = u.GetPrice(*symbol, 1, "baseToQuote", verbose)
-				if price != 0 {
-					result.Store(symbol.Symbol, price)
-				}
-			}
-		}()
-	}
+	var calls []*Call
+	var callSymbols []*sp.Symbol
+
 	for _, symbol := range symbols {
-		channel <- symbol
+		var tradePath = ethersHelper.GetTradePaths([]sp.Symbol{*symbol}, []string{"baseToQuote"})[0]
+		data, err := u.packQuote(*symbol, tradePath.AmountIn(1), tradePath)
+
+		if err != nil {
+			logger.WithProvider(sp.UniswapProviderName).Warn(
+				"Error packing quote", slog.String("symbol", symbol.Symbol), slog.Any("error", err),
+			)
+			continue
+		}
+		calls = append(calls, &Call{Target: u.quoterAddress, CallData: data})
+		callSymbols = append(callSymbols, symbol)
+	}
+
+	if err := u.multicall.Aggregate(calls); err != nil {
+		logger.WithProvider(sp.UniswapProviderName).Warn("Error aggregating prices", slog.Any("error", err))
+		return &result
+	}
+
+	for i, symbol := range callSymbols {
+		if !calls[i].Success {
+			logger.WithProvider(sp.UniswapProviderName).Debug(
+				"Quoter error", slog.String("symbol", symbol.Symbol), slog.Any("error", calls[i].Err),
+			)
+			continue
+		}
+		amountOut, err := u.unpackQuote(calls[i].ReturnData)
+
+		if err != nil {
+			logger.WithProvider(sp.UniswapProviderName).Debug(
+				"Quoter error", slog.String("symbol", symbol.Symbol), slog.Any("error", err),
+			)
+			continue
+		}
+		if price := units.NewAmount(amountOut, symbol.QuoteAssetDecimals).Float64(); price != 0 {
+			result.Store(symbol.Symbol, price)
+		}
 	}
-	close(channel)
-	wg.Wait()
 
 	return &result
 }
@@ -1,14 +1,36 @@
 package main
 
 import (
+	"arbitrage-bot/config"
+	"arbitrage-bot/helpers"
+	"arbitrage-bot/helpers/logger"
+	"arbitrage-bot/helpers/units"
+	"arbitrage-bot/services/rpcpool"
 	"arbitrage-bot/services/sourceprovider"
 	"arbitrage-bot/services/sourceprovider/dex"
-	"fmt"
+	"arbitrage-bot/services/tokenregistry"
 	_ "github.com/joho/godotenv/autoload"
+	"log/slog"
+	"os"
 )
 
 func main() {
-	var _sourceProvider = dex.NewPancakeswapSourceProvider()
+	if err := config.Init(); err != nil {
+		slog.Error("Error loading the configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	logger.Setup(config.Get().Log)
+
+	if err := tokenregistry.Init(); err != nil {
+		slog.Error("Error loading the token registry", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if err := rpcpool.Init(); err != nil {
+		slog.Error("Error creating the rpc pool", slog.Any("error", err))
+		os.Exit(1)
+	}
+	_sourceProvider, err := dex.NewPancakeswapSourceProvider()
+	helpers.Panic(err)
 	var symbol = sourceprovider.Symbol{
 		Symbol:             "BUSDWBNB",
 		BaseAsset:          "BUSD",
@@ -18,6 +40,8 @@ func main() {
 		QuoteAssetAddress:  "0xbb4cdb9cbd36b01bd1cbaebf2de08d9173bc095c",
 		QuoteAssetDecimals: 18,
 	}
-	var result = _sourceProvider.Web3Service().GetPrice(symbol, 1, "baseToQuote", true)
-	fmt.Println(result)
+	var amountIn = units.FromFloat(1, symbol.BaseAssetDecimals)
+	result, err := _sourceProvider.Web3Service().GetPrice(symbol, amountIn, "baseToQuote")
+	helpers.Panic(err)
+	slog.Info("Fetched price", slog.String("symbol", symbol.Symbol), slog.String("price", result.String()))
 }
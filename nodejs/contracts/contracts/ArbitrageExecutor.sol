@@ -52,8 +52,9 @@ contract ArbitrageExecutor is Ownable {
         UNISWAP_V2_ROUTER = _uniswapV2Router;
     }
 
-    function swapIn(SwapParams[] calldata paramsArray, uint256 amountIn, address flashloanToken1) public {
+    function swapIn(SwapParams[] calldata paramsArray, uint256 amountIn, address flashloanToken1) public payable {
         // swapIn with Flashloan, remember to set allowance for the tokens
+        // the value sent is the tip of the block builder, only paid if the arbitrage doesn't revert
         require(paramsArray.length > 0, "Empty params array");
         SwapParams calldata swapParams = paramsArray[0];
         address factoryAddress = swapParams.protocol == 0 ? PANCAKE_FACTORY : UNISWAP_V2_FACTORY;
@@ -72,6 +73,9 @@ contract ArbitrageExecutor is Ownable {
                 abi.encode(paramsArray, amountIn)
             );
         }
+        if (msg.value > 0) {
+            block.coinbase.transfer(msg.value);
+        }
     }
 
     function pancakeCall(address _sender, uint256 _amount0, uint256 _amount1, bytes calldata _data) external {